@@ -11,6 +11,18 @@ type Reader interface {
 	Browse(cxt context.Context) chan *assets.Group
 }
 
+// Watcher is implemented by the readers that keep sending new assets
+// until the context is cancelled
+type Watcher interface {
+	IsWatching() bool
+}
+
+// UploadHandler is implemented by the readers that act on the source files
+// once the server has confirmed the asset
+type UploadHandler interface {
+	HandleUploaded(ctx context.Context, a *assets.Asset, dryRun bool)
+}
+
 type AssetWriter interface {
 	WriteAsset(context.Context, *assets.Asset) error
 	// WriteGroup(ctx context.Context, group *assets.Group) error
@@ -0,0 +1,253 @@
+package folder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+// AfterUpload tells what to do with the local files once the server has confirmed the asset.
+type AfterUpload struct {
+	Mode AfterUploadMode
+	Dir  string // destination folder of the move mode
+}
+
+type AfterUploadMode string
+
+const (
+	AfterUploadKeep   AfterUploadMode = "keep"
+	AfterUploadDelete AfterUploadMode = "delete"
+	AfterUploadMove   AfterUploadMode = "move"
+)
+
+func (au AfterUpload) String() string {
+	switch au.Mode {
+	case AfterUploadMove:
+		return string(AfterUploadMove) + ":" + au.Dir
+	case "":
+		return string(AfterUploadKeep)
+	}
+	return string(au.Mode)
+}
+
+func (au *AfterUpload) Set(v string) error {
+	v = strings.TrimSpace(v)
+	mode, dir, _ := strings.Cut(v, ":")
+	switch AfterUploadMode(strings.ToLower(mode)) {
+	case AfterUploadKeep:
+		*au = AfterUpload{Mode: AfterUploadKeep}
+	case AfterUploadDelete:
+		*au = AfterUpload{Mode: AfterUploadDelete}
+	case AfterUploadMove:
+		if dir == "" {
+			return errors.New("the move mode needs a destination folder: move:<dir>")
+		}
+		*au = AfterUpload{Mode: AfterUploadMove, Dir: dir}
+	default:
+		return fmt.Errorf("invalid value %q, expected keep, delete or move:<dir>", v)
+	}
+	return nil
+}
+
+func (au AfterUpload) Type() string {
+	return "afterUpload"
+}
+
+// checkAfterUpload verifies that the files can be deleted or moved
+func (ifc *ImportFolderCmd) checkAfterUpload() error {
+	if ifc.AfterUpload.Mode == AfterUploadKeep || ifc.AfterUpload.Mode == "" {
+		return nil
+	}
+	var roots []string
+	for _, fsys := range ifc.fsyss {
+		root, err := fshelper.OSPath(fsys, ".")
+		if err != nil {
+			return fmt.Errorf("--after-upload=%s needs folders, not archives", ifc.AfterUpload)
+		}
+		roots = append(roots, root)
+	}
+	if ifc.AfterUpload.Mode != AfterUploadMove {
+		return nil
+	}
+	dest, err := filepath.Abs(ifc.AfterUpload.Dir)
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+		// the moved files would be found again
+		if rel, err := filepath.Rel(root, dest); err == nil && !strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("the destination folder %q can't be inside the source folder %q", ifc.AfterUpload.Dir, root)
+		}
+	}
+	return nil
+}
+
+// HandleUploaded deletes or moves the files of the asset and its sidecars once the server has confirmed it.
+// In dry-run mode, the actions are only reported.
+func (ifc *ImportFolderCmd) HandleUploaded(ctx context.Context, a *assets.Asset, dryRun bool) {
+	if ifc.AfterUpload.Mode == AfterUploadKeep || ifc.AfterUpload.Mode == "" {
+		return
+	}
+	ifc.confirmedLock.Lock()
+	defer ifc.confirmedLock.Unlock()
+	if ifc.confirmed == nil {
+		ifc.confirmed = map[string]bool{}
+	}
+	ifc.confirmed[a.File.FullName()] = true
+
+	ifc.afterUploadFile(ctx, a.File, dryRun)
+	for _, f := range sideCarsOf(a) {
+		// a photo.xmp shared by the RAW and the JPEG is handled with the last of them
+		if !ifc.sidecarReleased(f) {
+			continue
+		}
+		if _, err := f.Stat(); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		ifc.afterUploadFile(ctx, f, dryRun)
+	}
+}
+
+// sidecarReleased tells if all the media files using the sidecar are confirmed by the server.
+// A photo.jpg.xmp belongs to one file, a photo.xmp is shared by the files named photo.*,
+// the ones discarded or not yet uploaded keep it in place.
+func (ifc *ImportFolderCmd) sidecarReleased(sc fshelper.FSAndName) bool {
+	stem := strings.TrimSuffix(sc.Name(), path.Ext(sc.Name()))
+	if filetypes.DefaultSupportedMedia.IsMedia(path.Ext(stem)) {
+		return true
+	}
+	dir := path.Dir(sc.Name())
+	entries, err := fs.ReadDir(sc.FS(), dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		ext := path.Ext(name)
+		if e.IsDir() || strings.TrimSuffix(name, ext) != stem || !filetypes.DefaultSupportedMedia.IsMedia(ext) {
+			continue
+		}
+		if !ifc.confirmed[fshelper.FSName(sc.FS(), name).FullName()] {
+			return false
+		}
+	}
+	return true
+}
+
+// sideCarsOf returns the sidecar files linked to the asset by the folder adapter:
+// the immich-go JSON file, and the XMP files named photo.jpg.xmp and photo.xmp.
+func sideCarsOf(a *assets.Asset) []fshelper.FSAndName {
+	var files []fshelper.FSAndName
+	known := map[string]bool{}
+	add := func(f fshelper.FSAndName) {
+		if f.Name() != "" && !known[f.Name()] {
+			known[f.Name()] = true
+			files = append(files, f)
+		}
+	}
+	if a.FromApplication != nil {
+		add(a.FromApplication.File)
+	}
+	if a.FromSideCar != nil {
+		add(a.FromSideCar.File)
+	}
+	names, err := sideCarNames(a.File.FS(), a.File.Name(), ".xmp")
+	if err == nil {
+		for _, name := range names {
+			add(fshelper.FSName(a.File.FS(), name))
+		}
+	}
+	return files
+}
+
+func (ifc *ImportFolderCmd) afterUploadFile(ctx context.Context, f fshelper.FSAndName, dryRun bool) {
+	src, err := fshelper.OSPath(f.FS(), f.Name())
+	if err != nil {
+		ifc.processor.RecordNonAsset(ctx, f, 0, fileevent.ErrorFileAccess, "error", err.Error())
+		return
+	}
+
+	switch ifc.AfterUpload.Mode {
+	case AfterUploadDelete:
+		if !dryRun {
+			err = os.Remove(src)
+		}
+		if err != nil {
+			ifc.processor.RecordNonAsset(ctx, f, 0, fileevent.ErrorFileAccess, "error", err.Error())
+			return
+		}
+		ifc.processor.RecordNonAsset(ctx, f, 0, fileevent.ProcessedLocalDeleted, "dry-run", dryRun)
+	case AfterUploadMove:
+		dst := filepath.Join(ifc.AfterUpload.Dir, filepath.FromSlash(f.Name()))
+		if !dryRun {
+			err = moveFile(src, dst)
+		}
+		if err != nil {
+			ifc.processor.RecordNonAsset(ctx, f, 0, fileevent.ErrorFileAccess, "error", err.Error())
+			return
+		}
+		ifc.processor.RecordNonAsset(ctx, f, 0, fileevent.ProcessedLocalMoved, "destination", dst, "dry-run", dryRun)
+	}
+}
+
+// moveFile moves the file src to dst, creating the folders when needed.
+// An existing file isn't overwritten.
+func moveFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("can't move %q: %q already exists", src, dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	// rename fails across devices: copy the file, then remove the source
+	if err := copyFile(src, dst); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	info, err := s.Stat()
+	if err != nil {
+		return err
+	}
+	d, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(d, s); err != nil {
+		d.Close()
+		return err
+	}
+	if err = d.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,119 @@
+package folder
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/assettracker"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/fileprocessor"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+func TestAfterUploadSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  AfterUpload
+		err   bool
+	}{
+		{value: "keep", want: AfterUpload{Mode: AfterUploadKeep}},
+		{value: "DELETE", want: AfterUpload{Mode: AfterUploadDelete}},
+		{value: "move:/data/done", want: AfterUpload{Mode: AfterUploadMove, Dir: "/data/done"}},
+		{value: "move:C:\\done", want: AfterUpload{Mode: AfterUploadMove, Dir: "C:\\done"}},
+		{value: "move", err: true},
+		{value: "trash", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var au AfterUpload
+			err := au.Set(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("Set(%q) error = %v", tt.value, err)
+			}
+			if err == nil && au != tt.want {
+				t.Errorf("Set(%q) = %+v, want %+v", tt.value, au, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleUploaded(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	for _, name := range []string{"photo.jpg", "photo.jpg.xmp", "photo.xmp", "photo.jpg.json"} {
+		if err := os.MkdirAll(filepath.Join(src, "2024"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, "2024", name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := fshelper.NewFSWithName(src)
+	a := &assets.Asset{
+		File:            fshelper.FSName(fsys, "2024/photo.jpg"),
+		FromSideCar:     &assets.Metadata{File: fshelper.FSName(fsys, "2024/photo.jpg.xmp")},
+		FromApplication: &assets.Metadata{File: fshelper.FSName(fsys, "2024/photo.jpg.json")},
+	}
+
+	recorder := fileevent.NewRecorder(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ifc := &ImportFolderCmd{
+		AfterUpload: AfterUpload{Mode: AfterUploadMove, Dir: dst},
+		processor:   fileprocessor.New(assettracker.New(), recorder),
+		fsyss:       []fs.FS{fsys},
+	}
+	if err := ifc.checkAfterUpload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// dry run: nothing moves
+	ifc.HandleUploaded(context.Background(), a, true)
+	if _, err := os.Stat(filepath.Join(src, "2024", "photo.jpg")); err != nil {
+		t.Errorf("the file should not be moved in dry-run mode: %v", err)
+	}
+
+	ifc.HandleUploaded(context.Background(), a, false)
+	for _, name := range []string{"photo.jpg", "photo.jpg.xmp", "photo.xmp", "photo.jpg.json"} {
+		if _, err := os.Stat(filepath.Join(dst, "2024", name)); err != nil {
+			t.Errorf("%s should be moved: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(src, "2024", name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed from the source", name)
+		}
+	}
+	if c := recorder.GetCounts()[fileevent.ProcessedLocalMoved]; c != 8 {
+		t.Errorf("expected 8 move events, got %d", c)
+	}
+
+	// the sidecars already moved with the other file of a pair are ignored
+	ifc.HandleUploaded(context.Background(), &assets.Asset{File: fshelper.FSName(fsys, "2024/photo.dng")}, false)
+	if c := recorder.GetCounts()[fileevent.ErrorFileAccess]; c != 1 {
+		t.Errorf("expected only the error of the missing photo.dng, got %d errors", c)
+	}
+
+	// a photo.xmp shared by the RAW and the JPEG stays until both are confirmed
+	for _, name := range []string{"pair.cr2", "pair.jpg", "pair.xmp"} {
+		if err := os.WriteFile(filepath.Join(src, "2024", name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ifc.HandleUploaded(context.Background(), &assets.Asset{File: fshelper.FSName(fsys, "2024/pair.jpg")}, false)
+	if _, err := os.Stat(filepath.Join(src, "2024", "pair.xmp")); err != nil {
+		t.Errorf("pair.xmp is still used by pair.cr2: %v", err)
+	}
+	ifc.HandleUploaded(context.Background(), &assets.Asset{File: fshelper.FSName(fsys, "2024/pair.cr2")}, false)
+	if _, err := os.Stat(filepath.Join(dst, "2024", "pair.xmp")); err != nil {
+		t.Errorf("pair.xmp should be moved with the last file of the pair: %v", err)
+	}
+
+	// the destination can't be inside the source
+	ifc.AfterUpload.Dir = filepath.Join(src, "done")
+	if err := ifc.checkAfterUpload(); err == nil {
+		t.Error("a destination inside the source folder should be refused")
+	}
+}
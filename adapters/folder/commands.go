@@ -36,6 +36,9 @@ type ImportFolderCmd struct {
 	PicasaAlbum            bool
 	ICloudTakeout          bool
 	ICloudMemoriesAsAlbums bool
+	Watch                  bool          // keep watching the folders for new files
+	WatchDelay             time.Duration // quiet time before processing the new files of a folder
+	AfterUpload            AfterUpload   // what to do with the local files once uploaded
 	shared.StackOptions
 
 	// Internal fields
@@ -52,6 +55,9 @@ type ImportFolderCmd struct {
 	picasaAlbums            *gen.SyncMap[string, PicasaAlbum] // ap[string]PicasaAlbum
 	icloudMetas             *gen.SyncMap[string, iCloudMeta]
 	icloudMetaPass          bool
+	watcher                 *folderWatcher // set in watch mode
+	confirmedLock           sync.Mutex
+	confirmed               map[string]bool // files confirmed by the server, they release their shared sidecars
 }
 
 func (ifc *ImportFolderCmd) RegisterFlags(flags *pflag.FlagSet, cmd *cobra.Command) {
@@ -96,6 +102,12 @@ func NewFromFolderCommand(ctx context.Context, parent *cobra.Command, app *app.A
 	flags := cmd.Flags()
 	o := ImportFolderCmd{}
 	o.RegisterFlags(flags, cmd)
+	if parent != nil && parent.Name() == "upload" {
+		flags.BoolVar(&o.Watch, "watch", false, "After the initial scan, keep watching the folders and upload the new files as they appear, until interrupted")
+		flags.DurationVar(&o.WatchDelay, "watch-delay", 10*time.Second, "Wait for a folder to be quiet for this delay before uploading its new files")
+		o.AfterUpload = AfterUpload{Mode: AfterUploadKeep}
+		flags.Var(&o.AfterUpload, "after-upload", "What to do with the local files and their sidecars once the server has the asset: keep, delete or move:<dir>")
+	}
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		return o.run(cmd, args, app, runner)
 	}
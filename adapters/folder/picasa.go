@@ -66,3 +66,65 @@ func parsePicasaIni(r io.Reader) (PicasaAlbum, error) {
 
 	return Album, nil
 }
+
+// ReadPicasaFaces reads the names of the faces of a .picasa.ini file, by file name.
+// A file section lists its faces as "faces=rect64(…),contactID;…", the [Contacts2] section names the contacts as "contactID=Name;…".
+func ReadPicasaFaces(fsys fs.FS, filename string) (map[string][]string, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	faces, err := parsePicasaFaces(file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing picasa ini file: %w", err)
+	}
+	return faces, nil
+}
+
+func parsePicasaFaces(r io.Reader) (map[string][]string, error) {
+	scanner := bufio.NewScanner(r)
+	var currentSection string
+	contacts := map[string]string{}
+	faces := map[string][]string{} // contact IDs by file
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentSection = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case currentSection == "Contacts2":
+			name, _, _ := strings.Cut(value, ";")
+			contacts[key] = name
+		case currentSection != "Picasa" && key == "faces":
+			for _, face := range strings.Split(value, ";") {
+				if _, id, ok := strings.Cut(face, ","); ok && id != "ffffffffffffffff" {
+					faces[currentSection] = append(faces[currentSection], id)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	names := map[string][]string{}
+	for file, ids := range faces {
+		for _, id := range ids {
+			if name := contacts[id]; name != "" {
+				names[file] = append(names[file], name)
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,30 @@
+package folder
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePicasaFaces(t *testing.T) {
+	ini := `[Picasa]
+name=Holidays
+[IMG_0001.JPG]
+faces=rect64(3f845bcb59418507),8e62398ebda8c1a5;rect64(9eb15e89b6b584c1),ffffffffffffffff;rect64(1e3a0b6b59418507),d04ca592f8868c2
+[IMG_0002.JPG]
+faces=rect64(3f845bcb59418507),unknown
+[Contacts2]
+8e62398ebda8c1a5=Alice Smith;;
+d04ca592f8868c2=Bob;;
+`
+	faces, err := parsePicasaFaces(strings.NewReader(ini))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := faces["IMG_0001.JPG"]; !slices.Equal(got, []string{"Alice Smith", "Bob"}) {
+		t.Errorf("faces of IMG_0001.JPG = %v", got)
+	}
+	if _, ok := faces["IMG_0002.JPG"]; ok {
+		t.Errorf("the unknown contact is named: %v", faces)
+	}
+}
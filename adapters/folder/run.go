@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/simulot/immich-go/adapters"
+	"github.com/simulot/immich-go/adapters/shared"
 	"github.com/simulot/immich-go/app"
 	"github.com/simulot/immich-go/internal/assets"
 	"github.com/simulot/immich-go/internal/exif"
@@ -59,6 +60,27 @@ func (ifc *ImportFolderCmd) run(cmd *cobra.Command, args []string, app *app.Appl
 		}
 	}()
 
+	if ifc.Watch {
+		// the watch mode needs live file systems to see the new files
+		roots, err := newWatchRoots(args)
+		if err != nil {
+			return err
+		}
+		_ = fshelper.CloseFSs(ifc.fsyss)
+		ifc.fsyss = ifc.fsyss[:0]
+		for _, r := range roots {
+			ifc.fsyss = append(ifc.fsyss, r.fsys)
+		}
+		ifc.watcher, err = newFolderWatcher(ifc, roots, ifc.WatchDelay)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := ifc.checkAfterUpload(); err != nil {
+		return err
+	}
+
 	// Start the workers
 	ifc.pool = worker.NewPool(ifc.app.ConcurrentTask)
 
@@ -67,7 +89,8 @@ func (ifc *ImportFolderCmd) run(cmd *cobra.Command, args []string, app *app.Appl
 
 	ifc.requiresDateInformation = ifc.InclusionFlags.DateRange.IsSet() ||
 		ifc.TakeDateFromFilename || ifc.ManageBurst != filters.BurstNothing ||
-		ifc.ManageHEICJPG != filters.HeicJpgNothing || ifc.ManageRawJPG != filters.RawJPGNothing
+		ifc.ManageHEICJPG != filters.HeicJpgNothing || ifc.ManageRawJPG != filters.RawJPGNothing ||
+		len(app.ClockRules) > 0 || ifc.InclusionFlags.Where.IsSet()
 
 	if ifc.PicasaAlbum {
 		ifc.picasaAlbums = gen.NewSyncMap[string, PicasaAlbum]() // make(map[string]PicasaAlbum)
@@ -78,18 +101,21 @@ func (ifc *ImportFolderCmd) run(cmd *cobra.Command, args []string, app *app.Appl
 	}
 
 	if ifc.infoCollector == nil {
-		ifc.infoCollector = filenames.NewInfoCollector(ifc.tz, ifc.supportedMedia)
+		ifc.infoCollector = filenames.NewInfoCollector(ifc.tz, ifc.supportedMedia, app.NamePatterns...)
 	}
 
 	if ifc.InclusionFlags.DateRange.IsSet() {
 		ifc.InclusionFlags.DateRange.SetTZ(ifc.tz)
 	}
+	if err := ifc.InclusionFlags.Where.SetTZ(ifc.tz); err != nil {
+		return err
+	}
 
 	if ifc.ManageEpsonFastFoto {
 		ifc.groupers = append(ifc.groupers, epsonfastfoto.Group{}.Group)
 	}
 	if ifc.ManageBurst != filters.BurstNothing {
-		ifc.groupers = append(ifc.groupers, burst.Group)
+		ifc.groupers = append(ifc.groupers, ifc.BurstGrouper(burst.FileHash))
 	}
 	ifc.groupers = append(ifc.groupers, series.Group)
 
@@ -114,10 +140,26 @@ func (ifc *ImportFolderCmd) Browse(ctx context.Context) chan *assets.Group {
 			ifc.wg.Wait()
 			ifc.icloudMetaPass = false
 		}
+		// the watcher collects the changes during the initial scan
+		watchDone := make(chan struct{})
+		if ifc.watcher != nil {
+			go func() {
+				ifc.watcher.run(ctx, gOut)
+				close(watchDone)
+			}()
+		} else {
+			close(watchDone)
+		}
 		for _, fsys := range ifc.fsyss {
 			ifc.concurrentParseDir(ctx, fsys, ".", gOut)
 		}
 		ifc.wg.Wait()
+		if ifc.watcher != nil {
+			ifc.app.Log().Message("Watching the folders for new files. Press Ctrl+C to stop.")
+			ifc.watcher.start()
+		}
+		<-watchDone
+		ifc.wg.Wait()
 		ifc.pool.Stop()
 	}()
 	return gOut
@@ -137,12 +179,6 @@ func (ifc *ImportFolderCmd) concurrentParseDir(ctx context.Context, fsys fs.FS,
 }
 
 func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string, gOut chan *assets.Group) error {
-	fsName := ""
-	if fsys, ok := fsys.(interface{ Name() string }); ok {
-		fsName = fsys.Name()
-	}
-
-	var as []*assets.Asset
 	var entries []fs.DirEntry
 	var err error
 
@@ -155,6 +191,19 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 			return err
 		}
 	}
+	return ifc.parseEntries(ctx, fsys, dir, entries, gOut)
+}
+
+// parseEntries processes the entries of the directory dir, and sends the groups of assets to gOut.
+// The sub-directories are explored concurrently when the recursive option is set.
+func (ifc *ImportFolderCmd) parseEntries(ctx context.Context, fsys fs.FS, dir string, entries []fs.DirEntry, gOut chan *assets.Group) error {
+	fsName := ""
+	if fsys, ok := fsys.(interface{ Name() string }); ok {
+		fsName = fsys.Name()
+	}
+
+	var as []*assets.Asset
+	known := map[*assets.Asset]bool{} // assets of a watched folder already processed
 
 	for _, entry := range entries {
 		base := entry.Name()
@@ -277,12 +326,18 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 				return err
 			}
 			if a != nil {
-				// Record asset discovery with size
-				code := fileevent.DiscoveredImage
-				if mediaType == filetypes.TypeVideo {
-					code = fileevent.DiscoveredVideo
+				if ifc.watcher.isSeen(fsys, name, int64(a.FileSize), a.FileDate) {
+					// tracked again only when grouped with a new asset
+					known[a] = true
+				} else {
+					// Record asset discovery with size
+					code := fileevent.DiscoveredImage
+					if mediaType == filetypes.TypeVideo {
+						code = fileevent.DiscoveredVideo
+					}
+					ifc.processor.RecordAssetDiscovered(ctx, a.File, int64(a.FileSize), code)
+					ifc.watcher.markSeen(fsys, name, int64(a.FileSize), a.FileDate)
 				}
-				ifc.processor.RecordAssetDiscovered(ctx, a.File, int64(a.FileSize), code)
 				as = append(as, a)
 			}
 		}
@@ -352,7 +407,7 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 					ifc.processor.RecordNonAsset(ctx, fshelper.FSName(fsys, xmpName), 0, fileevent.ErrorFileAccess, "error", err.Error())
 				} else {
 					md := &assets.Metadata{}
-					err = xmpsidecar.ReadXMP(bytes.NewReader(buf), md)
+					err = xmpsidecar.ReadXMP(bytes.NewReader(buf), md, ifc.tz)
 					if err != nil {
 						ifc.processor.RecordNonAsset(ctx, fshelper.FSName(fsys, xmpName), 0, fileevent.ErrorFileAccess, "error", err.Error())
 					} else {
@@ -387,8 +442,12 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 						}
 						if (md == nil || md.DateTaken.IsZero()) && !a.Taken.IsZero() && ifc.TakeDateFromFilename {
 							// no exif, but we have a date in the filename and the TakeDateFromFilename is set
+							// The date has no time offset, it's localized again when the asset is geotagged
 							a.FromApplication = &assets.Metadata{
-								DateTaken: a.Taken,
+								DateTaken: assets.LocalizeDate(a.Taken, a.Latitude, a.Longitude),
+								NaiveDate: true,
+								Latitude:  a.Latitude,
+								Longitude: a.Longitude,
 							}
 							a.CaptureDate = a.FromApplication.DateTaken
 						}
@@ -397,12 +456,23 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 				}
 			}
 
+			// Correct the date given by the camera clock
+			if a.FromSourceFile != nil {
+				shared.FixClock(ctx, ifc.app.ClockRules, a, ifc.processor.Logger())
+			}
+
 			if !ifc.InclusionFlags.DateRange.InRange(a.CaptureDate) {
 				a.Close()
 				ifc.processor.RecordAssetDiscardedImmediately(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, "asset outside date range")
 				continue
 			}
 
+			if !ifc.InclusionFlags.Where.Match(a) {
+				a.Close()
+				ifc.processor.RecordAssetDiscardedImmediately(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, ifc.InclusionFlags.Where.String())
+				continue
+			}
+
 			// Add folder as tags
 			if ifc.FolderAsTags {
 				t := fsName
@@ -466,6 +536,9 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 
 	gs := groups.NewGrouperPipeline(ctx, ifc.groupers...).PipeGrouper(ctx, in)
 	for g := range gs {
+		if len(known) > 0 && !ifc.keepWatchedGroup(ctx, g, known) {
+			continue
+		}
 		select {
 		case gOut <- g:
 		case <-ctx.Done():
@@ -476,6 +549,16 @@ func (ifc *ImportFolderCmd) parseDir(ctx context.Context, fsys fs.FS, dir string
 }
 
 func checkExistSideCar(fsys fs.FS, name string, ext string) (string, error) {
+	l, err := sideCarNames(fsys, name, ext)
+	if err != nil || len(l) == 0 {
+		return "", err
+	}
+	return l[0], nil
+}
+
+// sideCarNames returns the sidecar files of the media, named photo.jpg.xmp then photo.xmp.
+// The extension case is ignored.
+func sideCarNames(fsys fs.FS, name string, ext string) ([]string, error) {
 	ext2 := ""
 	for _, r := range ext {
 		if r == '.' {
@@ -488,26 +571,20 @@ func checkExistSideCar(fsys fs.FS, name string, ext string) (string, error) {
 	base := name
 	l, err := fs.Glob(fsys, base+ext2)
 	if err != nil {
-		return "", err
-	}
-	if len(l) > 0 {
-		return l[0], nil
+		return nil, err
 	}
 
 	ext = path.Ext(base)
 	if !filetypes.DefaultSupportedMedia.IsMedia(ext) {
-		return "", nil
+		return l, nil
 	}
 	base = strings.TrimSuffix(base, ext)
 
-	l, err = fs.Glob(fsys, base+ext2)
+	l2, err := fs.Glob(fsys, base+ext2)
 	if err != nil {
-		return "", err
-	}
-	if len(l) > 0 {
-		return l[0], nil
+		return nil, err
 	}
-	return "", nil
+	return append(l, l2...), nil
 }
 
 func matchesBanned(list namematcher.List, name string, isDir bool) bool {
@@ -0,0 +1,378 @@
+package folder
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+/*
+	Watch mode
+
+	The watched folders are followed with fsnotify after the initial scan.
+	The new files are collected per folder, and the folder is processed
+	once it has been quiet for the delay: partially written files are not
+	read, and the files of a pair (live photo, RAW+JPEG) arriving a few seconds
+	apart are grouped together.
+
+	The new files are grouped with the assets of the folder already processed,
+	so a pair split over two batches is still stacked. Only the groups having
+	a new file are sent.
+*/
+
+// watchRoot is a folder given on the command line
+type watchRoot struct {
+	dir  string // OS path of the folder
+	fsys fs.FS  // live file system rooted on the folder
+}
+
+type fileKey struct {
+	fsys fs.FS
+	name string
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileStamp) same(o fileStamp) bool {
+	return s.size == o.size && s.modTime.Equal(o.modTime)
+}
+
+type pendingDir struct {
+	fsys       fs.FS
+	dir        string
+	files      map[string]fileStamp // last observed state of the files
+	lastChange time.Time
+}
+
+// folderWatcher collects the new files of the watched folders
+type folderWatcher struct {
+	ifc     *ImportFolderCmd
+	watcher *fsnotify.Watcher
+	roots   []watchRoot
+	delay   time.Duration
+
+	mu      sync.Mutex
+	started bool                    // the initial scan is done
+	seen    map[fileKey]fileStamp   // files already processed
+	pending map[fileKey]*pendingDir // folders with new files, by fs and folder name
+}
+
+// newWatchRoots checks that the arguments are folders, and opens them with a live file system
+func newWatchRoots(args []string) ([]watchRoot, error) {
+	roots := make([]watchRoot, 0, len(args))
+	for _, a := range args {
+		if fshelper.HasMagic(a) || strings.HasSuffix(strings.ToLower(a), ".zip") {
+			return nil, errors.New("the watch mode needs folders, not file patterns or archives: " + a)
+		}
+		s, err := os.Stat(a)
+		if err != nil {
+			return nil, err
+		}
+		if !s.IsDir() {
+			return nil, errors.New("the watch mode needs folders: " + a)
+		}
+		roots = append(roots, watchRoot{dir: filepath.Clean(a), fsys: fshelper.NewFSWithName(a)})
+	}
+	return roots, nil
+}
+
+func newFolderWatcher(ifc *ImportFolderCmd, roots []watchRoot, delay time.Duration) (*folderWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &folderWatcher{
+		ifc:     ifc,
+		watcher: watcher,
+		roots:   roots,
+		delay:   delay,
+		seen:    map[fileKey]fileStamp{},
+		pending: map[fileKey]*pendingDir{},
+	}
+	for _, r := range roots {
+		if err := w.addTree(r, ".", false); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// addTree watches the folder dir and its sub-folders.
+// The files found are queued when enqueue is set.
+func (w *folderWatcher) addTree(r watchRoot, dir string, enqueue bool) error {
+	return fs.WalkDir(r.fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if name != "." && matchesBanned(w.ifc.BannedFiles, name, true) {
+				return fs.SkipDir
+			}
+			if name != "." && !w.ifc.Recursive {
+				return fs.SkipDir
+			}
+			return w.watcher.Add(filepath.Join(r.dir, filepath.FromSlash(name)))
+		}
+		if enqueue {
+			w.touch(r, name)
+		}
+		return nil
+	})
+}
+
+// markSeen records a file processed by the scan, to not process it twice
+func (w *folderWatcher) markSeen(fsys fs.FS, name string, size int64, modTime time.Time) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.seen[fileKey{fsys: fsys, name: name}] = fileStamp{size: size, modTime: modTime}
+	w.mu.Unlock()
+}
+
+// isSeen tells if the file has already been processed in this state
+func (w *folderWatcher) isSeen(fsys fs.FS, name string, size int64, modTime time.Time) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seen, ok := w.seen[fileKey{fsys: fsys, name: name}]
+	return ok && seen.same(fileStamp{size: size, modTime: modTime})
+}
+
+// touch queues a new or modified file
+func (w *folderWatcher) touch(r watchRoot, name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	k := fileKey{fsys: r.fsys, name: path.Dir(name)}
+	pd, ok := w.pending[k]
+	if !ok {
+		pd = &pendingDir{fsys: r.fsys, dir: k.name, files: map[string]fileStamp{}}
+		w.pending[k] = pd
+	}
+	pd.files[name] = fileStamp{}
+	pd.lastChange = time.Now()
+}
+
+// forget removes a deleted or renamed file or folder from the queue and from the processed files
+func (w *folderWatcher) forget(r watchRoot, name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if pd, ok := w.pending[fileKey{fsys: r.fsys, name: path.Dir(name)}]; ok {
+		delete(pd.files, name)
+	}
+	k := fileKey{fsys: r.fsys, name: name}
+	if _, ok := w.seen[k]; ok {
+		delete(w.seen, k)
+		return
+	}
+	// a removed folder
+	prefix := name + "/"
+	for k := range w.seen {
+		if k.fsys == r.fsys && strings.HasPrefix(k.name, prefix) {
+			delete(w.seen, k)
+		}
+	}
+}
+
+// rootOf returns the watched folder containing the OS path, and the name relative to it
+func (w *folderWatcher) rootOf(osPath string) (watchRoot, string, bool) {
+	for _, r := range w.roots {
+		rel, err := filepath.Rel(r.dir, osPath)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		return r, filepath.ToSlash(rel), true
+	}
+	return watchRoot{}, "", false
+}
+
+func (w *folderWatcher) handleEvent(e fsnotify.Event) {
+	r, name, ok := w.rootOf(e.Name)
+	if !ok {
+		return
+	}
+	switch {
+	case e.Has(fsnotify.Create) || e.Has(fsnotify.Write):
+		s, err := fs.Stat(r.fsys, name)
+		if err != nil {
+			return
+		}
+		if s.IsDir() {
+			if e.Has(fsnotify.Create) && w.ifc.Recursive && !matchesBanned(w.ifc.BannedFiles, name, true) {
+				// the files may have been written before the folder is watched
+				if err := w.addTree(r, name, true); err != nil {
+					w.ifc.app.Log().Error("can't watch the folder", "folder", e.Name, "err", err)
+				}
+			}
+			return
+		}
+		w.touch(r, name)
+	case e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename):
+		w.forget(r, name)
+	}
+}
+
+// start enables the processing of the new files once the initial scan is done
+func (w *folderWatcher) start() {
+	w.mu.Lock()
+	w.started = true
+	w.mu.Unlock()
+}
+
+// readyEntries returns the folders with new files that have been quiet for the delay.
+// The entries are the new files and the assets of the folder already processed.
+func (w *folderWatcher) readyEntries() map[*pendingDir][]fs.DirEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		return nil
+	}
+	now := time.Now()
+	ready := map[*pendingDir][]fs.DirEntry{}
+	for k, pd := range w.pending {
+		// a file still growing delays the whole folder
+		for name, stamp := range pd.files {
+			s, err := fs.Stat(pd.fsys, name)
+			if err != nil {
+				delete(pd.files, name)
+				continue
+			}
+			current := fileStamp{size: s.Size(), modTime: s.ModTime()}
+			if !current.same(stamp) {
+				pd.files[name] = current
+				pd.lastChange = now
+			}
+		}
+		if now.Sub(pd.lastChange) < w.delay {
+			continue
+		}
+		delete(w.pending, k)
+		if !w.hasNewFiles(pd) {
+			continue
+		}
+
+		list, err := fs.ReadDir(pd.fsys, pd.dir)
+		if err != nil {
+			continue
+		}
+		var entries []fs.DirEntry
+		for _, e := range list {
+			if e.IsDir() {
+				continue
+			}
+			name := path.Join(pd.dir, e.Name())
+			if _, ok := pd.files[name]; !ok {
+				if _, ok := w.seen[fileKey{fsys: pd.fsys, name: name}]; !ok {
+					continue
+				}
+			}
+			entries = append(entries, e)
+		}
+		ready[pd] = entries
+	}
+	return ready
+}
+
+// hasNewFiles tells if a queued file of the folder hasn't been processed yet
+func (w *folderWatcher) hasNewFiles(pd *pendingDir) bool {
+	for name, stamp := range pd.files {
+		if seen, ok := w.seen[fileKey{fsys: pd.fsys, name: name}]; ok && seen.same(stamp) {
+			continue
+		}
+		s, err := fs.Stat(pd.fsys, name)
+		if err == nil && !s.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// keepWatchedGroup tells if the group of a watched folder has a new asset.
+// The assets already processed are tracked again when grouped with a new one,
+// the groups without new asset are dropped.
+func (ifc *ImportFolderCmd) keepWatchedGroup(ctx context.Context, g *assets.Group, known map[*assets.Asset]bool) bool {
+	all := g.Assets
+	for _, r := range g.Removed {
+		all = append(all, r.Asset)
+	}
+	if !slices.ContainsFunc(all, func(a *assets.Asset) bool { return !known[a] }) {
+		for _, a := range all {
+			a.Close()
+		}
+		return false
+	}
+	for _, a := range all {
+		if known[a] {
+			code := fileevent.DiscoveredImage
+			if ifc.supportedMedia.TypeFromExt(path.Ext(a.File.Name())) == filetypes.TypeVideo {
+				code = fileevent.DiscoveredVideo
+			}
+			ifc.processor.RecordAssetDiscovered(ctx, a.File, int64(a.FileSize), code)
+		}
+	}
+	return true
+}
+
+// run follows the changes until the context is cancelled.
+// The groups of new assets are sent to gOut
+func (w *folderWatcher) run(ctx context.Context, gOut chan *assets.Group) {
+	defer w.watcher.Close()
+	ifc := w.ifc
+	ticker := time.NewTicker(max(min(w.delay/2, time.Second), 100*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(e)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			ifc.app.Log().Error("watch error", "err", err)
+		case <-ticker.C:
+			for pd, entries := range w.readyEntries() {
+				ifc.app.Log().Info("new files in the watched folder", "folder", fshelper.FSName(pd.fsys, pd.dir), "files", len(entries))
+				ifc.wg.Add(1)
+				go ifc.pool.Submit(func() {
+					defer ifc.wg.Done()
+					if err := ifc.parseEntries(ctx, pd.fsys, pd.dir, entries, gOut); err != nil {
+						ifc.app.Log().Error(err.Error())
+					}
+				})
+			}
+		}
+	}
+}
+
+// IsWatching tells that the reader keeps sending new assets until the context is cancelled
+func (ifc *ImportFolderCmd) IsWatching() bool {
+	return ifc.watcher != nil
+}
@@ -0,0 +1,92 @@
+package folder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherReadyEntries(t *testing.T) {
+	dir := t.TempDir()
+	roots, err := newWatchRoots([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifc := &ImportFolderCmd{Recursive: true}
+	w, err := newFolderWatcher(ifc, roots, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.watcher.Close()
+	r := roots[0]
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a file processed by the initial scan
+	write("old.jpg", "old")
+	s, _ := os.Stat(filepath.Join(dir, "old.jpg"))
+	w.markSeen(r.fsys, "old.jpg", s.Size(), s.ModTime())
+
+	write("new.jpg", "new")
+	w.touch(r, "old.jpg")
+	w.touch(r, "new.jpg")
+
+	if ready := w.readyEntries(); len(ready) != 0 {
+		t.Fatalf("nothing should be processed before the end of the initial scan, got %v", ready)
+	}
+	w.start()
+	if ready := w.readyEntries(); len(ready) != 0 {
+		t.Fatalf("nothing should be processed before the delay, got %v", ready)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	ready := w.readyEntries()
+	if len(ready) != 1 {
+		t.Fatalf("expected one folder, got %d", len(ready))
+	}
+	for pd, entries := range ready {
+		if pd.dir != "." {
+			t.Errorf("expected the folder '.', got %q", pd.dir)
+		}
+		// the new file is grouped with the processed one
+		if len(entries) != 2 || entries[0].Name() != "new.jpg" || entries[1].Name() != "old.jpg" {
+			t.Errorf("expected new.jpg and old.jpg, got %v", entries)
+		}
+	}
+	if ready := w.readyEntries(); len(ready) != 0 {
+		t.Errorf("the folder should be processed once, got %v", ready)
+	}
+
+	// a folder without new file isn't processed
+	w.touch(r, "old.jpg")
+	time.Sleep(100 * time.Millisecond)
+	if ready := w.readyEntries(); len(ready) != 0 {
+		t.Errorf("nothing new should be processed, got %v", ready)
+	}
+
+	// the removed files are forgotten
+	w.markSeen(r.fsys, "sub/a.jpg", 1, s.ModTime())
+	w.forget(r, "old.jpg")
+	w.forget(r, "sub")
+	if len(w.seen) != 0 {
+		t.Errorf("expected no processed file left, got %v", w.seen)
+	}
+}
+
+func TestNewWatchRoots(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, arg := range []string{file, filepath.Join(dir, "*.jpg"), filepath.Join(dir, "takeout.zip")} {
+		if _, err := newWatchRoots([]string{arg}); err == nil {
+			t.Errorf("newWatchRoots(%q) should fail", arg)
+		}
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path"
 
 	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/exif"
 	"github.com/simulot/immich-go/internal/exif/sidecars/jsonsidecar"
 	"github.com/simulot/immich-go/internal/fshelper"
 	"github.com/simulot/immich-go/internal/fshelper/debugfiles"
@@ -24,8 +26,10 @@ type closer interface {
 	Close() error
 }
 type LocalAssetWriter struct {
-	WriteToFS  fs.FS
-	createdDir map[string]struct{}
+	WriteToFS     fs.FS
+	EmbedMetadata bool         // Write the asset's metadata into the file itself when the format allows it
+	Log           *slog.Logger // Reports the files copied without their metadata
+	createdDir    map[string]struct{}
 }
 
 func NewLocalAssetWriter(fsys fs.FS, writeToPath string) (*LocalAssetWriter, error) {
@@ -55,6 +59,40 @@ func (w *LocalAssetWriter) WriteGroup(ctx context.Context, group *assets.Group)
 	return err
 }
 
+// writeFile copies the reader into the file, the file is truncated
+func (w *LocalAssetWriter) writeFile(name string, r io.Reader) error {
+	f, err := fshelper.OpenFile(w.WriteToFS, name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return errors.Join(err, f.Close())
+}
+
+// writeWithMetadata writes the file with the metadata embedded.
+// The file is copied as is when the metadata can't be written, like a file not matching its extension.
+func (w *LocalAssetWriter) writeWithMetadata(name string, r io.ReadSeeker, md *assets.Metadata) error {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(exif.WriteMetadata(pw, r, path.Base(name), md))
+	}()
+	err := w.writeFile(name, pr)
+	pr.Close()
+	<-done // the reader is free
+	if err == nil {
+		return nil
+	}
+	if w.Log != nil {
+		w.Log.Warn("can't embed the metadata, the file is copied as is", "file", name, "err", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.writeFile(name, r)
+}
+
 func (w *LocalAssetWriter) WriteAsset(ctx context.Context, a *assets.Asset) error {
 	base := a.Base
 	dir := w.pathOfAsset(a)
@@ -106,7 +144,11 @@ func (w *LocalAssetWriter) WriteAsset(ctx context.Context, a *assets.Asset) erro
 			}
 
 			// write the asset
-			err = fshelper.WriteFile(w.WriteToFS, path.Join(dir, base), r)
+			if md := embeddedMetadata(a); w.EmbedMetadata && md != nil && exif.CanWriteMetadata(base) {
+				err = w.writeWithMetadata(path.Join(dir, base), r, md)
+			} else {
+				err = w.writeFile(path.Join(dir, base), r)
+			}
 			if err != nil {
 				return err
 			}
@@ -131,13 +173,18 @@ func (w *LocalAssetWriter) WriteAsset(ctx context.Context, a *assets.Asset) erro
 			}
 
 			// Having metadata from an Application or immich-go JSON?
-			if a.FromApplication != nil {
+			md := a.FromApplication
+			if md == nil && (a.GeoTagged || a.DateCorrected) {
+				// Keep the position given by the track log and the corrected date
+				md = embeddedMetadata(a)
+			}
+			if md != nil {
 				var scw fshelper.WFile
 				scw, err = fshelper.OpenFile(w.WriteToFS, path.Join(dir, base+".JSON"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
 				if err != nil {
 					return err
 				}
-				err = jsonsidecar.Write(a.FromApplication, scw)
+				err = jsonsidecar.Write(md, scw)
 				scw.Close()
 			}
 
@@ -146,6 +193,42 @@ func (w *LocalAssetWriter) WriteAsset(ctx context.Context, a *assets.Asset) erro
 	}
 }
 
+// embeddedMetadata returns the metadata to be written into the file, or nil when there is nothing to write
+func embeddedMetadata(a *assets.Asset) *assets.Metadata {
+	md := &assets.Metadata{
+		DateTaken:   a.CaptureDate,
+		Latitude:    a.Latitude,
+		Longitude:   a.Longitude,
+		Description: a.Description,
+		Tags:        a.Tags,
+		NaiveDate:   naiveDate(a),
+	}
+	if a.Rating > 0 && a.Rating <= 5 {
+		md.Rating = byte(a.Rating)
+	}
+	if md.DateTaken.IsZero() && md.Latitude == 0 && md.Longitude == 0 && md.Description == "" && md.Rating == 0 && len(md.Tags) == 0 {
+		return nil
+	}
+	return md
+}
+
+// naiveDate tells if the capture date has no time offset, like the date of the metadata giving it
+func naiveDate(a *assets.Asset) bool {
+	sources := []*assets.Metadata{a.FromApplication, a.FromSideCar, a.FromSourceFile}
+	for _, m := range sources {
+		if m != nil && !m.DateTaken.IsZero() && m.DateTaken.Equal(a.CaptureDate) {
+			return m.NaiveDate
+		}
+	}
+	// a date corrected by a clock rule keeps the kind of the camera's date
+	for _, m := range sources {
+		if m != nil && !m.DateTaken.IsZero() {
+			return m.NaiveDate
+		}
+	}
+	return false
+}
+
 func (w *LocalAssetWriter) pathOfAsset(a *assets.Asset) string {
 	d := a.CaptureDate
 	if d.IsZero() {
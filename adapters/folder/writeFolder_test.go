@@ -0,0 +1,64 @@
+package folder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/simulot/immich-go/internal/fshelper/osfs"
+)
+
+func TestWriteAssetMislabeled(t *testing.T) {
+	// a PNG file with the JPEG extension, like in some Google Photos takeouts
+	content := []byte("\x89PNG\r\n\x1a\n not really a JPEG")
+	src := fstest.MapFS{"IMG_0001.jpg": &fstest.MapFile{Data: content}}
+	a := &assets.Asset{
+		File:        fshelper.FSName(src, "IMG_0001.jpg"),
+		FileSize:    len(content),
+		CaptureDate: time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC),
+		Description: "holidays",
+	}
+	a.Base = "IMG_0001.jpg"
+
+	dir := t.TempDir()
+	w, err := NewLocalAssetWriter(osfs.DirFS(dir), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.EmbedMetadata = true
+	if err := w.WriteAsset(context.Background(), a); err != nil {
+		t.Fatalf("WriteAsset() error = %v", err)
+	}
+	a.Close()
+	got, err := os.ReadFile(filepath.Join(dir, "2023", "2023-06", "IMG_0001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("the file isn't copied as is: %q", got)
+	}
+}
+
+func TestEmbeddedMetadataNaiveDate(t *testing.T) {
+	d := time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC)
+	a := &assets.Asset{
+		CaptureDate:    d,
+		FromSourceFile: &assets.Metadata{DateTaken: d, NaiveDate: true},
+	}
+	if md := embeddedMetadata(a); md == nil || !md.NaiveDate {
+		t.Errorf("the naive date of the file is lost: %+v", md)
+	}
+
+	// the date given by the application has a time offset
+	a.FromApplication = &assets.Metadata{DateTaken: d.Add(time.Hour)}
+	a.CaptureDate = a.FromApplication.DateTaken
+	if md := embeddedMetadata(a); md == nil || md.NaiveDate {
+		t.Errorf("the date of the application isn't naive: %+v", md)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/simulot/immich-go/adapters"
+	"github.com/simulot/immich-go/adapters/shared"
 	"github.com/simulot/immich-go/app"
 	"github.com/simulot/immich-go/immich"
 	"github.com/simulot/immich-go/internal/assets"
@@ -103,7 +104,10 @@ func (fic *FromImmichCmd) Run(ctx context.Context, cmd *cobra.Command, app *app.
 
 	fic.processor = app.FileProcessor()
 	fic.ifs = immichfs.NewImmichFS(ctx, fic.client.Server, fic.client.Immich)
-	fic.ic = filenames.NewInfoCollector(time.Local, fic.client.Immich.SupportedMedia())
+	if err = fic.InclusionFlags.Where.SetTZ(app.GetTZ()); err != nil {
+		return err
+	}
+	fic.ic = filenames.NewInfoCollector(time.Local, fic.client.Immich.SupportedMedia(), app.NamePatterns...)
 
 	// check filters values against immich suggestions
 	if fic.Make != "" {
@@ -427,6 +431,12 @@ func (fic *FromImmichCmd) getAssets(ctx context.Context, grpChan chan *assets.Gr
 			asset.Tags[t].ID = ""
 		}
 
+		shared.FixClock(ctx, fic.app.ClockRules, asset, fic.processor.Logger())
+		if !fic.InclusionFlags.Where.Match(asset) {
+			fic.processor.RecordAssetDiscarded(ctx, asset.File, int64(asset.FileSize), fileevent.DiscardedFiltered, fic.InclusionFlags.Where.String())
+			return nil
+		}
+
 		g := assets.NewGroup(assets.GroupByNone, asset)
 		select {
 		case grpChan <- g:
@@ -107,6 +107,9 @@ func NewFromGooglePhotosCommand(ctx context.Context, parent *cobra.Command, app
 		log := app.Log()
 		toc.processor = app.FileProcessor()
 		toc.tz = app.GetTZ()
+		if err = toc.InclusionFlags.Where.SetTZ(toc.tz); err != nil {
+			return err
+		}
 
 		// make an fs.FS per zip file or folder given on the CLI
 		toc.fsyss, err = fshelper.ParsePath(args)
@@ -146,14 +149,14 @@ func NewFromGooglePhotosCommand(ctx context.Context, parent *cobra.Command, app
 			toc.groupers = append(toc.groupers, g.Group)
 		}
 		if toc.ManageBurst != filters.BurstNothing {
-			toc.groupers = append(toc.groupers, burst.Group)
+			toc.groupers = append(toc.groupers, toc.BurstGrouper(burst.FileHash))
 		}
 		toc.groupers = append(toc.groupers, series.Group)
 
 		// toc.filters = append(toc.filters, toc.StackOptions.ManageBurst.GroupFilter(), toc.StackOptions.ManageRawJPG.GroupFilter(), toc.StackOptions.ManageHEICJPG.GroupFilter())
 
 		toc.supportedMedia = toc.app.GetSupportedMedia()
-		toc.infoCollector = filenames.NewInfoCollector(toc.tz, toc.supportedMedia)
+		toc.infoCollector = filenames.NewInfoCollector(toc.tz, toc.supportedMedia, toc.app.NamePatterns...)
 
 		// callback the caller
 		return runner.Run(cmd, toc)
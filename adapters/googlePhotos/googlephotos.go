@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/simulot/immich-go/adapters/shared"
 	"github.com/simulot/immich-go/internal/assets"
 	"github.com/simulot/immich-go/internal/fileevent"
 	"github.com/simulot/immich-go/internal/filetypes"
@@ -528,11 +529,17 @@ func (toc *TakeoutCmd) filterOnMetadata(ctx context.Context, a *assets.Asset) fi
 		return fileevent.DiscardedFiltered
 	}
 
+	shared.FixClock(ctx, toc.app.ClockRules, a, toc.processor.Logger())
 	if toc.InclusionFlags.DateRange.IsSet() && !toc.InclusionFlags.DateRange.InRange(a.CaptureDate) {
 		toc.processor.RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, "discarding files out of date range")
 		a.Close()
 		return fileevent.DiscardedFiltered
 	}
+	if !toc.InclusionFlags.Where.Match(a) {
+		toc.processor.RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, toc.InclusionFlags.Where.String())
+		a.Close()
+		return fileevent.DiscardedFiltered
+	}
 	if toc.ImportFromAlbum != "" {
 		keep := false
 		dir := path.Dir(a.File.Name())
@@ -0,0 +1,230 @@
+// Package manifest reads the list of files to upload from a CSV or JSONL file, with the metadata of each file.
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/simulot/immich-go/adapters"
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/internal/assets"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/fileprocessor"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type FromManifestCmd struct {
+	// CLI flags
+	InclusionFlags cliflags.InclusionFlags
+
+	// internal state
+	app       *app.Application
+	processor *fileprocessor.FileProcessor
+	tz        *time.Location
+	entries   []Entry
+	baseDir   string           // folder of the relative paths
+	fsyss     map[string]fs.FS // file system per folder
+	stdin     io.Reader
+}
+
+func (fmc *FromManifestCmd) RegisterFlags(flags *pflag.FlagSet) {
+	fmc.InclusionFlags.RegisterFlags(flags, "")
+}
+
+// NewFromManifestCommand creates the command uploading the files listed by a manifest
+func NewFromManifestCommand(ctx context.Context, parent *cobra.Command, app *app.Application, runner adapters.Runner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "from-manifest [flags] <manifest.csv|manifest.jsonl|->",
+		Short: "Upload the files listed by a CSV or JSONL manifest, with their metadata",
+		Long: `Upload the files listed by a CSV file with a header line, or a JSONL file.
+The columns are: path, date, description, albums, tags, people, latitude, longitude, rating, favorite, visibility.
+Only the path is required. With -, NUL separated paths are read from the standard input (find -print0).`,
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.SetContext(ctx)
+	fmc := &FromManifestCmd{
+		app:   app,
+		stdin: os.Stdin,
+	}
+	fmc.RegisterFlags(cmd.Flags())
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return fmc.run(cmd, args[0], runner)
+	}
+	return cmd
+}
+
+func (fmc *FromManifestCmd) run(cmd *cobra.Command, manifest string, runner adapters.Runner) error {
+	fmc.processor = fmc.app.FileProcessor()
+	fmc.tz = fmc.app.GetTZ()
+	fmc.fsyss = map[string]fs.FS{}
+	if fmc.InclusionFlags.DateRange.IsSet() {
+		fmc.InclusionFlags.DateRange.SetTZ(fmc.tz)
+	}
+	if err := fmc.InclusionFlags.Where.SetTZ(fmc.tz); err != nil {
+		return err
+	}
+
+	var err error
+	fmc.entries, err = fmc.readManifest(manifest)
+	if err != nil {
+		return err
+	}
+	if len(fmc.entries) == 0 {
+		return errors.New("the manifest lists no file")
+	}
+	return runner.Run(cmd, fmc)
+}
+
+// readManifest reads the entries of the manifest, the relative paths are relative to the manifest's folder
+func (fmc *FromManifestCmd) readManifest(manifest string) ([]Entry, error) {
+	if manifest == "-" {
+		fmc.baseDir = "."
+		return readPaths(fmc.stdin)
+	}
+
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fmc.baseDir = filepath.Dir(manifest)
+
+	switch strings.ToLower(filepath.Ext(manifest)) {
+	case ".csv":
+		return readCSV(f, fmc.tz)
+	case ".jsonl", ".ndjson":
+		return readJSONL(f, fmc.tz)
+	}
+	return nil, fmt.Errorf("unknown manifest format %q, expected .csv or .jsonl", filepath.Ext(manifest))
+}
+
+// fileOf returns the file system and the name of the file of the entry
+func (fmc *FromManifestCmd) fileOf(e Entry) fshelper.FSAndName {
+	p := filepath.FromSlash(e.Path)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(fmc.baseDir, p)
+	}
+	p = filepath.Clean(p)
+	dir, base := filepath.Dir(p), filepath.Base(p)
+	fsys, ok := fmc.fsyss[dir]
+	if !ok {
+		fsys = fshelper.NewFSWithName(dir)
+		fmc.fsyss[dir] = fsys
+	}
+	return fshelper.FSName(fsys, base)
+}
+
+func (fmc *FromManifestCmd) Browse(ctx context.Context) chan *assets.Group {
+	gOut := make(chan *assets.Group)
+	go func() {
+		defer close(gOut)
+		sm := fmc.app.GetSupportedMedia()
+		ic := filenames.NewInfoCollector(fmc.tz, sm, fmc.app.NamePatterns...)
+
+		for _, e := range fmc.entries {
+			a := fmc.assetFromEntry(ctx, sm, ic, e)
+			if a == nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				a.Close()
+				return
+			case gOut <- assets.NewGroup(assets.GroupByNone, a):
+			}
+		}
+	}()
+	return gOut
+}
+
+// assetFromEntry builds the asset of the entry, or records why the file is discarded
+func (fmc *FromManifestCmd) assetFromEntry(ctx context.Context, sm filetypes.SupportedMedia, ic *filenames.InfoCollector, e Entry) *assets.Asset {
+	file := fmc.fileOf(e)
+	ext := strings.ToLower(path.Ext(file.Name()))
+
+	mediaType := sm.TypeFromExt(ext)
+	if mediaType != filetypes.TypeImage && mediaType != filetypes.TypeVideo {
+		fmc.processor.RecordNonAsset(ctx, file, 0, fileevent.DiscoveredUnsupported, "reason", "unsupported file type")
+		return nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		fmc.processor.RecordNonAsset(ctx, file, 0, fileevent.ErrorFileAccess, "error", err.Error())
+		return nil
+	}
+
+	a := &assets.Asset{
+		File:             file,
+		OriginalFileName: file.Name(),
+		FileSize:         int(info.Size()),
+		FileDate:         info.ModTime(),
+	}
+	a.SetNameInfo(ic.GetInfo(a.OriginalFileName))
+	a.CaptureDate = a.Taken
+
+	code := fileevent.DiscoveredImage
+	if mediaType == filetypes.TypeVideo {
+		code = fileevent.DiscoveredVideo
+	}
+	fmc.processor.RecordAssetDiscovered(ctx, a.File, int64(a.FileSize), code)
+
+	if e.hasMetadata() {
+		// the values of the manifest are forced after the upload
+		md := &assets.Metadata{
+			File:        a.File,
+			FileName:    a.OriginalFileName,
+			DateTaken:   e.Date,
+			NaiveDate:   e.NaiveDate,
+			Description: e.Description,
+			Latitude:    e.Latitude,
+			Longitude:   e.Longitude,
+			Rating:      byte(e.Rating),
+			Favorited:   e.Favorite,
+			Archived:    e.Visibility == assets.VisibilityArchive,
+		}
+		for _, album := range e.Albums {
+			md.Albums = append(md.Albums, assets.Album{Title: album})
+		}
+		for _, tag := range e.Tags {
+			md.AddTag(tag)
+		}
+		for _, p := range e.People {
+			md.AddTag("People/" + p)
+		}
+		if md.DateTaken.IsZero() {
+			// no date column, keep the date of the file name
+			md.DateTaken = a.CaptureDate
+			md.NaiveDate = true
+		}
+		a.FromApplication = a.UseMetadata(md)
+		a.Visibility = e.Visibility
+	}
+
+	if fmc.InclusionFlags.DateRange.IsSet() && !fmc.InclusionFlags.DateRange.InRange(a.CaptureDate) {
+		fmc.processor.RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, "asset outside date range")
+		return nil
+	}
+	if !fmc.InclusionFlags.IncludedExtensions.Include(ext) || fmc.InclusionFlags.ExcludedExtensions.Exclude(ext) {
+		fmc.processor.RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, "extension not included")
+		return nil
+	}
+	if !fmc.InclusionFlags.Where.Match(a) {
+		fmc.processor.RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, fmc.InclusionFlags.Where.String())
+		return nil
+	}
+	return a
+}
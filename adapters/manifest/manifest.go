@@ -0,0 +1,269 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/dates"
+)
+
+// Entry is a line of the manifest: a file and the metadata to force on its asset
+type Entry struct {
+	Path        string
+	Date        time.Time
+	NaiveDate   bool // the date has no time offset
+	Description string
+	Albums      []string
+	Tags        []string
+	People      []string
+	Latitude    float64
+	Longitude   float64
+	Rating      int
+	Favorite    bool
+	Visibility  assets.Visibility
+}
+
+// hasMetadata tells if the entry gives values for the server's asset
+func (e Entry) hasMetadata() bool {
+	return !e.Date.IsZero() || e.Description != "" || len(e.Albums) > 0 || len(e.Tags) > 0 || len(e.People) > 0 ||
+		e.Latitude != 0 || e.Longitude != 0 || e.Rating != 0 || e.Favorite || e.Visibility != ""
+}
+
+// listSeparator separates the items of the albums, tags and people columns of a CSV file
+const listSeparator = "|"
+
+// partialDates are the formats of the dates known to the month or the year
+var partialDates = []string{
+	"2006-01",
+	"2006",
+}
+
+// readCSV reads a CSV file with a header line naming the columns.
+// The comma and the semicolon are accepted as field separators.
+func readCSV(r io.Reader, tz *time.Location) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4096)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	cr := csv.NewReader(br)
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read the header of the manifest: %w", err)
+	}
+	for i := range columns {
+		columns[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(columns[i], "\ufeff")))
+	}
+	hasPath := false
+	for _, c := range columns {
+		if c == "path" || c == "file" {
+			hasPath = true
+		}
+	}
+	if !hasPath {
+		return nil, errors.New("the manifest has no path column")
+	}
+
+	var entries []Entry
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		var e Entry
+		for i, v := range record {
+			if i >= len(columns) {
+				break
+			}
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			var values []string
+			switch columns[i] {
+			case "albums", "album", "tags", "tag", "people":
+				values = splitList(v)
+			}
+			if err := e.set(columns[i], v, values, tz); err != nil {
+				return nil, fmt.Errorf("manifest line %d: %w", line, err)
+			}
+		}
+		if e.Path == "" {
+			return nil, fmt.Errorf("manifest line %d: the path is missing", line)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, listSeparator) {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// readJSONL reads a file with a JSON object per line. The keys are the same as the CSV columns,
+// the albums, tags and people are given as lists.
+func readJSONL(r io.Reader, tz *time.Location) ([]Entry, error) {
+	var entries []Entry
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for s.Scan() {
+		line++
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("manifest line %d: %w", line, err)
+		}
+		var e Entry
+		for k, v := range m {
+			var values []string
+			var text string
+			switch v := v.(type) {
+			case nil:
+				continue
+			case []any:
+				for _, item := range v {
+					values = append(values, fmt.Sprint(item))
+				}
+			case string:
+				text = v
+				values = []string{v}
+			default:
+				text = fmt.Sprint(v)
+			}
+			if err := e.set(strings.ToLower(k), text, values, tz); err != nil {
+				return nil, fmt.Errorf("manifest line %d: %w", line, err)
+			}
+		}
+		if e.Path == "" {
+			return nil, fmt.Errorf("manifest line %d: the path is missing", line)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// readPaths reads the NUL separated paths given by find -print0
+func readPaths(r io.Reader) ([]Entry, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, p := range bytes.Split(b, []byte{0}) {
+		p = bytes.TrimRight(p, "\r\n")
+		if len(p) > 0 {
+			entries = append(entries, Entry{Path: string(p)})
+		}
+	}
+	return entries, nil
+}
+
+func (e *Entry) set(key, value string, values []string, tz *time.Location) error {
+	var err error
+	switch key {
+	case "path", "file":
+		e.Path = value
+	case "date":
+		e.Date, e.NaiveDate, err = parseDate(value, tz)
+	case "description":
+		e.Description = value
+	case "albums", "album":
+		e.Albums = append(e.Albums, values...)
+	case "tags", "tag":
+		e.Tags = append(e.Tags, values...)
+	case "people":
+		e.People = append(e.People, values...)
+	case "latitude", "lat":
+		e.Latitude, err = strconv.ParseFloat(value, 64)
+		if err == nil && (e.Latitude < -90 || e.Latitude > 90) {
+			err = errors.New("the latitude must be between -90 and 90")
+		}
+	case "longitude", "long", "lon":
+		e.Longitude, err = strconv.ParseFloat(value, 64)
+		if err == nil && (e.Longitude < -180 || e.Longitude > 180) {
+			err = errors.New("the longitude must be between -180 and 180")
+		}
+	case "rating":
+		e.Rating, err = strconv.Atoi(value)
+		if err == nil && (e.Rating < 0 || e.Rating > 5) {
+			err = errors.New("the rating must be between 0 and 5")
+		}
+	case "favorite":
+		if value != "" {
+			e.Favorite, err = parseBool(value)
+		}
+	case "visibility":
+		switch v := assets.Visibility(strings.ToLower(value)); v {
+		case assets.VisibilityArchive, assets.VisibilityHidden, assets.VisibilityLocked, assets.VisibilityTimeline, "":
+			e.Visibility = v
+		default:
+			err = fmt.Errorf("invalid visibility %q", value)
+		}
+	default:
+		// unknown columns are ignored, the spreadsheet may have notes
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "x", "oui":
+		return true, nil
+	case "no", "n", "non":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+// parseDate reads a date with or without time offset. The dates without offset are read in the time zone tz.
+func parseDate(s string, tz *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if t, _, err := dates.Parse(s, tz); err == nil {
+		return t, true, nil
+	}
+	for _, f := range partialDates {
+		if t, err := time.ParseInLocation(f, s, tz); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", s)
+}
@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/assettracker"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/fileprocessor"
+	"github.com/simulot/immich-go/internal/filetypes"
+)
+
+func TestReadCSV(t *testing.T) {
+	const data = "\ufeffPath;Date;Description;Albums;People;Latitude;Longitude;Rating;Favorite;Visibility;Notes\n" +
+		"scans/box1/001.tif;1987-07;Summer at the lake;Family|Lake;Ann|Bob;45.9;6.1;4;yes;archive;faded\n" +
+		"scans/box1/002.tif;;;;;;;;;;\n"
+
+	entries, err := readCSV(strings.NewReader(data), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Path != "scans/box1/001.tif" || !e.Date.Equal(time.Date(1987, 7, 1, 0, 0, 0, 0, time.UTC)) || !e.NaiveDate {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if len(e.Albums) != 2 || e.Albums[1] != "Lake" || len(e.People) != 2 {
+		t.Errorf("unexpected lists %+v", e)
+	}
+	if e.Rating != 4 || !e.Favorite || e.Visibility != assets.VisibilityArchive || e.Latitude != 45.9 {
+		t.Errorf("unexpected values %+v", e)
+	}
+	if entries[1].hasMetadata() {
+		t.Errorf("the second entry has no metadata: %+v", entries[1])
+	}
+
+	for _, bad := range []string{
+		"date,description\n2020-01-01,no path\n",
+		"path,rating\na.jpg,9\n",
+		"path,date\na.jpg,yesterday\n",
+	} {
+		if _, err := readCSV(strings.NewReader(bad), time.UTC); err == nil {
+			t.Errorf("readCSV(%q) should fail", bad)
+		}
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	const data = `{"path": "/data/a.jpg", "date": "2010-05-01T10:00:00+02:00", "tags": ["x", "y/z"], "favorite": true}
+
+{"path": "b.mp4", "album": "Trip", "rating": 3}
+`
+	entries, err := readJSONL(strings.NewReader(data), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].NaiveDate || len(entries[0].Tags) != 2 || !entries[0].Favorite {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	if len(entries[1].Albums) != 1 || entries[1].Rating != 3 {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+
+	if _, err := readJSONL(strings.NewReader(`{"date": "2010"}`), time.UTC); err == nil {
+		t.Error("an entry without path should fail")
+	}
+}
+
+func TestReadPaths(t *testing.T) {
+	entries, err := readPaths(strings.NewReader("./a b.jpg\x00dir/c.heic\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != "./a b.jpg" || entries[1].Path != "dir/c.heic" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
+
+func TestAssetFromEntryWithoutDate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "PXL_20231006_063000139.jpg"), []byte("jpeg"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	recorder := fileevent.NewRecorder(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	fmc := &FromManifestCmd{
+		InclusionFlags: cliflags.InclusionFlags{DateRange: cliflags.InitDateRange(time.UTC, "2023")},
+		processor:      fileprocessor.New(assettracker.New(), recorder),
+		tz:             time.UTC,
+		baseDir:        dir,
+		fsyss:          map[string]fs.FS{},
+	}
+	sm := filetypes.DefaultSupportedMedia
+	ic := filenames.NewInfoCollector(time.UTC, sm)
+
+	// a description, but no date: the date of the file name is kept
+	a := fmc.assetFromEntry(context.Background(), sm, ic, Entry{Path: "PXL_20231006_063000139.jpg", Description: "beach"})
+	if a == nil {
+		t.Fatal("the asset is discarded")
+	}
+	want := time.Date(2023, 10, 6, 6, 30, 0, 0, time.UTC)
+	if !a.CaptureDate.Equal(want) {
+		t.Errorf("CaptureDate = %s, want %s", a.CaptureDate, want)
+	}
+	if a.FromApplication == nil || !a.FromApplication.DateTaken.Equal(want) || a.FromApplication.Description != "beach" {
+		t.Errorf("FromApplication = %+v", a.FromApplication)
+	}
+}
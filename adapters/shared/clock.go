@@ -0,0 +1,22 @@
+package shared
+
+import (
+	"context"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/clockrules"
+	"github.com/simulot/immich-go/internal/fileevent"
+)
+
+// FixClock corrects the capture date of the asset with the clock rules of the configuration.
+// It's applied by all adapters before filtering the assets on their date.
+func FixClock(ctx context.Context, rules clockrules.Rules, a *assets.Asset, recorder *fileevent.Recorder) {
+	if len(rules) == 0 || a.CaptureDate.IsZero() {
+		return
+	}
+	if d, ok := rules.Fix(a.CameraMake, a.CameraModel, a.CaptureDate); ok {
+		recorder.Record(ctx, fileevent.ProcessedDateCorrected, a.File, "camera date", a.CaptureDate, "corrected date", d)
+		a.CaptureDate = d
+		a.DateCorrected = true
+	}
+}
@@ -1,8 +1,11 @@
 package shared
 
 import (
+	"time"
+
 	"github.com/simulot/immich-go/internal/filters"
 	"github.com/simulot/immich-go/internal/groups"
+	"github.com/simulot/immich-go/internal/groups/burst"
 	"github.com/spf13/pflag"
 )
 
@@ -19,6 +22,12 @@ type StackOptions struct {
 	// ManageEpsonFastFoto enables the management of Epson FastFoto files.
 	ManageEpsonFastFoto bool `mapstructure:"manage_epson_fast_foto" json:"manage_epson_fast_foto" toml:"manage_epson_fast_foto" yaml:"manage_epson_fast_foto"`
 
+	// BurstWindow is the longest time between two frames of a burst taken by the same camera, 0 for the default burst detection.
+	BurstWindow time.Duration `mapstructure:"burst_window" json:"burst_window" toml:"burst_window" yaml:"burst_window"`
+
+	// BurstSimilarity is the largest perceptual distance between two frames of a burst, -1 to not compare the images.
+	BurstSimilarity int `mapstructure:"burst_similarity" json:"burst_similarity" toml:"burst_similarity" yaml:"burst_similarity"`
+
 	Filters  []filters.Filter
 	Groupers []groups.Grouper
 }
@@ -28,4 +37,19 @@ func (so *StackOptions) RegisterFlags(flags *pflag.FlagSet) {
 	flags.Var(&so.ManageRawJPG, "manage-raw-jpeg", "Manage coupled RAW and JPEG files. Possible values: NoStack, KeepRaw, KeepJPG, StackCoverRaw, StackCoverJPG")
 	flags.Var(&so.ManageBurst, "manage-burst", "Manage burst photos. Possible values: NoStack, Stack, StackKeepRaw, StackKeepJPEG")
 	flags.BoolVar(&so.ManageEpsonFastFoto, "manage-epson-fastfoto", false, "Manage Epson FastFoto file (default: false)")
+	flags.DurationVar(&so.BurstWindow, "burst-window", 0, "Group as bursts the images of the same camera taken less than this duration apart (ex: 300ms), 0 for the default burst detection")
+	flags.IntVar(&so.BurstSimilarity, "burst-similarity", -1, "With --burst-window, group only the frames within this perceptual distance (0-64), -1 to not compare the images")
+}
+
+// BurstGrouper returns the grouper of the bursts: the frames of the same camera within --burst-window when set,
+// the default burst detection otherwise. The hash function confirms the frames when --burst-similarity is set.
+func (so *StackOptions) BurstGrouper(hash burst.HashFn) groups.Grouper {
+	if so.BurstWindow <= 0 {
+		return burst.Group
+	}
+	d := burst.Detector{Window: so.BurstWindow, SameCamera: true, MaxDistance: so.BurstSimilarity}
+	if so.BurstSimilarity >= 0 {
+		d.Hash = hash
+	}
+	return d.Group
 }
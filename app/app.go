@@ -8,9 +8,12 @@ import (
 	"time"
 
 	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/clockrules"
 	"github.com/simulot/immich-go/internal/config"
+	"github.com/simulot/immich-go/internal/filenames"
 	"github.com/simulot/immich-go/internal/fileprocessor"
 	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/rules"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -29,12 +32,16 @@ type Application struct {
 	SaveConfig     bool
 	ConcurrentTask int
 	CfgFile        string
+	TZFromGPS      bool
 
 	// Internal state
-	log       *Log
-	processor *fileprocessor.FileProcessor // Unified file processing tracker
-	tz        *time.Location
-	Config    *config.ConfigurationManager
+	log          *Log
+	processor    *fileprocessor.FileProcessor // Unified file processing tracker
+	tz           *time.Location
+	Config       *config.ConfigurationManager
+	ClockRules   clockrules.Rules         // Camera clock corrections given by the configuration file
+	NamePatterns []*filenames.NamePattern // File name patterns given by the configuration file
+	Rules        rules.Rules              // Albums, tags and visibility rules given by the configuration file
 
 	sm filetypes.SupportedMedia
 
@@ -47,6 +54,7 @@ func (app *Application) RegisterFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&app.SaveConfig, "save-config", false, "Save the configuration to immich-go.yaml")
 	flags.Var(&app.OnErrors, "on-errors", "What to do when an error occurs (stop, continue, accept N errors at max)")
 	flags.IntVar(&app.ConcurrentTask, "concurrent-tasks", runtime.NumCPU(), "Number of concurrent tasks (1-20)")
+	flags.BoolVar(&app.TZFromGPS, "tz-from-gps", false, "Use the time zone of the GPS position for capture dates without time offset (offline lookup)")
 }
 
 func New(ctx context.Context, cmd *cobra.Command) *Application {
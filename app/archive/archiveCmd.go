@@ -11,14 +11,21 @@ import (
 	"github.com/simulot/immich-go/internal/assettracker"
 	"github.com/simulot/immich-go/internal/fileevent"
 	"github.com/simulot/immich-go/internal/fileprocessor"
+	"github.com/simulot/immich-go/internal/geotag"
 	"github.com/spf13/cobra"
 )
 
 type ArchiveCmd struct {
-	ArchivePath string
+	ArchivePath   string
+	EmbedMetadata bool
+	Geotag        geotag.Options
+	Verify        bool // Compare the checksum of the downloaded assets with the server's one
+	Retries       int  // Number of downloads retried after a checksum mismatch
+	SplitMotion   bool // Write the motion photos as a still image and a video
 
-	app  *app.Application
-	dest *folder.LocalAssetWriter
+	app       *app.Application
+	dest      *folder.LocalAssetWriter
+	geotagger *geotag.Geotagger
 }
 
 func NewArchiveCommand(ctx context.Context, app *app.Application) *cobra.Command {
@@ -32,6 +39,11 @@ func NewArchiveCommand(ctx context.Context, app *app.Application) *cobra.Command
 
 	cmd.PersistentFlags().StringVarP(&ac.ArchivePath, "write-to-folder", "w", "", "Path where to write the archive")
 	_ = cmd.MarkPersistentFlagRequired("write-to-folder")
+	ac.Geotag.RegisterFlags(cmd.PersistentFlags(), "")
+	cmd.PersistentFlags().BoolVar(&ac.Verify, "verify", false, "Compare the checksum of the assets downloaded from an Immich server with the server's one")
+	cmd.PersistentFlags().IntVar(&ac.Retries, "verify-retries", 1, "Number of times an asset is downloaded again after a checksum mismatch")
+	cmd.PersistentFlags().BoolVar(&ac.SplitMotion, "split-motion-photos", false, "Write the Samsung and Google motion photos as a still image and a video, like a live photo")
+	cmd.PersistentFlags().BoolVar(&ac.EmbedMetadata, "embed-metadata", false, "Write the date, GPS position, description, rating and tags into the JPEG and PNG files (EXIF and XMP)")
 
 	cmd.AddCommand(folder.NewFromFolderCommand(ctx, cmd, app, ac))
 	cmd.AddCommand(folder.NewFromICloudCommand(ctx, cmd, app, ac))
@@ -1,15 +1,20 @@
 package archive
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/simulot/immich-go/adapters"
 	"github.com/simulot/immich-go/adapters/folder"
+	"github.com/simulot/immich-go/internal/assets"
 	"github.com/simulot/immich-go/internal/assettracker"
 	"github.com/simulot/immich-go/internal/fileevent"
 	"github.com/simulot/immich-go/internal/fileprocessor"
 	"github.com/simulot/immich-go/internal/fshelper/osfs"
+	"github.com/simulot/immich-go/internal/geotag"
+	"github.com/simulot/immich-go/internal/motionphoto"
 	"github.com/spf13/cobra"
 )
 
@@ -38,6 +43,13 @@ func (ac *ArchiveCmd) Run(cmd *cobra.Command, adapter adapters.Reader) error {
 	if err != nil {
 		return err
 	}
+	ac.dest.EmbedMetadata = ac.EmbedMetadata
+	ac.dest.Log = log.Logger
+
+	ac.geotagger, err = geotag.New(ac.Geotag, ac.app.GetTZ())
+	if err != nil {
+		return err
+	}
 
 	gChan := adapter.Browse(ctx)
 	errCount := 0
@@ -50,12 +62,30 @@ func (ac *ArchiveCmd) Run(cmd *cobra.Command, adapter adapters.Reader) error {
 				return nil
 			}
 			for _, a := range g.Assets {
-				err := ac.dest.WriteAsset(ctx, a)
+				if ac.geotagger.TagAsset(a) {
+					ac.app.FileProcessor().Logger().Record(ctx, fileevent.ProcessedGeotagged, a.File, "latitude", a.Latitude, "longitude", a.Longitude)
+				}
+				code := fileevent.ErrorFileAccess
+				var err error
+				if ac.Verify {
+					if err = ac.verifyDownload(a); err != nil {
+						if errors.Is(err, errChecksumMismatch) {
+							code = fileevent.ErrorChecksum
+						}
+						_ = a.Close()
+					}
+				}
+				if err == nil && ac.SplitMotion {
+					err = ac.writeMotionVideo(ctx, a)
+				}
 				if err == nil {
-					err = a.Close()
+					err = ac.dest.WriteAsset(ctx, a)
+					if err == nil {
+						err = a.Close()
+					}
 				}
 				if err != nil {
-					ac.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorFileAccess, err)
+					ac.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), code, err)
 					errCount++
 					if errCount > 5 {
 						err := errors.New("too many errors, aborting")
@@ -70,3 +100,50 @@ func (ac *ArchiveCmd) Run(cmd *cobra.Command, adapter adapters.Reader) error {
 		}
 	}
 }
+
+// writeMotionVideo writes the video of a motion photo, the asset keeps the still image
+func (ac *ArchiveCmd) writeMotionVideo(ctx context.Context, a *assets.Asset) error {
+	still, video, err := motionphoto.SplitAsset(a)
+	if err != nil || video == nil {
+		return err
+	}
+	ac.app.FileProcessor().RecordAssetDiscovered(ctx, video.File, int64(video.FileSize), fileevent.DiscoveredVideo)
+	if err := ac.dest.WriteAsset(ctx, video); err != nil {
+		ac.app.FileProcessor().RecordAssetError(ctx, video.File, int64(video.FileSize), fileevent.ErrorFileAccess, err)
+		return err
+	}
+	_ = video.Close()
+	ac.app.FileProcessor().RecordAssetProcessed(ctx, video.File, int64(video.FileSize), fileevent.ProcessedFileArchived)
+	ac.app.FileProcessor().RecordNonAsset(ctx, a.File, 0, fileevent.ProcessedLivePhoto, "video", video.File.Name())
+	return a.SetContent(still)
+}
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// verifyDownload compares the checksum of the downloaded file with the one given by the server.
+// The file is downloaded again after a mismatch.
+// Assets without server checksum aren't checked.
+func (ac *ArchiveCmd) verifyDownload(a *assets.Asset) error {
+	expected := a.Checksum
+	if expected == "" {
+		return nil
+	}
+	for attempt := 0; ; attempt++ {
+		// the file is downloaded and its checksum is computed on the fly
+		f, err := a.OpenFile()
+		if err != nil {
+			return err
+		}
+		f.Close()
+		if a.Checksum == expected {
+			return nil
+		}
+		err = fmt.Errorf("%w: server %s, downloaded %s", errChecksumMismatch, expected, a.Checksum)
+		if attempt >= ac.Retries {
+			return err
+		}
+		ac.app.Log().Warn("checksum mismatch, downloading the asset again", "file", a.File, "attempt", attempt+1, "err", err)
+		_ = a.Close()
+		a.Checksum = expected
+	}
+}
@@ -8,8 +8,14 @@ import (
 	"github.com/simulot/immich-go/app"
 	"github.com/simulot/immich-go/app/archive"
 	"github.com/simulot/immich-go/app/stack"
+	"github.com/simulot/immich-go/app/tool"
 	"github.com/simulot/immich-go/app/upload"
 	"github.com/simulot/immich-go/app/version"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/clockrules"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/rules"
+	"github.com/simulot/immich-go/internal/tzlookup"
 	"github.com/spf13/cobra"
 )
 
@@ -38,10 +44,12 @@ func RootImmichGoCommand(ctx context.Context) (*cobra.Command, *app.Application)
 
 	// Add all subcommands to the root command
 	cmd.AddCommand(
-		version.NewVersionCommand(ctx, a), // Version command to display app version
-		upload.NewUploadCommand(ctx, a),   // Upload command for uploading assets
-		archive.NewArchiveCommand(ctx, a), // Archive command for archiving assets
-		stack.NewStackCommand(ctx, a),     // Stack command for managing stacks
+		version.NewVersionCommand(ctx, a),  // Version command to display app version
+		upload.NewUploadCommand(ctx, a),    // Upload command for uploading assets
+		upload.NewApplyPlanCommand(ctx, a), // Apply-plan command executing a plan written by upload --plan-out
+		archive.NewArchiveCommand(ctx, a),  // Archive command for archiving assets
+		stack.NewStackCommand(ctx, a),      // Stack command for managing stacks
+		tool.NewToolCommand(ctx, a),        // Tool command for miscellaneous server tasks
 	)
 
 	// PersistentPreRunE is executed before any command runs, used for initialization
@@ -58,9 +66,32 @@ func RootImmichGoCommand(ctx context.Context) (*cobra.Command, *app.Application)
 			return err
 		}
 
+		// Camera clock corrections are given by the clock-rules section of the configuration file
+		a.ClockRules, err = clockrules.ParseConfig(a.Config.Get("clock-rules"))
+		if err != nil {
+			return err
+		}
+
+		// User defined file name patterns are given by the name-patterns section of the configuration file
+		a.NamePatterns, err = filenames.ParseNamePatterns(a.Config.Get("name-patterns"))
+		if err != nil {
+			return err
+		}
+
+		// Albums, tags and visibility of the uploaded assets are given by the rules section of the configuration file
+		a.Rules, err = rules.ParseConfig(a.Config.Get("rules"))
+		if err != nil {
+			return err
+		}
+
 		// clip the number of concurrent tasks
 		a.ConcurrentTask = min(max(a.ConcurrentTask, 1), 20)
 
+		// Dates without time offset are interpreted in the time zone of their GPS position
+		if a.TZFromGPS {
+			assets.SetTimeZoneFinder(tzlookup.New())
+		}
+
 		// Save configuration if the --save-config flag is set
 		if save, _ := cmd.Flags().GetBool("save-config"); save {
 			if err := a.Config.Save("immich-go.yaml"); err != nil {
@@ -2,6 +2,7 @@ package stack
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"time"
 
@@ -14,9 +15,9 @@ import (
 	"github.com/simulot/immich-go/internal/filetypes"
 	"github.com/simulot/immich-go/internal/filters"
 	"github.com/simulot/immich-go/internal/groups"
-	"github.com/simulot/immich-go/internal/groups/burst"
 	"github.com/simulot/immich-go/internal/groups/epsonfastfoto"
 	"github.com/simulot/immich-go/internal/groups/series"
+	"github.com/simulot/immich-go/internal/phash"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -31,6 +32,9 @@ type StackCmd struct {
 	// CLI flags
 	StackOptions shared.StackOptions
 	DateRange    cliflags.DateRange
+	Unstack      bool        // dissolve the existing stacks selected by the stack options
+	Cover        CoverPolicy // choose again the cover of the existing stacks
+	ReportMixed  bool        // list the existing stacks of unrelated assets
 
 	// internal state
 	SupportedMedia filetypes.SupportedMedia
@@ -45,6 +49,9 @@ type StackCmd struct {
 func (sc *StackCmd) RegisterFlags(flags *pflag.FlagSet) {
 	sc.StackOptions.RegisterFlags(flags)
 	flags.Var(&sc.DateRange, "date-range", "photos must be taken in the date range")
+	flags.BoolVar(&sc.Unstack, "unstack", false, "Dissolve the existing stacks that the --manage-* options would make, all the stacks without option")
+	flags.Var(&sc.Cover, "cover", "Choose again the cover of the existing stacks: raw, jpeg, resolution or favorite")
+	flags.BoolVar(&sc.ReportMixed, "report-mixed", false, "List the existing stacks of unrelated assets")
 }
 
 // const timeFormat = "2006-01-02T15:04:05.000Z"
@@ -53,7 +60,8 @@ func NewStackCommand(ctx context.Context, a *app.Application) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "stack [flags]",
 		Short: "Update Immich for stacking related photos",
-		Long:  `Stack photos related to each other according to the options`,
+		Long: `Stack photos related to each other according to the options.
+With --unstack, --cover or --report-mixed, the existing stacks are dissolved, get a new cover or are checked instead.`,
 	}
 
 	o := &StackCmd{}
@@ -62,6 +70,9 @@ func NewStackCommand(ctx context.Context, a *app.Application) *cobra.Command {
 	cmd.TraverseChildren = true
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error { //nolint:contextcheck
+		if o.Unstack && o.Cover != CoverNone {
+			return fmt.Errorf("--unstack and --cover can't be used together")
+		}
 		// ready to run
 		ctx := cmd.Context()
 		err := o.client.Open(ctx, a)
@@ -71,7 +82,7 @@ func NewStackCommand(ctx context.Context, a *app.Application) *cobra.Command {
 		o.TZ = a.GetTZ()
 		o.DateRange.SetTZ(a.GetTZ())
 
-		o.InfoCollector = filenames.NewInfoCollector(o.TZ, o.client.Immich.SupportedMedia())
+		o.InfoCollector = filenames.NewInfoCollector(o.TZ, o.client.Immich.SupportedMedia(), a.NamePatterns...)
 		o.filters = append(o.filters,
 			o.StackOptions.ManageBurst.GroupFilter(),
 			o.StackOptions.ManageRawJPG.GroupFilter(),
@@ -81,7 +92,7 @@ func NewStackCommand(ctx context.Context, a *app.Application) *cobra.Command {
 			o.groupers = append(o.groupers, epsonfastfoto.Group{}.Group)
 		}
 		if o.StackOptions.ManageBurst != filters.BurstNothing {
-			o.groupers = append(o.groupers, burst.Group)
+			o.groupers = append(o.groupers, o.StackOptions.BurstGrouper(o.thumbnailHash))
 		}
 		o.groupers = append(o.groupers, series.Group)
 
@@ -92,34 +103,50 @@ func NewStackCommand(ctx context.Context, a *app.Application) *cobra.Command {
 				if a.IsTrashed {
 					return nil
 				}
-
-				asset := a.AsAsset()
-				asset.SetNameInfo(o.InfoCollector.GetInfo(asset.OriginalFileName))
-				asset.FromApplication = &assets.Metadata{
-					FileName:    a.OriginalFileName,
-					Latitude:    a.ExifInfo.Latitude,
-					Longitude:   a.ExifInfo.Longitude,
-					Description: a.ExifInfo.Description,
-					DateTaken:   a.ExifInfo.DateTimeOriginal.Time,
-					Trashed:     a.IsTrashed,
-					Archived:    a.IsArchived,
-					Favorited:   a.IsFavorite,
-					Rating:      byte(a.Rating),
-					Tags:        asset.Tags,
-				}
-
-				o.assets = append(o.assets, asset)
+				o.assets = append(o.assets, o.asAsset(a))
 				return nil
 			})
 		if err != nil {
 			return err
 		}
+		if o.Unstack || o.Cover != CoverNone || o.ReportMixed {
+			return o.ProcessStacks(ctx, a, cmd.OutOrStdout())
+		}
 		err = o.ProcessAssets(ctx, a)
 		return err
 	}
 	return cmd
 }
 
+// thumbnailHash computes the perceptual hash of the asset's thumbnail
+func (s *StackCmd) thumbnailHash(ctx context.Context, a *assets.Asset) (phash.Hash, error) {
+	rc, err := s.client.Immich.DownloadThumbnail(ctx, a.ID)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return phash.Read(rc)
+}
+
+// asAsset converts the server's asset, with the information given by its name
+func (s *StackCmd) asAsset(a *immich.Asset) *assets.Asset {
+	asset := a.AsAsset()
+	asset.SetNameInfo(s.InfoCollector.GetInfo(asset.OriginalFileName))
+	asset.FromApplication = &assets.Metadata{
+		FileName:    a.OriginalFileName,
+		Latitude:    a.ExifInfo.Latitude,
+		Longitude:   a.ExifInfo.Longitude,
+		Description: a.ExifInfo.Description,
+		DateTaken:   a.ExifInfo.DateTimeOriginal.Time,
+		Trashed:     a.IsTrashed,
+		Archived:    a.IsArchived,
+		Favorited:   a.IsFavorite,
+		Rating:      byte(a.Rating),
+		Tags:        asset.Tags,
+	}
+	return asset
+}
+
 func (s *StackCmd) ProcessAssets(ctx context.Context, app *app.Application) error {
 	log := app.Log()
 
@@ -0,0 +1,247 @@
+package stack
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/filters"
+	"github.com/simulot/immich-go/internal/groups"
+	"github.com/simulot/immich-go/internal/groups/epsonfastfoto"
+	"github.com/simulot/immich-go/internal/groups/series"
+)
+
+// CoverPolicy chooses the cover of the existing stacks
+type CoverPolicy string
+
+const (
+	CoverNone       CoverPolicy = ""
+	CoverRaw        CoverPolicy = "raw"        // the first RAW file
+	CoverJPEG       CoverPolicy = "jpeg"       // the first JPEG file
+	CoverResolution CoverPolicy = "resolution" // the biggest image
+	CoverFavorite   CoverPolicy = "favorite"   // the first favorite
+)
+
+func (c *CoverPolicy) Set(s string) error {
+	switch p := CoverPolicy(strings.ToLower(s)); p {
+	case CoverNone, CoverRaw, CoverJPEG, CoverResolution, CoverFavorite:
+		*c = p
+		return nil
+	}
+	return fmt.Errorf("invalid value %q for the cover policy, expected raw, jpeg, resolution or favorite", s)
+}
+
+func (c CoverPolicy) String() string { return string(c) }
+func (c CoverPolicy) Type() string   { return "CoverPolicy" }
+
+// chooseCover returns the index of the cover chosen by the policy, the current cover when no asset fits
+func chooseCover(list []*assets.Asset, policy CoverPolicy, current int) int {
+	best := -1
+	for i, a := range list {
+		switch policy {
+		case CoverRaw:
+			if best < 0 && filetypes.IsRawFile(a.Ext) {
+				best = i
+			}
+		case CoverJPEG:
+			if best < 0 && (a.Ext == ".jpg" || a.Ext == ".jpeg") {
+				best = i
+			}
+		case CoverFavorite:
+			if best < 0 && a.Favorite {
+				best = i
+			}
+		case CoverResolution:
+			if a.Width*a.Height > 0 && (best < 0 || a.Width*a.Height > list[best].Width*list[best].Height) {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		return current
+	}
+	return best
+}
+
+// stackKind tells how the assets of a stack are related, the stack is mixed when they are not.
+// The series of a file name are tried first, then the bursts found by burstGrouper and the Epson FastFoto scans.
+func stackKind(ctx context.Context, list []*assets.Asset, burstGrouper groups.Grouper, epson bool) (assets.GroupBy, bool) {
+	sorted := slices.Clone(list)
+	slices.SortFunc(sorted, func(a, b *assets.Asset) int {
+		if c := cmp.Compare(a.Radical, b.Radical); c != 0 {
+			return c
+		}
+		return a.CaptureDate.Compare(b.CaptureDate)
+	})
+
+	groupers := []groups.Grouper{series.Group, burstGrouper}
+	if epson {
+		groupers = append(groupers, epsonfastfoto.Group{}.Group)
+	}
+	for _, grouper := range groupers {
+		if g := groupAll(ctx, sorted, grouper); g != nil {
+			return g.Grouping, true
+		}
+	}
+	return assets.GroupByNone, false
+}
+
+// groupAll returns the group made by the grouper when it gathers all the assets
+func groupAll(ctx context.Context, list []*assets.Asset, grouper groups.Grouper) *assets.Group {
+	in := make(chan *assets.Asset)
+	go func() {
+		defer close(in)
+		for _, a := range list {
+			select {
+			case in <- a:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var found []*assets.Group
+	for g := range groups.NewGrouperPipeline(ctx, grouper).PipeGrouper(ctx, in) {
+		found = append(found, g)
+	}
+	if len(found) != 1 || len(found[0].Assets) != len(list) || found[0].Grouping == assets.GroupByNone {
+		return nil
+	}
+	return found[0]
+}
+
+// selectedKinds are the kinds of stacks made by the stack options, nil when no option is given
+func (s *StackCmd) selectedKinds() []assets.GroupBy {
+	var kinds []assets.GroupBy
+	if s.StackOptions.ManageRawJPG != filters.RawJPGNothing {
+		kinds = append(kinds, assets.GroupByRawJpg)
+	}
+	if s.StackOptions.ManageHEICJPG != filters.HeicJpgNothing {
+		kinds = append(kinds, assets.GroupByHeicJpg)
+	}
+	if s.StackOptions.ManageBurst != filters.BurstNothing {
+		kinds = append(kinds, assets.GroupByBurst)
+	}
+	if s.StackOptions.ManageEpsonFastFoto {
+		kinds = append(kinds, assets.GroupByOther)
+	}
+	return kinds
+}
+
+// ProcessStacks dissolves the existing stacks, changes their cover or reports the mixed ones
+func (s *StackCmd) ProcessStacks(ctx context.Context, app *app.Application, out io.Writer) error {
+	log := app.Log()
+	client := s.client.Immich.(immich.ImmichStackInterface)
+
+	// the stacks having an asset in the date range
+	inRange := map[string]*assets.Asset{}
+	for _, a := range s.assets {
+		inRange[a.ID] = a
+	}
+	stacks, err := client.GetAllStacks(ctx)
+	if err != nil {
+		return err
+	}
+	kinds := s.selectedKinds()
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tSTACK\tFILE\tDATE")
+	printStack := func(action string, st immich.Stack, list []*assets.Asset) {
+		for _, a := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", action, st.ID, a.OriginalFileName, a.CaptureDate.Format("2006-01-02 15:04:05"))
+			action = ""
+		}
+	}
+
+	burstGrouper := s.StackOptions.BurstGrouper(s.thumbnailHash)
+	unstacked, covered, mixed, failed := 0, 0, 0, 0
+	for _, st := range stacks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		selected := slices.ContainsFunc(st.Assets, func(ia *immich.Asset) bool { return inRange[ia.ID] != nil })
+		if !selected || len(st.Assets) == 0 {
+			continue
+		}
+		var list []*assets.Asset
+		for _, ia := range st.Assets {
+			a, ok := inRange[ia.ID]
+			if !ok {
+				if ia.ExifInfo.DateTimeOriginal.IsZero() {
+					// the server may list the stack's assets without their exifInfo,
+					// the capture date and the camera are needed to tell the kind of the stack
+					full, err := s.client.Immich.GetAssetInfo(ctx, ia.ID)
+					if err != nil {
+						log.Error("can't get the asset of the stack", "stack", st.ID, "asset", ia.ID, "err", err)
+						failed++
+						list = nil
+						break
+					}
+					ia = full
+				}
+				a = s.asAsset(ia)
+			}
+			list = append(list, a)
+		}
+		if len(list) == 0 {
+			continue
+		}
+		kind, related := stackKind(ctx, list, burstGrouper, s.StackOptions.ManageEpsonFastFoto)
+		if !related {
+			mixed++
+			if s.ReportMixed {
+				printStack("mixed", st, list)
+			}
+		}
+
+		switch {
+		case s.Unstack:
+			if len(kinds) > 0 && (!related || !slices.Contains(kinds, kind)) {
+				continue
+			}
+			printStack("unstack", st, list)
+			if err := client.DeleteStack(ctx, st.ID); err != nil {
+				log.Error("can't dissolve the stack", "stack", st.ID, "err", err)
+				failed++
+				continue
+			}
+			unstacked++
+		case s.Cover != CoverNone:
+			current := slices.IndexFunc(list, func(a *assets.Asset) bool { return a.ID == st.PrimaryAssetID })
+			i := chooseCover(list, s.Cover, current)
+			if i < 0 || i == current {
+				continue
+			}
+			printStack("cover", st, list[i:i+1])
+			if err := client.UpdateStack(ctx, st.ID, list[i].ID); err != nil {
+				log.Error("can't change the cover of the stack", "stack", st.ID, "err", err)
+				failed++
+				continue
+			}
+			covered++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case s.Unstack:
+		log.Message("%d stacks dissolved", unstacked)
+	case s.Cover != CoverNone:
+		log.Message("%d stack covers changed", covered)
+	}
+	log.Message("%d stacks of unrelated assets", mixed)
+	if failed > 0 {
+		return fmt.Errorf("%d stacks not changed", failed)
+	}
+	return nil
+}
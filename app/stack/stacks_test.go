@@ -0,0 +1,73 @@
+package stack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/groups/burst"
+)
+
+func stackAsset(ic *filenames.InfoCollector, id, name string, date time.Time) *assets.Asset {
+	a := &assets.Asset{ID: id, OriginalFileName: name, CaptureDate: date}
+	a.SetNameInfo(ic.GetInfo(name))
+	return a
+}
+
+func TestStackKind(t *testing.T) {
+	ic := filenames.NewInfoCollector(time.UTC, filetypes.DefaultSupportedMedia)
+	d := time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC)
+	ctx := context.Background()
+
+	pair := []*assets.Asset{stackAsset(ic, "1", "IMG_0001.CR3", d), stackAsset(ic, "2", "IMG_0001.JPG", d)}
+	if kind, ok := stackKind(ctx, pair, burst.Group, false); !ok || kind != assets.GroupByRawJpg {
+		t.Errorf("raw/jpeg stack: %v %v", kind, ok)
+	}
+
+	mixed := []*assets.Asset{stackAsset(ic, "1", "IMG_0001.JPG", d), stackAsset(ic, "2", "DSC_0456.JPG", d.Add(time.Hour))}
+	if _, ok := stackKind(ctx, mixed, burst.Group, false); ok {
+		t.Error("the stack of unrelated assets is not detected")
+	}
+
+	// frames 2 seconds apart are a burst only with a longer --burst-window
+	frames := []*assets.Asset{stackAsset(ic, "1", "DSC_0001.JPG", d), stackAsset(ic, "2", "DSC_0002.JPG", d.Add(2*time.Second))}
+	if _, ok := stackKind(ctx, frames, burst.Group, false); ok {
+		t.Error("the frames are too far apart for the default burst detection")
+	}
+	window := burst.Detector{Window: 3 * time.Second, SameCamera: true}
+	if kind, ok := stackKind(ctx, frames, window.Group, false); !ok || kind != assets.GroupByBurst {
+		t.Errorf("burst stack with a window: %v %v", kind, ok)
+	}
+}
+
+func TestChooseCover(t *testing.T) {
+	ic := filenames.NewInfoCollector(time.UTC, filetypes.DefaultSupportedMedia)
+	d := time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC)
+	jpg := stackAsset(ic, "1", "IMG_0001.JPG", d)
+	jpg.Width, jpg.Height = 4032, 3024
+	raw := stackAsset(ic, "2", "IMG_0001.CR3", d)
+	raw.Width, raw.Height = 6000, 4000
+	raw.Favorite = true
+	list := []*assets.Asset{jpg, raw}
+
+	tcs := []struct {
+		policy CoverPolicy
+		want   int
+	}{
+		{CoverRaw, 1},
+		{CoverJPEG, 0},
+		{CoverResolution, 1},
+		{CoverFavorite, 1},
+	}
+	for _, tc := range tcs {
+		if got := chooseCover(list, tc.policy, 0); got != tc.want {
+			t.Errorf("chooseCover(%s) = %d, want %d", tc.policy, got, tc.want)
+		}
+	}
+	if got := chooseCover(list[:1], CoverRaw, 0); got != 0 {
+		t.Errorf("without RAW file the cover is kept, got %d", got)
+	}
+}
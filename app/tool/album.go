@@ -0,0 +1,488 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/spf13/cobra"
+)
+
+// AlbumCmd holds the state shared by the album sub-commands
+type AlbumCmd struct {
+	serverCmd
+}
+
+func NewAlbumCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "album",
+		Short: "Manage the albums of the server",
+	}
+
+	o := &AlbumCmd{}
+	o.registerFlags(cmd.PersistentFlags(), "albums")
+
+	cmd.AddCommand(
+		o.newDeleteCommand(a),
+		o.newRenameCommand(a),
+		o.newMergeCommand(a),
+		o.newSplitCommand(a),
+		o.newPruneCommand(a),
+		o.newDedupeCommand(a),
+	)
+	return cmd
+}
+
+// albums returns the user's albums, the albums shared by others can't be changed
+func (ac *AlbumCmd) albums(ctx context.Context) ([]immich.AlbumSimplified, error) {
+	albums, err := ac.client.Immich.GetAllAlbums(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get the albums list: %w", err)
+	}
+	var r []immich.AlbumSimplified
+	for _, al := range albums {
+		if al.OwnerID == "" || al.OwnerID == ac.client.User.ID {
+			r = append(r, al)
+		}
+	}
+	return r, nil
+}
+
+// albumsNamed returns the albums having the name, the earliest first
+func albumsNamed(albums []immich.AlbumSimplified, name string) []immich.AlbumSimplified {
+	var r []immich.AlbumSimplified
+	for _, al := range albums {
+		if al.AlbumName == name {
+			r = append(r, al)
+		}
+	}
+	sortAlbums(r)
+	return r
+}
+
+// sortAlbums sorts the albums by creation date, then by ID
+func sortAlbums(albums []immich.AlbumSimplified) {
+	slices.SortFunc(albums, func(a, b immich.AlbumSimplified) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+func printAlbums(out io.Writer, action string, albums []immich.AlbumSimplified) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, al := range albums {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d assets\n", action, al.ID, al.AlbumName, al.AssetCount)
+	}
+	return w.Flush()
+}
+
+func (ac *AlbumCmd) newDeleteCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <pattern>",
+		Short: "Delete the albums whose name matches a regular expression, the assets are kept",
+		Args:  cobra.ExactArgs(1),
+	}
+	cmd.RunE = ac.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return fmt.Errorf("album pattern %q can't be parsed: %w", args[0], err)
+		}
+		albums, err := ac.albums(ctx)
+		if err != nil {
+			return err
+		}
+		var matched []immich.AlbumSimplified
+		for _, al := range albums {
+			if re.MatchString(al.AlbumName) {
+				matched = append(matched, al)
+			}
+		}
+		slices.SortFunc(matched, func(a, b immich.AlbumSimplified) int { return cmp.Compare(a.AlbumName, b.AlbumName) })
+		return ac.deleteAlbums(ctx, a, out, matched)
+	})
+	return cmd
+}
+
+// deleteAlbums lists the albums and deletes them after confirmation
+func (ac *AlbumCmd) deleteAlbums(ctx context.Context, a *app.Application, out io.Writer, albums []immich.AlbumSimplified) error {
+	if len(albums) == 0 {
+		a.Log().Message("no album to delete")
+		return nil
+	}
+	if err := printAlbums(out, "delete", albums); err != nil {
+		return err
+	}
+	if ok, err := ac.confirm(ctx); err != nil || !ok {
+		return err
+	}
+	var errs error
+	deleted := 0
+	for _, al := range albums {
+		if err := ac.client.Immich.DeleteAlbum(ctx, al.ID); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("can't delete the album %q: %w", al.AlbumName, err))
+			continue
+		}
+		deleted++
+	}
+	a.Log().Message("%d albums deleted", deleted)
+	return errs
+}
+
+func (ac *AlbumCmd) newRenameCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rename <name> <new name>",
+		Short: "Rename an album",
+		Args:  cobra.ExactArgs(2),
+	}
+	cmd.RunE = ac.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		albums, err := ac.albums(ctx)
+		if err != nil {
+			return err
+		}
+		named := albumsNamed(albums, args[0])
+		switch len(named) {
+		case 0:
+			return fmt.Errorf("album %q not found", args[0])
+		case 1:
+		default:
+			return fmt.Errorf("%d albums are named %q, use the dedupe command first", len(named), args[0])
+		}
+		al := named[0]
+		fmt.Fprintf(out, "rename %q to %q\n", al.AlbumName, args[1])
+		if ok, err := ac.confirm(ctx); err != nil || !ok {
+			return err
+		}
+		return ac.client.Immich.UpdateAlbum(ctx, al.ID, args[1], al.Description)
+	})
+	return cmd
+}
+
+func (ac *AlbumCmd) newMergeCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge <target> <source>...",
+		Short: "Merge albums into the target album",
+		Long: `Add the assets of the source albums to the target album, then delete the source albums.
+The target album is created when missing. It gets the description of the earliest album.`,
+		Args: cobra.MinimumNArgs(2),
+	}
+	cmd.RunE = ac.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		albums, err := ac.albums(ctx)
+		if err != nil {
+			return err
+		}
+		var target *immich.AlbumSimplified
+		if named := albumsNamed(albums, args[0]); len(named) > 0 {
+			target = &named[0]
+		}
+		var sources []immich.AlbumSimplified
+		for _, name := range args[1:] {
+			named := albumsNamed(albums, name)
+			if len(named) == 0 {
+				return fmt.Errorf("album %q not found", name)
+			}
+			for _, al := range named {
+				if target != nil && al.ID == target.ID {
+					continue
+				}
+				sources = append(sources, al)
+			}
+		}
+		return ac.mergeAlbums(ctx, a, out, args[0], target, sources)
+	})
+	return cmd
+}
+
+// mergedDescription returns the description of the earliest album having one
+func mergedDescription(albums []immich.AlbumSimplified) string {
+	albums = slices.Clone(albums)
+	sortAlbums(albums)
+	for _, al := range albums {
+		if al.Description != "" {
+			return al.Description
+		}
+	}
+	return ""
+}
+
+// mergeAlbums moves the assets of the sources into the target, created when nil, and deletes the sources
+func (ac *AlbumCmd) mergeAlbums(ctx context.Context, a *app.Application, out io.Writer, name string, target *immich.AlbumSimplified, sources []immich.AlbumSimplified) error {
+	if len(sources) == 0 {
+		a.Log().Message("no album to merge into %q", name)
+		return nil
+	}
+	all := slices.Clone(sources)
+	if target != nil {
+		all = append(all, *target)
+	}
+	description := mergedDescription(all)
+
+	if target != nil {
+		fmt.Fprintf(out, "merge into %s %q\n", target.ID, name)
+	} else {
+		fmt.Fprintf(out, "merge into the new album %q\n", name)
+	}
+	if err := printAlbums(out, "  merge and delete", sources); err != nil {
+		return err
+	}
+	if ok, err := ac.confirm(ctx); err != nil || !ok {
+		return err
+	}
+
+	var ids []string
+	seen := map[string]bool{}
+	for _, al := range sources {
+		content, err := ac.client.Immich.GetAlbumInfo(ctx, al.ID, false)
+		if err != nil {
+			return fmt.Errorf("can't get the assets of the album %q: %w", al.AlbumName, err)
+		}
+		for _, ia := range content.Assets {
+			if !seen[ia.ID] {
+				seen[ia.ID] = true
+				ids = append(ids, ia.ID)
+			}
+		}
+	}
+
+	if target == nil {
+		if _, err := ac.client.Immich.CreateAlbum(ctx, name, description, ids); err != nil {
+			return fmt.Errorf("can't create the album %q: %w", name, err)
+		}
+		a.Log().Message("album %q created with %d assets", name, len(ids))
+	} else {
+		if len(ids) > 0 {
+			if _, err := ac.client.Immich.AddAssetToAlbum(ctx, target.ID, ids); err != nil {
+				return fmt.Errorf("can't add the assets to the album %q: %w", name, err)
+			}
+		}
+		if description != target.Description {
+			if err := ac.client.Immich.UpdateAlbum(ctx, target.ID, target.AlbumName, description); err != nil {
+				return fmt.Errorf("can't update the description of the album %q: %w", name, err)
+			}
+		}
+		a.Log().Message("%d assets added to the album %q", len(ids), name)
+	}
+
+	var errs error
+	for _, al := range sources {
+		if err := ac.client.Immich.DeleteAlbum(ctx, al.ID); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("can't delete the album %q: %w", al.AlbumName, err))
+		}
+	}
+	return errs
+}
+
+const (
+	splitByGap   = "gap"
+	splitByMonth = "month"
+)
+
+// albumPart is a part of a split album
+type albumPart struct {
+	Name string
+	IDs  []string
+}
+
+// splitAssets groups the assets by month, or by series separated by a gap, in date order.
+// The parts are named after the album and the first date of the part.
+// The assets without date are put in a last part named after the album and "undated".
+func splitAssets(name string, list []*immich.Asset, by string, gap time.Duration) []albumPart {
+	var undated []string
+	list = slices.DeleteFunc(slices.Clone(list), func(ia *immich.Asset) bool {
+		if ia.ExifInfo.DateTimeOriginal.IsZero() {
+			undated = append(undated, ia.ID)
+			return true
+		}
+		return false
+	})
+	slices.SortStableFunc(list, func(a, b *immich.Asset) int {
+		return a.ExifInfo.DateTimeOriginal.Compare(b.ExifInfo.DateTimeOriginal.Time)
+	})
+
+	var parts []albumPart
+	var last time.Time
+	for i, ia := range list {
+		d := ia.ExifInfo.DateTimeOriginal.Time
+		newPart := i == 0
+		switch by {
+		case splitByMonth:
+			newPart = newPart || d.Year() != last.Year() || d.Month() != last.Month()
+		default:
+			newPart = newPart || d.Sub(last) > gap
+		}
+		if newPart {
+			suffix := d.Format("2006-01-02")
+			if by == splitByMonth {
+				suffix = d.Format("2006-01")
+			}
+			parts = append(parts, albumPart{Name: name + " " + suffix})
+		}
+		parts[len(parts)-1].IDs = append(parts[len(parts)-1].IDs, ia.ID)
+		last = d
+	}
+
+	// two series starting the same day get a number
+	count := map[string]int{}
+	for _, p := range parts {
+		count[p.Name]++
+	}
+	n := map[string]int{}
+	for i, p := range parts {
+		if count[p.Name] > 1 {
+			n[p.Name]++
+			parts[i].Name = fmt.Sprintf("%s (%d)", p.Name, n[p.Name])
+		}
+	}
+	if len(undated) > 0 {
+		parts = append(parts, albumPart{Name: name + " undated", IDs: undated})
+	}
+	return parts
+}
+
+func (ac *AlbumCmd) newSplitCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "split <album> [flags]",
+		Short: "Split an album by month or by gaps between the capture dates",
+		Long: `Create an album for each month, or for each series of assets separated by a gap, named after the album and the first date.
+The original album is deleted, unless --keep is given.`,
+		Args: cobra.ExactArgs(1),
+	}
+	var by string
+	var gap time.Duration
+	var keep bool
+	cmd.Flags().StringVar(&by, "by", splitByGap, "Split the album by gap or by month")
+	cmd.Flags().DurationVar(&gap, "gap", 24*time.Hour, "Minimum gap between the capture dates of two parts")
+	cmd.Flags().BoolVar(&keep, "keep", false, "Keep the original album")
+
+	cmd.RunE = ac.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		if by != splitByGap && by != splitByMonth {
+			return fmt.Errorf("invalid value %q for --by, expected gap or month", by)
+		}
+		albums, err := ac.albums(ctx)
+		if err != nil {
+			return err
+		}
+		named := albumsNamed(albums, args[0])
+		switch len(named) {
+		case 0:
+			return fmt.Errorf("album %q not found", args[0])
+		case 1:
+		default:
+			return fmt.Errorf("%d albums are named %q, use the dedupe command first", len(named), args[0])
+		}
+		al := named[0]
+		content, err := ac.client.Immich.GetAlbumInfo(ctx, al.ID, false)
+		if err != nil {
+			return fmt.Errorf("can't get the assets of the album %q: %w", al.AlbumName, err)
+		}
+		parts := splitAssets(al.AlbumName, content.Assets, by, gap)
+		if len(parts) < 2 {
+			a.Log().Message("the album %q can't be split", al.AlbumName)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, p := range parts {
+			fmt.Fprintf(w, "create\t%s\t%d assets\n", p.Name, len(p.IDs))
+		}
+		if !keep {
+			fmt.Fprintf(w, "delete\t%s\t%d assets\n", al.AlbumName, len(content.Assets))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := ac.confirm(ctx); err != nil || !ok {
+			return err
+		}
+
+		for _, p := range parts {
+			if _, err := ac.client.Immich.CreateAlbum(ctx, p.Name, al.Description, p.IDs); err != nil {
+				return fmt.Errorf("can't create the album %q: %w", p.Name, err)
+			}
+		}
+		a.Log().Message("%d albums created", len(parts))
+		if keep {
+			return nil
+		}
+		return ac.client.Immich.DeleteAlbum(ctx, al.ID)
+	})
+	return cmd
+}
+
+func (ac *AlbumCmd) newPruneCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete the empty albums",
+		Args:  cobra.NoArgs,
+	}
+	cmd.RunE = ac.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		albums, err := ac.albums(ctx)
+		if err != nil {
+			return err
+		}
+		var empty []immich.AlbumSimplified
+		for _, al := range albums {
+			if al.AssetCount == 0 {
+				empty = append(empty, al)
+			}
+		}
+		slices.SortFunc(empty, func(a, b immich.AlbumSimplified) int { return cmp.Compare(a.AlbumName, b.AlbumName) })
+		return ac.deleteAlbums(ctx, a, out, empty)
+	})
+	return cmd
+}
+
+// sameTitleAlbums returns the groups of albums having the same title, the earliest album first
+func sameTitleAlbums(albums []immich.AlbumSimplified) [][]immich.AlbumSimplified {
+	byName := map[string][]immich.AlbumSimplified{}
+	for _, al := range albums {
+		byName[al.AlbumName] = append(byName[al.AlbumName], al)
+	}
+	var groups [][]immich.AlbumSimplified
+	for _, g := range byName {
+		if len(g) < 2 {
+			continue
+		}
+		sortAlbums(g)
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b []immich.AlbumSimplified) int {
+		return cmp.Compare(a[0].AlbumName, b[0].AlbumName)
+	})
+	return groups
+}
+
+func (ac *AlbumCmd) newDedupeCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Merge the albums having the same title into the earliest one",
+		Args:  cobra.NoArgs,
+	}
+	cmd.RunE = ac.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		albums, err := ac.albums(ctx)
+		if err != nil {
+			return err
+		}
+		groups := sameTitleAlbums(albums)
+		a.Log().Message("%d groups of albums with the same title found", len(groups))
+
+		var errs error
+		for _, g := range groups {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			errs = errors.Join(errs, ac.mergeAlbums(ctx, a, out, g[0].AlbumName, &g[0], g[1:]))
+		}
+		return errs
+	})
+	return cmd
+}
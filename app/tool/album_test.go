@@ -0,0 +1,88 @@
+package tool
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+)
+
+func TestSplitAssets(t *testing.T) {
+	d := time.Date(2023, 6, 30, 10, 0, 0, 0, time.UTC)
+	asset := func(id string, date time.Time) *immich.Asset {
+		ia := &immich.Asset{ID: id}
+		ia.ExifInfo.DateTimeOriginal.Time = date
+		return ia
+	}
+	list := []*immich.Asset{
+		asset("3", d.Add(30*time.Hour)),
+		asset("1", d),
+		asset("2", d.Add(3*time.Hour)),
+		asset("4", d.Add(31*time.Hour)),
+		asset("5", d.Add(60*time.Hour)),
+		asset("6", time.Time{}),
+	}
+
+	tcs := []struct {
+		name string
+		by   string
+		want []albumPart
+	}{
+		{
+			name: "gap",
+			by:   splitByGap,
+			want: []albumPart{
+				{Name: "Trip 2023-06-30", IDs: []string{"1", "2"}},
+				{Name: "Trip 2023-07-01", IDs: []string{"3", "4"}},
+				{Name: "Trip 2023-07-02", IDs: []string{"5"}},
+				{Name: "Trip undated", IDs: []string{"6"}},
+			},
+		},
+		{
+			name: "month",
+			by:   splitByMonth,
+			want: []albumPart{
+				{Name: "Trip 2023-06", IDs: []string{"1", "2"}},
+				{Name: "Trip 2023-07", IDs: []string{"3", "4", "5"}},
+				{Name: "Trip undated", IDs: []string{"6"}},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := splitAssets("Trip", list, tc.by, 24*time.Hour)
+			if !slices.EqualFunc(got, tc.want, func(a, b albumPart) bool { return a.Name == b.Name && slices.Equal(a.IDs, b.IDs) }) {
+				t.Errorf("splitAssets() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// two series on the same day
+	got := splitAssets("Day", []*immich.Asset{asset("1", d), asset("2", d.Add(5*time.Hour))}, splitByGap, time.Hour)
+	if len(got) != 2 || got[0].Name != "Day 2023-06-30 (1)" || got[1].Name != "Day 2023-06-30 (2)" {
+		t.Errorf("splitAssets() = %v", got)
+	}
+}
+
+func TestSameTitleAlbums(t *testing.T) {
+	d := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	album := func(id, name, description string, created time.Time) immich.AlbumSimplified {
+		al := immich.AlbumSimplified{ID: id, AlbumName: name, Description: description}
+		al.CreatedAt.Time = created
+		return al
+	}
+	albums := []immich.AlbumSimplified{
+		album("a", "Holidays", "", d.Add(time.Hour)),
+		album("b", "Family", "", d),
+		album("c", "Holidays", "Summer", d.Add(2*time.Hour)),
+		album("d", "Holidays", "", d),
+	}
+	groups := sameTitleAlbums(albums)
+	if len(groups) != 1 || len(groups[0]) != 3 || groups[0][0].ID != "d" {
+		t.Fatalf("sameTitleAlbums() = %v", groups)
+	}
+	if desc := mergedDescription(groups[0]); desc != "Summer" {
+		t.Errorf("mergedDescription() = %q, want Summer", desc)
+	}
+}
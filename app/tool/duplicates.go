@@ -0,0 +1,356 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/quality"
+	"github.com/simulot/immich-go/internal/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// DuplicatesCmd finds the duplicates of the server, keeps the best copy and moves the others to the trash
+type DuplicatesCmd struct {
+	// CLI flags
+	By              string             // grouping of the duplicates: name or checksum
+	DateRange       cliflags.DateRange // only the assets taken in the range
+	IgnoreExtension bool               // IMG_1234.HEIC and IMG_1234.JPG are duplicates
+	IgnoreTZErrors  bool               // the same photo with a capture date shifted by hours
+	Prefer          quality.Policy     // choose the copy to keep
+
+	serverCmd
+}
+
+const (
+	duplicatesByName     = "name"
+	duplicatesByChecksum = "checksum"
+)
+
+func (dc *DuplicatesCmd) RegisterFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&dc.By, "by", duplicatesByName, "Grouping of the duplicates: name (same capture date and file name) or checksum (same content, across owners)")
+	flags.Var(&dc.DateRange, "date-range", "Only consider the server assets taken in the date range")
+	flags.BoolVar(&dc.IgnoreExtension, "ignore-extension", false, "Ignore the extensions of the photo export formats (JPEG, HEIC...) when grouping by name")
+	flags.BoolVar(&dc.IgnoreTZErrors, "ignore-tz-errors", false, "Group by name the capture dates differing by a time zone shift, to tolerate time zone errors")
+	flags.Var(&dc.Prefer, "prefer", "Policy choosing the copy to keep: size, quality, or a list of criteria among resolution, format, bitdepth, exif, size")
+}
+
+func NewDuplicatesCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "duplicates [flags]",
+		Short: "Remove the duplicates of the server",
+		Long: `Group the server's assets by capture date and file name, or by checksum, and keep the best copy of each group.
+The albums, tags, favorite and rating of the other copies are given to the kept one, then the other copies are moved to the trash.`,
+		Args: cobra.NoArgs,
+	}
+
+	o := &DuplicatesCmd{}
+	o.RegisterFlags(cmd.Flags())
+	o.registerFlags(cmd.Flags(), "duplicates")
+
+	run := o.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		o.DateRange.SetTZ(a.GetTZ())
+		return o.run(ctx, a, out)
+	})
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if o.By != duplicatesByName && o.By != duplicatesByChecksum {
+			return fmt.Errorf("invalid value %q for --by, expected name or checksum", o.By)
+		}
+		return run(cmd, args)
+	}
+	return cmd
+}
+
+// duplicateKey identifies the copies of an asset
+type duplicateKey struct {
+	Date     time.Time
+	Name     string
+	Type     string
+	Owner    string
+	Checksum string
+}
+
+// key returns the key of the asset for the grouping.
+// The assets without capture date can't be grouped by name.
+func (dc *DuplicatesCmd) key(ia *immich.Asset) (duplicateKey, bool) {
+	if dc.By == duplicatesByChecksum {
+		return duplicateKey{Checksum: ia.Checksum}, true
+	}
+	if ia.ExifInfo.DateTimeOriginal.IsZero() {
+		return duplicateKey{}, false
+	}
+	var d time.Time
+	if !dc.IgnoreTZErrors {
+		// with --ignore-tz-errors, the dates are compared by tzClusters
+		d = ia.ExifInfo.DateTimeOriginal.Time.Round(time.Minute)
+	}
+	name := strings.ToUpper(ia.OriginalFileName)
+	if dc.IgnoreExtension {
+		// like the upload, only the export formats of a photo are interchangeable
+		if ext := path.Ext(name); quality.Interchangeable(strings.TrimPrefix(strings.ToLower(ext), ".")) {
+			name = strings.TrimSuffix(name, ext)
+		}
+	}
+	return duplicateKey{Date: d, Name: name, Type: ia.Type, Owner: ia.OwnerID}, true
+}
+
+// maxTZShift is the largest difference between two time zones
+const maxTZShift = 26 * time.Hour
+
+// tzShifted tells if the capture dates differ by a time zone shift: a multiple of 15 minutes
+func tzShifted(a, b time.Time) bool {
+	d := a.Round(time.Minute).Sub(b.Round(time.Minute))
+	if d < 0 {
+		d = -d
+	}
+	return d <= maxTZShift && d%(15*time.Minute) == 0
+}
+
+// tzClusters splits the copies having the same name into the ones whose capture dates differ by a time zone shift
+func tzClusters(g []*immich.Asset) [][]*immich.Asset {
+	var clusters [][]*immich.Asset
+next:
+	for _, ia := range g {
+		for i, c := range clusters {
+			if tzShifted(c[0].ExifInfo.DateTimeOriginal.Time, ia.ExifInfo.DateTimeOriginal.Time) {
+				clusters[i] = append(c, ia)
+				continue next
+			}
+		}
+		clusters = append(clusters, []*immich.Asset{ia})
+	}
+	return clusters
+}
+
+// groupDuplicates returns the groups of copies, sorted by date and name
+func (dc *DuplicatesCmd) groupDuplicates(list []*immich.Asset) [][]*immich.Asset {
+	byKey := map[duplicateKey][]*immich.Asset{}
+	var keys []duplicateKey
+	for _, ia := range list {
+		k, ok := dc.key(ia)
+		if !ok {
+			continue
+		}
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], ia)
+	}
+
+	var groups [][]*immich.Asset
+	for _, k := range keys {
+		clusters := [][]*immich.Asset{byKey[k]}
+		if dc.By == duplicatesByName && dc.IgnoreTZErrors {
+			clusters = tzClusters(byKey[k])
+		}
+		for _, g := range clusters {
+			if len(g) < 2 {
+				continue
+			}
+			slices.SortFunc(g, func(a, b *immich.Asset) int { return cmp.Compare(a.ID, b.ID) })
+			groups = append(groups, g)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []*immich.Asset) int {
+		if c := a[0].ExifInfo.DateTimeOriginal.Compare(b[0].ExifInfo.DateTimeOriginal.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(strings.ToUpper(a[0].OriginalFileName), strings.ToUpper(b[0].OriginalFileName))
+	})
+	return groups
+}
+
+// duplicateChoice is the decision for a group of copies
+type duplicateChoice struct {
+	keeper  *immich.Asset
+	trashed []*immich.Asset
+	reasons map[string]string // why the copy is trashed, by ID
+	others  []*immich.Asset   // copies of other owners, never changed
+}
+
+// chooseKeeper picks the best copy of the user's assets with the policy
+func chooseKeeper(g []*immich.Asset, p *quality.Policy, userID string) duplicateChoice {
+	var c duplicateChoice
+	var owned []*immich.Asset
+	for _, ia := range g {
+		if ia.OwnerID != "" && ia.OwnerID != userID {
+			c.others = append(c.others, ia)
+			continue
+		}
+		owned = append(owned, ia)
+	}
+	if len(owned) == 0 {
+		return c
+	}
+	info := func(ia *immich.Asset) quality.Info {
+		return quality.Of(ia.AsAsset(), quality.ServerSource, false)
+	}
+	c.keeper = owned[0]
+	for _, ia := range owned[1:] {
+		if r, _ := p.Compare(info(ia), info(c.keeper)); r > 0 {
+			c.keeper = ia
+		}
+	}
+	c.reasons = map[string]string{}
+	for _, ia := range owned {
+		if ia == c.keeper {
+			continue
+		}
+		c.trashed = append(c.trashed, ia)
+		_, c.reasons[ia.ID] = p.Compare(info(c.keeper), info(ia))
+	}
+	return c
+}
+
+// mergedUpdate returns the favorite and the rating of the copies missing on the keeper
+func mergedUpdate(keeper *immich.Asset, trashed []*immich.Asset) (immich.UpdAssetField, bool) {
+	var u immich.UpdAssetField
+	changed := false
+	rating := keeper.Rating
+	for _, ia := range trashed {
+		if ia.IsFavorite && !keeper.IsFavorite {
+			u.IsFavorite = true
+			changed = true
+		}
+		if ia.Rating > rating {
+			rating = ia.Rating
+			u.Rating = &rating
+			changed = true
+		}
+	}
+	return u, changed
+}
+
+func (dc *DuplicatesCmd) run(ctx context.Context, a *app.Application, out io.Writer) error {
+	log := a.Log()
+
+	var mu sync.Mutex
+	var list []*immich.Asset
+	err := dc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithExif().WithDateRange(dc.DateRange), func(ia *immich.Asset) error {
+		if ia.IsTrashed {
+			return nil
+		}
+		mu.Lock()
+		list = append(list, ia)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Message("%d server assets read", len(list))
+
+	groups := dc.groupDuplicates(list)
+	log.Message("%d groups of duplicates found", len(groups))
+
+	trashed, failed := 0, 0
+	for i, g := range groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c := chooseKeeper(g, &dc.Prefer, dc.client.User.ID)
+		if len(c.trashed) == 0 {
+			// the copies of other owners can't be changed
+			continue
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Group %d/%d: %s taken on %s\n", i+1, len(groups), c.keeper.OriginalFileName, c.keeper.ExifInfo.DateTimeOriginal.Format(time.DateTime))
+		printCopy := func(action string, ia *immich.Asset, reason string) {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%dx%d\t%s\t%s\n", action, ia.ID, ia.OriginalFileName, ia.ExifInfo.ExifImageWidth, ia.ExifInfo.ExifImageHeight, ui.FormatBytes(ia.ExifInfo.FileSizeInByte), reason)
+		}
+		printCopy("keep", c.keeper, "")
+		for _, ia := range c.trashed {
+			printCopy("trash", ia, c.reasons[ia.ID])
+		}
+		for _, ia := range c.others {
+			printCopy("other owner", ia, "")
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		ok, err := dc.confirm(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := dc.merge(ctx, c); err != nil {
+			log.Error("can't merge the duplicates, they are kept", "file", c.keeper.OriginalFileName, "err", err)
+			failed++
+			continue
+		}
+		ids := make([]string, 0, len(c.trashed))
+		for _, ia := range c.trashed {
+			ids = append(ids, ia.ID)
+		}
+		if err := dc.client.Immich.DeleteAssets(ctx, ids, false); err != nil {
+			log.Error("can't trash the duplicates", "file", c.keeper.OriginalFileName, "err", err)
+			failed++
+			continue
+		}
+		trashed += len(ids)
+	}
+
+	log.Message("%d duplicates moved to the trash", trashed)
+	if failed > 0 {
+		return fmt.Errorf("%d groups of duplicates not cleaned", failed)
+	}
+	return nil
+}
+
+// merge gives the albums, the tags, the favorite and the rating of the trashed copies to the keeper
+func (dc *DuplicatesCmd) merge(ctx context.Context, c duplicateChoice) error {
+	var errs error
+	albums := map[string]bool{}
+	tags := map[string]bool{}
+	for _, ia := range c.trashed {
+		r, err := dc.client.Immich.GetAssetAlbums(ctx, ia.ID)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		for _, al := range r {
+			albums[al.ID] = true
+		}
+		info, err := dc.client.Immich.GetAssetInfo(ctx, ia.ID)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		for _, t := range info.Tags {
+			tags[t.ID] = true
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+
+	for id := range albums {
+		_, err := dc.client.Immich.AddAssetToAlbum(ctx, id, []string{c.keeper.ID})
+		errs = errors.Join(errs, err)
+	}
+	for id := range tags {
+		_, err := dc.client.Immich.TagAssets(ctx, id, []string{c.keeper.ID})
+		errs = errors.Join(errs, err)
+	}
+	if u, ok := mergedUpdate(c.keeper, c.trashed); ok {
+		_, err := dc.client.Immich.UpdateAsset(ctx, c.keeper.ID, u)
+		errs = errors.Join(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,93 @@
+package tool
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/quality"
+)
+
+func duplicateAsset(id, name, owner, checksum string, date time.Time, size int64, w, h int) *immich.Asset {
+	ia := &immich.Asset{ID: id, OriginalFileName: name, OwnerID: owner, Checksum: checksum, Type: "IMAGE"}
+	ia.ExifInfo.DateTimeOriginal.Time = date
+	ia.ExifInfo.FileSizeInByte = size
+	ia.ExifInfo.ExifImageWidth = w
+	ia.ExifInfo.ExifImageHeight = h
+	return ia
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	d := time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC)
+	list := []*immich.Asset{
+		duplicateAsset("1", "IMG_1.jpg", "me", "c1", d, 100, 0, 0),
+		duplicateAsset("2", "img_1.JPG", "me", "c2", d.Add(10*time.Second), 200, 0, 0),
+		duplicateAsset("3", "IMG_1.heic", "me", "c3", d.Add(2*time.Hour), 150, 0, 0),
+		duplicateAsset("4", "IMG_1.jpg", "other", "c1", d, 100, 0, 0),
+		duplicateAsset("5", "IMG_2.jpg", "me", "c5", d, 100, 0, 0),
+		duplicateAsset("6", "IMG_3.jpg", "me", "c6", d.Add(-11*time.Hour), 100, 0, 0),
+		duplicateAsset("7", "IMG_3.jpg", "me", "c7", d.Add(3*time.Hour), 100, 0, 0),
+		duplicateAsset("8", "IMG_4.jpg", "me", "c8", time.Time{}, 100, 0, 0),
+		duplicateAsset("9", "IMG_4.jpg", "me", "c9", time.Time{}, 100, 0, 0),
+		duplicateAsset("10", "IMG_2.dng", "me", "c10", d, 100, 0, 0),
+	}
+
+	count := func(dc *DuplicatesCmd) []int {
+		var sizes []int
+		for _, g := range dc.groupDuplicates(list) {
+			sizes = append(sizes, len(g))
+		}
+		return sizes
+	}
+	tcs := []struct {
+		name string
+		dc   DuplicatesCmd
+		want []int
+	}{
+		{name: "by name", dc: DuplicatesCmd{By: duplicatesByName}, want: []int{2}},
+		{name: "ignore extension", dc: DuplicatesCmd{By: duplicatesByName, IgnoreExtension: true}, want: []int{2}},
+		{name: "ignore extension and tz", dc: DuplicatesCmd{By: duplicatesByName, IgnoreExtension: true, IgnoreTZErrors: true}, want: []int{2, 3}},
+		{name: "by checksum across owners", dc: DuplicatesCmd{By: duplicatesByChecksum}, want: []int{2}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := count(&tc.dc)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("groups = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChooseKeeper(t *testing.T) {
+	d := time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC)
+	original := duplicateAsset("1", "IMG_1.jpg", "me", "c1", d, 3_000_000, 4032, 3024)
+	original.Rating = 2
+	saver := duplicateAsset("2", "IMG_1.jpg", "me", "c2", d, 600_000, 2048, 1536)
+	saver.IsFavorite = true
+	saver.Rating = 4
+	screenshot := duplicateAsset("3", "IMG_1.jpg", "me", "c3", d, 4_000_000, 1170, 2532)
+	shared := duplicateAsset("4", "IMG_1.jpg", "other", "c1", d, 3_000_000, 4032, 3024)
+	g := []*immich.Asset{original, saver, screenshot, shared}
+
+	var size quality.Policy
+	if c := chooseKeeper(g, &size, "me"); c.keeper != screenshot {
+		t.Errorf("the size policy keeps the biggest file, got %s", c.keeper.ID)
+	}
+
+	var q quality.Policy
+	_ = q.Set("quality")
+	c := chooseKeeper(g, &q, "me")
+	if c.keeper != original || len(c.trashed) != 2 || len(c.others) != 1 {
+		t.Fatalf("keeper %s, trashed %d, others %d", c.keeper.ID, len(c.trashed), len(c.others))
+	}
+	if c.reasons[saver.ID] == "" {
+		t.Error("the reason is missing")
+	}
+
+	u, ok := mergedUpdate(c.keeper, c.trashed)
+	if !ok || !u.IsFavorite || u.Rating == nil || *u.Rating != 4 {
+		t.Errorf("mergedUpdate() = %+v, %v", u, ok)
+	}
+}
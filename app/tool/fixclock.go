@@ -0,0 +1,190 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/clockrules"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// FixClockCmd corrects the capture dates of the server's assets with the clock rules of the configuration
+type FixClockCmd struct {
+	// CLI flags
+	DateRange cliflags.DateRange
+	Tag       string // tag given to the corrected assets
+
+	serverCmd
+}
+
+func (fc *FixClockCmd) RegisterFlags(flags *pflag.FlagSet) {
+	flags.Var(&fc.DateRange, "date-range", "Only correct the assets taken in the date range")
+	flags.StringVar(&fc.Tag, "tag", "{immich-go}/clock-fixed", "Tag given to the corrected assets, tagged assets are never corrected twice (empty to disable)")
+}
+
+func NewFixClockCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fix-clock [flags]",
+		Short: "Correct the capture dates of the server's assets with the clock rules",
+		Long:  `Apply the clock-rules of the configuration file to the assets already on the server`,
+		Args:  cobra.NoArgs,
+	}
+
+	o := &FixClockCmd{}
+	o.RegisterFlags(cmd.Flags())
+	o.registerFlags(cmd.Flags(), "dates")
+
+	run := o.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		o.DateRange.SetTZ(a.GetTZ())
+		return o.run(ctx, a, out)
+	})
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(a.ClockRules) == 0 {
+			return errors.New("no clock-rules in the configuration file")
+		}
+		return run(cmd, args)
+	}
+	return cmd
+}
+
+type clockFix struct {
+	asset *immich.Asset
+	from  time.Time
+	to    time.Time
+}
+
+func (fc *FixClockCmd) run(ctx context.Context, a *app.Application, out io.Writer) error {
+	log := a.Log()
+
+	// Assets already corrected by a previous run
+	var tagID string
+	fixed := map[string]bool{}
+	if fc.Tag != "" {
+		tags, err := fc.client.Immich.GetAllTags(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range tags {
+			if t.Value == fc.Tag {
+				tagID = t.ID
+				break
+			}
+		}
+		if tagID != "" {
+			var mu sync.Mutex
+			err = fc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().WithTags(tagID), func(ia *immich.Asset) error {
+				mu.Lock()
+				fixed[ia.ID] = true
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var list []*immich.Asset
+	so := immich.SearchOptions().WithExif().WithDateRange(fc.DateRange)
+	err := fc.client.Immich.GetFilteredAssetsFn(ctx, so, func(ia *immich.Asset) error {
+		mu.Lock()
+		list = append(list, ia)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fixes := clockFixes(a.ClockRules, list, fixed)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tCAMERA\tCAMERA DATE\tCORRECTED DATE")
+	for _, f := range fixes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.asset.OriginalFileName, f.asset.ExifInfo.Make+" "+f.asset.ExifInfo.Model, formatDate(f.from), formatDate(f.to))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	log.Message("%d assets match the clock rules", len(fixes))
+	if len(fixes) == 0 {
+		return nil
+	}
+
+	ok, err := fc.confirm(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	var ids []string
+	for _, f := range fixes {
+		log.Info("Correcting the capture date", "file", f.asset.OriginalFileName, "camera", f.asset.ExifInfo.Make+" "+f.asset.ExifInfo.Model, "camera date", f.from, "corrected date", f.to)
+		_, err := fc.client.Immich.UpdateAsset(ctx, f.asset.ID, immich.UpdAssetField{DateTimeOriginal: f.to})
+		if err != nil {
+			log.Error("Can't update the asset", "file", f.asset.OriginalFileName, "error", err)
+			continue
+		}
+		ids = append(ids, f.asset.ID)
+	}
+
+	if fc.Tag != "" && len(ids) > 0 {
+		if tagID == "" {
+			tags, err := fc.client.Immich.UpsertTags(ctx, []string{fc.Tag})
+			if err != nil {
+				return err
+			}
+			tagID = tags[0].ID
+		}
+		for chunk := range slices.Chunk(ids, 1000) {
+			if _, err := fc.client.Immich.TagAssets(ctx, tagID, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Message("%d assets corrected out of %d matching the clock rules", len(ids), len(fixes))
+	return nil
+}
+
+// clockFixes returns the corrections of the assets matching the rules, sorted by camera date.
+// The trashed assets and the ones already fixed are left aside.
+func clockFixes(rules clockrules.Rules, list []*immich.Asset, fixed map[string]bool) []clockFix {
+	var fixes []clockFix
+	for _, ia := range list {
+		if ia.IsTrashed || fixed[ia.ID] {
+			continue
+		}
+		from := cameraDate(ia)
+		to, ok := rules.Fix(ia.ExifInfo.Make, ia.ExifInfo.Model, from)
+		if !ok {
+			continue
+		}
+		fixes = append(fixes, clockFix{asset: ia, from: from, to: to})
+	}
+	sort.Slice(fixes, func(i, j int) bool {
+		return fixes[i].from.Before(fixes[j].from)
+	})
+	return fixes
+}
+
+// cameraDate returns the date of the asset as shown by the camera clock.
+// The server gives the date in UTC, and the local date without time zone.
+func cameraDate(ia *immich.Asset) time.Time {
+	d := ia.ExifInfo.DateTimeOriginal.Time
+	if d.IsZero() || ia.LocalDateTime.IsZero() {
+		return d
+	}
+	wall := ia.LocalDateTime.UTC()
+	offset := wall.Sub(d).Round(time.Minute)
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.FixedZone("", int(offset.Seconds())))
+}
@@ -0,0 +1,48 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/clockrules"
+)
+
+func cameraAsset(id, cameraMake string, local time.Time) *immich.Asset {
+	ia := &immich.Asset{ID: id, OriginalFileName: id + ".jpg"}
+	ia.ExifInfo.Make = cameraMake
+	ia.ExifInfo.DateTimeOriginal.Time = local.UTC()
+	ia.LocalDateTime.Time = time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+	return ia
+}
+
+func TestClockFixes(t *testing.T) {
+	rules, err := clockrules.ParseConfig([]string{`make=Canon from=2012-05-01 to=2012-05-20 shift=+7h`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, _ := time.LoadLocation("Europe/Paris")
+	list := []*immich.Asset{
+		cameraAsset("late", "Canon", time.Date(2012, 5, 10, 10, 0, 0, 0, paris)),
+		cameraAsset("early", "Canon", time.Date(2012, 5, 2, 10, 0, 0, 0, paris)),
+		cameraAsset("other camera", "NIKON", time.Date(2012, 5, 2, 10, 0, 0, 0, paris)),
+		cameraAsset("out of the period", "Canon", time.Date(2012, 6, 2, 10, 0, 0, 0, paris)),
+		cameraAsset("trashed", "Canon", time.Date(2012, 5, 3, 10, 0, 0, 0, paris)),
+		cameraAsset("already fixed", "Canon", time.Date(2012, 5, 4, 10, 0, 0, 0, paris)),
+	}
+	list[4].IsTrashed = true
+
+	fixes := clockFixes(rules, list, map[string]bool{"already fixed": true})
+	var ids []string
+	for _, f := range fixes {
+		ids = append(ids, f.asset.ID)
+	}
+	if want := []string{"early", "late"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("clockFixes() = %v, want %v", ids, want)
+	}
+	// the camera clock is read in the time zone of the capture
+	if got, want := fixes[0].to, time.Date(2012, 5, 2, 17, 0, 0, 0, paris); !got.Equal(want) {
+		t.Errorf("corrected date = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,374 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	gp "github.com/simulot/immich-go/adapters/googlePhotos"
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/exif/sidecars/xmpsidecar"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// FixDatesCmd corrects the server's assets having a missing capture date, or the date of the upload or of the file
+type FixDatesCmd struct {
+	// CLI flags
+	DateRange cliflags.DateRange
+	Sidecars  []string // folders with the XMP sidecars and the takeout JSON files
+	Albums    bool     // use the dates of the neighbours in the albums
+	CSV       string   // file receiving the changes
+
+	serverCmd
+}
+
+func (fd *FixDatesCmd) RegisterFlags(flags *pflag.FlagSet) {
+	flags.Var(&fd.DateRange, "date-range", "Only consider the server assets taken in the date range")
+	flags.StringSliceVar(&fd.Sidecars, "sidecars", nil, "Folders with the XMP sidecars or the Google takeout JSON files of the assets (can be repeated)")
+	flags.BoolVar(&fd.Albums, "albums", true, "Use the dates of the neighbouring assets in the albums")
+	flags.StringVar(&fd.CSV, "csv", "", "Write the changes to a CSV file")
+}
+
+func NewFixDatesCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fix-dates [flags]",
+		Short: "Correct the missing or wrong capture dates of the server's assets",
+		Long: `Find the server's assets without capture date, or whose capture date is the date of the upload or of the file,
+and correct them with the date found in the file name, in a local sidecar or takeout JSON file, or with the dates of the neighbouring assets in their albums.`,
+		Args: cobra.NoArgs,
+	}
+
+	o := &FixDatesCmd{}
+	o.RegisterFlags(cmd.Flags())
+	o.registerFlags(cmd.Flags(), "dates")
+
+	cmd.RunE = o.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		o.DateRange.SetTZ(a.GetTZ())
+		return o.run(ctx, a, out)
+	})
+	return cmd
+}
+
+// Sources of the corrected dates, by priority
+const (
+	dateFromName    = "name"
+	dateFromSidecar = "sidecar"
+	dateFromAlbum   = "album"
+)
+
+// dateFix is the correction of an asset's date
+type dateFix struct {
+	asset  *immich.Asset
+	date   time.Time
+	source string
+	detail string
+}
+
+// dateTolerance is the difference under which two dates are the same
+const dateTolerance = time.Minute
+
+func sameDate(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -dateTolerance && d < dateTolerance
+}
+
+// suspiciousDate tells if the capture date of the asset has been invented by the server:
+// missing, or the date of the upload or of the file. The files of a camera keep their date,
+// the copies of a memory card have the capture date as file date.
+func suspiciousDate(ia *immich.Asset) (bool, string) {
+	d := ia.ExifInfo.DateTimeOriginal.Time
+	switch {
+	case d.IsZero():
+		return true, "no capture date"
+	case ia.ExifInfo.Make != "" || ia.ExifInfo.Model != "":
+		return false, ""
+	case !ia.CreatedAt.IsZero() && sameDate(d, ia.CreatedAt.Time):
+		return true, "date of the upload"
+	case !ia.FileModifiedAt.IsZero() && sameDate(d, ia.FileModifiedAt.Time):
+		return true, "date of the file"
+	}
+	return false, ""
+}
+
+// sidecarDates are the dates of the sidecars, by lower case file name and radical of the asset.
+// Several folders can have a sidecar for the same name.
+type sidecarDates map[string][]sidecarDate
+
+type sidecarDate struct {
+	date time.Time
+	file string
+}
+
+// readSidecars reads the dates of the XMP sidecars and of the takeout JSON files of the folders.
+// An XMP file describes the asset having its name without the .xmp extension, a JSON file the asset of its title.
+// The XMP dates without time offset are expressed in the time zone tz.
+func readSidecars(fsys fs.FS, root string, tz *time.Location) (sidecarDates, error) {
+	dates := sidecarDates{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(path.Ext(name))
+		if ext != ".xmp" && ext != ".json" {
+			return nil
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var key string
+		var date time.Time
+		switch ext {
+		case ".xmp":
+			var md assets.Metadata
+			if err := xmpsidecar.ReadXMP(f, &md, tz); err != nil {
+				return nil
+			}
+			key, date = strings.TrimSuffix(path.Base(name), path.Ext(name)), md.DateTaken
+		case ".json":
+			var gmd gp.GoogleMetaData
+			if err := json.NewDecoder(f).Decode(&gmd); err != nil || gmd.PhotoTakenTime == nil {
+				return nil
+			}
+			key, date = gmd.Title, gmd.AsMetadata(fshelper.FSName(fsys, name), false).DateTaken
+		}
+		if key != "" && !date.IsZero() {
+			key = strings.ToLower(key)
+			dates[key] = append(dates[key], sidecarDate{date: date, file: path.Join(root, name)})
+		}
+		return nil
+	})
+	return dates, err
+}
+
+// lookup returns the date of the sidecar of the file name, or of a sidecar named after its radical.
+// The name is ambiguous when the sidecars of several files with this name give different dates,
+// the asset can't be told from the others.
+func (sd sidecarDates) lookup(name string) (sidecarDate, bool) {
+	name = strings.ToLower(name)
+	l, ok := sd[name]
+	if !ok {
+		l, ok = sd[strings.TrimSuffix(name, path.Ext(name))]
+	}
+	if !ok {
+		return sidecarDate{}, false
+	}
+	for _, d := range l[1:] {
+		if !sameDate(d.date, l[0].date) {
+			return sidecarDate{}, false
+		}
+	}
+	return l[0], true
+}
+
+// albumNeighbourDate returns the date of the nearest asset of the album with a trusted date,
+// the album's assets being sorted by file name: IMG_0012 is taken between IMG_0011 and IMG_0013
+func albumNeighbourDate(album []*immich.Asset, id string) (time.Time, string, bool) {
+	sorted := slices.Clone(album)
+	slices.SortFunc(sorted, func(a, b *immich.Asset) int {
+		return cmp.Compare(strings.ToUpper(a.OriginalFileName), strings.ToUpper(b.OriginalFileName))
+	})
+	i := slices.IndexFunc(sorted, func(ia *immich.Asset) bool { return ia.ID == id })
+	if i < 0 {
+		return time.Time{}, "", false
+	}
+	for step := 1; step < len(sorted); step++ {
+		for _, j := range []int{i - step, i + step} {
+			if j < 0 || j >= len(sorted) {
+				continue
+			}
+			if bad, _ := suspiciousDate(sorted[j]); !bad {
+				return sorted[j].ExifInfo.DateTimeOriginal.Time, sorted[j].OriginalFileName, true
+			}
+		}
+	}
+	return time.Time{}, "", false
+}
+
+func (fd *FixDatesCmd) run(ctx context.Context, a *app.Application, out io.Writer) error {
+	log := a.Log()
+
+	var mu sync.Mutex
+	var suspicious []*immich.Asset
+	reasons := map[string]string{}
+	err := fd.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithExif().WithDateRange(fd.DateRange), func(ia *immich.Asset) error {
+		if ia.IsTrashed {
+			return nil
+		}
+		if bad, reason := suspiciousDate(ia); bad {
+			mu.Lock()
+			suspicious = append(suspicious, ia)
+			reasons[ia.ID] = reason
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Message("%d assets with a missing or wrong capture date", len(suspicious))
+	if len(suspicious) == 0 {
+		return nil
+	}
+
+	sidecars := sidecarDates{}
+	for _, dir := range fd.Sidecars {
+		dates, err := readSidecars(os.DirFS(dir), dir, a.GetTZ())
+		if err != nil {
+			return err
+		}
+		for k, d := range dates {
+			sidecars[k] = append(sidecars[k], d...)
+		}
+	}
+
+	ic := filenames.NewInfoCollector(a.GetTZ(), filetypes.DefaultSupportedMedia, a.NamePatterns...)
+	var fixes []dateFix
+	var unresolved []*immich.Asset
+	for _, ia := range suspicious {
+		if t := ic.GetInfo(ia.OriginalFileName).Taken; !t.IsZero() {
+			fixes = append(fixes, dateFix{asset: ia, date: t, source: dateFromName, detail: ia.OriginalFileName})
+			continue
+		}
+		if d, ok := sidecars.lookup(ia.OriginalFileName); ok {
+			fixes = append(fixes, dateFix{asset: ia, date: d.date, source: dateFromSidecar, detail: d.file})
+			continue
+		}
+		unresolved = append(unresolved, ia)
+	}
+	if fd.Albums && len(unresolved) > 0 {
+		found, err := fd.fromAlbums(ctx, unresolved)
+		if err != nil {
+			return err
+		}
+		fixes = append(fixes, found...)
+	}
+
+	// the dates already right are not changed
+	fixes = slices.DeleteFunc(fixes, func(f dateFix) bool {
+		return sameDate(f.date, f.asset.ExifInfo.DateTimeOriginal.Time)
+	})
+	slices.SortFunc(fixes, func(a, b dateFix) int {
+		return cmp.Compare(strings.ToUpper(a.asset.OriginalFileName), strings.ToUpper(b.asset.OriginalFileName))
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tCURRENT\tPROBLEM\tNEW\tSOURCE\tDETAIL")
+	for _, f := range fixes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", f.asset.OriginalFileName, formatDate(f.asset.ExifInfo.DateTimeOriginal.Time), reasons[f.asset.ID], formatDate(f.date), f.source, f.detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	log.Message("%d dates can be corrected, %d can't", len(fixes), len(suspicious)-len(fixes))
+
+	if fd.CSV != "" {
+		if err := writeDateFixes(fd.CSV, fixes, reasons); err != nil {
+			return err
+		}
+		log.Message("Changes written to %s", fd.CSV)
+	}
+	if len(fixes) == 0 {
+		return nil
+	}
+
+	ok, err := fd.confirm(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	failed := 0
+	for _, f := range fixes {
+		_, err := fd.client.Immich.UpdateAsset(ctx, f.asset.ID, immich.UpdAssetField{DateTimeOriginal: f.date})
+		if err != nil {
+			log.Error("Can't update the asset", "file", f.asset.OriginalFileName, "error", err)
+			failed++
+		}
+	}
+	log.Message("%d dates corrected", len(fixes)-failed)
+	if failed > 0 {
+		return fmt.Errorf("%d dates not corrected", failed)
+	}
+	return nil
+}
+
+// fromAlbums finds the dates of the assets with the neighbours in their albums
+func (fd *FixDatesCmd) fromAlbums(ctx context.Context, unresolved []*immich.Asset) ([]dateFix, error) {
+	wanted := map[string]*immich.Asset{}
+	for _, ia := range unresolved {
+		wanted[ia.ID] = ia
+	}
+	albums, err := fd.client.Immich.GetAllAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var fixes []dateFix
+	for _, al := range albums {
+		if len(wanted) == 0 {
+			break
+		}
+		content, err := fd.client.Immich.GetAlbumInfo(ctx, al.ID, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, aa := range content.Assets {
+			ia, ok := wanted[aa.ID]
+			if !ok {
+				continue
+			}
+			if d, neighbour, ok := albumNeighbourDate(content.Assets, aa.ID); ok {
+				fixes = append(fixes, dateFix{asset: ia, date: d, source: dateFromAlbum, detail: fmt.Sprintf("%s, near %s", al.AlbumName, neighbour)})
+				delete(wanted, aa.ID)
+			}
+		}
+	}
+	return fixes, nil
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateTime)
+}
+
+// writeDateFixes writes the changes to a CSV file
+func writeDateFixes(name string, fixes []dateFix, reasons map[string]string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"id", "file", "current", "problem", "new", "source", "detail"})
+	for _, fx := range fixes {
+		current := ""
+		if t := fx.asset.ExifInfo.DateTimeOriginal.Time; !t.IsZero() {
+			current = t.Format(time.RFC3339)
+		}
+		_ = w.Write([]string{fx.asset.ID, fx.asset.OriginalFileName, current, reasons[fx.asset.ID], fx.date.Format(time.RFC3339), fx.source, fx.detail})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
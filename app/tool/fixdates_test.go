@@ -0,0 +1,91 @@
+package tool
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+)
+
+func TestSuspiciousDate(t *testing.T) {
+	upload := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	taken := time.Date(2019, 7, 14, 9, 30, 0, 0, time.UTC)
+	asset := func(date, modified time.Time, model string) *immich.Asset {
+		ia := &immich.Asset{}
+		ia.CreatedAt.Time = upload
+		ia.FileModifiedAt.Time = modified
+		ia.ExifInfo.DateTimeOriginal.Time = date
+		ia.ExifInfo.Model = model
+		return ia
+	}
+
+	tcs := []struct {
+		name string
+		ia   *immich.Asset
+		want bool
+	}{
+		{"no date", asset(time.Time{}, taken, ""), true},
+		{"upload date", asset(upload.Add(20*time.Second), taken, ""), true},
+		{"file date", asset(taken, taken, ""), true},
+		{"camera file", asset(taken, taken, "EOS 5D"), false},
+		{"good date", asset(taken, upload, ""), false},
+	}
+	for _, tc := range tcs {
+		if got, _ := suspiciousDate(tc.ia); got != tc.want {
+			t.Errorf("%s: suspiciousDate() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReadSidecars(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/IMG_0001.JPG.xmp": {Data: []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/"><exif:DateTimeOriginal>2019-07-14T09:30:00Z</exif:DateTimeOriginal></rdf:Description>
+</rdf:RDF></x:xmpmeta>`)},
+		"takeout/PXL_1.jpg.supplemental-metadata.json": {Data: []byte(`{"title":"Scan 12.jpg","photoTakenTime":{"timestamp":"1563096600"}}`)},
+		"takeout/album.json":                           {Data: []byte(`{"title":"Holidays"}`)},
+		"2019/DSC_0001.jpg.json":                       {Data: []byte(`{"title":"DSC_0001.jpg","photoTakenTime":{"timestamp":"1563096600"}}`)},
+		"2021/DSC_0001.jpg.json":                       {Data: []byte(`{"title":"DSC_0001.jpg","photoTakenTime":{"timestamp":"1626255000"}}`)},
+		"copy/Scan 12.jpg.json":                        {Data: []byte(`{"title":"Scan 12.jpg","photoTakenTime":{"timestamp":"1563096600"}}`)},
+	}
+	dates, err := readSidecars(fsys, "root", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2019, 7, 14, 9, 30, 0, 0, time.UTC)
+	if d, ok := dates.lookup("img_0001.jpg"); !ok || !d.date.Equal(want) {
+		t.Errorf("xmp: %v %v", d, ok)
+	}
+	if d, ok := dates.lookup("Scan 12.jpg"); !ok || !d.date.Equal(want) {
+		t.Errorf("json: %v %v", d, ok)
+	}
+	if d, ok := dates.lookup("DSC_0001.jpg"); ok {
+		t.Errorf("the sidecars of different files have the same name: %v", d)
+	}
+	if len(dates) != 3 {
+		t.Errorf("the album file is not a sidecar: %v", dates)
+	}
+}
+
+func TestAlbumNeighbourDate(t *testing.T) {
+	taken := time.Date(2019, 7, 14, 9, 30, 0, 0, time.UTC)
+	asset := func(id, name string, date time.Time) *immich.Asset {
+		ia := &immich.Asset{ID: id, OriginalFileName: name}
+		ia.ExifInfo.DateTimeOriginal.Time = date
+		return ia
+	}
+	album := []*immich.Asset{
+		asset("3", "IMG_0013.jpg", taken.Add(time.Minute)),
+		asset("1", "IMG_0011.jpg", time.Time{}),
+		asset("2", "IMG_0012.jpg", time.Time{}),
+		asset("0", "IMG_0010.jpg", taken),
+	}
+	d, neighbour, ok := albumNeighbourDate(album, "2")
+	if !ok || neighbour != "IMG_0013.jpg" || !d.Equal(taken.Add(time.Minute)) {
+		t.Errorf("albumNeighbourDate() = %v %s %v", d, neighbour, ok)
+	}
+	if _, _, ok := albumNeighbourDate(album[1:3], "2"); ok {
+		t.Error("no neighbour has a date")
+	}
+}
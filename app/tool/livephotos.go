@@ -0,0 +1,391 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/exif"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// LivePhotosCmd holds the state shared by the live-photos sub-commands
+type LivePhotosCmd struct {
+	// CLI flags
+	DateRange cliflags.DateRange // only the images taken in the range
+
+	serverCmd
+}
+
+func NewLivePhotosCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "live-photos",
+		Short: "Link or unlink the images and the videos of the live photos and motion photos",
+	}
+
+	o := &LivePhotosCmd{}
+	o.registerFlags(cmd.PersistentFlags(), "assets")
+	cmd.PersistentFlags().Var(&o.DateRange, "date-range", "Only consider the images taken in the date range")
+
+	cmd.AddCommand(
+		o.newLinkCommand(a),
+		o.newUnlinkCommand(a),
+	)
+	return cmd
+}
+
+// runE sets the time zone of the date range before running the sub-command
+func (lc *LivePhotosCmd) runE(a *app.Application, run func(ctx context.Context, args []string, out io.Writer) error) func(cmd *cobra.Command, args []string) error {
+	return lc.serverCmd.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		lc.DateRange.SetTZ(a.GetTZ())
+		return run(ctx, args, out)
+	})
+}
+
+// assets returns the images and the videos of the user, the hidden ones included.
+// The videos are read without date range: their capture date can be just outside.
+func (lc *LivePhotosCmd) assets(ctx context.Context) (images []*immich.Asset, videos []*immich.Asset, err error) {
+	var mu sync.Mutex
+	err = lc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All(), func(ia *immich.Asset) error {
+		if ia.IsTrashed || ia.OwnerID != lc.client.User.ID {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch ia.Type {
+		case "IMAGE":
+			if lc.DateRange.InRange(ia.ExifInfo.DateTimeOriginal.Time) {
+				images = append(images, ia)
+			}
+		case "VIDEO":
+			videos = append(videos, ia)
+		}
+		return nil
+	})
+	byDate := func(a, b *immich.Asset) int {
+		return a.ExifInfo.DateTimeOriginal.Compare(b.ExifInfo.DateTimeOriginal.Time)
+	}
+	slices.SortFunc(images, byDate)
+	slices.SortFunc(videos, byDate)
+	return images, videos, err
+}
+
+// livePair is an image and the video of its live photo
+type livePair struct {
+	Image     *immich.Asset
+	Video     *immich.Asset
+	Gap       time.Duration // between the capture dates
+	ContentID bool          // the pair shares the Apple content identifier
+}
+
+// sameContentFn tells if the assets have the same Apple content identifier, known is false when an identifier can't be read
+type sameContentFn func(image, video *immich.Asset) (same bool, known bool)
+
+// matchLivePhotos pairs the unlinked images with the unlinked videos taken less than maxGap apart.
+// The pairs have the same name radical, or the same content identifier when sameContent is given.
+// A differing content identifier rejects the pair. Each asset gets at most one partner, the nearest in time.
+// The image and video lists are sorted by capture date.
+func matchLivePhotos(images, videos []*immich.Asset, radical func(string) string, maxGap time.Duration, sameContent sameContentFn) []livePair {
+	linked := map[string]bool{}
+	for _, im := range images {
+		if im.LivePhotoVideoID != "" {
+			linked[im.LivePhotoVideoID] = true
+		}
+	}
+
+	type candidate struct {
+		livePair
+		sameName bool
+	}
+	var candidates []candidate
+	for _, im := range images {
+		if im.LivePhotoVideoID != "" {
+			continue
+		}
+		d := im.ExifInfo.DateTimeOriginal.Time
+		if d.IsZero() {
+			continue
+		}
+		first := sort.Search(len(videos), func(i int) bool {
+			return !videos[i].ExifInfo.DateTimeOriginal.Before(d.Add(-maxGap))
+		})
+		for _, v := range videos[first:] {
+			vd := v.ExifInfo.DateTimeOriginal.Time
+			if vd.After(d.Add(maxGap)) {
+				break
+			}
+			if linked[v.ID] {
+				continue
+			}
+			c := candidate{
+				livePair: livePair{Image: im, Video: v, Gap: vd.Sub(d).Abs()},
+				sameName: radical(im.OriginalFileName) == radical(v.OriginalFileName),
+			}
+			if sameContent != nil {
+				same, known := sameContent(im, v)
+				if known && !same {
+					continue
+				}
+				c.ContentID = known
+			}
+			if c.sameName || c.ContentID {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	// the best candidates first
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.ContentID != b.ContentID {
+			if a.ContentID {
+				return -1
+			}
+			return 1
+		}
+		if a.sameName != b.sameName {
+			if a.sameName {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.Gap, b.Gap)
+	})
+
+	used := map[string]bool{}
+	var pairs []livePair
+	for _, c := range candidates {
+		if used[c.Image.ID] || used[c.Video.ID] {
+			continue
+		}
+		used[c.Image.ID], used[c.Video.ID] = true, true
+		pairs = append(pairs, c.livePair)
+	}
+	slices.SortFunc(pairs, func(a, b livePair) int {
+		return a.Image.ExifInfo.DateTimeOriginal.Compare(b.Image.ExifInfo.DateTimeOriginal.Time)
+	})
+	return pairs
+}
+
+// contentIDs reads the Apple content identifiers of the assets' originals, once per asset
+type contentIDs struct {
+	ctx    context.Context
+	client immich.ImmichAssetInterface
+	ids    map[string]string // "" when the identifier can't be read
+}
+
+func (c *contentIDs) get(ia *immich.Asset) string {
+	if id, ok := c.ids[ia.ID]; ok {
+		return id
+	}
+	id := ""
+	if exif.HasContentIdentifier(ia.OriginalFileName) {
+		rc, err := c.client.DownloadAsset(c.ctx, ia.ID)
+		if err == nil {
+			id, _ = exif.ContentIdentifier(rc, ia.OriginalFileName)
+			rc.Close()
+		}
+	}
+	c.ids[ia.ID] = id
+	return id
+}
+
+func (c *contentIDs) same(image, video *immich.Asset) (bool, bool) {
+	imID := c.get(image)
+	if imID == "" {
+		return false, false
+	}
+	vID := c.get(video)
+	if vID == "" {
+		return false, false
+	}
+	return imID == vID, true
+}
+
+func (lc *LivePhotosCmd) newLinkCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link",
+		Short: "Link the images and the videos of the live photos uploaded separately",
+		Long: `Find the unlinked images and videos taken less than --max-gap apart with the same name radical, like IMG_1234.HEIC and IMG_1234.MOV, and link them as live photos.
+With --content-id, the originals of the candidates are downloaded to compare their Apple ContentIdentifier: the pairs with the same identifier are linked even when renamed, the pairs with different identifiers are not.
+With --hide, the videos of the live photos are hidden from the timeline, the ones linked before included.`,
+		Args: cobra.NoArgs,
+	}
+	var maxGap time.Duration
+	var contentID, hide bool
+	cmd.Flags().DurationVar(&maxGap, "max-gap", 2*time.Second, "Largest difference between the capture dates of the image and the video")
+	cmd.Flags().BoolVar(&contentID, "content-id", false, "Compare the Apple ContentIdentifier of the originals")
+	cmd.Flags().BoolVar(&hide, "hide", false, "Hide the videos of the live photos from the timeline")
+
+	cmd.RunE = lc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		log := a.Log()
+		images, videos, err := lc.assets(ctx)
+		if err != nil {
+			return err
+		}
+		log.Message("%d images and %d videos read", len(images), len(videos))
+
+		ic := filenames.NewInfoCollector(a.GetTZ(), filetypes.DefaultSupportedMedia, a.NamePatterns...)
+		radical := func(name string) string { return ic.GetInfo(name).Radical }
+		var sameContent sameContentFn
+		if contentID {
+			c := &contentIDs{ctx: ctx, client: lc.client.Immich, ids: map[string]string{}}
+			sameContent = c.same
+		}
+		pairs := matchLivePhotos(images, videos, radical, maxGap, sameContent)
+
+		// the videos already linked but still in the timeline
+		var visible []string
+		if hide {
+			byID := map[string]*immich.Asset{}
+			for _, v := range videos {
+				byID[v.ID] = v
+			}
+			for _, im := range images {
+				if v, ok := byID[im.LivePhotoVideoID]; ok && v.Visibility != string(assets.VisibilityHidden) {
+					visible = append(visible, v.ID)
+				}
+			}
+		}
+		if len(pairs) == 0 && len(visible) == 0 {
+			log.Message("no live photo to link")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IMAGE\tVIDEO\tDATE\tGAP\tCONTENT ID")
+		for _, p := range pairs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", p.Image.OriginalFileName, p.Video.OriginalFileName, p.Image.ExifInfo.DateTimeOriginal.Format(time.DateTime), p.Gap, p.ContentID)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(visible) > 0 {
+			log.Message("%d videos of live photos to hide", len(visible))
+		}
+		if ok, err := lc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+
+		var mu sync.Mutex
+		var errs error
+		linked := 0
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(max(a.ConcurrentTask, 1))
+		for _, p := range pairs {
+			g.Go(func() error {
+				if err := lc.client.Immich.LinkLivePhoto(gctx, p.Image.ID, p.Video.ID); err != nil {
+					mu.Lock()
+					errs = errors.Join(errs, fmt.Errorf("can't link %s and %s: %w", p.Image.OriginalFileName, p.Video.OriginalFileName, err))
+					mu.Unlock()
+					return nil
+				}
+				mu.Lock()
+				linked++
+				if hide {
+					visible = append(visible, p.Video.ID)
+				}
+				mu.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		if len(visible) > 0 {
+			if err := lc.client.Immich.SetAssetsVisibility(ctx, visible, assets.VisibilityHidden); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("can't hide the videos: %w", err))
+			}
+		}
+		log.Message("%d live photos linked", linked)
+		return errs
+	})
+	return cmd
+}
+
+func (lc *LivePhotosCmd) newUnlinkCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unlink",
+		Short: "Unlink the images and the videos of the live photos",
+		Long:  `Unlink the videos of the live photos from their images, the videos are shown again in the timeline. The assets are kept.`,
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = lc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		log := a.Log()
+		images, videos, err := lc.assets(ctx)
+		if err != nil {
+			return err
+		}
+		names := map[string]string{}
+		for _, v := range videos {
+			names[v.ID] = v.OriginalFileName
+		}
+		var linked []*immich.Asset
+		for _, im := range images {
+			if im.LivePhotoVideoID != "" {
+				linked = append(linked, im)
+			}
+		}
+		if len(linked) == 0 {
+			log.Message("no live photo to unlink")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IMAGE\tVIDEO\tDATE")
+		for _, im := range linked {
+			video := cmp.Or(names[im.LivePhotoVideoID], im.LivePhotoVideoID)
+			fmt.Fprintf(w, "%s\t%s\t%s\n", im.OriginalFileName, video, im.ExifInfo.DateTimeOriginal.Format(time.DateTime))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := lc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+
+		var mu sync.Mutex
+		var errs error
+		var shown []string
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(max(a.ConcurrentTask, 1))
+		for _, im := range linked {
+			g.Go(func() error {
+				err := lc.client.Immich.LinkLivePhoto(gctx, im.ID, "")
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = errors.Join(errs, fmt.Errorf("can't unlink %s: %w", im.OriginalFileName, err))
+					return nil
+				}
+				shown = append(shown, im.LivePhotoVideoID)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		if len(shown) > 0 {
+			if err := lc.client.Immich.SetAssetsVisibility(ctx, shown, assets.VisibilityTimeline); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("can't show the videos: %w", err))
+			}
+		}
+		log.Message("%d live photos unlinked", len(shown))
+		return errs
+	})
+	return cmd
+}
@@ -0,0 +1,69 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/filetypes"
+)
+
+func liveAsset(id, name, typ string, date time.Time) *immich.Asset {
+	ia := &immich.Asset{ID: id, OriginalFileName: name, Type: typ}
+	ia.ExifInfo.DateTimeOriginal.Time = date
+	return ia
+}
+
+func TestMatchLivePhotos(t *testing.T) {
+	d := time.Date(2023, 6, 1, 10, 20, 30, 0, time.UTC)
+	images := []*immich.Asset{
+		liveAsset("i1", "IMG_0001.HEIC", "IMAGE", d),
+		liveAsset("i2", "IMG_0002.HEIC", "IMAGE", d.Add(time.Minute)),
+		liveAsset("i3", "renamed.heic", "IMAGE", d.Add(2*time.Minute)),
+		liveAsset("i4", "IMG_0004.HEIC", "IMAGE", d.Add(3*time.Minute)),
+		liveAsset("i5", "IMG_0005.HEIC", "IMAGE", d.Add(4*time.Minute)),
+		liveAsset("i6", "PXL_20230601_102530000.MP.jpg", "IMAGE", d.Add(5*time.Minute)),
+	}
+	images[4].LivePhotoVideoID = "v5"
+	videos := []*immich.Asset{
+		liveAsset("v1", "IMG_0001.MOV", "VIDEO", d.Add(500*time.Millisecond)),
+		liveAsset("v2", "IMG_0002.MOV", "VIDEO", d.Add(time.Minute+10*time.Second)), // too far
+		liveAsset("v3", "IMG_0003.MOV", "VIDEO", d.Add(2*time.Minute)),
+		liveAsset("v4", "IMG_0004.MOV", "VIDEO", d.Add(3*time.Minute)),
+		liveAsset("v5", "IMG_0005.MOV", "VIDEO", d.Add(4*time.Minute)), // already linked
+		liveAsset("v6", "PXL_20230601_102530000.mp4", "VIDEO", d.Add(5*time.Minute+time.Second)),
+	}
+
+	ic := filenames.NewInfoCollector(time.UTC, filetypes.DefaultSupportedMedia)
+	radical := func(name string) string { return ic.GetInfo(name).Radical }
+	pairs := func(list []livePair) [][2]string {
+		var r [][2]string
+		for _, p := range list {
+			r = append(r, [2]string{p.Image.ID, p.Video.ID})
+		}
+		return r
+	}
+
+	got := pairs(matchLivePhotos(images, videos, radical, 2*time.Second, nil))
+	want := [][2]string{{"i1", "v1"}, {"i4", "v4"}, {"i6", "v6"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("by name: got %v, want %v", got, want)
+	}
+
+	// renamed.heic and IMG_0003.MOV share their identifier, IMG_0004 differs, IMG_0001 can't be read
+	ids := map[string]string{"i3": "A", "v3": "A", "i4": "B", "v4": "C", "i6": "D", "v6": "D"}
+	sameContent := func(image, video *immich.Asset) (bool, bool) {
+		a, b := ids[image.ID], ids[video.ID]
+		if a == "" || b == "" {
+			return false, false
+		}
+		return a == b, true
+	}
+	got = pairs(matchLivePhotos(images, videos, radical, 2*time.Second, sameContent))
+	want = [][2]string{{"i1", "v1"}, {"i3", "v3"}, {"i6", "v6"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("by content id: got %v, want %v", got, want)
+	}
+}
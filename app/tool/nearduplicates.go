@@ -0,0 +1,121 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/phash"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// NearDuplicatesCmd lists the clusters of near-duplicate images of the server
+type NearDuplicatesCmd struct {
+	// CLI flags
+	DateRange cliflags.DateRange
+	Distance  int // maximum distance between the perceptual hashes
+
+	// internal state
+	client app.Client
+}
+
+func (nc *NearDuplicatesCmd) RegisterFlags(flags *pflag.FlagSet) {
+	flags.Var(&nc.DateRange, "date-range", "Only consider the server assets taken in the date range")
+	flags.IntVar(&nc.Distance, "distance", 6, "Maximum number of different bits between the perceptual hashes of near-duplicates (0-64)")
+}
+
+func NewNearDuplicatesCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "near-duplicates [flags]",
+		Short: "List the near-duplicate images of the server",
+		Long: `Compute the perceptual hash of the server's images with their thumbnails, and list the clusters of images
+looking the same: resized, recompressed or slightly edited versions of a photo.
+The hashes are kept in the user's cache folder, the next runs only download the new thumbnails.`,
+		Args: cobra.NoArgs,
+	}
+
+	o := &NearDuplicatesCmd{}
+	o.RegisterFlags(cmd.Flags())
+	o.client.RegisterFlags(cmd.Flags(), "")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error { //nolint:contextcheck
+		ctx := cmd.Context()
+		err := o.client.Open(ctx, a)
+		if err != nil {
+			return err
+		}
+		defer o.client.Close()
+		o.DateRange.SetTZ(a.GetTZ())
+		return o.run(ctx, a, cmd.OutOrStdout())
+	}
+	return cmd
+}
+
+func (nc *NearDuplicatesCmd) run(ctx context.Context, a *app.Application, out io.Writer) error {
+	log := a.Log()
+
+	var mu sync.Mutex
+	images := map[string]*immich.Asset{}
+	var thumbnails []phash.Thumbnail
+	err := nc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithExif().WithDateRange(nc.DateRange), func(ia *immich.Asset) error {
+		if ia.IsTrashed || ia.Type != "IMAGE" {
+			return nil
+		}
+		mu.Lock()
+		images[ia.ID] = ia
+		thumbnails = append(thumbnails, phash.Thumbnail{ID: ia.ID, Checksum: ia.Checksum})
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Message("%d server images read", len(images))
+
+	var c *phash.Cache
+	name, err := phash.CachePath()
+	if err == nil {
+		c, err = phash.OpenCache(name)
+	}
+	if err != nil {
+		log.Warn("can't read the perceptual hashes cache", "err", err)
+	}
+	var ix phash.Index
+	failed, err := phash.IndexThumbnails(ctx, &ix, c, thumbnails, a.ConcurrentTask, nc.client.Immich.DownloadThumbnail)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		log.Warn("some server thumbnails can't be read, their images are not compared", "count", failed)
+	}
+	if err := c.Save(); err != nil {
+		log.Warn("can't write the perceptual hashes cache", "err", err)
+	}
+
+	clusters := ix.Clusters(nc.Distance)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tDISTANCE\tID\tFILE\tDATE\tSIZE")
+	for i, cluster := range clusters {
+		for _, m := range cluster {
+			ia := images[m.ID]
+			date := ""
+			if t := ia.ExifInfo.DateTimeOriginal.Time; !t.IsZero() {
+				date = t.Format(time.DateTime)
+			}
+			// the distance to the first image of the cluster
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%d\n", i+1, phash.Distance(cluster[0].Hash, m.Hash), m.ID, ia.OriginalFileName, date, ia.ExifInfo.FileSizeInByte)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	log.Message("%d images compared, %d clusters of near-duplicates found", ix.Len(), len(clusters))
+	return nil
+}
@@ -0,0 +1,671 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/clbanning/mxj/v2"
+	"github.com/simulot/immich-go/adapters/folder"
+	gp "github.com/simulot/immich-go/adapters/googlePhotos"
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// PeopleCmd holds the state shared by the people sub-commands
+type PeopleCmd struct {
+	serverCmd
+}
+
+func NewPeopleCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "people",
+		Short: "Name, merge and hide the people of the server",
+	}
+
+	o := &PeopleCmd{}
+	o.registerFlags(cmd.PersistentFlags(), "people")
+
+	cmd.AddCommand(
+		o.newExportCommand(a),
+		o.newImportCommand(a),
+		o.newMergeCommand(a),
+		o.newHideCommand(a),
+		o.newRecoverNamesCommand(a),
+	)
+	return cmd
+}
+
+// person is a person of the server with its number of assets
+type person struct {
+	immich.PersonResponseDto
+	assets int
+}
+
+// people returns all the people of the server, hidden ones included, sorted by name.
+// With counts, the number of assets of each person is read.
+func (pc *PeopleCmd) people(ctx context.Context, a *app.Application, counts bool) ([]*person, error) {
+	var list []*person
+	withHidden := true
+	err := pc.client.Immich.GetAllPeopleIterator(ctx, func(p *immich.PersonResponseDto) error {
+		list = append(list, &person{PersonResponseDto: *p})
+		return nil
+	}, immich.GetAllPeopleOptions{WithHidden: &withHidden})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(list, func(a, b *person) int {
+		if c := cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if !counts {
+		return list, nil
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(a.ConcurrentTask, 1))
+	for _, p := range list {
+		g.Go(func() error {
+			n, err := pc.client.Immich.GetPersonStatistics(gctx, p.ID)
+			p.assets = n
+			return err
+		})
+	}
+	return list, g.Wait()
+}
+
+func formatBirthDate(p *person) string {
+	if p.BirthDate.IsZero() {
+		return ""
+	}
+	return p.BirthDate.Format(time.DateOnly)
+}
+
+func (pc *PeopleCmd) newExportCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [flags]",
+		Short: "List the people with their number of assets",
+		Long: `List the people with their number of assets. The list can be written to a CSV file,
+and the face thumbnails to a folder, named after the person ID: fill the name column and give the file to the import command.`,
+		Args: cobra.NoArgs,
+	}
+	var csvName, thumbnails string
+	cmd.Flags().StringVar(&csvName, "csv", "", "Write the people to a CSV file")
+	cmd.Flags().StringVar(&thumbnails, "thumbnails", "", "Write the face thumbnails to the folder")
+
+	cmd.RunE = pc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		list, err := pc.people(ctx, a, true)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tASSETS\tBIRTH DATE\tHIDDEN")
+		for _, p := range list {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%v\n", p.ID, p.Name, p.assets, formatBirthDate(p), p.IsHidden)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if thumbnails != "" {
+			if err := pc.writeThumbnails(ctx, a, thumbnails, list); err != nil {
+				return err
+			}
+		}
+		if csvName != "" {
+			return writePeople(csvName, list, thumbnails)
+		}
+		return nil
+	})
+	return cmd
+}
+
+// writeThumbnails writes the face thumbnail of each person to <dir>/<id>.jpg
+func (pc *PeopleCmd) writeThumbnails(ctx context.Context, a *app.Application, dir string, list []*person) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(a.ConcurrentTask, 1))
+	for _, p := range list {
+		g.Go(func() error {
+			rc, err := pc.client.Immich.DownloadPersonThumbnail(gctx, p.ID)
+			if err != nil {
+				a.Log().Warn("can't get the thumbnail", "person", p.ID, "err", err)
+				return nil
+			}
+			defer rc.Close()
+			f, err := os.Create(filepath.Join(dir, p.ID+".jpg"))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, rc)
+			return errors.Join(err, f.Close())
+		})
+	}
+	return g.Wait()
+}
+
+// writePeople writes the people to a CSV file, readable by the import command
+func writePeople(name string, list []*person, thumbnails string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"person", "name", "birthDate", "assets", "hidden", "thumbnail"})
+	for _, p := range list {
+		thumbnail := ""
+		if thumbnails != "" {
+			thumbnail = filepath.Join(thumbnails, p.ID+".jpg")
+		}
+		_ = w.Write([]string{p.ID, p.Name, formatBirthDate(p), strconv.Itoa(p.assets), strconv.FormatBool(p.IsHidden), thumbnail})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// personChange is the new name and birth date of a person
+type personChange struct {
+	person    *person
+	name      string
+	birthDate time.Time
+	source    string
+}
+
+// resolvePerson finds the person designated by a person ID, a thumbnail file named after the ID, or a name
+func resolvePerson(list []*person, key string) (*person, error) {
+	byID := func(id string) *person {
+		i := slices.IndexFunc(list, func(p *person) bool { return p.ID == id })
+		if i < 0 {
+			return nil
+		}
+		return list[i]
+	}
+	if p := byID(key); p != nil {
+		return p, nil
+	}
+	base := path.Base(filepath.ToSlash(key))
+	if p := byID(strings.TrimSuffix(base, path.Ext(base))); p != nil {
+		return p, nil
+	}
+	var named []*person
+	for _, p := range list {
+		if p.Name != "" && strings.EqualFold(p.Name, key) {
+			named = append(named, p)
+		}
+	}
+	switch len(named) {
+	case 0:
+		return nil, fmt.Errorf("no person %q", key)
+	case 1:
+		return named[0], nil
+	}
+	return nil, fmt.Errorf("%d people are named %q, use the merge command first", len(named), key)
+}
+
+// readPeopleChanges reads the CSV mapping: person ID, thumbnail file or current name, new name, optional birth date.
+// A first line starting with "person" or "id" is a header.
+func readPeopleChanges(r io.Reader, list []*person) ([]personChange, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var changes []personChange
+	var errs error
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && len(rec) > 0 && (strings.EqualFold(rec[0], "person") || strings.EqualFold(rec[0], "id")) {
+			continue
+		}
+		if len(rec) < 2 || rec[0] == "" {
+			continue
+		}
+		p, err := resolvePerson(list, rec[0])
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		c := personChange{person: p, name: strings.TrimSpace(rec[1]), source: fmt.Sprintf("line %d", line)}
+		if len(rec) > 2 && strings.TrimSpace(rec[2]) != "" {
+			c.birthDate, err = time.Parse(time.DateOnly, strings.TrimSpace(rec[2]))
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("line %d: invalid birth date %q, expected YYYY-MM-DD", line, rec[2]))
+				continue
+			}
+		}
+		if (c.name == "" || c.name == p.Name) && (c.birthDate.IsZero() || c.birthDate.Equal(p.BirthDate.Time)) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes, errs
+}
+
+// applyChanges shows the changes and applies them after confirmation
+func (pc *PeopleCmd) applyChanges(ctx context.Context, a *app.Application, out io.Writer, changes []personChange) error {
+	if len(changes) == 0 {
+		a.Log().Message("no change")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tNEW NAME\tBIRTH DATE\tSOURCE")
+	for _, c := range changes {
+		birthDate := ""
+		if !c.birthDate.IsZero() {
+			birthDate = c.birthDate.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.person.ID, c.person.Name, c.name, birthDate, c.source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if ok, err := pc.confirm(ctx); err != nil || !ok {
+		return err
+	}
+
+	var errs error
+	for _, c := range changes {
+		upd := immich.PersonUpdate{Name: c.name}
+		if !c.birthDate.IsZero() {
+			upd.BirthDate = c.birthDate.Format(time.DateOnly)
+		}
+		if err := pc.client.Immich.UpdatePerson(ctx, c.person.ID, upd); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("can't update the person %s: %w", c.person.ID, err))
+		}
+	}
+	a.Log().Message("%d people updated", len(changes))
+	return errs
+}
+
+func (pc *PeopleCmd) newImportCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file.csv>",
+		Short: "Name the people and set their birth dates with a CSV file",
+		Long: `Read a CSV file with the columns: person, name, birth date (optional, YYYY-MM-DD).
+The person is given by its ID, by the thumbnail file written by the export command, or by its current name.`,
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.RunE = pc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		list, err := pc.people(ctx, a, false)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		changes, err := readPeopleChanges(f, list)
+		if err != nil {
+			a.Log().Warn("some lines are ignored", "err", err)
+		}
+		return pc.applyChanges(ctx, a, out, changes)
+	})
+	return cmd
+}
+
+// samePeople returns the groups of people having the same name, the person with the most assets first
+func samePeople(list []*person) [][]*person {
+	byName := map[string][]*person{}
+	var names []string
+	for _, p := range list {
+		name := strings.ToLower(strings.TrimSpace(p.Name))
+		if name == "" {
+			continue
+		}
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], p)
+	}
+	slices.Sort(names)
+	var groups [][]*person
+	for _, name := range names {
+		g := byName[name]
+		if len(g) < 2 {
+			continue
+		}
+		slices.SortStableFunc(g, func(a, b *person) int { return cmp.Compare(b.assets, a.assets) })
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func (pc *PeopleCmd) newMergeCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge",
+		Short: "Merge the people having the same name",
+		Long:  `Merge the people having the same name into the one with the most assets. The faces of the others are given to it.`,
+		Args:  cobra.NoArgs,
+	}
+	cmd.RunE = pc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		list, err := pc.people(ctx, a, true)
+		if err != nil {
+			return err
+		}
+		groups := samePeople(list)
+		if len(groups) == 0 {
+			a.Log().Message("no people to merge")
+			return nil
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, g := range groups {
+			fmt.Fprintf(w, "keep\t%s\t%s\t%d assets\n", g[0].ID, g[0].Name, g[0].assets)
+			for _, p := range g[1:] {
+				fmt.Fprintf(w, "  merge\t%s\t%s\t%d assets\n", p.ID, p.Name, p.assets)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := pc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+		var errs error
+		for _, g := range groups {
+			ids := make([]string, 0, len(g)-1)
+			for _, p := range g[1:] {
+				ids = append(ids, p.ID)
+			}
+			if err := pc.client.Immich.MergePeople(ctx, g[0].ID, ids); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("can't merge the people named %q: %w", g[0].Name, err))
+			}
+		}
+		a.Log().Message("%d groups of people merged", len(groups))
+		return errs
+	})
+	return cmd
+}
+
+func (pc *PeopleCmd) newHideCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hide [flags]",
+		Short: "Hide the people having few assets",
+		Args:  cobra.NoArgs,
+	}
+	var below int
+	var unnamed bool
+	cmd.Flags().IntVar(&below, "below", 3, "Hide the people having less assets than this number")
+	cmd.Flags().BoolVar(&unnamed, "unnamed", true, "Hide only the people without name")
+
+	cmd.RunE = pc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		list, err := pc.people(ctx, a, true)
+		if err != nil {
+			return err
+		}
+		var hidden []*person
+		for _, p := range list {
+			if !p.IsHidden && p.assets < below && (!unnamed || p.Name == "") {
+				hidden = append(hidden, p)
+			}
+		}
+		if len(hidden) == 0 {
+			a.Log().Message("no people to hide")
+			return nil
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, p := range hidden {
+			fmt.Fprintf(w, "hide\t%s\t%s\t%d assets\n", p.ID, p.Name, p.assets)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := pc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+		var errs error
+		isHidden := true
+		for _, p := range hidden {
+			if err := pc.client.Immich.UpdatePerson(ctx, p.ID, immich.PersonUpdate{IsHidden: &isHidden}); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("can't hide the person %s: %w", p.ID, err))
+			}
+		}
+		a.Log().Message("%d people hidden", len(hidden))
+		return errs
+	})
+	return cmd
+}
+
+// faceNames are the names of the faces found in the local files, by lower case file name
+type faceNames map[string]faceName
+
+type faceName struct {
+	names []string
+	file  string
+}
+
+// lookup returns the names of the faces of the file name, or of a sidecar named after its radical
+func (fn faceNames) lookup(name string) (faceName, bool) {
+	name = strings.ToLower(name)
+	if f, ok := fn[name]; ok {
+		return f, true
+	}
+	f, ok := fn[strings.TrimSuffix(name, path.Ext(name))]
+	return f, ok
+}
+
+// readFaceNames reads the names of the people of the takeout JSON files, the .picasa.ini files
+// and the face regions of the XMP sidecars of the folder
+func readFaceNames(fsys fs.FS, root string) (faceNames, error) {
+	names := faceNames{}
+	add := func(key string, list []string, name string) {
+		if key == "" || len(list) == 0 {
+			return
+		}
+		names[strings.ToLower(key)] = faceName{names: list, file: path.Join(root, name)}
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		base := path.Base(name)
+		ext := strings.ToLower(path.Ext(name))
+		switch {
+		case strings.EqualFold(base, ".picasa.ini") || strings.EqualFold(base, "picasa.ini"):
+			faces, err := folder.ReadPicasaFaces(fsys, name)
+			if err != nil {
+				return nil
+			}
+			for file, list := range faces {
+				add(file, list, name)
+			}
+		case ext == ".xmp":
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			add(strings.TrimSuffix(base, path.Ext(base)), xmpFaceNames(f), name)
+		case ext == ".json":
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			var gmd gp.GoogleMetaData
+			if err := json.NewDecoder(f).Decode(&gmd); err != nil {
+				return nil
+			}
+			var list []string
+			for _, p := range gmd.People {
+				list = append(list, p.Name)
+			}
+			add(gmd.Title, list, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// xmpFaceNames returns the names of the face regions of an XMP file,
+// in the Metadata Working Group or the Microsoft Photo format
+func xmpFaceNames(r io.Reader) []string {
+	m, err := mxj.NewMapXmlReader(r)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, l := range m.LeafNodes() {
+		if !strings.Contains(l.Path, ".Regions.") && !strings.Contains(l.Path, ".RegionInfo.") {
+			continue
+		}
+		key := strings.TrimPrefix(l.Path[strings.LastIndex(l.Path, ".")+1:], "-")
+		if key != "Name" && key != "PersonDisplayName" {
+			continue
+		}
+		name, _ := l.Value.(string)
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// faceVote returns the unnamed person of an asset recognized by the names found for the asset:
+// the names of the named people are set aside, one unnamed person and one name must remain
+func faceVote(names []string, people []immich.PersonResponseDto) (string, string, bool) {
+	var unnamed []string
+	remaining := slices.Clone(names)
+	for _, p := range people {
+		if p.Name == "" {
+			unnamed = append(unnamed, p.ID)
+			continue
+		}
+		remaining = slices.DeleteFunc(remaining, func(n string) bool { return strings.EqualFold(n, p.Name) })
+	}
+	if len(unnamed) != 1 || len(remaining) != 1 {
+		return "", "", false
+	}
+	return unnamed[0], remaining[0], true
+}
+
+// electNames returns the name of each person having enough votes and no tie
+func electNames(votes map[string]map[string]int, minVotes int) map[string]string {
+	elected := map[string]string{}
+	for id, counts := range votes {
+		best, bestCount, tie := "", 0, false
+		for name, n := range counts {
+			switch {
+			case n > bestCount:
+				best, bestCount, tie = name, n, false
+			case n == bestCount:
+				tie = true
+			}
+		}
+		if !tie && bestCount >= minVotes {
+			elected[id] = best
+		}
+	}
+	return elected
+}
+
+func (pc *PeopleCmd) newRecoverNamesCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recover-names <folder>... [flags]",
+		Short: "Name the people with the names of the takeout JSON files, Picasa or XMP face regions",
+		Long: `Read the names of the people in the Google takeout JSON files, the .picasa.ini files and the face regions of the XMP sidecars of the folders.
+The files are matched with the server's assets by name. When an asset has one unnamed person left once the named people are set aside,
+and the file has one name left, the name is a vote for the person. A person gets the name with the most votes.`,
+		Args: cobra.MinimumNArgs(1),
+	}
+	var minVotes int
+	cmd.Flags().IntVar(&minVotes, "min-votes", 2, "Minimum number of assets giving the same name to a person")
+
+	cmd.RunE = pc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		log := a.Log()
+		names := faceNames{}
+		for _, dir := range args {
+			fn, err := readFaceNames(os.DirFS(dir), dir)
+			if err != nil {
+				return err
+			}
+			for k, v := range fn {
+				names[k] = v
+			}
+		}
+		log.Message("%d files with names of people found", len(names))
+
+		var mu sync.Mutex
+		var candidates []*immich.Asset
+		err := pc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All(), func(ia *immich.Asset) error {
+			if _, ok := names.lookup(ia.OriginalFileName); ok && !ia.IsTrashed {
+				mu.Lock()
+				candidates = append(candidates, ia)
+				mu.Unlock()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		votes := map[string]map[string]int{}
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(max(a.ConcurrentTask, 1))
+		for _, ia := range candidates {
+			g.Go(func() error {
+				info, err := pc.client.Immich.GetAssetInfo(gctx, ia.ID)
+				if err != nil {
+					return err
+				}
+				fn, _ := names.lookup(ia.OriginalFileName)
+				id, name, ok := faceVote(fn.names, info.People)
+				if !ok {
+					return nil
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if votes[id] == nil {
+					votes[id] = map[string]int{}
+				}
+				votes[id][name]++
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+
+		list, err := pc.people(ctx, a, false)
+		if err != nil {
+			return err
+		}
+		elected := electNames(votes, minVotes)
+		var changes []personChange
+		for _, p := range list {
+			if name, ok := elected[p.ID]; ok && p.Name == "" {
+				changes = append(changes, personChange{person: p, name: name, source: fmt.Sprintf("%d votes", votes[p.ID][name])})
+			}
+		}
+		return pc.applyChanges(ctx, a, out, changes)
+	})
+	return cmd
+}
@@ -0,0 +1,105 @@
+package tool
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/simulot/immich-go/immich"
+)
+
+func testPeople() []*person {
+	p := func(id, name string, assets int) *person {
+		return &person{PersonResponseDto: immich.PersonResponseDto{ID: id, Name: name}, assets: assets}
+	}
+	return []*person{
+		p("8a1b", "Alice", 10),
+		p("9c2d", "", 2),
+		p("7e3f", "alice", 25),
+		p("6f4a", "Bob", 4),
+	}
+}
+
+func TestReadPeopleChanges(t *testing.T) {
+	list := testPeople()
+	changes, err := readPeopleChanges(strings.NewReader(`person,name,birthDate
+9c2d,Carol
+thumbnails/6f4a.jpg,Robert,1980-02-29
+Bob,,1980-03-01
+alice,Alicia
+unknown,Dan
+`), list)
+	if err == nil {
+		t.Error("the unknown and the ambiguous people are errors")
+	}
+	if len(changes) != 3 {
+		t.Fatalf("changes = %v", changes)
+	}
+	if changes[0].person.ID != "9c2d" || changes[0].name != "Carol" {
+		t.Errorf("by ID: %+v", changes[0])
+	}
+	if changes[1].person.ID != "6f4a" || changes[1].name != "Robert" || changes[1].birthDate.Day() != 29 {
+		t.Errorf("by thumbnail: %+v", changes[1])
+	}
+	if changes[2].person.ID != "6f4a" || changes[2].name != "" || changes[2].birthDate.Day() != 1 {
+		t.Errorf("by name: %+v", changes[2])
+	}
+}
+
+func TestSamePeople(t *testing.T) {
+	groups := samePeople(testPeople())
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].ID != "7e3f" {
+		t.Errorf("samePeople() = %v", groups)
+	}
+}
+
+func TestFaceNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/IMG_0001.JPG.xmp": {Data: []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:mwg-rs="http://www.metadataworkinggroup.com/schemas/regions/"><mwg-rs:RegionInfo><mwg-rs:RegionList><rdf:Bag>
+<rdf:li><rdf:Description mwg-rs:Name="Alice" mwg-rs:Type="Face"/></rdf:li>
+<rdf:li><rdf:Description mwg-rs:Name="Bob" mwg-rs:Type="Face"/></rdf:li>
+</rdf:Bag></mwg-rs:RegionList></mwg-rs:RegionInfo></rdf:Description></rdf:RDF></x:xmpmeta>`)},
+		"b/.picasa.ini":  {Data: []byte("[IMG_0002.JPG]\nfaces=rect64(3f845bcb59418507),8e62\n[Contacts2]\n8e62=Carol;;\n")},
+		"takeout/1.json": {Data: []byte(`{"title":"PXL_1.jpg","people":[{"name":"Dan"}]}`)},
+		"takeout/2.json": {Data: []byte(`{"title":"PXL_2.jpg"}`)},
+	}
+	names, err := readFaceNames(fsys, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := map[string][]string{
+		"img_0001.jpg": {"Alice", "Bob"},
+		"IMG_0002.JPG": {"Carol"},
+		"PXL_1.jpg":    {"Dan"},
+	}
+	for file, want := range tcs {
+		if got, ok := names.lookup(file); !ok || !slices.Equal(got.names, want) {
+			t.Errorf("lookup(%q) = %v, want %v", file, got.names, want)
+		}
+	}
+	if len(names) != 3 {
+		t.Errorf("the files without people are ignored: %v", names)
+	}
+}
+
+func TestFaceVote(t *testing.T) {
+	people := []immich.PersonResponseDto{{ID: "1", Name: "Alice"}, {ID: "2"}}
+	if id, name, ok := faceVote([]string{"alice", "Bob"}, people); !ok || id != "2" || name != "Bob" {
+		t.Errorf("faceVote() = %s %s %v", id, name, ok)
+	}
+	if _, _, ok := faceVote([]string{"Bob", "Carol"}, people); ok {
+		t.Error("two names for one unnamed person")
+	}
+
+	votes := map[string]map[string]int{
+		"2": {"Bob": 3, "Carol": 1},
+		"3": {"Dan": 1, "Eve": 1},
+		"4": {"Fred": 1},
+	}
+	elected := electNames(votes, 2)
+	if len(elected) != 1 || elected["2"] != "Bob" {
+		t.Errorf("electNames() = %v", elected)
+	}
+}
@@ -0,0 +1,501 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// TagsCmd holds the state shared by the tags sub-commands
+type TagsCmd struct {
+	serverCmd
+}
+
+func NewTagsCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tags",
+		Short: "Manage the tags of the server",
+	}
+
+	o := &TagsCmd{}
+	o.registerFlags(cmd.PersistentFlags(), "tags")
+
+	cmd.AddCommand(
+		o.newListCommand(a),
+		o.newMoveCommand(a, "rename <tag> <new tag>", "Rename or move a tag and its sub-tags", false),
+		o.newMoveCommand(a, "merge <tag> <into tag>", "Merge a tag and its sub-tags into another tag", true),
+		o.newDeleteCommand(a),
+		o.newToAlbumsCommand(a),
+		o.newFromAlbumsCommand(a),
+	)
+	return cmd
+}
+
+// tags returns the server's tags sorted by value
+func (tc *TagsCmd) tags(ctx context.Context) ([]immich.TagSimplified, error) {
+	tags, err := tc.client.Immich.GetAllTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get the tags list: %w", err)
+	}
+	slices.SortFunc(tags, func(a, b immich.TagSimplified) int { return cmp.Compare(a.Value, b.Value) })
+	return tags, nil
+}
+
+// subtree returns the tag with the value and its sub-tags, the tag first
+func subtree(tags []immich.TagSimplified, value string) []immich.TagSimplified {
+	var r []immich.TagSimplified
+	for _, t := range tags {
+		if t.Value == value || strings.HasPrefix(t.Value, value+"/") {
+			r = append(r, t)
+		}
+	}
+	slices.SortFunc(r, func(a, b immich.TagSimplified) int { return cmp.Compare(a.Value, b.Value) })
+	return r
+}
+
+// movedValue returns the value of the tag once the subtree from is moved to to
+func movedValue(value, from, to string) string {
+	return to + strings.TrimPrefix(value, from)
+}
+
+// assetCount returns the number of assets having the tag or one of its sub-tags
+func (tc *TagsCmd) assetCount(ctx context.Context, t immich.TagSimplified) (int, error) {
+	var mu sync.Mutex
+	count := 0
+	err := tc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithTags(t.ID), func(ia *immich.Asset) error {
+		if ia.IsTrashed {
+			return nil
+		}
+		mu.Lock()
+		count++
+		mu.Unlock()
+		return nil
+	})
+	return count, err
+}
+
+// taggedAssets returns the assets of each tag of the subtree, the sub-tags' assets excluded
+func (tc *TagsCmd) taggedAssets(ctx context.Context, a *app.Application, tree []immich.TagSimplified) (map[string][]string, error) {
+	inTree := map[string]bool{}
+	for _, t := range tree {
+		inTree[t.ID] = true
+	}
+
+	// the search gives the assets of the sub-tags too, the assets' info gives their own tags
+	var mu sync.Mutex
+	var ids []string
+	err := tc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithTags(tree[0].ID), func(ia *immich.Asset) error {
+		mu.Lock()
+		ids = append(ids, ia.ID)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byTag := map[string][]string{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(a.ConcurrentTask, 1))
+	for _, id := range ids {
+		g.Go(func() error {
+			info, err := tc.client.Immich.GetAssetInfo(gctx, id)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, t := range info.Tags {
+				if inTree[t.ID] {
+					byTag[t.ID] = append(byTag[t.ID], id)
+				}
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	for _, list := range byTag {
+		slices.Sort(list)
+	}
+	return byTag, nil
+}
+
+func (tc *TagsCmd) newListCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [pattern]",
+		Short: "List the tags with their number of assets",
+		Args:  cobra.MaximumNArgs(1),
+	}
+	cmd.RunE = tc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		re, err := tagPattern(args)
+		if err != nil {
+			return err
+		}
+		tags, err := tc.tags(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TAG\tASSETS")
+		for _, t := range tags {
+			if !re.MatchString(t.Value) {
+				continue
+			}
+			count, err := tc.assetCount(ctx, t)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%d\n", t.Value, count)
+		}
+		return w.Flush()
+	})
+	return cmd
+}
+
+// tagPattern compiles the optional pattern argument, all tags match without pattern
+func tagPattern(args []string) (*regexp.Regexp, error) {
+	if len(args) == 0 || args[0] == "" {
+		return regexp.MustCompile(`.*`), nil
+	}
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("tag pattern %q can't be parsed: %w", args[0], err)
+	}
+	return re, nil
+}
+
+func (tc *TagsCmd) newMoveCommand(a *app.Application, use, short string, merge bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long: short + `.
+The assets are tagged with the new tags, then the old tags are deleted. A tag "a" moved to "b/c" gives "b/c", its sub-tag "a/d" gives "b/c/d".`,
+		Args: cobra.ExactArgs(2),
+	}
+	cmd.RunE = tc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		from, to := strings.Trim(args[0], "/"), strings.Trim(args[1], "/")
+		if from == to || strings.HasPrefix(to, from+"/") {
+			return fmt.Errorf("the tag %q can't be moved into itself", from)
+		}
+		tags, err := tc.tags(ctx)
+		if err != nil {
+			return err
+		}
+		tree := subtree(tags, from)
+		if len(tree) == 0 || tree[0].Value != from {
+			return fmt.Errorf("tag %q not found", from)
+		}
+		exists := len(subtree(tags, to)) > 0
+		switch {
+		case merge && !exists:
+			return fmt.Errorf("tag %q not found, use the rename command", to)
+		case !merge && exists:
+			return fmt.Errorf("the tag %q exists, use the merge command", to)
+		}
+		return tc.moveTags(ctx, a, out, tree, from, to)
+	})
+	return cmd
+}
+
+// moveTags tags the assets of the subtree with the moved tags and deletes the subtree
+func (tc *TagsCmd) moveTags(ctx context.Context, a *app.Application, out io.Writer, tree []immich.TagSimplified, from, to string) error {
+	byTag, err := tc.taggedAssets(ctx, a, tree)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, t := range tree {
+		fmt.Fprintf(w, "%s\t->\t%s\t%d assets\n", t.Value, movedValue(t.Value, from, to), len(byTag[t.ID]))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if ok, err := tc.confirm(ctx); err != nil || !ok {
+		return err
+	}
+
+	values := make([]string, 0, len(tree))
+	for _, t := range tree {
+		values = append(values, movedValue(t.Value, from, to))
+	}
+	created, err := tc.client.Immich.UpsertTags(ctx, values)
+	if err != nil {
+		return fmt.Errorf("can't create the tags: %w", err)
+	}
+	newID := map[string]string{}
+	for _, t := range created {
+		newID[t.Value] = t.ID
+	}
+
+	tagged := 0
+	for _, t := range tree {
+		ids := byTag[t.ID]
+		if len(ids) == 0 {
+			continue
+		}
+		id, ok := newID[movedValue(t.Value, from, to)]
+		if !ok {
+			return fmt.Errorf("the tag %q hasn't been created", movedValue(t.Value, from, to))
+		}
+		r, err := tc.client.Immich.BulkTagAssets(ctx, []string{id}, ids)
+		if err != nil {
+			return fmt.Errorf("can't tag the assets with %q: %w", movedValue(t.Value, from, to), err)
+		}
+		tagged += r.Count
+	}
+
+	// deleting the tag deletes its sub-tags
+	if err := tc.client.Immich.DeleteTag(ctx, tree[0].ID); err != nil {
+		return fmt.Errorf("can't delete the tag %q: %w", from, err)
+	}
+	a.Log().Message("%d tags moved from %q to %q, %d assets tagged", len(tree), from, to, tagged)
+	return nil
+}
+
+func (tc *TagsCmd) newDeleteCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete [pattern]",
+		Short: "Delete the tags matching a regular expression, or the unused tags",
+		Long: `Delete the tags whose value matches the regular expression, like "^{immich-go}/".
+With --unused, only the tags without assets are deleted. The sub-tags are deleted with their parent, the assets are kept.`,
+		Args: cobra.MaximumNArgs(1),
+	}
+	var unused bool
+	cmd.Flags().BoolVar(&unused, "unused", false, "Delete only the tags without assets")
+
+	cmd.RunE = tc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		if len(args) == 0 && !unused {
+			return errors.New("give a pattern or --unused")
+		}
+		re, err := tagPattern(args)
+		if err != nil {
+			return err
+		}
+		tags, err := tc.tags(ctx)
+		if err != nil {
+			return err
+		}
+		var deleted []immich.TagSimplified
+		for _, t := range tags {
+			if !re.MatchString(t.Value) {
+				continue
+			}
+			// the sub-tags of a deleted tag are already gone
+			if slices.ContainsFunc(deleted, func(d immich.TagSimplified) bool { return strings.HasPrefix(t.Value, d.Value+"/") }) {
+				continue
+			}
+			if unused {
+				count, err := tc.assetCount(ctx, t)
+				if err != nil {
+					return err
+				}
+				if count > 0 {
+					continue
+				}
+			}
+			deleted = append(deleted, t)
+		}
+		if len(deleted) == 0 {
+			a.Log().Message("no tag to delete")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, t := range deleted {
+			fmt.Fprintf(w, "delete\t%s\t%s\n", t.ID, t.Value)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := tc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+		var errs error
+		for _, t := range deleted {
+			if err := tc.client.Immich.DeleteTag(ctx, t.ID); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("can't delete the tag %q: %w", t.Value, err))
+			}
+		}
+		a.Log().Message("%d tags deleted", len(deleted))
+		return errs
+	})
+	return cmd
+}
+
+func (tc *TagsCmd) newToAlbumsCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "to-albums <tag>",
+		Short: "Make an album for the tag and each of its sub-tags",
+		Long: `Put the assets of the tag and of each sub-tag in an album named after the tag value, like "Travel/Rome".
+The existing albums are completed. The tags are kept, unless --delete is given.`,
+		Args: cobra.ExactArgs(1),
+	}
+	var del bool
+	cmd.Flags().BoolVar(&del, "delete", false, "Delete the tags once the albums are made")
+
+	cmd.RunE = tc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		value := strings.Trim(args[0], "/")
+		tags, err := tc.tags(ctx)
+		if err != nil {
+			return err
+		}
+		tree := subtree(tags, value)
+		if len(tree) == 0 || tree[0].Value != value {
+			return fmt.Errorf("tag %q not found", value)
+		}
+		byTag, err := tc.taggedAssets(ctx, a, tree)
+		if err != nil {
+			return err
+		}
+		albums, err := tc.client.Immich.GetAllAlbums(ctx)
+		if err != nil {
+			return fmt.Errorf("can't get the albums list: %w", err)
+		}
+		albumID := map[string]string{}
+		for _, al := range albums {
+			if al.OwnerID == "" || al.OwnerID == tc.client.User.ID {
+				albumID[al.AlbumName] = al.ID
+			}
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, t := range tree {
+			if len(byTag[t.ID]) == 0 {
+				continue
+			}
+			action := "create album"
+			if albumID[t.Value] != "" {
+				action = "add to album"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d assets\n", action, t.Value, len(byTag[t.ID]))
+		}
+		if del {
+			fmt.Fprintf(w, "delete tag\t%s\t\n", value)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := tc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+
+		for _, t := range tree {
+			ids := byTag[t.ID]
+			if len(ids) == 0 {
+				continue
+			}
+			if id := albumID[t.Value]; id != "" {
+				_, err = tc.client.Immich.AddAssetToAlbum(ctx, id, ids)
+			} else {
+				_, err = tc.client.Immich.CreateAlbum(ctx, t.Value, "", ids)
+			}
+			if err != nil {
+				return fmt.Errorf("can't fill the album %q: %w", t.Value, err)
+			}
+		}
+		if !del {
+			return nil
+		}
+		return tc.client.Immich.DeleteTag(ctx, tree[0].ID)
+	})
+	return cmd
+}
+
+func (tc *TagsCmd) newFromAlbumsCommand(a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "from-albums <pattern>",
+		Short: "Tag the assets of the albums matching a regular expression with the album name",
+		Long: `Tag the assets of each album whose name matches the regular expression with a tag named after the album, under the --parent tag.
+The albums are kept, unless --delete is given.`,
+		Args: cobra.ExactArgs(1),
+	}
+	var parent string
+	var del bool
+	cmd.Flags().StringVar(&parent, "parent", "", "Parent of the created tags, like \"Albums\"")
+	cmd.Flags().BoolVar(&del, "delete", false, "Delete the albums once their assets are tagged")
+
+	cmd.RunE = tc.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		re, err := tagPattern(args)
+		if err != nil {
+			return err
+		}
+		albums, err := tc.client.Immich.GetAllAlbums(ctx)
+		if err != nil {
+			return fmt.Errorf("can't get the albums list: %w", err)
+		}
+		var matched []immich.AlbumSimplified
+		for _, al := range albums {
+			if re.MatchString(al.AlbumName) && (!del || al.OwnerID == "" || al.OwnerID == tc.client.User.ID) {
+				matched = append(matched, al)
+			}
+		}
+		slices.SortFunc(matched, func(a, b immich.AlbumSimplified) int { return cmp.Compare(a.AlbumName, b.AlbumName) })
+		if len(matched) == 0 {
+			a.Log().Message("no album matches %q", args[0])
+			return nil
+		}
+
+		tagValue := func(al immich.AlbumSimplified) string {
+			v := strings.Trim(al.AlbumName, "/")
+			if p := strings.Trim(parent, "/"); p != "" {
+				v = p + "/" + v
+			}
+			return v
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, al := range matched {
+			fmt.Fprintf(w, "%s\t->\t%s\t%d assets\n", al.AlbumName, tagValue(al), al.AssetCount)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if ok, err := tc.confirm(ctx); err != nil || !ok {
+			return err
+		}
+
+		var errs error
+		for _, al := range matched {
+			content, err := tc.client.Immich.GetAlbumInfo(ctx, al.ID, false)
+			if err != nil {
+				return fmt.Errorf("can't get the assets of the album %q: %w", al.AlbumName, err)
+			}
+			tags, err := tc.client.Immich.UpsertTags(ctx, []string{tagValue(al)})
+			if err != nil {
+				return fmt.Errorf("can't create the tag %q: %w", tagValue(al), err)
+			}
+			if len(tags) == 0 {
+				return fmt.Errorf("the tag %q hasn't been created", tagValue(al))
+			}
+			ids := make([]string, 0, len(content.Assets))
+			for _, ia := range content.Assets {
+				ids = append(ids, ia.ID)
+			}
+			if len(ids) > 0 {
+				if _, err := tc.client.Immich.BulkTagAssets(ctx, []string{tags[0].ID}, ids); err != nil {
+					return fmt.Errorf("can't tag the assets of the album %q: %w", al.AlbumName, err)
+				}
+			}
+			if del {
+				if err := tc.client.Immich.DeleteAlbum(ctx, al.ID); err != nil {
+					errs = errors.Join(errs, fmt.Errorf("can't delete the album %q: %w", al.AlbumName, err))
+				}
+			}
+		}
+		return errs
+	})
+	return cmd
+}
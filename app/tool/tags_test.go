@@ -0,0 +1,33 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/simulot/immich-go/immich"
+)
+
+func TestSubtree(t *testing.T) {
+	tags := []immich.TagSimplified{
+		{ID: "3", Value: "People/Alice"},
+		{ID: "1", Value: "People"},
+		{ID: "4", Value: "People Old"},
+		{ID: "2", Value: "People/Alice/Kids"},
+	}
+	tree := subtree(tags, "People")
+	if len(tree) != 3 || tree[0].ID != "1" || tree[2].ID != "2" {
+		t.Fatalf("subtree() = %v", tree)
+	}
+
+	tcs := []struct {
+		value, from, to, want string
+	}{
+		{"People", "People", "Persons", "Persons"},
+		{"People/Alice/Kids", "People", "Family/Persons", "Family/Persons/Alice/Kids"},
+		{"People/Alice", "People/Alice", "Alice", "Alice"},
+	}
+	for _, tc := range tcs {
+		if got := movedValue(tc.value, tc.from, tc.to); got != tc.want {
+			t.Errorf("movedValue(%q, %q, %q) = %q, want %q", tc.value, tc.from, tc.to, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/internal/filenames"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/spf13/cobra"
+)
+
+// NewTestNameCommand shows the information extracted from file names by the built-in matchers
+// and the name-patterns of the configuration file
+func NewTestNameCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test-name [flags] [file names...]",
+		Short: "Show the information extracted from file names",
+		Long:  `Show what each file name resolves to with the built-in matchers and the name-patterns of the configuration file. The names are read from the standard input when none is given.`,
+	}
+	var extra []string
+	cmd.Flags().StringArrayVar(&extra, "pattern", nil, "Additional name pattern to test, tried after the ones of the configuration file (can be repeated)")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		patterns := append([]*filenames.NamePattern{}, a.NamePatterns...)
+		for _, expr := range extra {
+			p, err := filenames.CompileNamePattern(expr)
+			if err != nil {
+				return err
+			}
+			patterns = append(patterns, p)
+		}
+		ic := filenames.NewInfoCollector(a.GetTZ(), filetypes.DefaultSupportedMedia, patterns...)
+
+		names := args
+		if len(names) == 0 {
+			var err error
+			names, err = readLines(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tMATCHER\tRADICAL\tTYPE\tKIND\tINDEX\tCOVER\tTAKEN")
+		for _, name := range names {
+			matcher, info := ic.Resolve(name)
+			taken := ""
+			if !info.Taken.IsZero() {
+				taken = info.Taken.Format("2006-01-02 15:04:05 -07:00")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%t\t%s\n", name, matcher, info.Radical, info.Type, info.Kind, info.Index, info.IsCover, taken)
+		}
+		return w.Flush()
+	}
+	return cmd
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if l := strings.TrimSpace(s.Text()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, s.Err()
+}
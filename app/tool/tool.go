@@ -2,9 +2,12 @@ package tool
 
 import (
 	"context"
+	"io"
 
 	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/internal/ui"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func NewToolCommand(ctx context.Context, a *app.Application) *cobra.Command {
@@ -12,5 +15,58 @@ func NewToolCommand(ctx context.Context, a *app.Application) *cobra.Command {
 		Use:   "tool",
 		Short: "Miscellaneous tools",
 	}
+	c.AddCommand(
+		NewFixClockCommand(ctx, a),       // Correct the dates of cameras with a wrong clock
+		NewTestNameCommand(ctx, a),       // Show the information extracted from file names
+		NewVerifyCommand(ctx, a),         // Compare local files with the server's assets
+		NewUndoSessionCommand(ctx, a),    // Revert an upload session
+		NewNearDuplicatesCommand(ctx, a), // List the near-duplicate images of the server
+		NewDuplicatesCommand(ctx, a),     // Remove the duplicates of the server
+		NewFixDatesCommand(ctx, a),       // Correct the missing or wrong capture dates
+		NewAlbumCommand(ctx, a),          // Manage the albums of the server
+		NewTagsCommand(ctx, a),           // Manage the tags of the server
+		NewPeopleCommand(ctx, a),         // Name, merge and hide the people of the server
+		NewLivePhotosCommand(ctx, a),     // Link or unlink the live photos of the server
+	)
 	return c
 }
+
+// serverCmd holds the state shared by the sub-commands changing the server: the connection and the --yes flag
+type serverCmd struct {
+	// CLI flags
+	Yes bool // don't ask before changing the server
+
+	// internal state
+	client app.Client
+}
+
+// registerFlags registers the server flags and --yes on the parent command, what is the kind of the changed objects
+func (sc *serverCmd) registerFlags(flags *pflag.FlagSet, what string) {
+	sc.client.RegisterFlags(flags, "")
+	flags.BoolVar(&sc.Yes, "yes", false, "Change the "+what+" without asking")
+}
+
+// runE opens the connection to the server before running the sub-command
+func (sc *serverCmd) runE(a *app.Application, run func(ctx context.Context, args []string, out io.Writer) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error { //nolint:contextcheck
+		ctx := cmd.Context()
+		err := sc.client.Open(ctx, a)
+		if err != nil {
+			return err
+		}
+		defer sc.client.Close()
+		return run(ctx, args, cmd.OutOrStdout())
+	}
+}
+
+// confirm asks the user before a change, unless --yes is given
+func (sc *serverCmd) confirm(ctx context.Context) (bool, error) {
+	if sc.Yes {
+		return true, nil
+	}
+	r, err := ui.ConfirmYesNo(ctx, "Proceed?", "n")
+	if err != nil {
+		return false, err
+	}
+	return r == "y", nil
+}
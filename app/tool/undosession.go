@@ -0,0 +1,340 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/journal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// UndoSessionCmd reverts the changes made by an upload session
+type UndoSessionCmd struct {
+	// CLI flags
+	Force   bool   // delete the assets for good
+	Journal string // journal file of the session
+
+	serverCmd
+}
+
+func (uc *UndoSessionCmd) RegisterFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&uc.Force, "force", false, "Delete the uploaded assets for good instead of moving them to the trash")
+	flags.StringVar(&uc.Journal, "journal", "", "Journal file of the session (default: the one written by the upload in the user's cache folder)")
+}
+
+func NewUndoSessionCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo-session [flags] <session>",
+		Short: "Revert an upload made with --session-tag",
+		Long: `Delete the assets uploaded by the session, the albums and tags it has created, the stacks it has made,
+and remove the assets from the albums existing before the session.
+The session is the date of the session tag: "2006-01-02 15:04:05" or "{immich-go}/2006-01-02 15:04:05".`,
+		Args: cobra.ExactArgs(1),
+	}
+
+	o := &UndoSessionCmd{}
+	o.RegisterFlags(cmd.Flags())
+	o.registerFlags(cmd.Flags(), "server")
+
+	cmd.RunE = o.runE(a, func(ctx context.Context, args []string, out io.Writer) error {
+		return o.run(ctx, a, args[0], out)
+	})
+	return cmd
+}
+
+// undoPlan lists the changes to revert
+type undoPlan struct {
+	stacks         []string        // stacks to delete
+	createdAlbums  []journal.Entry // albums created by the session
+	existingAlbums []journal.Entry // assets to remove from the albums existing before the session
+	createdTags    []journal.Entry // tags created by the session
+	existingTags   []journal.Entry // assets to untag
+	assets         []string        // assets to delete
+	kept           []journal.Entry // replacements kept, their original has been deleted for good
+}
+
+// planUndo computes the changes to revert from the journal and the assets having the session tag
+func planUndo(entries []journal.Entry, tagged []string) undoPlan {
+	var p undoPlan
+	replacements := map[string]bool{}
+	for _, e := range entries {
+		if e.Kind == journal.KindReplace {
+			replacements[e.ID] = true
+			p.kept = append(p.kept, e)
+		}
+	}
+
+	seen := map[string]bool{}
+	addAsset := func(id string) {
+		if id != "" && !seen[id] && !replacements[id] {
+			seen[id] = true
+			p.assets = append(p.assets, id)
+		}
+	}
+
+	albums := map[string]*[]journal.Entry{} // album ID to the list holding the album
+	tags := map[string]*[]journal.Entry{}   // tag ID to the list holding the tag
+	// merge the entries of a collection, the next entries of a created collection are not marked as created
+	merge := func(list *[]journal.Entry, index map[string]*[]journal.Entry, e journal.Entry) {
+		if l, ok := index[e.ID]; ok {
+			i := slices.IndexFunc(*l, func(c journal.Entry) bool { return c.ID == e.ID })
+			(*l)[i].Assets = append((*l)[i].Assets, e.Assets...)
+			return
+		}
+		index[e.ID] = list
+		*list = append(*list, e)
+	}
+	for _, e := range entries {
+		switch e.Kind {
+		case journal.KindUpload:
+			addAsset(e.ID)
+		case journal.KindStack:
+			p.stacks = append(p.stacks, e.ID)
+		case journal.KindAlbum:
+			if e.Created {
+				merge(&p.createdAlbums, albums, e)
+				continue
+			}
+			// the replacements are kept in the albums of their original
+			e.Assets = slices.DeleteFunc(slices.Clone(e.Assets), func(id string) bool { return replacements[id] })
+			merge(&p.existingAlbums, albums, e)
+		case journal.KindTag:
+			if e.Created {
+				merge(&p.createdTags, tags, e)
+				continue
+			}
+			// the replacements keep the tags of their original
+			e.Assets = slices.DeleteFunc(slices.Clone(e.Assets), func(id string) bool { return replacements[id] })
+			merge(&p.existingTags, tags, e)
+		}
+	}
+	for _, id := range tagged {
+		addAsset(id)
+	}
+	return p
+}
+
+// undoLine is a line of the report
+type undoLine struct {
+	status string
+	kind   string
+	name   string
+	detail string
+}
+
+const (
+	undoPlanned     = "to revert"
+	undoReverted    = "reverted"
+	undoFailed      = "failed"
+	undoNotReverted = "not reverted"
+)
+
+func (uc *UndoSessionCmd) run(ctx context.Context, a *app.Application, session string, out io.Writer) error {
+	log := a.Log()
+	if !strings.HasPrefix(session, journal.SessionPrefix) {
+		session = journal.SessionPrefix + session
+	}
+
+	name := uc.Journal
+	if name == "" {
+		var err error
+		name, err = journal.Path(session)
+		if err != nil {
+			return err
+		}
+	}
+	entries, err := journal.Read(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log.Warn("no journal for the session, only the assets with the session tag are deleted", "journal", name)
+	case err != nil && len(entries) == 0:
+		return err
+	case err != nil:
+		log.Warn("the journal is truncated", "journal", name, "err", err)
+	}
+
+	// the assets having the session tag, the journal can be incomplete
+	var tagged []string
+	tags, err := uc.client.Immich.GetAllTags(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if t.Value != session {
+			continue
+		}
+		err = uc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithTags(t.ID), func(ia *immich.Asset) error {
+			tagged = append(tagged, ia.ID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(entries, func(e journal.Entry) bool { return e.Kind == journal.KindTag && e.ID == t.ID }) {
+			entries = append(entries, journal.Entry{Kind: journal.KindTag, ID: t.ID, Name: t.Value, Created: true})
+		}
+	}
+	if len(entries) == 0 && len(tagged) == 0 {
+		return fmt.Errorf("nothing to undo for the session %q", session)
+	}
+
+	p := planUndo(entries, tagged)
+	if err := writeUndoLines(out, p.lines(uc.Force)); err != nil {
+		return err
+	}
+	ok, err := uc.confirm(ctx)
+	if err != nil || !ok {
+		return err
+	}
+
+	lines := uc.undo(ctx, p)
+	if err := writeUndoLines(out, lines); err != nil {
+		return err
+	}
+	failed := 0
+	for _, l := range lines {
+		if l.status == undoFailed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d changes of the session not reverted", failed)
+	}
+	if !uc.client.DryRun && uc.Journal == "" {
+		// the journal is kept for the record
+		if err := os.Rename(name, name+".undone"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warn("can't rename the journal", "err", err)
+		}
+	}
+	return nil
+}
+
+// lines lists the planned changes
+func (p undoPlan) lines(force bool) []undoLine {
+	var lines []undoLine
+	add := func(kind, name, detail string) {
+		lines = append(lines, undoLine{status: undoPlanned, kind: kind, name: name, detail: detail})
+	}
+	for _, id := range p.stacks {
+		add("stack", id, "delete the stack")
+	}
+	for _, e := range p.createdAlbums {
+		add("album", e.Name, "delete the album, or remove its assets when others have been added after the session")
+	}
+	for _, e := range p.existingAlbums {
+		if len(e.Assets) > 0 {
+			add("album", e.Name, fmt.Sprintf("remove %d assets", len(e.Assets)))
+		}
+	}
+	for _, e := range p.createdTags {
+		add("tag", e.Name, "delete the tag")
+	}
+	for _, e := range p.existingTags {
+		if len(e.Assets) > 0 {
+			add("tag", e.Name, fmt.Sprintf("untag %d assets", len(e.Assets)))
+		}
+	}
+	if len(p.assets) > 0 {
+		detail := fmt.Sprintf("move %d assets to the trash", len(p.assets))
+		if force {
+			detail = fmt.Sprintf("delete %d assets for good", len(p.assets))
+		}
+		add("assets", "", detail)
+	}
+	return lines
+}
+
+// writeUndoLines prints the lines as a table
+func writeUndoLines(out io.Writer, lines []undoLine) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tKIND\tNAME\tDETAIL")
+	for _, l := range lines {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", l.status, l.kind, l.name, l.detail)
+	}
+	return w.Flush()
+}
+
+// undo reverts the changes, the stacks first and the assets at last
+func (uc *UndoSessionCmd) undo(ctx context.Context, p undoPlan) []undoLine {
+	var lines []undoLine
+	report := func(kind, name string, err error, detail string) {
+		if err != nil {
+			lines = append(lines, undoLine{status: undoFailed, kind: kind, name: name, detail: err.Error()})
+			return
+		}
+		lines = append(lines, undoLine{status: undoReverted, kind: kind, name: name, detail: detail})
+	}
+
+	for _, id := range p.stacks {
+		report("stack", id, uc.client.Immich.DeleteStack(ctx, id), "stack deleted")
+	}
+
+	for _, e := range p.createdAlbums {
+		// an album completed after the session is kept, only the session's assets are removed
+		info, err := uc.client.Immich.GetAlbumInfo(ctx, e.ID, false)
+		if err != nil {
+			report("album", e.Name, err, "")
+			continue
+		}
+		others := false
+		for _, aa := range info.Assets {
+			if !slices.Contains(e.Assets, aa.ID) {
+				others = true
+				break
+			}
+		}
+		if others {
+			_, err = uc.client.Immich.RemoveAssetFromAlbum(ctx, e.ID, e.Assets)
+			report("album", e.Name, err, "album kept, it has assets added after the session")
+			continue
+		}
+		report("album", e.Name, uc.client.Immich.DeleteAlbum(ctx, e.ID), "album deleted")
+	}
+	for _, e := range p.existingAlbums {
+		if len(e.Assets) == 0 {
+			continue
+		}
+		_, err := uc.client.Immich.RemoveAssetFromAlbum(ctx, e.ID, e.Assets)
+		report("album", e.Name, err, fmt.Sprintf("%d assets removed", len(e.Assets)))
+	}
+
+	for _, e := range p.createdTags {
+		report("tag", e.Name, uc.client.Immich.DeleteTag(ctx, e.ID), "tag deleted")
+	}
+	for _, e := range p.existingTags {
+		if len(e.Assets) == 0 {
+			continue
+		}
+		_, err := uc.client.Immich.UntagAssets(ctx, e.ID, e.Assets)
+		report("tag", e.Name, err, fmt.Sprintf("%d assets untagged", len(e.Assets)))
+	}
+
+	if len(p.assets) > 0 {
+		detail := fmt.Sprintf("%d assets moved to the trash", len(p.assets))
+		if uc.Force {
+			detail = fmt.Sprintf("%d assets deleted", len(p.assets))
+		}
+		report("assets", "", uc.client.Immich.DeleteAssets(ctx, p.assets, uc.Force), detail)
+	}
+
+	for _, e := range p.kept {
+		lines = append(lines, undoLine{
+			status: undoNotReverted,
+			kind:   "replacement",
+			name:   e.Name,
+			detail: fmt.Sprintf("the original %s was deleted for good by the upgrade, the new asset %s is kept", e.Replaced, e.ID),
+		})
+	}
+	return lines
+}
@@ -0,0 +1,42 @@
+package tool
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/simulot/immich-go/internal/journal"
+)
+
+func TestPlanUndo(t *testing.T) {
+	entries := []journal.Entry{
+		{Kind: journal.KindUpload, ID: "a1"},
+		{Kind: journal.KindUpload, ID: "a2"},
+		{Kind: journal.KindReplace, ID: "r1", Replaced: "old1"},
+		{Kind: journal.KindAlbum, ID: "new", Name: "New", Created: true, Assets: []string{"a1"}},
+		{Kind: journal.KindAlbum, ID: "old", Name: "Old", Assets: []string{"a2", "r1"}},
+		{Kind: journal.KindAlbum, ID: "new", Name: "New", Assets: []string{"a2"}},
+		{Kind: journal.KindTag, ID: "t1", Name: "{immich-go}/2024-01-01 00:00:00", Created: true, Assets: []string{"a1"}},
+		{Kind: journal.KindTag, ID: "t2", Name: "family", Assets: []string{"a1", "r1"}},
+		{Kind: journal.KindStack, ID: "st1", Assets: []string{"a1", "a2"}},
+	}
+	p := planUndo(entries, []string{"a1", "a3", "r1"})
+
+	if !slices.Equal(p.assets, []string{"a1", "a2", "a3"}) {
+		t.Errorf("assets: %v", p.assets)
+	}
+	if len(p.kept) != 1 || p.kept[0].Replaced != "old1" {
+		t.Errorf("kept: %+v", p.kept)
+	}
+	if len(p.createdAlbums) != 1 || !slices.Equal(p.createdAlbums[0].Assets, []string{"a1", "a2"}) {
+		t.Errorf("created albums: %+v", p.createdAlbums)
+	}
+	if len(p.existingAlbums) != 1 || !slices.Equal(p.existingAlbums[0].Assets, []string{"a2"}) {
+		t.Errorf("existing albums: %+v", p.existingAlbums)
+	}
+	if len(p.createdTags) != 1 || len(p.existingTags) != 1 || !slices.Equal(p.existingTags[0].Assets, []string{"a1"}) {
+		t.Errorf("tags: %+v %+v", p.createdTags, p.existingTags)
+	}
+	if !slices.Equal(p.stacks, []string{"st1"}) {
+		t.Errorf("stacks: %v", p.stacks)
+	}
+}
@@ -0,0 +1,275 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/adapters/shared"
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	cliflags "github.com/simulot/immich-go/internal/cliFlags"
+	"github.com/simulot/immich-go/internal/exif"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/simulot/immich-go/internal/fshelper/hash"
+	"github.com/simulot/immich-go/internal/namematcher"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
+)
+
+// VerifyCmd compares a local tree of photos with the assets of the server
+type VerifyCmd struct {
+	// CLI flags
+	DateRange   cliflags.DateRange
+	BannedFiles namematcher.List
+	Extra       bool // list the server assets not found locally
+
+	// internal state
+	client app.Client
+}
+
+func (vc *VerifyCmd) RegisterFlags(flags *pflag.FlagSet) {
+	vc.BannedFiles, _ = namematcher.New(shared.DefaultBannedFiles...)
+	flags.Var(&vc.DateRange, "date-range", "Only consider the server assets taken in the date range")
+	flags.Var(&vc.BannedFiles, "ban-file", "Exclude a file based on a pattern (case-insensitive). Can be specified multiple times.")
+	flags.BoolVar(&vc.Extra, "extra", true, "List the server assets not found in the local files")
+}
+
+func NewVerifyCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify [flags] <path>...",
+		Short: "Compare local files with the server's assets using their checksums",
+		Long:  `List the local files missing on the server, the files whose server's asset has a different content, and the server assets not found locally`,
+		Args:  cobra.MinimumNArgs(1),
+	}
+
+	o := &VerifyCmd{}
+	o.RegisterFlags(cmd.Flags())
+	o.client.RegisterFlags(cmd.Flags(), "")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error { //nolint:contextcheck
+		ctx := cmd.Context()
+		err := o.client.Open(ctx, a)
+		if err != nil {
+			return err
+		}
+		defer o.client.Close()
+		o.DateRange.SetTZ(a.GetTZ())
+		return o.run(ctx, a, args, cmd.OutOrStdout())
+	}
+	return cmd
+}
+
+// localFile is a media file of the local tree
+type localFile struct {
+	file     fshelper.FSAndName
+	checksum string
+	size     int64
+	date     time.Time // capture date read in the file, zero when unknown
+}
+
+const (
+	verifyMissing  = "missing"
+	verifyMismatch = "mismatch"
+	verifyExtra    = "extra"
+)
+
+// verifyLine is a difference between the local files and the server
+type verifyLine struct {
+	status string
+	file   string // local file
+	server *immich.Asset
+}
+
+func (vc *VerifyCmd) run(ctx context.Context, a *app.Application, args []string, out io.Writer) error {
+	log := a.Log()
+
+	fsyss, err := fshelper.ParsePath(args)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fshelper.CloseFSs(fsyss) }()
+
+	locals, err := vc.readLocalFiles(ctx, a, fsyss)
+	if err != nil {
+		return err
+	}
+	log.Message("%d local files read", len(locals))
+
+	var mu sync.Mutex
+	var serverAssets []*immich.Asset
+	err = vc.client.Immich.GetFilteredAssetsFn(ctx, immich.SearchOptions().All().WithExif().WithDateRange(vc.DateRange), func(ia *immich.Asset) error {
+		if ia.IsTrashed {
+			return nil
+		}
+		mu.Lock()
+		serverAssets = append(serverAssets, ia)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Message("%d server assets read", len(serverAssets))
+
+	lines, found := compareWithServer(locals, serverAssets, vc.Extra)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tFILE\tSERVER ASSET\tSERVER ID")
+	counts := map[string]int{}
+	for _, l := range lines {
+		counts[l.status]++
+		id := ""
+		if l.server != nil {
+			id = l.server.ID
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", l.status, l.file, serverName(l.server), id)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	log.Message("%d local files checked: %d found on the server, %d missing, %d mismatched", len(locals), found, counts[verifyMissing], counts[verifyMismatch])
+	if vc.Extra {
+		log.Message("%d server assets not found locally", counts[verifyExtra])
+	}
+	if counts[verifyMissing]+counts[verifyMismatch] > 0 {
+		return fmt.Errorf("%d local files are missing or different on the server", counts[verifyMissing]+counts[verifyMismatch])
+	}
+	return nil
+}
+
+// readLocalFiles lists the media files of the file systems, and computes their checksums
+func (vc *VerifyCmd) readLocalFiles(ctx context.Context, a *app.Application, fsyss []fs.FS) ([]localFile, error) {
+	sm := a.GetSupportedMedia()
+	var mu sync.Mutex
+	var locals []localFile
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(a.ConcurrentTask)
+	for _, fsys := range fsyss {
+		err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if d.IsDir() {
+				if name != "." && vc.BannedFiles.MatchDir(name) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if vc.BannedFiles.MatchFile(name) || !sm.IsMedia(path.Ext(name)) {
+				return nil
+			}
+			g.Go(func() error {
+				checksum, err := hash.Base64Encode(hash.FileSHA1Hash(fsys, name))
+				if err != nil {
+					return err
+				}
+				l := localFile{file: fshelper.FSName(fsys, name), checksum: checksum}
+				if fi, err := fs.Stat(fsys, name); err == nil {
+					l.size = fi.Size()
+				}
+				if f, err := fsys.Open(name); err == nil {
+					if md, err := exif.MetadataFromDirectRead(f, name, a.GetTZ()); err == nil && md != nil {
+						l.date = md.DateTaken
+					}
+					f.Close()
+				}
+				mu.Lock()
+				locals = append(locals, l)
+				mu.Unlock()
+				return nil
+			})
+			return nil
+		})
+		if err != nil {
+			_ = g.Wait()
+			return nil, err
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return locals, nil
+}
+
+// compareWithServer matches the local files with the server assets by checksum.
+// A local file without checksum match is mismatched when the server has an asset with the same name
+// and the same capture date or size, it is missing otherwise.
+// It returns the differences, sorted by status and file, and the number of files found on the server.
+func compareWithServer(locals []localFile, serverAssets []*immich.Asset, withExtra bool) ([]verifyLine, int) {
+	byChecksum := map[string]*immich.Asset{}
+	byName := map[string][]*immich.Asset{}
+	for _, sa := range serverAssets {
+		byChecksum[sa.Checksum] = sa
+		byName[sa.OriginalFileName] = append(byName[sa.OriginalFileName], sa)
+	}
+
+	var lines []verifyLine
+	found := 0
+	matched := map[string]bool{}
+	for _, l := range locals {
+		if sa, ok := byChecksum[l.checksum]; ok {
+			matched[sa.ID] = true
+			found++
+			continue
+		}
+		mismatch := false
+		for _, sa := range byName[path.Base(l.file.Name())] {
+			if sameLocalFile(l, sa) {
+				mismatch = true
+				matched[sa.ID] = true
+				lines = append(lines, verifyLine{status: verifyMismatch, file: l.file.FullName(), server: sa})
+			}
+		}
+		if !mismatch {
+			lines = append(lines, verifyLine{status: verifyMissing, file: l.file.FullName()})
+		}
+	}
+
+	if withExtra {
+		for _, sa := range serverAssets {
+			if !matched[sa.ID] {
+				lines = append(lines, verifyLine{status: verifyExtra, server: sa})
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].status != lines[j].status {
+			return lines[i].status < lines[j].status
+		}
+		if lines[i].file != lines[j].file {
+			return lines[i].file < lines[j].file
+		}
+		return serverName(lines[i].server) < serverName(lines[j].server)
+	})
+	return lines, found
+}
+
+// sameLocalFile tells if the server asset having the name of the local file is a version of it
+func sameLocalFile(l localFile, sa *immich.Asset) bool {
+	if l.size > 0 && l.size == sa.ExifInfo.FileSizeInByte {
+		return true
+	}
+	d := sa.ExifInfo.DateTimeOriginal.Time
+	return !l.date.IsZero() && !d.IsZero() && l.date.Truncate(time.Second).Equal(d.Truncate(time.Second))
+}
+
+func serverName(sa *immich.Asset) string {
+	if sa == nil {
+		return ""
+	}
+	return sa.OriginalFileName
+}
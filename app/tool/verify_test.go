@@ -0,0 +1,59 @@
+package tool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+func TestCompareWithServer(t *testing.T) {
+	d := time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC)
+	locals := []localFile{
+		{file: fshelper.FSName(nil, "2024/IMG_0001.jpg"), checksum: "aaa"},
+		{file: fshelper.FSName(nil, "2024/IMG_0002.jpg"), checksum: "bbb", date: d},
+		{file: fshelper.FSName(nil, "2024/IMG_0003.jpg"), checksum: "ccc"},
+		{file: fshelper.FSName(nil, "2025/IMG_0004.jpg"), checksum: "ddd", date: d.AddDate(1, 0, 0), size: 1000},
+	}
+	server := []*immich.Asset{
+		{ID: "1", OriginalFileName: "IMG_0001.jpg", Checksum: "aaa"},
+		{ID: "2", OriginalFileName: "IMG_0002.jpg", Checksum: "xxx"},
+		{ID: "4", OriginalFileName: "IMG_0004.jpg", Checksum: "yyy"}, // another photo with the same name
+		{ID: "9", OriginalFileName: "IMG_0009.jpg", Checksum: "zzz"},
+	}
+	server[1].ExifInfo.DateTimeOriginal.Time = d
+	server[2].ExifInfo.DateTimeOriginal.Time = d
+	server[2].ExifInfo.FileSizeInByte = 2000
+
+	lines, found := compareWithServer(locals, server, true)
+	if found != 1 {
+		t.Errorf("expected 1 file found, got %d", found)
+	}
+	want := []struct {
+		status, file, serverID string
+	}{
+		{verifyExtra, "", "4"},
+		{verifyExtra, "", "9"},
+		{verifyMismatch, "2024/IMG_0002.jpg", "2"},
+		{verifyMissing, "2024/IMG_0003.jpg", ""},
+		{verifyMissing, "2025/IMG_0004.jpg", ""},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %+v", len(want), len(lines), lines)
+	}
+	for i, w := range want {
+		id := ""
+		if lines[i].server != nil {
+			id = lines[i].server.ID
+		}
+		if lines[i].status != w.status || lines[i].file != w.file || id != w.serverID {
+			t.Errorf("line %d: got %s %q %q, want %s %q %q", i, lines[i].status, lines[i].file, id, w.status, w.file, w.serverID)
+		}
+	}
+
+	lines, _ = compareWithServer(locals, server, false)
+	if len(lines) != 3 {
+		t.Errorf("expected 3 lines without the extra assets, got %d", len(lines))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"path"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/simulot/immich-go/internal/assets"
 	"github.com/simulot/immich-go/internal/gen/syncmap"
 	"github.com/simulot/immich-go/internal/gen/syncset"
+	"github.com/simulot/immich-go/internal/quality"
 )
 
 // - - go:generate stringer -type=AdviceCode
@@ -61,6 +64,9 @@ type immichIndex struct {
 	// map of base name to assetID
 	byName *syncmap.SyncMap[string, []string]
 
+	// map of the lower case name without extension to assetID, for the formats used by the exports
+	byStem *syncmap.SyncMap[string, []string]
+
 	// map of SHA1 to assetID
 	byChecksum *syncmap.SyncMap[string, *assets.Asset]
 
@@ -72,6 +78,7 @@ func newAssetIndex() *immichIndex {
 		immichAssets:    syncmap.New[string, *assets.Asset](),
 		byChecksum:      syncmap.New[string, *assets.Asset](),
 		byName:          syncmap.New[string, []string](),
+		byStem:          syncmap.New[string, []string](),
 		uploadsChecksum: syncset.New[string](),
 	}
 }
@@ -144,9 +151,30 @@ func (ii *immichIndex) add(a *assets.Asset, local bool) *assets.Asset {
 	l, _ := ii.byName.Load(filename)
 	l = append(l, a.ID)
 	ii.byName.Store(filename, l)
+	ii.addStem(a)
 	return a
 }
 
+// addStem indexes the asset by its name without extension, when its format is used by the exports
+func (ii *immichIndex) addStem(a *assets.Asset) {
+	stem, ok := stemOf(a.OriginalFileName)
+	if !ok {
+		return
+	}
+	l, _ := ii.byStem.Load(stem)
+	l = append(l, a.ID)
+	ii.byStem.Store(stem, l)
+}
+
+// stemOf returns the lower case name without extension of the formats used by the exports
+func stemOf(name string) (string, bool) {
+	ext := path.Ext(name)
+	if !quality.Interchangeable(strings.TrimPrefix(strings.ToLower(ext), ".")) {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSuffix(name, ext)), true
+}
+
 func (ii *immichIndex) replaceAsset(newA *assets.Asset, oldA *assets.Asset) *assets.Asset {
 	if newA.ID == "" {
 		panic("asset ID is empty")
@@ -165,6 +193,7 @@ func (ii *immichIndex) replaceAsset(newA *assets.Asset, oldA *assets.Asset) *ass
 	l, _ := ii.byName.Load(filename)
 	l = append(l, newA.ID)
 	ii.byName.Store(filename, l)
+	ii.addStem(newA)
 	return newA
 }
 
@@ -195,30 +224,38 @@ func formatBytes(s int64) string {
 	return fmt.Sprintf("%.1f %s", roundedSize, suffixes[exp])
 }
 
-func (ii *immichIndex) adviceSameOnServer(sa *assets.Asset) *Advice {
+func (ii *immichIndex) adviceSameOnServer(sa *assets.Asset, reason string) *Advice {
 	return &Advice{
 		Advice:      SameOnServer,
-		Message:     fmt.Sprintf("An asset with the same name:%q, date:%q and size:%s exists on the server. No need to upload.", sa.OriginalFileName, sa.CaptureDate.Format(time.DateTime), formatBytes(int64(sa.FileSize))),
+		Message:     fmt.Sprintf("An asset with the same name:%q, date:%q and size:%s exists on the server. No need to upload.", sa.OriginalFileName, sa.CaptureDate.Format(time.DateTime), formatBytes(int64(sa.FileSize))) + because(reason),
 		ServerAsset: sa,
 	}
 }
 
-func (ii *immichIndex) adviceSmallerOnServer(sa *assets.Asset) *Advice {
+func (ii *immichIndex) adviceSmallerOnServer(sa *assets.Asset, reason string) *Advice {
 	return &Advice{
 		Advice:      SmallerOnServer,
-		Message:     fmt.Sprintf("An asset with the same name:%q and date:%q but with smaller size:%s exists on the server. Replace it.", sa.OriginalFileName, sa.CaptureDate.Format(time.DateTime), formatBytes(int64(sa.FileSize))),
+		Message:     fmt.Sprintf("An asset with the same name:%q and date:%q but of lower quality exists on the server. Replace it.", sa.OriginalFileName, sa.CaptureDate.Format(time.DateTime)) + because(reason),
 		ServerAsset: sa,
 	}
 }
 
-func (ii *immichIndex) adviceBetterOnServer(sa *assets.Asset) *Advice {
+func (ii *immichIndex) adviceBetterOnServer(sa *assets.Asset, reason string) *Advice {
 	return &Advice{
 		Advice:      BetterOnServer,
-		Message:     fmt.Sprintf("An asset with the same name:%q and date:%q but with bigger size:%s exists on the server. No need to upload.", sa.OriginalFileName, sa.CaptureDate.Format(time.DateTime), formatBytes(int64(sa.FileSize))),
+		Message:     fmt.Sprintf("An asset with the same name:%q and date:%q but of better quality exists on the server. No need to upload.", sa.OriginalFileName, sa.CaptureDate.Format(time.DateTime)) + because(reason),
 		ServerAsset: sa,
 	}
 }
 
+// because formats the reason of the decision of the quality policy
+func because(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return " Reason: " + reason + "."
+}
+
 func (ii *immichIndex) adviceAlreadyProcessed(sa *assets.Asset) *Advice {
 	return &Advice{
 		Advice:      AlreadyProcessed,
@@ -261,39 +298,57 @@ func (ii *immichIndex) ShouldUpload(la *assets.Asset, upCmd *UpCmd) (*Advice, er
 		if ii.isAlreadyProcessed(checksum) {
 			return ii.adviceAlreadyProcessed(sa), nil
 		}
-		return ii.adviceSameOnServer(sa), nil
+		return ii.adviceSameOnServer(sa, "same checksum"), nil
 	}
 
 	filename := path.Base(la.File.Name())
 
 	// check all files with the same name
-	ids, ok := ii.byName.Load(filename)
+	ids, _ := ii.byName.Load(filename)
+	if upCmd.Prefer.Has(quality.Format) {
+		// the exports of the photo in another format are other versions of the same photo
+		if stem, ok := stemOf(filename); ok {
+			others, _ := ii.byStem.Load(stem)
+			for _, id := range others {
+				if !slices.Contains(ids, id) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
 
-	if ok && len(ids) > 0 {
+	if len(ids) > 0 {
 		dateTaken := la.CaptureDate
 		if dateTaken.IsZero() {
 			dateTaken = la.FileDate
 		}
-		size := int64(la.FileSize)
 
+		var local quality.Info
+		localRead := false
 		for _, id := range ids {
 			sa, ok := ii.immichAssets.Load(id)
 			if !ok {
 				continue
 			}
 
-			compareDate := compareDate(dateTaken, sa.CaptureDate)
-			compareSize := size - int64(sa.FileSize)
-
-			switch {
-			case compareDate == 0 && upCmd.Overwrite:
+			if compareDate(dateTaken, sa.CaptureDate) != 0 {
+				continue
+			}
+			if upCmd.Overwrite {
 				return ii.adviceForceUpload(sa), nil
-			case compareDate == 0 && compareSize == 0:
-				return ii.adviceSameOnServer(sa), nil
-			case compareDate == 0 && compareSize > 0:
-				return ii.adviceSmallerOnServer(sa), nil
-			case compareDate == 0 && compareSize < 0:
-				return ii.adviceBetterOnServer(sa), nil
+			}
+			if !localRead {
+				local = quality.Of(la, upCmd.source, upCmd.Prefer.Has(quality.Resolution) || upCmd.Prefer.Has(quality.BitDepth))
+				localRead = true
+			}
+			cmp, reason := upCmd.Prefer.Compare(local, quality.Of(sa, quality.ServerSource, false))
+			switch {
+			case cmp == 0:
+				return ii.adviceSameOnServer(sa, reason), nil
+			case cmp > 0:
+				return ii.adviceSmallerOnServer(sa, reason), nil
+			default:
+				return ii.adviceBetterOnServer(sa, reason), nil
 			}
 		}
 	}
@@ -0,0 +1,357 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/simulot/immich-go/app"
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/spf13/cobra"
+)
+
+// ApplyPlanCmd executes the plan written by upload --plan-out
+type ApplyPlanCmd struct {
+	client app.Client
+
+	// internal state
+	app     *app.Application
+	ids     map[string]string // file to server's asset ID
+	sums    map[string]string // checksum of the files to server's asset ID
+	albums  map[string]string // album title to album ID
+	refused int
+	errors  int
+	applied int
+}
+
+// NewApplyPlanCommand creates the command executing a plan
+func NewApplyPlanCommand(ctx context.Context, a *app.Application) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply-plan [flags] <plan.jsonl>",
+		Short: "Execute the plan written by upload --plan-out",
+		Long: `Execute the actions of a plan written by the upload command with the flag --plan-out.
+An action is refused when its file or the server's asset has changed since the planning.`,
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.SetContext(ctx)
+	ac := &ApplyPlanCmd{app: a}
+	ac.client.RegisterFlags(cmd.Flags(), "")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error { //nolint:contextcheck
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		entries, err := readPlan(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		err = ac.client.Open(ctx, a)
+		if err != nil {
+			return err
+		}
+		defer ac.client.Close()
+		return ac.run(ctx, entries)
+	}
+	return cmd
+}
+
+func (ac *ApplyPlanCmd) run(ctx context.Context, entries []planEntry) error {
+	log := ac.app.Log()
+	ac.ids = map[string]string{}
+	ac.sums = map[string]string{}
+	ac.albums = map[string]string{}
+
+	albums, err := ac.client.Immich.GetAllAlbums(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get the album list from the server: %w", err)
+	}
+	for _, al := range albums {
+		ac.albums[al.AlbumName] = al.ID
+	}
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := ac.apply(ctx, e)
+		var refusal *refusedError
+		switch {
+		case errors.As(err, &refusal):
+			ac.refused++
+			log.Warn("plan entry refused", "action", e.Action, "file", e.File, "name", e.Name, "reason", refusal.reason)
+		case err != nil:
+			ac.errors++
+			log.Error("plan entry failed", "action", e.Action, "file", e.File, "name", e.Name, "err", err)
+		default:
+			ac.applied++
+		}
+	}
+
+	log.Message("%d plan entries applied, %d refused, %d errors", ac.applied, ac.refused, ac.errors)
+	if ac.refused > 0 || ac.errors > 0 {
+		return fmt.Errorf("%d plan entries not applied", ac.refused+ac.errors)
+	}
+	return nil
+}
+
+// refusedError signals an entry not applied because the file or the server has changed
+type refusedError struct {
+	reason string
+}
+
+func (e *refusedError) Error() string { return e.reason }
+
+func refused(format string, args ...any) error {
+	return &refusedError{reason: fmt.Sprintf(format, args...)}
+}
+
+func (ac *ApplyPlanCmd) apply(ctx context.Context, e planEntry) error {
+	switch e.Action {
+	case planUpload:
+		if err := checkFile(e); err != nil {
+			return refused("%s", err)
+		}
+		return ac.upload(ctx, e)
+
+	case planReplace:
+		if err := checkFile(e); err != nil {
+			return refused("%s", err)
+		}
+		if err := ac.checkServer(ctx, e); err != nil {
+			return err
+		}
+		return ac.replace(ctx, e)
+
+	case planSkip:
+		if err := checkFile(e); err != nil {
+			return refused("%s", err)
+		}
+		if e.ServerID == "" {
+			// duplicate of a file of the plan, it's the same server's asset
+			id, ok := ac.sums[e.Checksum]
+			if !ok {
+				return refused("the duplicated file of the plan hasn't been applied")
+			}
+			ac.setID(e, id)
+			return nil
+		}
+		if err := ac.checkServer(ctx, e); err != nil {
+			return err
+		}
+		ac.setID(e, e.ServerID)
+		return nil
+
+	case planAlbum:
+		return ac.album(ctx, e)
+
+	case planTag:
+		return ac.tag(ctx, e)
+
+	case planStack:
+		ids := ac.stackOf(e)
+		if len(ids) < 2 {
+			return refused("not enough assets to stack")
+		}
+		_, err := ac.client.Immich.CreateStack(ctx, ids)
+		return err
+
+	case planDelete:
+		if err := ac.checkServer(ctx, e); err != nil {
+			return err
+		}
+		return ac.client.Immich.DeleteAssets(ctx, []string{e.ServerID}, false)
+	}
+	return fmt.Errorf("unknown action %q", e.Action)
+}
+
+// setID records the server's asset of the entry's file
+func (ac *ApplyPlanCmd) setID(e planEntry, id string) {
+	ac.ids[e.File] = id
+	if e.Checksum != "" && id != "" {
+		ac.sums[e.Checksum] = id
+	}
+}
+
+// checkServer tells if the server's asset is still the one of the plan
+func (ac *ApplyPlanCmd) checkServer(ctx context.Context, e planEntry) error {
+	sa, err := ac.client.Immich.GetAssetInfo(ctx, e.ServerID)
+	if err != nil {
+		return refused("the server's asset %s is not readable: %s", e.ServerID, err)
+	}
+	if sa.IsTrashed {
+		return refused("the server's asset %s is in the trash", e.ServerID)
+	}
+	if e.ServerChecksum != "" && sa.Checksum != e.ServerChecksum {
+		return refused("the server's asset %s has changed since the planning", e.ServerID)
+	}
+	return nil
+}
+
+// assetOf returns the asset to upload for the entry
+func (ac *ApplyPlanCmd) assetOf(e planEntry) *assets.Asset {
+	fsys := fshelper.NewFSWithName(filepath.Dir(e.File))
+	a := &assets.Asset{
+		File:             fshelper.FSName(fsys, filepath.Base(e.File)),
+		OriginalFileName: filepath.Base(e.File),
+		FileSize:         int(e.Size),
+		FileDate:         e.ModTime,
+		Checksum:         e.Checksum,
+	}
+	if e.Asset != nil {
+		a.OriginalFileName = e.Asset.OriginalFileName
+		a.CaptureDate = e.Asset.CaptureDate
+		a.Favorite = e.Asset.Favorite
+		a.Archived = e.Asset.Archived
+		a.Visibility = e.Asset.Visibility
+	}
+	if e.Sidecar != "" {
+		scFS := fshelper.NewFSWithName(filepath.Dir(e.Sidecar))
+		a.FromSideCar = &assets.Metadata{File: fshelper.FSName(scFS, filepath.Base(e.Sidecar))}
+	}
+	return a
+}
+
+func (ac *ApplyPlanCmd) upload(ctx context.Context, e planEntry) error {
+	a := ac.assetOf(e)
+	defer a.Close()
+	ar, err := ac.client.Immich.AssetUpload(ctx, a)
+	if err != nil {
+		return err
+	}
+	if ar.Status == immich.UploadDuplicate {
+		ac.setID(e, ar.ID)
+		return refused("the server has got the asset since the planning")
+	}
+	ac.setID(e, ar.ID)
+	if e.Update != nil {
+		_, err = ac.client.Immich.UpdateAsset(ctx, ar.ID, *e.Update)
+		if err != nil {
+			return err
+		}
+	}
+	ac.app.Log().Info("uploaded", "file", e.File, "id", ar.ID)
+	return nil
+}
+
+// replace uploads the file, copies the metadata of the server's asset and deletes it
+func (ac *ApplyPlanCmd) replace(ctx context.Context, e planEntry) error {
+	a := ac.assetOf(e)
+	defer a.Close()
+	ar, err := ac.client.Immich.AssetUpload(ctx, a)
+	if err != nil {
+		return err
+	}
+	if ar.Status == immich.UploadDuplicate {
+		ac.setID(e, ar.ID)
+		return refused("the server has got the asset since the planning")
+	}
+	ac.setID(e, ar.ID)
+	err = ac.client.Immich.CopyAsset(ctx, e.ServerID, ar.ID)
+	if err != nil {
+		return err
+	}
+	// the trash is skipped: the metadata and the albums are copied to the new asset
+	err = ac.client.Immich.DeleteAssets(ctx, []string{e.ServerID}, true)
+	if err != nil {
+		return err
+	}
+	ac.app.Log().Info("replaced", "file", e.File, "id", ar.ID, "replaced", e.ServerID)
+	return nil
+}
+
+func (ac *ApplyPlanCmd) album(ctx context.Context, e planEntry) error {
+	ids := ac.assetsOf(e)
+	if len(ids) == 0 {
+		return refused("no asset to add to the album %s", e.Name)
+	}
+	id := e.ServerID
+	if id != "" {
+		found := false
+		for _, known := range ac.albums {
+			if known == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return refused("the album %s has been deleted since the planning", e.Name)
+		}
+	} else {
+		id = ac.albums[e.Name]
+	}
+	if id == "" {
+		al, err := ac.client.Immich.CreateAlbum(ctx, e.Name, e.Description, ids)
+		if err != nil {
+			return err
+		}
+		ac.albums[e.Name] = al.ID
+		ac.app.Log().Info("created album", "album", e.Name, "assets", len(ids))
+		return nil
+	}
+	_, err := ac.client.Immich.AddAssetToAlbum(ctx, id, ids)
+	if err != nil {
+		return err
+	}
+	ac.app.Log().Info("updated album", "album", e.Name, "assets", len(ids))
+	return nil
+}
+
+func (ac *ApplyPlanCmd) tag(ctx context.Context, e planEntry) error {
+	ids := ac.assetsOf(e)
+	if len(ids) == 0 {
+		return refused("no asset to tag with %s", e.Name)
+	}
+	id := e.ServerID
+	if id == "" {
+		r, err := ac.client.Immich.UpsertTags(ctx, []string{e.Name})
+		if err != nil {
+			return err
+		}
+		id = r[0].ID
+	}
+	_, err := ac.client.Immich.TagAssets(ctx, id, ids)
+	if err != nil {
+		return err
+	}
+	ac.app.Log().Info("updated tag", "tag", e.Name, "assets", len(ids))
+	return nil
+}
+
+// assetsOf returns the server's assets of the entry followed by the IDs of its files applied so far
+func (ac *ApplyPlanCmd) assetsOf(e planEntry) []string {
+	return append(slices.Clone(e.ServerAssets), ac.idsOf(e.Files)...)
+}
+
+// stackOf returns the server's IDs of the stack's members in the order of the plan, the cover first.
+// The files not applied so far are dropped.
+func (ac *ApplyPlanCmd) stackOf(e planEntry) []string {
+	ids := make([]string, 0, len(e.Stack))
+	for _, m := range e.Stack {
+		if m.File == "" {
+			ids = append(ids, m.ServerAsset)
+		} else if id, ok := ac.ids[m.File]; ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// idsOf returns the server's IDs of the files applied so far
+func (ac *ApplyPlanCmd) idsOf(files []string) []string {
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		if id, ok := ac.ids[f]; ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
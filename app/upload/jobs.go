@@ -0,0 +1,160 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+)
+
+// jobsPollInterval is the delay between two readings of the server's job queues
+const jobsPollInterval = 2 * time.Second
+
+// startableJobs are the values of --start-jobs and the server's queues they start.
+// The queues are started without force: only the assets missing the result are processed, like the uploaded ones.
+// The whole queue is started: the server's CreateJob only accepts the maintenance jobs, not these ones,
+// and it can't be limited to the uploaded assets.
+var startableJobs = map[string]string{
+	"thumbnails": "thumbnailGeneration",
+	"duplicates": "duplicateDetection",
+}
+
+// jobQueue is the state of a server's job queue
+type jobQueue struct {
+	Name    string
+	Active  int
+	Waiting int
+	Delayed int
+	Failed  int
+	Paused  bool
+}
+
+// pending is the number of jobs not yet done by the queue
+func (q jobQueue) pending() int {
+	return q.Active + q.Waiting + q.Delayed
+}
+
+// jobQueues returns the queues having jobs, sorted by name
+func jobQueues(jobs map[string]immich.Job) []jobQueue {
+	queues := []jobQueue{}
+	for name, j := range jobs {
+		q := jobQueue{
+			Name:    name,
+			Active:  j.JobCounts.Active,
+			Waiting: j.JobCounts.Waiting,
+			Delayed: j.JobCounts.Delayed,
+			Failed:  j.JobCounts.Failed,
+			Paused:  j.QueueStatus.IsPaused,
+		}
+		if q.pending() > 0 || q.Failed > 0 {
+			queues = append(queues, q)
+		}
+	}
+	slices.SortFunc(queues, func(a, b jobQueue) int { return strings.Compare(a.Name, b.Name) })
+	return queues
+}
+
+// drained tells if the queues have no more jobs to do. The paused queues are not waited for.
+func drained(queues []jobQueue) bool {
+	for _, q := range queues {
+		if !q.Paused && q.pending() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeJobQueues prints the queues as a table
+func writeJobQueues(w io.Writer, queues []jobQueue) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Queue\tActive\tWaiting\tDelayed\tFailed\t")
+	for _, q := range queues {
+		name := q.Name
+		if q.Paused {
+			name += " (paused)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t\n", name, q.Active, q.Waiting, q.Delayed, q.Failed)
+	}
+	tw.Flush()
+}
+
+// waitForJobs polls the server's job queues until they are drained or the timeout elapses.
+// The queues are given to the report function after each reading.
+// The queues must be seen drained twice in a row: the server queues the next jobs of an asset when one ends.
+func waitForJobs(ctx context.Context, getJobs func(context.Context) (map[string]immich.Job, error), interval, timeout time.Duration, report func([]jobQueue)) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	idle := 0
+	for {
+		jobs, err := getJobs(ctx)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return false, nil
+			}
+			return false, err
+		}
+		queues := jobQueues(jobs)
+		report(queues)
+		if drained(queues) {
+			idle++
+			if idle > 1 {
+				return true, nil
+			}
+		} else {
+			idle = 0
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return false, nil
+			}
+			return false, ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// startJobs starts the queues given by --start-jobs
+func (uc *UpCmd) startJobs(ctx context.Context) error {
+	for _, job := range uc.StartJobs {
+		name := startableJobs[job]
+		_, err := uc.client.AdminImmich.SendJobCommand(ctx, name, immich.Start, false)
+		if err != nil {
+			uc.app.Log().Error("Immich Job command sent", "start", name, "err", err.Error())
+			return err
+		}
+		uc.app.Log().Info("Immich Job command sent", "start", name)
+	}
+	return nil
+}
+
+// waitServerJobs waits for the server to process the uploaded assets, the queues are shown by the user interface
+func (uc *UpCmd) waitServerJobs(ctx context.Context) error {
+	report := uc.showJobs
+	if report == nil {
+		report = func(queues []jobQueue) {
+			for _, q := range queues {
+				uc.app.Log().Info("Immich Job queue", "queue", q.Name, "active", q.Active, "waiting", q.Waiting, "delayed", q.Delayed, "failed", q.Failed)
+			}
+		}
+	}
+	uc.app.Log().Message("Waiting for the server to process the assets")
+	done, err := waitForJobs(ctx, uc.client.AdminImmich.GetJobs, jobsPollInterval, uc.WaitTimeout, report)
+	if err != nil {
+		return fmt.Errorf("can't get the server's jobs: %w", err)
+	}
+	if !done {
+		uc.app.Log().Warn("the server's jobs are still running after the timeout", "timeout", uc.WaitTimeout)
+		return nil
+	}
+	uc.app.Log().Message("The server has processed the assets")
+	return nil
+}
@@ -0,0 +1,81 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+)
+
+func job(active, waiting int, paused bool) immich.Job {
+	var j immich.Job
+	j.JobCounts.Active = active
+	j.JobCounts.Waiting = waiting
+	j.QueueStatus.IsPaused = paused
+	return j
+}
+
+func TestJobQueues(t *testing.T) {
+	queues := jobQueues(map[string]immich.Job{
+		"thumbnailGeneration": job(1, 10, false),
+		"metadataExtraction":  job(0, 0, false),
+		"faceDetection":       job(0, 5, true),
+	})
+	want := []jobQueue{
+		{Name: "faceDetection", Waiting: 5, Paused: true},
+		{Name: "thumbnailGeneration", Active: 1, Waiting: 10},
+	}
+	if !reflect.DeepEqual(queues, want) {
+		t.Errorf("jobQueues() = %v, want %v", queues, want)
+	}
+	if drained(queues) {
+		t.Errorf("drained() = true with a running queue")
+	}
+	if !drained(queues[:1]) {
+		t.Errorf("drained() = false with a paused queue")
+	}
+}
+
+func TestWaitForJobs(t *testing.T) {
+	// the server reads, the queue seems drained once, then gets the next jobs
+	readings := []map[string]immich.Job{
+		{"metadataExtraction": job(2, 3, false)},
+		{"metadataExtraction": job(0, 0, false)},
+		{"thumbnailGeneration": job(1, 0, false)},
+		{"thumbnailGeneration": job(0, 0, false)},
+		{"thumbnailGeneration": job(0, 0, false)},
+	}
+	calls := 0
+	getJobs := func(context.Context) (map[string]immich.Job, error) {
+		r := readings[min(calls, len(readings)-1)]
+		calls++
+		return r, nil
+	}
+	reports := 0
+	done, err := waitForJobs(context.Background(), getJobs, time.Millisecond, time.Minute, func([]jobQueue) { reports++ })
+	if err != nil || !done {
+		t.Fatalf("waitForJobs() = %v, %v", done, err)
+	}
+	if calls != len(readings) || reports != len(readings) {
+		t.Errorf("waitForJobs() read the jobs %d times and reported %d times, want %d", calls, reports, len(readings))
+	}
+
+	// the queue never ends
+	busy := func(context.Context) (map[string]immich.Job, error) {
+		return map[string]immich.Job{"smartSearch": job(1, 100, false)}, nil
+	}
+	done, err = waitForJobs(context.Background(), busy, time.Millisecond, 20*time.Millisecond, func([]jobQueue) {})
+	if err != nil || done {
+		t.Errorf("waitForJobs() after the timeout = %v, %v", done, err)
+	}
+
+	failure := errors.New("forbidden")
+	failing := func(context.Context) (map[string]immich.Job, error) { return nil, failure }
+	_, err = waitForJobs(context.Background(), failing, time.Millisecond, time.Minute, func([]jobQueue) {})
+	if !errors.Is(err, failure) {
+		t.Errorf("waitForJobs() error = %v, want %v", err, failure)
+	}
+}
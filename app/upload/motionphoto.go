@@ -0,0 +1,51 @@
+package upload
+
+import (
+	"context"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/motionphoto"
+)
+
+// handleMotionPhoto uploads the motion photo as a still image and a video linked as a live photo.
+// The file is uploaded whole when it isn't a motion photo.
+func (uc *UpCmd) handleMotionPhoto(ctx context.Context, a *assets.Asset) error {
+	if !uc.SplitMotion || uc.plan != nil {
+		return uc.handleAsset(ctx, a)
+	}
+	still, video, err := motionphoto.SplitAsset(a)
+	if err != nil {
+		uc.app.Log().Warn("can't read the motion photo, the file is uploaded whole", "file", a.File, "err", err)
+		return uc.handleAsset(ctx, a)
+	}
+	if video == nil {
+		return uc.handleAsset(ctx, a)
+	}
+	if err := a.SetContent(still); err != nil {
+		return err
+	}
+	// the server is asked about the still image, uploaded by an earlier run.
+	// The still image is handled alone when the server has it or a version of it.
+	if advice, err := uc.assetIndex.ShouldUpload(a, uc); err != nil || advice.Advice != NotOnServer {
+		return uc.handleAsset(ctx, a)
+	}
+
+	// the video is uploaded first, the file is handled after the upload of the still image
+	uc.app.FileProcessor().RecordAssetDiscovered(ctx, video.File, int64(video.FileSize), fileevent.DiscoveredVideo)
+	if err := uc.handleAsset(ctx, video); err != nil || video.ID == "" {
+		a.Close()
+		uc.app.FileProcessor().RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, "the video of the motion photo isn't uploaded")
+		return err
+	}
+	if err := uc.handleAsset(ctx, a); err != nil || a.ID == "" {
+		return err
+	}
+
+	if err := uc.client.Immich.LinkLivePhoto(ctx, a.ID, video.ID); err != nil {
+		uc.app.Log().Error("Can't link the still image and the video of the motion photo", "file", a.File, "error", err)
+		return nil
+	}
+	uc.app.FileProcessor().Logger().Record(ctx, fileevent.ProcessedLivePhoto, a.File, "video", video.File.Name())
+	return nil
+}
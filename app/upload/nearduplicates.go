@@ -0,0 +1,157 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fileevent"
+	"github.com/simulot/immich-go/internal/journal"
+	"github.com/simulot/immich-go/internal/phash"
+)
+
+// NearMode tells what to do with the images having a near-duplicate on the server
+type NearMode string
+
+const (
+	NearNone  NearMode = "none"  // upload them
+	NearSkip  NearMode = "skip"  // don't upload them, their albums are given to the server's asset
+	NearStack NearMode = "stack" // upload them and stack them with the server's asset
+	NearTag   NearMode = "tag"   // upload them and tag both assets with nearDuplicateTag
+)
+
+// nearDuplicateTag is given to the near-duplicates with --near-duplicates=tag.
+// It's out of the session tags namespace: undo-session mustn't take it for a session.
+const nearDuplicateTag = "{immich-go}-near-duplicate"
+
+func (m NearMode) String() string {
+	if m == "" {
+		return string(NearNone)
+	}
+	return string(m)
+}
+
+func (m *NearMode) Set(v string) error {
+	switch mode := NearMode(strings.ToLower(strings.TrimSpace(v))); mode {
+	case NearNone, NearSkip, NearStack, NearTag:
+		*m = mode
+		return nil
+	}
+	return fmt.Errorf("invalid value %q, expected none, skip, stack or tag", v)
+}
+
+func (m NearMode) Type() string {
+	return "nearMode"
+}
+
+func (m NearMode) enabled() bool {
+	return m != "" && m != NearNone
+}
+
+// nearThumbnail tells if the perceptual hash of the server's asset is needed
+func (uc *UpCmd) nearThumbnail(a *immich.Asset) (phash.Thumbnail, bool) {
+	if !uc.NearDuplicates.enabled() || a.Type != "IMAGE" || a.IsTrashed {
+		return phash.Thumbnail{}, false
+	}
+	return phash.Thumbnail{ID: a.ID, Checksum: a.Checksum}, true
+}
+
+// indexNearDuplicates computes the perceptual hashes of the server's images with their thumbnails.
+// The hashes are kept in the user's cache folder for the next uploads.
+func (uc *UpCmd) indexNearDuplicates(ctx context.Context, thumbnails []phash.Thumbnail) error {
+	uc.nearIndex = &phash.Index{}
+	var c *phash.Cache
+	name, err := phash.CachePath()
+	if err == nil {
+		c, err = phash.OpenCache(name)
+	}
+	if err != nil {
+		uc.app.Log().Warn("can't read the perceptual hashes cache", "err", err)
+	}
+
+	uc.app.Log().Message("Computing the perceptual hashes of %d server images", len(thumbnails))
+	failed, err := phash.IndexThumbnails(ctx, uc.nearIndex, c, thumbnails, uc.app.ConcurrentTask, uc.client.Immich.DownloadThumbnail)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		uc.app.Log().Warn("some server thumbnails can't be read, their images are not compared", "count", failed)
+	}
+	if err := c.Save(); err != nil {
+		uc.app.Log().Warn("can't write the perceptual hashes cache", "err", err)
+	}
+	return nil
+}
+
+// nearDuplicate returns the server's image the nearest to the asset, within the distance set by --near-distance.
+// Only the images decoded by Go are compared, the HEIC and raw files are not.
+// The EXIF thumbnail is used when the image has one.
+func (uc *UpCmd) nearDuplicate(a *assets.Asset) (*assets.Asset, int) {
+	if uc.nearIndex == nil || !phash.Decodable(strings.ToLower(path.Ext(a.File.Name()))) {
+		return nil, 0
+	}
+	f, err := a.OpenFile()
+	if err != nil {
+		uc.app.Log().Debug("can't open the file for the perceptual hash", "file", a.File, "err", err)
+		return nil, 0
+	}
+	h, err := phash.ReadPreview(f)
+	f.Close()
+	if err != nil {
+		uc.app.Log().Debug("can't compute the perceptual hash", "file", a.File, "err", err)
+		return nil, 0
+	}
+	for _, m := range uc.nearIndex.Near(h, uc.NearDistance) {
+		if sa := uc.assetIndex.getByID(m.ID); sa != nil {
+			return sa, m.Distance
+		}
+	}
+	return nil, 0
+}
+
+// skipNearDuplicate keeps the server's asset, the albums of the local asset are given to it
+func (uc *UpCmd) skipNearDuplicate(ctx context.Context, a *assets.Asset, sa *assets.Asset, distance int) error {
+	reason := fmt.Sprintf("near-duplicate of %s at distance %d", sa.OriginalFileName, distance)
+	a.ID = sa.ID
+	if err := uc.planAsset(planSkip, a, sa, reason); err != nil {
+		return err
+	}
+	uc.app.FileProcessor().RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedNearDuplicate, reason)
+	uc.manageAssetAlbums(ctx, a.File, a.ID, a.Albums)
+	return nil
+}
+
+// linkNearDuplicate stacks or tags the uploaded asset with its near-duplicate
+func (uc *UpCmd) linkNearDuplicate(ctx context.Context, a *assets.Asset, sa *assets.Asset) {
+	if a.ID == "" {
+		return
+	}
+	switch uc.NearDuplicates {
+	case NearStack:
+		// the server's asset stays the cover
+		ids := []string{sa.ID, a.ID}
+		uc.app.FileProcessor().RecordNonAsset(ctx, a.File, 0, fileevent.ProcessedStacked)
+		if uc.plan != nil {
+			err := uc.plan.write(planEntry{Action: planStack, Stack: uc.plan.membersOf(ids)})
+			if err != nil {
+				uc.app.Log().Error("can't write the plan", "err", err)
+			}
+			return
+		}
+		stackID, err := uc.client.Immich.CreateStack(ctx, ids)
+		if err != nil {
+			uc.app.Log().Error("Can't stack the near-duplicates", "file", a.File, "server asset", sa.OriginalFileName, "error", err)
+			return
+		}
+		uc.record(journal.Entry{Kind: journal.KindStack, ID: stackID, Assets: ids})
+	case NearTag:
+		// the uploaded asset has got the tag before the upload
+		t := assets.Tag{Name: nearDuplicateTag, Value: nearDuplicateTag}
+		if uc.tagsCache.AddIDToCollection(t.Name, t, sa.ID) {
+			uc.app.FileProcessor().Logger().Record(ctx, fileevent.ProcessedTagged, a.File, "tag", t.Value, "near-duplicate", sa.OriginalFileName)
+		}
+	}
+}
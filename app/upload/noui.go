@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -23,6 +25,7 @@ func (uc *UpCmd) runNoUI(ctx context.Context, app *app.Application) error {
 	var preparationDone atomic.Bool
 
 	stopProgress := make(chan any)
+	progressDone := make(chan any)
 	var maxImmich, currImmich int
 	spinner := []rune{' ', ' ', '.', ' ', ' '}
 	spinIdx := 0
@@ -59,6 +62,7 @@ func (uc *UpCmd) runNoUI(ctx context.Context, app *app.Application) error {
 		defer func() {
 			ticker.Stop()
 			fmt.Println(progressString())
+			close(progressDone)
 		}()
 		for {
 			select {
@@ -111,15 +115,26 @@ func (uc *UpCmd) runNoUI(ctx context.Context, app *app.Application) error {
 
 		counts := app.FileProcessor().Logger().GetCounts()
 		messages := strings.Builder{}
-		if counts[fileevent.ErrorUploadFailed]+counts[fileevent.ErrorServerError]+counts[fileevent.ErrorFileAccess]+counts[fileevent.ErrorIncomplete] > 0 {
+		if counts[fileevent.ErrorUploadFailed]+counts[fileevent.ErrorServerError]+counts[fileevent.ErrorFileAccess]+counts[fileevent.ErrorIncomplete]+counts[fileevent.ErrorChecksum] > 0 {
 			messages.WriteString("Some errors have occurred. Look at the log file for details\n")
 		}
 
 		if messages.Len() > 0 {
 			cancel(errors.New(messages.String()))
 		}
-		err = errors.Join(err, uc.finishing(ctx))
+		// the progress line is ended before the table of the server's jobs
 		close(stopProgress)
+		<-progressDone
+		var last []jobQueue
+		uc.showJobs = func(queues []jobQueue) {
+			if slices.Equal(queues, last) {
+				return
+			}
+			last = queues
+			fmt.Printf("Server's jobs at %s\n", time.Now().Format(time.TimeOnly))
+			writeJobQueues(os.Stdout, queues)
+		}
+		err = errors.Join(err, uc.finishing(ctx))
 		return err
 	})
 
@@ -0,0 +1,322 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/simulot/immich-go/internal/fshelper/hash"
+)
+
+// Actions of a plan
+const (
+	planUpload  = "upload"  // upload the file
+	planReplace = "replace" // upload the file, copy the metadata of the server's asset and delete it
+	planSkip    = "skip"    // the server has the asset, the file is only used for albums, tags and stacks
+	planAlbum   = "album"   // add files to an album, created when it has no ID
+	planTag     = "tag"     // tag files, the tag is created when it has no ID
+	planStack   = "stack"   // stack files, the first one is the cover
+	planDelete  = "delete"  // delete a server's asset
+)
+
+// planEntry is a line of the plan written by upload --plan-out and executed by apply-plan
+type planEntry struct {
+	Action string `json:"action"`
+
+	// The local file, with the values checked before applying the entry
+	File     string    `json:"file,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	ModTime  time.Time `json:"modTime,omitzero"`
+	Checksum string    `json:"checksum,omitempty"`
+	Sidecar  string    `json:"sidecar,omitempty"`
+
+	// The server's asset, album or tag
+	ServerID       string `json:"serverID,omitempty"`
+	ServerChecksum string `json:"serverChecksum,omitempty"`
+
+	Reason       string                `json:"reason,omitempty"`       // why the file is skipped
+	Name         string                `json:"name,omitempty"`         // album title or tag value
+	Description  string                `json:"description,omitempty"`  // album description
+	Files        []string              `json:"files,omitempty"`        // files of the album or tag
+	ServerAssets []string              `json:"serverAssets,omitempty"` // server's assets of the album or tag without a file in the plan
+	Stack        []planMember          `json:"stack,omitempty"`        // members of the stack, the first one is the cover
+	Asset        *planAsset            `json:"asset,omitempty"`        // the values sent with the file
+	Update       *immich.UpdAssetField `json:"update,omitempty"`       // the values forced after the upload
+}
+
+// planMember is a member of a stack: a file of the plan or a server's asset without a file in the plan
+type planMember struct {
+	File        string `json:"file,omitempty"`
+	ServerAsset string `json:"serverAsset,omitempty"`
+}
+
+// planAsset holds the values of the asset sent with the uploaded file
+type planAsset struct {
+	OriginalFileName string            `json:"originalFileName"`
+	CaptureDate      time.Time         `json:"captureDate,omitzero"`
+	Favorite         bool              `json:"favorite,omitempty"`
+	Archived         bool              `json:"archived,omitempty"`
+	Visibility       assets.Visibility `json:"visibility,omitempty"`
+}
+
+// errNotPlanned tells that the file isn't on the file system, like the files read from ZIP archives
+var errNotPlanned = errors.New("the plan only refers to files of the file system")
+
+// planWriter writes the plan, the entries are written in the order of the upload
+type planWriter struct {
+	lock  sync.Mutex
+	f     io.WriteCloser
+	w     *bufio.Writer
+	enc   *json.Encoder
+	files map[string]string // asset ID to file, the IDs are the dry-run's ones for the uploaded files, empty for the files left out
+}
+
+func newPlanWriter(name string) (*planWriter, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &planWriter{
+		f:     f,
+		w:     w,
+		enc:   json.NewEncoder(w),
+		files: map[string]string{},
+	}, nil
+}
+
+func (pw *planWriter) write(e planEntry) error {
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	return pw.enc.Encode(e)
+}
+
+func (pw *planWriter) Close() error {
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	return errors.Join(pw.w.Flush(), pw.f.Close())
+}
+
+// fileEntry returns an entry for the asset's file.
+// The plan can only refer to files of the OS file system, errNotPlanned is returned for the others.
+func (pw *planWriter) fileEntry(action string, a *assets.Asset) (planEntry, error) {
+	p, err := fshelper.OSPath(a.File.FS(), a.File.Name())
+	if err != nil {
+		return planEntry{}, fmt.Errorf("can't plan the file %s: %w", a.File.FullName(), errNotPlanned)
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return planEntry{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return planEntry{}, err
+	}
+	checksum, err := a.GetChecksum()
+	if err != nil {
+		return planEntry{}, err
+	}
+	e := planEntry{
+		Action:   action,
+		File:     p,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Checksum: checksum,
+	}
+	if a.FromSideCar != nil && strings.HasSuffix(strings.ToLower(a.FromSideCar.File.Name()), ".xmp") {
+		if sc, err := fshelper.OSPath(a.FromSideCar.File.FS(), a.FromSideCar.File.Name()); err == nil {
+			e.Sidecar, _ = filepath.Abs(sc)
+		}
+	}
+	if a.ID != "" {
+		pw.lock.Lock()
+		pw.files[a.ID] = p
+		pw.lock.Unlock()
+	}
+	return e, nil
+}
+
+// leaveOut records the ID of a file left out of the plan, it's neither a file nor a server's asset of the plan
+func (pw *planWriter) leaveOut(id string) {
+	if id == "" {
+		return
+	}
+	pw.lock.Lock()
+	pw.files[id] = ""
+	pw.lock.Unlock()
+}
+
+// filesOf returns the files of the asset IDs
+func (pw *planWriter) filesOf(ids []string) []string {
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	files := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if f, ok := pw.files[id]; ok && f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// serverAssetsOf returns the IDs that are not assets of the plan's files
+func (pw *planWriter) serverAssetsOf(ids []string) []string {
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	var others []string
+	for _, id := range ids {
+		if _, ok := pw.files[id]; !ok {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+// membersOf returns the files and the server's assets of the IDs, in the order of the IDs.
+// The files left out of the plan are dropped.
+func (pw *planWriter) membersOf(ids []string) []planMember {
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	members := make([]planMember, 0, len(ids))
+	for _, id := range ids {
+		f, ok := pw.files[id]
+		switch {
+		case !ok:
+			members = append(members, planMember{ServerAsset: id})
+		case f != "":
+			members = append(members, planMember{File: f})
+		}
+	}
+	return members
+}
+
+// planAsset writes the entry of an asset handled by the upload
+func (uc *UpCmd) planAsset(action string, a *assets.Asset, server *assets.Asset, reason string) error {
+	if uc.plan == nil {
+		return nil
+	}
+	e, err := uc.plan.fileEntry(action, a)
+	if errors.Is(err, errNotPlanned) {
+		uc.app.Log().Warn("The file is left out of the plan", "file", a.File, "reason", errNotPlanned.Error())
+		// only the skipped files have the ID of a server's asset
+		if action != planSkip || server == nil {
+			uc.plan.leaveOut(a.ID)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if server != nil {
+		e.ServerID = server.ID
+		e.ServerChecksum = server.Checksum
+	}
+	e.Reason = reason
+	if action == planUpload || action == planReplace {
+		e.Asset = &planAsset{
+			OriginalFileName: a.OriginalFileName,
+			CaptureDate:      a.CaptureDate,
+			Favorite:         a.Favorite,
+			Archived:         a.Archived,
+			Visibility:       a.Visibility,
+		}
+	}
+	if action == planUpload {
+		if param, ok := serverUpdate(a); ok {
+			e.Update = &param
+		}
+	}
+	return uc.plan.write(e)
+}
+
+// planCollection writes the entry of an album or a tag
+func (uc *UpCmd) planCollection(action, id, name, description string, ids []string) {
+	files := uc.plan.filesOf(ids)
+	others := uc.plan.serverAssetsOf(ids)
+	if len(files) == 0 && len(others) == 0 {
+		return
+	}
+	err := uc.plan.write(planEntry{
+		Action:       action,
+		ServerID:     id,
+		Name:         name,
+		Description:  description,
+		Files:        files,
+		ServerAssets: others,
+	})
+	if err != nil {
+		uc.app.Log().Error("can't write the plan", "err", err)
+	}
+}
+
+// readPlan reads the entries of a plan
+func readPlan(r io.Reader) ([]planEntry, error) {
+	var entries []planEntry
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for s.Scan() {
+		line++
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var e planEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, fmt.Errorf("plan line %d: %w", line, err)
+		}
+		switch e.Action {
+		case planUpload, planReplace, planSkip:
+			if e.File == "" {
+				return nil, fmt.Errorf("plan line %d: the file is missing", line)
+			}
+		case planAlbum, planTag:
+			if e.Name == "" {
+				return nil, fmt.Errorf("plan line %d: the name is missing", line)
+			}
+		case planStack:
+		case planDelete:
+			if e.ServerID == "" {
+				return nil, fmt.Errorf("plan line %d: the server's asset is missing", line)
+			}
+		default:
+			return nil, fmt.Errorf("plan line %d: unknown action %q", line, e.Action)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// checkFile tells if the file is still the one of the plan
+func checkFile(e planEntry) error {
+	info, err := os.Stat(e.File)
+	if err != nil {
+		return err
+	}
+	if info.Size() != e.Size || !info.ModTime().Equal(e.ModTime) {
+		return errors.New("the file has changed since the planning")
+	}
+	f, err := os.Open(e.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	checksum, err := hash.Base64Encode(hash.GetSHA1Hash(f))
+	if err != nil {
+		return err
+	}
+	if checksum != e.Checksum {
+		return errors.New("the content of the file has changed since the planning")
+	}
+	return nil
+}
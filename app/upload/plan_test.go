@@ -0,0 +1,189 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/simulot/immich-go/immich"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+func TestPlanRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "IMG_0001.JPG")
+	if err := os.WriteFile(file, []byte("photo content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := &assets.Asset{
+		File:             fshelper.FSName(fshelper.NewFSWithName(dir), "IMG_0001.JPG"),
+		OriginalFileName: "IMG_0001.JPG",
+		ID:               "dry-run-id",
+	}
+
+	planFile := filepath.Join(dir, "plan.jsonl")
+	pw, err := newPlanWriter(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := pw.fileEntry(planUpload, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	description := "forced"
+	e.Update = &immich.UpdAssetField{Description: &description, DateTimeOriginal: time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)}
+	if err := pw.write(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.write(planEntry{Action: planAlbum, Name: "Holidays", Files: pw.filesOf([]string{"dry-run-id", "unknown"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := readPlan(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].File != file || entries[0].Checksum == "" || entries[0].Update.Description == nil || *entries[0].Update.Description != "forced" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	if len(entries[1].Files) != 1 || entries[1].Files[0] != file {
+		t.Errorf("unexpected album entry %+v", entries[1])
+	}
+
+	if err := checkFile(entries[0]); err != nil {
+		t.Errorf("the file hasn't changed: %s", err)
+	}
+	modTime := entries[0].ModTime
+	if err := os.WriteFile(file, []byte("PHOTO CONTENT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFile(entries[0]); err == nil {
+		t.Error("the content of the file has changed")
+	}
+}
+
+func TestPlanLeaveOut(t *testing.T) {
+	pw, err := newPlanWriter(filepath.Join(t.TempDir(), "plan.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+
+	// a file read from an archive
+	fsys := fstest.MapFS{"IMG_0001.JPG": &fstest.MapFile{Data: []byte("photo content")}}
+	a := &assets.Asset{File: fshelper.FSName(fsys, "IMG_0001.JPG"), ID: "dry-run-id"}
+	if _, err := pw.fileEntry(planUpload, a); !errors.Is(err, errNotPlanned) {
+		t.Fatalf("expected errNotPlanned, got %v", err)
+	}
+	pw.leaveOut(a.ID)
+	ids := []string{"dry-run-id", "server-id"}
+	if files := pw.filesOf(ids); len(files) != 0 {
+		t.Errorf("expected no file, got %v", files)
+	}
+	if others := pw.serverAssetsOf(ids); len(others) != 1 || others[0] != "server-id" {
+		t.Errorf("expected only the server's asset, got %v", others)
+	}
+}
+
+func TestReadPlanErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"action": "upload"}`,
+		`{"action": "album", "files": ["a.jpg"]}`,
+		`{"action": "delete"}`,
+		`{"action": "rename", "file": "a.jpg"}`,
+		`not json`,
+	} {
+		if _, err := readPlan(strings.NewReader(bad)); err == nil {
+			t.Errorf("readPlan(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPlanStackMembers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "IMG_0001.JPG")
+	if err := os.WriteFile(file, []byte("photo content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pw, err := newPlanWriter(filepath.Join(dir, "plan.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	a := &assets.Asset{File: fshelper.FSName(fshelper.NewFSWithName(dir), "IMG_0001.JPG"), ID: "dry-run-id"}
+	if _, err := pw.fileEntry(planUpload, a); err != nil {
+		t.Fatal(err)
+	}
+	pw.leaveOut("left-out-id")
+
+	// the cover is the server's asset of a near-duplicate
+	members := pw.membersOf([]string{"server-id", "left-out-id", "dry-run-id"})
+	if len(members) != 2 || members[0].ServerAsset != "server-id" || members[1].File != file {
+		t.Fatalf("unexpected members %+v", members)
+	}
+	ac := &ApplyPlanCmd{ids: map[string]string{file: "uploaded-id"}}
+	if ids := ac.stackOf(planEntry{Stack: members}); !slices.Equal(ids, []string{"server-id", "uploaded-id"}) {
+		t.Errorf("stackOf() = %v", ids)
+	}
+
+	// the cover is the file
+	members = pw.membersOf([]string{"dry-run-id", "server-id"})
+	if ids := ac.stackOf(planEntry{Stack: members}); !slices.Equal(ids, []string{"uploaded-id", "server-id"}) {
+		t.Errorf("stackOf() = %v", ids)
+	}
+}
+
+func TestApplySkipLocalDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	pw, err := newPlanWriter(filepath.Join(dir, "plan.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	entry := func(action, name string) planEntry {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("photo content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		e, err := pw.fileEntry(action, &assets.Asset{File: fshelper.FSName(fshelper.NewFSWithName(dir), name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	uploaded := entry(planUpload, "IMG_0001.JPG")
+	duplicate := entry(planSkip, "IMG_0001 copy.JPG")
+
+	ac := &ApplyPlanCmd{ids: map[string]string{}, sums: map[string]string{}}
+	var refusal *refusedError
+	if err := ac.apply(context.Background(), duplicate); !errors.As(err, &refusal) {
+		t.Errorf("the duplicate of a file not applied should be refused, got %v", err)
+	}
+	ac.setID(uploaded, "uploaded-id")
+	if err := ac.apply(context.Background(), duplicate); err != nil {
+		t.Fatal(err)
+	}
+	if ac.ids[duplicate.File] != "uploaded-id" {
+		t.Errorf("the duplicate has the ID %q", ac.ids[duplicate.File])
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/simulot/immich-go/adapters"
@@ -15,6 +17,8 @@ import (
 	"github.com/simulot/immich-go/internal/fileevent"
 	"github.com/simulot/immich-go/internal/filters"
 	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/simulot/immich-go/internal/journal"
+	"github.com/simulot/immich-go/internal/phash"
 	"github.com/simulot/immich-go/internal/worker"
 )
 
@@ -22,6 +26,10 @@ func (uc *UpCmd) saveAlbum(ctx context.Context, album assets.Album, ids []string
 	if len(ids) == 0 {
 		return album, nil
 	}
+	if uc.plan != nil {
+		uc.planCollection(planAlbum, album.ID, album.Title, album.Description, ids)
+		return album, nil
+	}
 	if album.ID == "" {
 		r, err := uc.client.Immich.CreateAlbum(ctx, album.Title, album.Description, ids)
 		if err != nil {
@@ -30,13 +38,24 @@ func (uc *UpCmd) saveAlbum(ctx context.Context, album assets.Album, ids []string
 		}
 		uc.app.Log().Info("created album", "album", album.Title, "assets", len(ids))
 		album.ID = r.ID
+		uc.record(journal.Entry{Kind: journal.KindAlbum, ID: album.ID, Name: album.Title, Created: true, Assets: ids})
 		return album, nil
 	}
-	_, err := uc.client.Immich.AddAssetToAlbum(ctx, album.ID, ids)
+	results, err := uc.client.Immich.AddAssetToAlbum(ctx, album.ID, ids)
 	if err != nil {
 		uc.app.Log().Error("failed to add assets to album", "err", err, "album", album.Title, "assets", len(ids))
 		return album, err
 	}
+	// the server's assets already in the album are not added by the session
+	var added []string
+	for _, r := range results {
+		if r.Success {
+			added = append(added, r.ID)
+		}
+	}
+	if len(added) > 0 {
+		uc.record(journal.Entry{Kind: journal.KindAlbum, ID: album.ID, Name: album.Title, Assets: added})
+	}
 	uc.app.Log().Info("updated album", "album", album.Title, "assets", len(ids))
 	return album, err
 }
@@ -45,6 +64,11 @@ func (uc *UpCmd) saveTags(ctx context.Context, tag assets.Tag, ids []string) (as
 	if len(ids) == 0 {
 		return tag, nil
 	}
+	if uc.plan != nil {
+		uc.planCollection(planTag, tag.ID, tag.Value, "", ids)
+		return tag, nil
+	}
+	created := false
 	if tag.ID == "" {
 		r, err := uc.client.Immich.UpsertTags(ctx, []string{tag.Value})
 		if err != nil {
@@ -53,12 +77,14 @@ func (uc *UpCmd) saveTags(ctx context.Context, tag assets.Tag, ids []string) (as
 		}
 		uc.app.Log().Info("created tag", "tag", tag.Value)
 		tag.ID = r[0].ID
+		created = !uc.serverTags[tag.Value]
 	}
 	_, err := uc.client.Immich.TagAssets(ctx, tag.ID, ids)
 	if err != nil {
 		uc.app.Log().Error("failed to add assets to tag", "err", err, "tag", tag.Value, "assets", len(ids))
 		return tag, err
 	}
+	uc.record(journal.Entry{Kind: journal.KindTag, ID: tag.ID, Name: tag.Value, Created: created, Assets: ids})
 	uc.app.Log().Info("updated tag", "tag", tag.Value, "assets", len(ids))
 	return tag, err
 }
@@ -100,11 +126,37 @@ func (uc *UpCmd) finishing(ctx context.Context) error {
 	// do waiting operations
 	uc.albumsCache.Close()
 	uc.tagsCache.Close()
+	if err := uc.journal.Close(); err != nil {
+		uc.app.Log().Error("can't write the session journal", "err", err)
+	}
+	var planErr error
+	if uc.plan != nil {
+		planErr = uc.plan.Close()
+		if planErr == nil {
+			uc.app.Log().Message("Plan written to %s, execute it with the command apply-plan", uc.PlanOut)
+		} else {
+			planErr = fmt.Errorf("can't write the plan: %w", planErr)
+		}
+	}
 
 	// Resume immich background jobs if requested
 	err := uc.resumeJobs(ctx)
 	if err != nil {
-		return err
+		return errors.Join(err, planErr)
+	}
+
+	// Let the server process the uploaded assets
+	if !uc.client.DryRun {
+		if len(uc.StartJobs) > 0 {
+			if err := uc.startJobs(ctx); err != nil {
+				return errors.Join(err, planErr)
+			}
+		}
+		if uc.WaitForJobs {
+			if err := uc.waitServerJobs(ctx); err != nil {
+				return errors.Join(err, planErr)
+			}
+		}
 	}
 
 	// Generate FileProcessor report
@@ -118,7 +170,7 @@ func (uc *UpCmd) finishing(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	return planErr
 }
 
 func (uc *UpCmd) upload(ctx context.Context, adapter adapters.Reader) error {
@@ -138,11 +190,16 @@ func (uc *UpCmd) upload(ctx context.Context, adapter adapters.Reader) error {
 			fmt.Println(uc.app.FileProcessor().GenerateReport())
 		}
 	}()
+	// In watch mode, the upload is stopped with Ctrl+C: the albums and tags must be saved after the cancellation
+	saveCtx := ctx
+	if isWatching(adapter) {
+		saveCtx = context.WithoutCancel(ctx)
+	}
 	uc.albumsCache = cache.NewCollectionCache(50, func(album assets.Album, ids []string) (assets.Album, error) {
-		return uc.saveAlbum(ctx, album, ids)
+		return uc.saveAlbum(saveCtx, album, ids)
 	})
 	uc.tagsCache = cache.NewCollectionCache(50, func(tag assets.Tag, ids []string) (assets.Tag, error) {
-		return uc.saveTags(ctx, tag, ids)
+		return uc.saveTags(saveCtx, tag, ids)
 	})
 
 	uc.adapter = adapter
@@ -212,13 +269,15 @@ func (uc *UpCmd) getImmichAlbums(ctx context.Context) error {
 }
 
 func (uc *UpCmd) getImmichAssets(ctx context.Context, updateFn progressUpdate) error {
-	defer close(uc.immichAssetsReady)
+	ready := sync.OnceFunc(func() { close(uc.immichAssetsReady) })
+	defer ready()
 	statistics, err := uc.client.Immich.GetAssetStatistics(ctx)
 	if err != nil {
 		return err
 	}
 	totalOnImmich := statistics.Total
 	received := 0
+	var thumbnails []phash.Thumbnail
 
 	err = uc.client.Immich.GetAllAssets(ctx, func(a *immich.Asset) error {
 		if updateFn != nil {
@@ -240,6 +299,9 @@ func (uc *UpCmd) getImmichAssets(ctx context.Context, updateFn progressUpdate) e
 				return nil
 			}
 			uc.assetIndex.addImmichAsset(a)
+			if t, ok := uc.nearThumbnail(a); ok {
+				thumbnails = append(thumbnails, t)
+			}
 			uc.app.Log().Debug("Immich asset:", "ID", a.ID, "FileName", a.OriginalFileName, "Capture date", a.ExifInfo.DateTimeOriginal, "CheckSum", a.Checksum, "FileSize", a.ExifInfo.FileSizeInByte, "DeviceAssetID", a.DeviceAssetID, "OwnerID", a.OwnerID, "IsTrashed", a.IsTrashed, "IsArchived", a.IsArchived)
 			return nil
 		}
@@ -251,6 +313,11 @@ func (uc *UpCmd) getImmichAssets(ctx context.Context, updateFn progressUpdate) e
 		updateFn(totalOnImmich, totalOnImmich)
 	}
 	uc.app.Log().Info(fmt.Sprintf("Assets on the server: %d", uc.assetIndex.len()))
+	if uc.NearDuplicates.enabled() {
+		// the albums can be read while the thumbnails are hashed
+		ready()
+		return uc.indexNearDuplicates(ctx, thumbnails)
+	}
 	return nil
 }
 
@@ -259,7 +326,9 @@ func (uc *UpCmd) uploadLoop(ctx context.Context, groupChan chan *assets.Group) e
 
 	// the goroutine submits the groups, and stops when then number of error is higher than tolerated
 	var wg sync.WaitGroup
+	loopDone := make(chan struct{})
 	wg.Go(func() {
+		defer close(loopDone)
 		workers := worker.NewPool(uc.app.ConcurrentTask)
 		defer workers.Stop()
 		for {
@@ -284,14 +353,40 @@ func (uc *UpCmd) uploadLoop(ctx context.Context, groupChan chan *assets.Group) e
 		}
 	})
 
+	// In watch mode, save the albums and tags regularly instead of waiting for the end of the upload
+	if isWatching(uc.adapter) {
+		wg.Go(func() {
+			ticker := time.NewTicker(watchFlushPeriod)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-loopDone:
+					return
+				case <-ticker.C:
+					uc.albumsCache.Flush()
+					uc.tagsCache.Flush()
+				}
+			}
+		})
+	}
+
 	wg.Wait()
 	err := context.Cause(ctx)
+	if isWatching(uc.adapter) && errors.Is(err, context.Canceled) {
+		// the normal way to stop watching
+		err = nil
+	}
 
 	// Cleanup: delete server assets if needed
 	if len(uc.deleteServerList) > 0 {
 		ids := []string{}
 		for _, da := range uc.deleteServerList {
 			ids = append(ids, da.ID)
+			if uc.plan != nil {
+				if err := uc.plan.write(planEntry{Action: planDelete, ServerID: da.ID, ServerChecksum: da.Checksum}); err != nil {
+					return err
+				}
+			}
 		}
 		err := uc.DeleteServerAssets(ctx, ids)
 		if err != nil {
@@ -302,6 +397,14 @@ func (uc *UpCmd) uploadLoop(ctx context.Context, groupChan chan *assets.Group) e
 	return err
 }
 
+// watchFlushPeriod is the period of the albums and tags saving in watch mode
+const watchFlushPeriod = 30 * time.Second
+
+func isWatching(adapter adapters.Reader) bool {
+	w, ok := adapter.(adapters.Watcher)
+	return ok && w.IsWatching()
+}
+
 func (uc *UpCmd) handleGroup(ctx context.Context, g *assets.Group) error {
 	var errGroup error
 
@@ -314,9 +417,35 @@ func (uc *UpCmd) handleGroup(ctx context.Context, g *assets.Group) error {
 		uc.app.FileProcessor().RecordAssetDiscarded(ctx, a.Asset.File, int64(a.Asset.FileSize), fileevent.DiscardedNotSelected, a.Reason)
 	}
 
+	// Locate the assets and apply the rules of the configuration file
+	var cover *assets.Asset
+	if g.CoverIndex < len(g.Assets) {
+		cover = g.Assets[g.CoverIndex]
+	}
+	kept := g.Assets[:0]
+	for _, a := range g.Assets {
+		if uc.geotagger.TagAsset(a) {
+			uc.app.FileProcessor().Logger().Record(ctx, fileevent.ProcessedGeotagged, a.File, "latitude", a.Latitude, "longitude", a.Longitude)
+		}
+		if len(uc.app.Rules) > 0 {
+			res := uc.app.Rules.Apply(a, uc.source)
+			if res.Skip {
+				a.Close()
+				uc.app.FileProcessor().RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.DiscardedFiltered, "skipped by the rule "+res.SkipBy)
+				continue
+			}
+			if len(res.Matched) > 0 {
+				uc.app.FileProcessor().RecordNonAsset(ctx, a.File, 0, fileevent.ProcessedRulesApplied, "rules", strings.Join(res.Matched, ", "))
+			}
+		}
+		kept = append(kept, a)
+	}
+	g.Assets = kept
+	g.CoverIndex = max(slices.Index(g.Assets, cover), 0)
+
 	// Upload assets from the group
 	for _, a := range g.Assets {
-		err := uc.handleAsset(ctx, a)
+		err := uc.handleMotionPhoto(ctx, a)
 		errGroup = errors.Join(err)
 	}
 
@@ -333,10 +462,17 @@ func (uc *UpCmd) handleGroup(ctx context.Context, g *assets.Group) error {
 				ids = append(ids, a.ID)
 			}
 		}
-		if len(ids) > 1 {
-			_, err := client.CreateStack(ctx, ids)
+		if len(ids) > 1 && uc.plan != nil {
+			err := uc.plan.write(planEntry{Action: planStack, Stack: uc.plan.membersOf(ids)})
+			if err != nil {
+				uc.app.Log().Error("can't write the plan", "err", err)
+			}
+		} else if len(ids) > 1 {
+			stackID, err := client.CreateStack(ctx, ids)
 			if err != nil {
 				uc.app.Log().Error("Can't create stack", "error", err)
+			} else {
+				uc.record(journal.Entry{Kind: journal.KindStack, ID: stackID, Assets: ids})
 			}
 		}
 	}
@@ -345,6 +481,12 @@ func (uc *UpCmd) handleGroup(ctx context.Context, g *assets.Group) error {
 }
 
 func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
+	confirmed := false // the server has the asset
+	defer func() {
+		if confirmed {
+			uc.handleUploaded(ctx, a)
+		}
+	}()
 	defer func() {
 		a.Close() // Close and clean resources linked to the local asset
 	}()
@@ -357,12 +499,28 @@ func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
 
 	switch advice.Advice {
 	case NotOnServer: // Upload and manage albums
+		near, distance := uc.nearDuplicate(a)
+		if near != nil {
+			if uc.NearDuplicates == NearSkip {
+				return uc.skipNearDuplicate(ctx, a, near, distance)
+			}
+			if uc.NearDuplicates == NearTag {
+				a.AddTag(nearDuplicateTag)
+			}
+		}
 		serverStatus, err := uc.uploadAsset(ctx, a)
 		if err != nil {
 			return err
 		}
+		if err := uc.planAsset(planUpload, a, nil, ""); err != nil {
+			return err
+		}
+		confirmed = true
 
 		uc.processUploadedAsset(ctx, a, serverStatus)
+		if near != nil {
+			uc.linkNearDuplicate(ctx, a, near)
+		}
 		return nil
 
 	case SmallerOnServer: // Upload, manage albums and delete the server's asset
@@ -375,6 +533,10 @@ func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
 		if err != nil {
 			return err
 		}
+		if err := uc.planAsset(planReplace, a, advice.ServerAsset, ""); err != nil {
+			return err
+		}
+		confirmed = true
 
 		uc.processUploadedAsset(ctx, a, serverStatus)
 		uc.app.FileProcessor().RecordAssetProcessed(ctx, a.File, int64(a.FileSize), fileevent.ProcessedUploadUpgraded)
@@ -382,15 +544,24 @@ func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
 		return nil
 
 	case AlreadyProcessed: // SHA1 already processed
+		if err := uc.planAsset(planSkip, a, nil, advice.Advice.String()); err != nil {
+			return err
+		}
 		// Record as discarded - duplicate in input
 		uc.app.FileProcessor().RecordNonAsset(ctx, a.File, int64(a.FileSize), fileevent.DiscardedLocalDuplicate)
 		uc.app.FileProcessor().RecordAssetProcessed(ctx, a.File, int64(a.FileSize), fileevent.ProcessedMetadataUpdated)
 		uc.manageAssetAlbums(ctx, a.File, a.ID, a.Albums)
+		confirmed = true
 		return nil
 
 	case SameOnServer:
 		a.ID = advice.ServerAsset.ID
+		// the server has the same name, date and size, only a checksum match is a confirmation
+		confirmed = advice.ServerAsset.Checksum == a.Checksum
 		a.Albums = append(a.Albums, advice.ServerAsset.Albums...)
+		if err := uc.planAsset(planSkip, a, advice.ServerAsset, advice.Advice.String()); err != nil {
+			return err
+		}
 		// Record as processed - duplicate on server
 		uc.app.FileProcessor().RecordNonAsset(ctx, a.File, int64(a.FileSize), fileevent.DiscardedServerDuplicate)
 		uc.app.FileProcessor().RecordAssetProcessed(ctx, a.File, int64(a.FileSize), fileevent.ProcessedMetadataUpdated)
@@ -398,6 +569,9 @@ func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
 
 	case BetterOnServer: // and manage albums
 		a.ID = advice.ServerAsset.ID
+		if err := uc.planAsset(planSkip, a, advice.ServerAsset, advice.Advice.String()); err != nil {
+			return err
+		}
 		// Record as discarded - server has better version
 		uc.app.FileProcessor().RecordAssetDiscarded(ctx, a.File, int64(a.FileSize), fileevent.ProcessedMetadataUpdated, advice.Message)
 		uc.manageAssetAlbums(ctx, a.File, a.ID, a.Albums)
@@ -412,12 +586,19 @@ func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
 
 			// Upload the superior asset
 			serverStatus, err = uc.replaceAsset(ctx, a, advice.ServerAsset)
+			if err == nil {
+				err = uc.planAsset(planReplace, a, advice.ServerAsset, "")
+			}
 		} else {
 			serverStatus, err = uc.uploadAsset(ctx, a)
+			if err == nil {
+				err = uc.planAsset(planUpload, a, nil, "")
+			}
 		}
 		if err != nil {
 			return err
 		}
+		confirmed = true
 
 		uc.processUploadedAsset(ctx, a, serverStatus)
 		return nil
@@ -426,6 +607,17 @@ func (uc *UpCmd) handleAsset(ctx context.Context, a *assets.Asset) error {
 	return nil
 }
 
+// handleUploaded lets the adapter delete or move the source files of an asset confirmed by the server
+func (uc *UpCmd) handleUploaded(ctx context.Context, a *assets.Asset) {
+	if a.MotionPhoto != nil {
+		// the video extracted from a motion photo has no file
+		return
+	}
+	if h, ok := uc.adapter.(adapters.UploadHandler); ok {
+		h.HandleUploaded(ctx, a, uc.client.DryRun)
+	}
+}
+
 // uploadAsset uploads the asset to the server.
 // set the server's asset ID to the asset.
 // return the duplicate condition and error.
@@ -439,10 +631,8 @@ func (uc *UpCmd) uploadAsset(ctx context.Context, a *assets.Asset) (string, erro
 		a.AddTag(tag)
 	}
 
-	ar, err := uc.client.Immich.AssetUpload(ctx, a)
+	ar, err := uc.verifiedUpload(ctx, a)
 	if err != nil {
-		// Record upload error
-		uc.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorServerError, err)
 		return "", err // Must signal the error to the caller
 	}
 	if ar.Status == immich.UploadDuplicate {
@@ -462,43 +652,118 @@ func (uc *UpCmd) uploadAsset(ctx context.Context, a *assets.Asset) (string, erro
 	} else {
 		// Record successful upload
 		uc.app.FileProcessor().RecordAssetProcessed(ctx, a.File, int64(a.FileSize), fileevent.ProcessedUploadSuccess)
+		uc.record(journal.Entry{Kind: journal.KindUpload, ID: ar.ID, Name: a.File.FullName()})
 	}
 	a.ID = ar.ID
 
 	// // DEBGUG
 	//  if theID, ok := uc.assetIndex.byI
 
-	if a.FromApplication != nil && ar.Status != immich.StatusDuplicate {
-		// metadata from application (immich or google photos) are forced.
-		// if a.Description != "" || (a.Latitude != 0 && a.Longitude != 0) || a.Rating != 0 || !a.CaptureDate.IsZero() {
-		a.UseMetadata(a.FromApplication)
-		_, err := uc.client.Immich.UpdateAsset(ctx, a.ID, immich.UpdAssetField{
-			Description:      a.Description,
+	if ar.Status != immich.StatusDuplicate {
+		if a.FromApplication != nil {
+			// metadata from application (immich or google photos) are forced.
+			a.UseMetadata(a.FromApplication)
+		}
+		if param, ok := serverUpdate(a); ok {
+			_, err := uc.client.Immich.UpdateAsset(ctx, a.ID, param)
+			if err != nil {
+				// Record metadata update error
+				uc.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorServerError, err)
+				return "", err
+			}
+			// Record successful metadata update
+			uc.app.FileProcessor().Logger().Record(ctx, fileevent.ProcessedMetadataUpdated, a.File)
+		}
+	}
+	uc.assetIndex.addLocalAsset(a)
+	return ar.Status, nil
+}
+
+// serverUpdate returns the values to force on the server's asset after its upload
+func serverUpdate(a *assets.Asset) (immich.UpdAssetField, bool) {
+	if a.FromApplication != nil {
+		param := immich.UpdAssetField{
 			Latitude:         a.Latitude,
 			Longitude:        a.Longitude,
-			Rating:           a.Rating,
 			DateTimeOriginal: a.CaptureDate,
-		})
+		}
+		if a.Description != "" || a.RuleDescription {
+			param.Description = &a.Description
+		}
+		if a.Rating != 0 || a.RuleRating {
+			param.Rating = &a.Rating
+		}
+		return param, true
+	}
+	if !a.GeoTagged && !a.DateCorrected && !a.RuleRating && !a.RuleDescription {
+		return immich.UpdAssetField{}, false
+	}
+	// The position given by the track log, the corrected date, or the values given by the rules are not in the file
+	param := immich.UpdAssetField{
+		Latitude:  a.Latitude,
+		Longitude: a.Longitude,
+	}
+	if a.DateCorrected {
+		param.DateTimeOriginal = a.CaptureDate
+	}
+	if a.RuleDescription {
+		param.Description = &a.Description
+	}
+	if a.RuleRating {
+		param.Rating = &a.Rating
+	}
+	return param, true
+}
+
+// verifiedUpload uploads the asset. With --verify, the checksum computed by the server is compared
+// with the local one, and the asset is uploaded again after a mismatch.
+// The errors are recorded.
+func (uc *UpCmd) verifiedUpload(ctx context.Context, a *assets.Asset) (immich.AssetResponse, error) {
+	for attempt := 0; ; attempt++ {
+		ar, err := uc.client.Immich.AssetUpload(ctx, a)
+		if err != nil {
+			// Record upload error
+			uc.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorServerError, err)
+			return ar, err
+		}
+		// the server detects the duplicates with the checksum
+		if !uc.Verify || uc.client.DryRun || ar.Status == immich.UploadDuplicate {
+			return ar, nil
+		}
+
+		checksum, err := a.GetChecksum()
+		if err != nil {
+			uc.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorFileAccess, err)
+			return ar, err
+		}
+		sa, err := uc.client.Immich.GetAssetInfo(ctx, ar.ID)
 		if err != nil {
-			// Record metadata update error
 			uc.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorServerError, err)
-			return "", err
+			return ar, err
+		}
+		if sa.Checksum == checksum {
+			return ar, nil
+		}
+
+		err = fmt.Errorf("checksum mismatch: local %s, server %s", checksum, sa.Checksum)
+		// the corrupted asset is removed from the server
+		if delErr := uc.client.Immich.DeleteAssets(ctx, []string{ar.ID}, true); delErr != nil {
+			err = errors.Join(err, delErr)
 		}
-		// Record successful metadata update
-		uc.app.FileProcessor().Logger().Record(ctx, fileevent.ProcessedMetadataUpdated, a.File)
+		if attempt >= uc.Retries {
+			uc.app.FileProcessor().RecordAssetError(ctx, a.File, int64(a.FileSize), fileevent.ErrorChecksum, err)
+			return ar, err
+		}
+		uc.app.Log().Warn("checksum mismatch, uploading the asset again", "file", a.File, "attempt", attempt+1, "err", err)
 	}
-	uc.assetIndex.addLocalAsset(a)
-	return ar.Status, nil
 }
 
 // replaceAsset replaces an asset on the server. It uploads the new asset, copies the metadata from the old one and deletes the old one.
 // https://github.com/immich-app/immich/pull/23172#issue-3542430029
 func (uc *UpCmd) replaceAsset(ctx context.Context, newAsset, oldAsset *assets.Asset) (string, error) {
 	// 1. Upload the new asset
-	ar, err := uc.client.Immich.AssetUpload(ctx, newAsset)
+	ar, err := uc.verifiedUpload(ctx, newAsset)
 	if err != nil {
-		// Record upload error
-		uc.app.FileProcessor().RecordAssetError(ctx, newAsset.File, int64(newAsset.FileSize), fileevent.ErrorServerError, err)
 		return "", err // Must signal the error to the caller
 	}
 	newAsset.ID = ar.ID
@@ -523,6 +788,7 @@ func (uc *UpCmd) replaceAsset(ctx context.Context, newAsset, oldAsset *assets.As
 		uc.app.FileProcessor().RecordAssetError(ctx, newAsset.File, int64(newAsset.FileSize), fileevent.ErrorServerError, err)
 		return "", err // Must signal the error to the caller
 	}
+	uc.record(journal.Entry{Kind: journal.KindReplace, ID: ar.ID, Name: newAsset.File.FullName(), Replaced: oldAsset.ID})
 	uc.assetIndex.replaceAsset(newAsset, oldAsset)
 	// Record successful upgrade
 	// uc.app.FileProcessor().RecordAssetProcessed(ctx, newAsset.File, int64(newAsset.FileSize), fileevent.ProcessedUploadUpgraded)
@@ -577,22 +843,3 @@ func (uc *UpCmd) processUploadedAsset(ctx context.Context, a *assets.Asset, serv
 		uc.manageAssetTags(ctx, a)
 	}
 }
-
-/*
-func (upCmd *UpCmd) DeleteLocalAssets() error {
-	upCmd.RootImmichFlags.Message(fmt.Sprintf("%d local assets to delete.", len(upCmd.deleteLocalList)))
-
-	for _, a := range upCmd.deleteLocalList {
-		if !upCmd.DryRun {
-			upCmd.Log.Info(fmt.Sprintf("delete file %q", a.Title))
-			err := a.Remove()
-			if err != nil {
-				return err
-			}
-		} else {
-			upCmd.Log.Info(fmt.Sprintf("file %q not deleted, dry run mode.", a.Title))
-		}
-	}
-	return nil
-}
-*/
@@ -54,6 +54,9 @@ type uiPage struct {
 	immichUpload  *tvxwidgets.PercentageModeGauge
 
 	watchJobs bool
+
+	// server's job queues, with --wait-for-jobs
+	jobsTable *tview.Table
 }
 
 func (ui *uiPage) highJackLogger(app *app.Application) {
@@ -173,6 +176,17 @@ func (uc *UpCmd) runUI(ctx context.Context, app *app.Application) error {
 		}
 	}()
 
+	// show the server's job queues while waiting for them
+	uc.showJobs = func(queues []jobQueue) {
+		uiApp.QueueUpdateDraw(func() {
+			if !pages.HasPage("jobs") {
+				pages.AddPage("jobs", ui.newJobsPage(), true, true)
+			}
+			ui.updateJobsTable(queues)
+		})
+	}
+	defer func() { uc.showJobs = nil }() // the jobs are logged once the UI is stopped
+
 	// start the UI
 	uiGroup.Go(func() error {
 		select {
@@ -229,10 +243,11 @@ func (uc *UpCmd) runUI(ctx context.Context, app *app.Application) error {
 
 		uploadDone.Store(true)
 		counts := app.FileProcessor().Logger().GetCounts()
-		if counts[fileevent.ErrorUploadFailed]+counts[fileevent.ErrorServerError]+counts[fileevent.ErrorFileAccess]+counts[fileevent.ErrorIncomplete] > 0 {
+		if counts[fileevent.ErrorUploadFailed]+counts[fileevent.ErrorServerError]+counts[fileevent.ErrorFileAccess]+counts[fileevent.ErrorIncomplete]+counts[fileevent.ErrorChecksum] > 0 {
 			messages.WriteString("Some errors have occurred. Look at the log file for details\n")
 		}
 
+		pages.RemovePage("jobs")
 		modal := newModal(messages.String())
 		pages.AddPage("modal", modal, true, false)
 		// upload is done!
@@ -359,10 +374,41 @@ func (uc *UpCmd) newUI(ctx context.Context, a *app.Application) *uiPage {
 	ui.screen.AddItem(ui.footer, 3, 0, 1, 1, 0, 0, false)
 
 	// Adjust section's height
-	ui.screen.SetRows(4, 10, 0, 1)
+	ui.screen.SetRows(4, 11, 0, 1)
 	return ui
 }
 
+// newJobsPage returns the page showing the server's job queues
+func (ui *uiPage) newJobsPage() tview.Primitive {
+	ui.jobsTable = tview.NewTable().SetBorders(false)
+	ui.jobsTable.SetBorder(true).SetTitle("Waiting for the server's jobs")
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(ui.jobsTable, 16, 1, false).
+			AddItem(nil, 0, 1, false), 80, 1, false).
+		AddItem(nil, 0, 1, false)
+}
+
+// updateJobsTable shows the counts of the server's job queues
+func (ui *uiPage) updateJobsTable(queues []jobQueue) {
+	ui.jobsTable.Clear()
+	for c, h := range []string{"Queue", "Active", "Waiting", "Delayed", "Failed"} {
+		ui.jobsTable.SetCell(0, c, tview.NewTableCell(h).SetTextColor(tcell.ColorDarkOrange).SetExpansion(1))
+	}
+	for r, q := range queues {
+		name := q.Name
+		if q.Paused {
+			name += " (paused)"
+		}
+		ui.jobsTable.SetCellSimple(r+1, 0, name)
+		for c, v := range []int{q.Active, q.Waiting, q.Delayed, q.Failed} {
+			ui.jobsTable.SetCell(r+1, c+1, tview.NewTableCell(fmt.Sprintf("%d", v)).SetAlign(tview.AlignRight))
+		}
+	}
+}
+
 type progressUpdate func(value, maxValue int)
 
 // call back to get the progression
@@ -459,8 +505,15 @@ func (ui *uiPage) createProcessingZone() *tview.Grid {
 	ui.addProcessingCounter(processing, 3, "Tagged", fileevent.ProcessedTagged)
 	// Row 4: Metadata updated
 	ui.addProcessingCounter(processing, 4, "Metadata updated", fileevent.ProcessedMetadataUpdated)
-
-	processing.SetSize(5, 2, 1, 1).SetColumns(20, 10)
+	// Row 5: Geotagged from track logs
+	ui.addProcessingCounter(processing, 5, "Geotagged", fileevent.ProcessedGeotagged)
+	// Row 6: Dates corrected by clock rules
+	ui.addProcessingCounter(processing, 6, "Dates corrected", fileevent.ProcessedDateCorrected)
+	// Row 7-8: Local files handled after the upload
+	ui.addProcessingCounter(processing, 7, "Local files deleted", fileevent.ProcessedLocalDeleted)
+	ui.addProcessingCounter(processing, 8, "Local files moved", fileevent.ProcessedLocalMoved)
+
+	processing.SetSize(9, 2, 1, 1).SetColumns(20, 10)
 	return processing
 }
 
@@ -2,6 +2,7 @@ package upload
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/simulot/immich-go/adapters/folder"
 	"github.com/simulot/immich-go/adapters/fromimmich"
 	gp "github.com/simulot/immich-go/adapters/googlePhotos"
+	"github.com/simulot/immich-go/adapters/manifest"
 	"github.com/simulot/immich-go/adapters/shared"
 	"github.com/simulot/immich-go/app"
 	"github.com/simulot/immich-go/immich"
@@ -20,9 +22,13 @@ import (
 	"github.com/simulot/immich-go/internal/fileprocessor"
 	"github.com/simulot/immich-go/internal/filters"
 	"github.com/simulot/immich-go/internal/gen/syncset"
+	"github.com/simulot/immich-go/internal/geotag"
 	"github.com/simulot/immich-go/internal/groups/burst"
 	"github.com/simulot/immich-go/internal/groups/epsonfastfoto"
 	"github.com/simulot/immich-go/internal/groups/series"
+	"github.com/simulot/immich-go/internal/journal"
+	"github.com/simulot/immich-go/internal/phash"
+	"github.com/simulot/immich-go/internal/quality"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -55,12 +61,24 @@ type UpCmd struct {
 	// Cli flags
 
 	shared.StackOptions
-	client     app.Client
-	NoUI       bool // Disable UI
-	Overwrite  bool // Always overwrite files on the server with local versions
-	Tags       []string
-	SessionTag bool
-	session    string // Session tag value
+	client         app.Client
+	NoUI           bool // Disable UI
+	Overwrite      bool // Always overwrite files on the server with local versions
+	Tags           []string
+	SessionTag     bool
+	session        string // Session tag value
+	Geotag         geotag.Options
+	Verify         bool           // Compare the checksum of the uploaded assets with the server's one
+	Retries        int            // Number of uploads retried after a checksum mismatch
+	PlanOut        string         // Write the actions to a plan file instead of changing the server
+	Prefer         quality.Policy // Compare the local assets with the server's ones having the same name and date
+	Priority       []string       // Sources from the best to the worst, for the source criterion
+	NearDuplicates NearMode       // What to do with the images having a near-duplicate on the server
+	NearDistance   int            // Maximum distance between the perceptual hashes of near-duplicates
+	SplitMotion    bool           // Split the motion photos into a still image and a video linked as a live photo
+	WaitForJobs    bool           // Wait for the server's jobs to process the assets before exiting
+	WaitTimeout    time.Duration  // Maximum duration of the wait for the server's jobs
+	StartJobs      []string       // Server's jobs started after the upload
 
 	// Upload command state
 	// Filters           []filters.Filter
@@ -72,11 +90,18 @@ type UpCmd struct {
 	immichAssetsReady chan struct{}                        // Signal that the asset index is ready
 	deleteServerList  []*immich.Asset                      // List of server assets to remove
 	adapter           adapters.Reader                      // the source of assets
+	source            string                               // name of the source command, used by the rules
 	DebugCounters     bool                                 // Enable CSV action counters per file
 	albumsCache       *cache.CollectionCache[assets.Album] // List of albums present on the server
 	tagsCache         *cache.CollectionCache[assets.Tag]   // List of tags present on the server
 	finished          bool                                 // the finish task has been run
 	infoCollector     *filenames.InfoCollector             // Collects information about the files being processed
+	geotagger         *geotag.Geotagger                    // Locates the assets without GPS data on track logs
+	plan              *planWriter                          // Writes the actions with --plan-out
+	journal           *journal.Journal                     // Records the changes of the session, to undo them
+	serverTags        map[string]bool                      // The tags present on the server before the session
+	nearIndex         *phash.Index                         // Perceptual hashes of the server's images, with --near-duplicates
+	showJobs          func([]jobQueue)                     // Shows the server's job queues with --wait-for-jobs
 }
 
 func (uc *UpCmd) RegisterFlags(flags *pflag.FlagSet) {
@@ -86,7 +111,20 @@ func (uc *UpCmd) RegisterFlags(flags *pflag.FlagSet) {
 	flags.StringSliceVar(&uc.Tags, "tag", nil, "Add tags to the imported assets. Can be specified multiple times. Hierarchy is supported using a / separator (e.g. 'tag1/subtag1')")
 	flags.BoolVar(&uc.SessionTag, "session-tag", false, "Tag uploaded photos with a tag \"{immich-go}/YYYY-MM-DD HH-MM-SS\"")
 
+	flags.BoolVar(&uc.Verify, "verify", false, "After each upload, compare the checksum computed by the server with the local one")
+	flags.IntVar(&uc.Retries, "verify-retries", 1, "Number of times an asset is uploaded again after a checksum mismatch")
+	flags.Var(&uc.Prefer, "prefer", "Choose between the local asset and the server's one with the same name and date: size, quality, or a list of criteria among resolution, format, bitdepth, exif, source, size")
+	flags.StringSliceVar(&uc.Priority, "source-priority", quality.DefaultSourcePriority, "Sources from the best to the worst, for the source criterion of --prefer. The server's assets are ranked as 'server'")
+	flags.Var(&uc.NearDuplicates, "near-duplicates", "What to do with the images having a near-duplicate on the server: none, skip, stack or tag")
+	flags.IntVar(&uc.NearDistance, "near-distance", 6, "Maximum number of different bits between the perceptual hashes of near-duplicates (0-64)")
+	flags.BoolVar(&uc.SplitMotion, "split-motion-photos", false, "Split the Samsung and Google motion photos into a still image and a video, uploaded as a live photo")
+	flags.BoolVar(&uc.WaitForJobs, "wait-for-jobs", false, "After the upload, wait for the server to generate the thumbnails, extract the metadata and detect the faces")
+	flags.DurationVar(&uc.WaitTimeout, "wait-timeout", time.Hour, "Maximum duration of the wait for the server's jobs")
+	flags.StringSliceVar(&uc.StartJobs, "start-jobs", nil, "Server's queues started after the upload: thumbnails, duplicates. They process all the assets missing the result, not only the uploaded ones")
+	flags.StringVar(&uc.PlanOut, "plan-out", "", "Write the intended actions to a JSONL file, executed later with the command apply-plan. The server is not changed")
+
 	uc.StackOptions.RegisterFlags(flags)
+	uc.Geotag.RegisterFlags(flags, "")
 }
 
 // NewUploadCommand creates the root "upload" command and adds subcommands for each supported source.
@@ -112,6 +150,7 @@ func NewUploadCommand(ctx context.Context, app *app.Application) *cobra.Command
 	cmd.AddCommand(folder.NewFromPicasaCommand(ctx, cmd, app, uc))
 	cmd.AddCommand(gp.NewFromGooglePhotosCommand(ctx, cmd, app, uc))
 	cmd.AddCommand(fromimmich.NewFromImmichCommand(ctx, cmd, app, uc))
+	cmd.AddCommand(manifest.NewFromManifestCommand(ctx, cmd, app, uc))
 
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		// Initialize the FileProcessor (tracker + logger)
@@ -136,17 +175,67 @@ func NewUploadCommand(ctx context.Context, app *app.Application) *cobra.Command
 	return cmd
 }
 
+// openJournal opens the journal of the session, used by the command tool undo-session
+func (uc *UpCmd) openJournal(ctx context.Context) error {
+	tags, err := uc.client.Immich.GetAllTags(ctx)
+	if err != nil {
+		return err
+	}
+	uc.serverTags = map[string]bool{}
+	for _, t := range tags {
+		uc.serverTags[t.Value] = true
+	}
+	name, err := journal.Path(uc.session)
+	if err != nil {
+		return err
+	}
+	uc.journal, err = journal.Open(name)
+	if err != nil {
+		return err
+	}
+	uc.app.Log().Info("session journal", "session", uc.session, "file", name)
+	return nil
+}
+
+// record writes a change to the session's journal
+func (uc *UpCmd) record(e journal.Entry) {
+	if err := uc.journal.Record(e); err != nil {
+		uc.app.Log().Error("can't write the session journal", "err", err)
+	}
+}
+
 // Run is called back by the actual asset reader
 func (uc *UpCmd) Run(cmd *cobra.Command, adapter adapters.Reader) error {
 	uc.Mode = UpModeFolder // TODO
+	uc.source = cmd.Name()
 
 	// ready to run
 	ctx := cmd.Context()
+	for _, job := range uc.StartJobs {
+		if _, ok := startableJobs[job]; !ok {
+			return fmt.Errorf("invalid value %q for --start-jobs, expected thumbnails or duplicates", job)
+		}
+	}
+	if uc.PlanOut != "" && uc.SplitMotion {
+		return errors.New("the flags --plan-out and --split-motion-photos can't be used together")
+	}
+	if uc.PlanOut != "" {
+		// the plan is made without changing the server
+		uc.client.DryRun = true
+		uc.client.PauseImmichBackgroundJobs = false
+	}
 	err := uc.client.Open(ctx, uc.app)
 	if err != nil {
 		return err
 	}
+	if uc.PlanOut != "" {
+		uc.plan, err = newPlanWriter(uc.PlanOut)
+		if err != nil {
+			return err
+		}
+	}
 	uc.tz = uc.app.GetTZ()
+	uc.Prefer.SetSourcePriority(uc.Priority)
 	uc.app.SetSupportedMedia(uc.client.Immich.SupportedMedia())
 
 	// Initialize the FileProcessor if not already done
@@ -158,7 +247,18 @@ func (uc *UpCmd) Run(cmd *cobra.Command, adapter adapters.Reader) error {
 	}
 
 	if uc.SessionTag {
-		uc.session = fmt.Sprintf("{immich-go}/%s", time.Now().Format("2006-01-02 15:04:05"))
+		uc.session = journal.SessionPrefix + time.Now().Format("2006-01-02 15:04:05")
+		if !uc.client.DryRun {
+			err = uc.openJournal(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	uc.geotagger, err = geotag.New(uc.Geotag, uc.tz)
+	if err != nil {
+		return err
 	}
 
 	if uc.ManageEpsonFastFoto {
@@ -166,11 +266,11 @@ func (uc *UpCmd) Run(cmd *cobra.Command, adapter adapters.Reader) error {
 		uc.Groupers = append(uc.Groupers, g.Group)
 	}
 	if uc.ManageBurst != filters.BurstNothing {
-		uc.Groupers = append(uc.Groupers, burst.Group)
+		uc.Groupers = append(uc.Groupers, uc.BurstGrouper(burst.FileHash))
 	}
 	uc.Groupers = append(uc.Groupers, series.Group)
 	uc.Filters = append(uc.Filters, uc.ManageBurst.GroupFilter(), uc.ManageRawJPG.GroupFilter(), uc.ManageHEICJPG.GroupFilter())
-	uc.infoCollector = filenames.NewInfoCollector(uc.tz, uc.app.GetSupportedMedia())
+	uc.infoCollector = filenames.NewInfoCollector(uc.tz, uc.app.GetSupportedMedia(), uc.app.NamePatterns...)
 
 	return uc.upload(ctx, adapter)
 }
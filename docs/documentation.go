@@ -230,6 +230,8 @@ func generateConfigurationFileExamples(rootCmd *cobra.Command, p string) {
 	fmt.Fprintln(f, "```")
 	fmt.Fprintln(f, "")
 	fmt.Fprintln(f, "</details>")
+	fmt.Fprintln(f, "")
+	fmt.Fprintln(f, "The sections that aren't bound to a command line flag (`clock-rules`, `name-patterns`) are described in [Configuration sections](configuration-sections.md).")
 }
 
 // setDateRanges recursively sets all date-range fields in the configuration map
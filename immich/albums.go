@@ -12,16 +12,16 @@ type AlbumSimplified struct {
 	ID          string `json:"id,omitempty"`
 	AlbumName   string `json:"albumName"`
 	Description string `json:"description,omitempty"`
-	// OwnerID                    string    `json:"ownerId"`
-	// CreatedAt                  time.Time `json:"createdAt"`
+	OwnerID     string `json:"ownerId,omitempty"`
 	// UpdatedAt                  time.Time `json:"updatedAt"`
 	// AlbumThumbnailAssetID      string    `json:"albumThumbnailAssetId"`
 	// SharedUsers                []string  `json:"sharedUsers"`
 	// Owner                      User      `json:"owner"`
 	// Shared                     bool      `json:"shared"`
-	// AssetCount                 int       `json:"assetCount"`
 	// LastModifiedAssetTimestamp time.Time `json:"lastModifiedAssetTimestamp"
-	AssetIds []string `json:"assetIds,omitempty"`
+	CreatedAt  ImmichTime `json:"createdAt,omitzero"`
+	AssetCount int        `json:"assetCount,omitempty"`
+	AssetIds   []string   `json:"assetIds,omitempty"`
 }
 
 func AlbumsFromAlbumSimplified(albums []AlbumSimplified) []assets.Album {
@@ -173,9 +173,44 @@ func (ic *ImmichClient) GetAssetAlbums(ctx context.Context, assetID string) ([]A
 	return r, err
 }
 
+// RemoveAssetFromAlbum removes assets from an album, the assets are kept
+func (ic *ImmichClient) RemoveAssetFromAlbum(ctx context.Context, albumID string, assets []string) ([]UpdateAlbumResult, error) {
+	if ic.dryRun {
+		return []UpdateAlbumResult{}, nil
+	}
+	var r []UpdateAlbumResult
+	body := UpdateAlbum{
+		IDS: assets,
+	}
+	err := ic.newServerCall(ctx, EndPointRemoveAssetFromAlbum).do(
+		deleteRequest(fmt.Sprintf("/albums/%s/assets", albumID), setAcceptJSON(),
+			setJSONBody(body)),
+		responseJSON(&r))
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 func (ic *ImmichClient) DeleteAlbum(ctx context.Context, id string) error {
 	if ic.dryRun {
 		return nil
 	}
 	return ic.newServerCall(ctx, EndPointDeleteAlbum).do(deleteRequest("/albums/" + id))
 }
+
+// UpdateAlbum changes the name and the description of an album
+func (ic *ImmichClient) UpdateAlbum(ctx context.Context, id string, name string, description string) error {
+	if ic.dryRun {
+		return nil
+	}
+	body := struct {
+		AlbumName   string `json:"albumName"`
+		Description string `json:"description"`
+	}{
+		AlbumName:   name,
+		Description: description,
+	}
+	return ic.newServerCall(ctx, EndPointUpdateAlbum).do(
+		patchRequest("/albums/"+id, setAcceptJSON(), setJSONBody(body)))
+}
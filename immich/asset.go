@@ -15,10 +15,10 @@ import (
 
 // immich Asset simplified
 type Asset struct {
-	Checksum string `json:"checksum"`
-	// createdAt
-	DeviceAssetID string `json:"deviceAssetId"`
-	DeviceID      string `json:"deviceId"`
+	Checksum      string     `json:"checksum"`
+	CreatedAt     ImmichTime `json:"createdAt"` // date of the upload
+	DeviceAssetID string     `json:"deviceAssetId"`
+	DeviceID      string     `json:"deviceId"`
 	// duplicateId
 	Duration       string     `json:"duration"`
 	ExifInfo       ExifInfo   `json:"exifInfo"`
@@ -43,9 +43,9 @@ type Asset struct {
 	// originalMimeType
 	OriginalPath string `json:"originalPath"`
 	// owner
-	OwnerID string `json:"ownerId"`
-	// people
-	Resized bool `json:"resized"`
+	OwnerID string              `json:"ownerId"`
+	People  []PersonResponseDto `json:"people,omitempty"` // given by GetAssetInfo
+	Resized bool                `json:"resized"`
 	// stack
 	Tags      []TagSimplified `json:"tags"`
 	Thumbhash string          `json:"thumbhash"`
@@ -77,6 +77,10 @@ func (ia Asset) AsAsset() *assets.Asset {
 		File:             fshelper.FSName(nil, ia.OriginalFileName),
 		FileSize:         int(ia.ExifInfo.FileSizeInByte),
 		Checksum:         ia.Checksum,
+		CameraMake:       ia.ExifInfo.Make,
+		CameraModel:      ia.ExifInfo.Model,
+		Width:            ia.ExifInfo.ExifImageWidth,
+		Height:           ia.ExifInfo.ExifImageHeight,
 	}
 	for _, album := range ia.Albums {
 		a.Albums = append(a.Albums, assets.Album{
@@ -237,9 +241,9 @@ type UpdAssetField struct {
 	IsFavorite       bool      `json:"isFavorite,omitempty"`
 	Latitude         float64   `json:"latitude,omitempty"`
 	Longitude        float64   `json:"longitude,omitempty"`
-	Description      string    `json:"description,omitempty"`
-	Rating           int       `json:"rating,omitempty"`
-	DateTimeOriginal time.Time `json:"dateTimeOriginal,omitempty"`
+	Description      *string   `json:"description,omitempty"` // nil to keep the value, empty to remove it
+	Rating           *int      `json:"rating,omitempty"`      // nil to keep the value
+	DateTimeOriginal time.Time `json:"dateTimeOriginal,omitzero"`
 }
 
 // MarshalJSON customizes the JSON marshaling for the UpdAssetField struct.
@@ -252,9 +256,9 @@ func (u UpdAssetField) MarshalJSON() ([]byte, error) {
 		IsFavorite       bool      `json:"isFavorite,omitempty"`
 		Latitude         float64   `json:"latitude"`
 		Longitude        float64   `json:"longitude"`
-		Description      string    `json:"description,omitempty"`
-		Rating           int       `json:"rating,omitempty"`
-		DateTimeOriginal time.Time `json:"dateTimeOriginal,omitempty"`
+		Description      *string   `json:"description,omitempty"`
+		Rating           *int      `json:"rating,omitempty"`
+		DateTimeOriginal time.Time `json:"dateTimeOriginal,omitzero"`
 	}
 
 	// alias is used to omit Latitude and Longitude when they are zero.
@@ -278,6 +282,32 @@ func (ic *ImmichClient) UpdateAsset(ctx context.Context, id string, param UpdAss
 	return &r, err
 }
 
+// LinkLivePhoto links the video to the image as the motion part of a live photo, an empty video ID unlinks the image
+func (ic *ImmichClient) LinkLivePhoto(ctx context.Context, imageID string, videoID string) error {
+	if ic.dryRun {
+		return nil
+	}
+	param := struct {
+		LivePhotoVideoID *string `json:"livePhotoVideoId"`
+	}{}
+	if videoID != "" {
+		param.LivePhotoVideoID = &videoID
+	}
+	return ic.newServerCall(ctx, "LinkLivePhoto").do(putRequest("/assets/"+imageID, setJSONBody(param)))
+}
+
+// SetAssetsVisibility shows the assets in the timeline, archives or hides them
+func (ic *ImmichClient) SetAssetsVisibility(ctx context.Context, ids []string, visibility assets.Visibility) error {
+	if ic.dryRun {
+		return nil
+	}
+	param := struct {
+		IDs        []string          `json:"ids"`
+		Visibility assets.Visibility `json:"visibility"`
+	}{IDs: ids, Visibility: visibility}
+	return ic.newServerCall(ctx, "SetAssetsVisibility").do(putRequest("/assets", setJSONBody(param)))
+}
+
 func (ic *ImmichClient) DownloadAsset(ctx context.Context, id string) (io.ReadCloser, error) {
 	var rc io.ReadCloser
 
@@ -285,6 +315,14 @@ func (ic *ImmichClient) DownloadAsset(ctx context.Context, id string) (io.ReadCl
 	return rc, err
 }
 
+// DownloadThumbnail returns the small thumbnail of the asset, a WEBP or JPEG image of about 250 pixels
+func (ic *ImmichClient) DownloadThumbnail(ctx context.Context, id string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+
+	err := ic.newServerCall(ctx, "DownloadThumbnail").do(getRequest(fmt.Sprintf("/assets/%s/thumbnail?size=thumbnail", id), setOctetStream()), responseOctetStream(&rc))
+	return rc, err
+}
+
 // CopyAsset copy metadata from the sourceID to targeID
 func (ic *ImmichClient) CopyAsset(ctx context.Context, sourceID string, targetID string) error {
 	if ic.dryRun {
@@ -27,6 +27,8 @@ const (
 	EndPointCreateAlbum            = "CreateAlbum"
 	EndPointGetAssetAlbums         = "GetAssetAlbums"
 	EndPointDeleteAlbum            = "DeleteAlbum"
+	EndPointRemoveAssetFromAlbum   = "RemoveAssetFromAlbum"
+	EndPointUpdateAlbum            = "UpdateAlbum"
 	EndPointPingServer             = "PingServer"
 	EndPointValidateConnection     = "ValidateConnection"
 	EndPointGetServerStatistics    = "GetServerStatistics"
@@ -37,12 +39,18 @@ const (
 	EndPointTagAssets              = "TagAssets"
 	EndPointBulkTagAssets          = "BulkTagAssets"
 	EndPointGetAllTags             = "GetAllTags"
+	EndPointUntagAssets            = "UntagAssets"
+	EndPointDeleteTag              = "DeleteTag"
 	EndPointAssetUpload            = "AssetUpload"
 	EndPointAssetReplace           = "AssetReplace"
 	EndPointCopyAsset              = "CopyAsset"
 	EndPointGetAboutInfo           = "GetAboutInfo"
 	EndPointGetSearchSuggestions   = "GetSearchSuggestions"
 	EndPointGetAllPeople           = "GetAllPeople"
+	EndPointUpdatePerson           = "UpdatePerson"
+	EndPointMergePeople            = "MergePeople"
+	EndPointGetPersonStatistics    = "GetPersonStatistics"
+	EndPointGetPersonThumbnail     = "GetPersonThumbnail"
 	EndPointSignUpAdmin            = "SignUpAdmin"
 	EndPointAdminLogin             = "AdminLogin"
 	EndPointLogin                  = "Login"
@@ -217,6 +225,15 @@ func putRequest(url string, opts ...serverRequestOption) requestFunction {
 	}
 }
 
+func patchRequest(url string, opts ...serverRequestOption) requestFunction {
+	return func(sc *serverCall) *http.Request {
+		if sc.err != nil {
+			return nil
+		}
+		return sc.request(http.MethodPatch, sc.ic.endPoint+url, opts...)
+	}
+}
+
 func (sc *serverCall) do(fnRequest requestFunction, opts ...serverResponseOption) error {
 	var (
 		resp *http.Response
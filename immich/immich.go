@@ -22,12 +22,16 @@ type ImmichInterface interface {
 	ImmichTagInterface
 	ImmichStackInterface
 	ImmichJobInterface
+	ImmichPeopleInterface
 }
 
 type ImmichAssetInterface interface {
 	GetAssetInfo(ctx context.Context, id string) (*Asset, error)
 	DownloadAsset(ctx context.Context, id string) (io.ReadCloser, error)
+	DownloadThumbnail(ctx context.Context, id string) (io.ReadCloser, error)
 	UpdateAsset(ctx context.Context, id string, param UpdAssetField) (*Asset, error)
+	LinkLivePhoto(ctx context.Context, imageID string, videoID string) error
+	SetAssetsVisibility(ctx context.Context, ids []string, visibility assets.Visibility) error
 	ReplaceAsset(ctx context.Context, ID string, la *assets.Asset) (AssetResponse, error) // Deprecated
 	CopyAsset(ctx context.Context, sourceID string, targetID string) error
 	GetAllAssets(ctx context.Context, fn func(*Asset) error) error
@@ -83,7 +87,11 @@ type ImmichAlbumInterface interface {
 
 	// GetAssetAlbums get all albums that an asset belongs to
 	GetAssetAlbums(ctx context.Context, assetID string) ([]AlbumSimplified, error)
+	RemoveAssetFromAlbum(ctx context.Context, albumID string, assets []string) ([]UpdateAlbumResult, error)
 	DeleteAlbum(ctx context.Context, id string) error
+
+	// UpdateAlbum changes the name and the description of an album
+	UpdateAlbum(ctx context.Context, id string, name string, description string) error
 }
 type ImmichTagInterface interface {
 	GetAllTags(ctx context.Context) ([]TagSimplified, error)
@@ -93,6 +101,12 @@ type ImmichTagInterface interface {
 		tagID string,
 		assetIDs []string,
 	) ([]TagAssetsResponse, error)
+	UntagAssets(
+		ctx context.Context,
+		tagID string,
+		assetIDs []string,
+	) ([]TagAssetsResponse, error)
+	DeleteTag(ctx context.Context, id string) error
 	BulkTagAssets(
 		ctx context.Context,
 		tagIDs []string,
@@ -105,6 +119,12 @@ type ImmichTagInterface interface {
 type ImmichStackInterface interface {
 	// CreateStack create a stack with the given assets, the 1st asset is the cover, return the stack ID
 	CreateStack(ctx context.Context, ids []string) (string, error)
+	// DeleteStack deletes the stack, the assets are kept
+	DeleteStack(ctx context.Context, id string) error
+	// GetAllStacks returns the user's stacks with their assets
+	GetAllStacks(ctx context.Context) ([]Stack, error)
+	// UpdateStack changes the cover of the stack
+	UpdateStack(ctx context.Context, id string, primaryAssetID string) error
 }
 
 type ImmichJobInterface interface {
@@ -123,6 +143,10 @@ type ImmichPeopleInterface interface {
 	GetAllPeopleIterator(ctx context.Context, fn func(*PersonResponseDto) error, opts ...GetAllPeopleOptions) error
 	GetPersonByName(ctx context.Context, name string, opts ...GetAllPeopleOptions) (*PersonResponseDto, error)
 	GetPeopleByNames(ctx context.Context, names []string, opts ...GetAllPeopleOptions) (map[string]*PersonResponseDto, error)
+	UpdatePerson(ctx context.Context, id string, upd PersonUpdate) error
+	MergePeople(ctx context.Context, id string, ids []string) error
+	GetPersonStatistics(ctx context.Context, id string) (int, error)
+	DownloadPersonThumbnail(ctx context.Context, id string) (io.ReadCloser, error)
 }
 
 type myBool bool
@@ -3,6 +3,7 @@ package immich
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 )
@@ -197,3 +198,49 @@ func (ic *ImmichClient) GetPeopleByNames(ctx context.Context, names []string, op
 
 	return result, nil
 }
+
+// PersonUpdate lists the changes of a person, the empty fields are unchanged
+type PersonUpdate struct {
+	Name      string `json:"name,omitempty"`
+	BirthDate string `json:"birthDate,omitempty"` // YYYY-MM-DD
+	IsHidden  *bool  `json:"isHidden,omitempty"`
+}
+
+// UpdatePerson changes the name, the birth date or the visibility of a person
+func (ic *ImmichClient) UpdatePerson(ctx context.Context, id string, upd PersonUpdate) error {
+	if ic.dryRun {
+		return nil
+	}
+	return ic.newServerCall(ctx, EndPointUpdatePerson).do(
+		putRequest("/people/"+id, setAcceptJSON(), setJSONBody(upd)))
+}
+
+// MergePeople merges the people ids into the person id, their faces are given to the person
+func (ic *ImmichClient) MergePeople(ctx context.Context, id string, ids []string) error {
+	if ic.dryRun {
+		return nil
+	}
+	body := struct {
+		IDs []string `json:"ids"`
+	}{IDs: ids}
+	return ic.newServerCall(ctx, EndPointMergePeople).do(
+		postRequest("/people/"+id+"/merge", "application/json", setAcceptJSON(), setJSONBody(body)))
+}
+
+// GetPersonStatistics returns the number of assets of a person
+func (ic *ImmichClient) GetPersonStatistics(ctx context.Context, id string) (int, error) {
+	var r struct {
+		Assets int `json:"assets"`
+	}
+	err := ic.newServerCall(ctx, EndPointGetPersonStatistics).do(
+		getRequest("/people/"+id+"/statistics", setAcceptJSON()), responseJSON(&r))
+	return r.Assets, err
+}
+
+// DownloadPersonThumbnail returns the JPEG thumbnail of a person's face
+func (ic *ImmichClient) DownloadPersonThumbnail(ctx context.Context, id string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := ic.newServerCall(ctx, EndPointGetPersonThumbnail).do(
+		getRequest("/people/"+id+"/thumbnail", setOctetStream()), responseOctetStream(&rc))
+	return rc, err
+}
@@ -38,3 +38,36 @@ func (ic *ImmichClient) CreateStack(ctx context.Context, ids []string) (string,
 	err := ic.newServerCall(ctx, "createStack").do(postRequest("/stacks", "application/json", setAcceptJSON(), setJSONBody(param)), responseJSON(&result))
 	return result.ID, err
 }
+
+// DeleteStack deletes the stack, the assets are kept
+func (ic *ImmichClient) DeleteStack(ctx context.Context, id string) error {
+	if ic.dryRun {
+		return nil
+	}
+	return ic.newServerCall(ctx, "deleteStack").do(deleteRequest("/stacks/" + id))
+}
+
+// Stack is a stack of assets, the primary asset is the cover
+type Stack struct {
+	ID             string   `json:"id"`
+	PrimaryAssetID string   `json:"primaryAssetId"`
+	Assets         []*Asset `json:"assets"`
+}
+
+// GetAllStacks returns the user's stacks with their assets
+func (ic *ImmichClient) GetAllStacks(ctx context.Context) ([]Stack, error) {
+	var r []Stack
+	err := ic.newServerCall(ctx, "searchStacks").do(getRequest("/stacks", setAcceptJSON()), responseJSON(&r))
+	return r, err
+}
+
+// UpdateStack changes the cover of the stack
+func (ic *ImmichClient) UpdateStack(ctx context.Context, id string, primaryAssetID string) error {
+	if ic.dryRun {
+		return nil
+	}
+	body := struct {
+		PrimaryAssetID string `json:"primaryAssetId"`
+	}{PrimaryAssetID: primaryAssetID}
+	return ic.newServerCall(ctx, "updateStack").do(putRequest("/stacks/"+id, setAcceptJSON(), setJSONBody(body)))
+}
@@ -81,6 +81,42 @@ func (ic *ImmichClient) TagAssets(
 	return resp, nil
 }
 
+// UntagAssets removes the tag from the assets
+func (ic *ImmichClient) UntagAssets(
+	ctx context.Context,
+	tagID string,
+	assetIDs []string,
+) ([]TagAssetsResponse, error) {
+	if ic.dryRun {
+		resp := make([]TagAssetsResponse, len(assetIDs))
+		for i, a := range assetIDs {
+			resp[i] = TagAssetsResponse{
+				ID:      a,
+				Success: true,
+			}
+		}
+		return resp, nil
+	}
+
+	var resp []TagAssetsResponse
+
+	body := struct {
+		IDs []string `json:"ids"`
+	}{IDs: assetIDs}
+	err := ic.newServerCall(ctx, EndPointUntagAssets).
+		do(deleteRequest(fmt.Sprintf("/tags/%s/assets", tagID), setJSONBody(body), setAcceptJSON()), responseJSON(&resp))
+
+	return resp, err
+}
+
+// DeleteTag deletes the tag and its children
+func (ic *ImmichClient) DeleteTag(ctx context.Context, id string) error {
+	if ic.dryRun {
+		return nil
+	}
+	return ic.newServerCall(ctx, EndPointDeleteTag).do(deleteRequest("/tags/" + id))
+}
+
 func (ic *ImmichClient) BulkTagAssets(
 	ctx context.Context,
 	tagIDs []string,
@@ -135,7 +135,9 @@ func (ic *ImmichClient) prepareCallValues(la *assets.Asset, s fs.FileInfo, ext,
 	callValues["fileExtension"] = ext
 	callValues["duration"] = formatDuration(0)
 	callValues["isReadOnly"] = "false"
-	if la.Archived {
+	if la.Visibility != assets.VisibilityUnknown {
+		callValues["visibility"] = string(la.Visibility)
+	} else if la.Archived {
 		callValues["visibility"] = "archive"
 	} else {
 		callValues["visibility"] = "timeline"
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/simulot/immich-go/internal/fshelper"
@@ -47,6 +48,8 @@ type Asset struct {
 	OriginalFileName string // File name as delivered to Immich/Google
 	Description      string // Google Photos may a have description
 	FileSize         int    // File size in bytes
+	Width            int    // Pixel width, 0 when unknown
+	Height           int    // Pixel height, 0 when unknown
 
 	// Metadata for the process and the upload to Immich
 	CaptureDate time.Time  // Date of the capture
@@ -59,6 +62,15 @@ type Asset struct {
 	Tags        []Tag      // List of tags the asset is tagged with
 	Visibility  Visibility // Immich visibility
 
+	// Values given by the rules of the configuration file
+	RuleRating      bool // The rating has been set by a rule
+	RuleDescription bool // The description has been set by a rule
+
+	// Camera
+	CameraMake    string // Maker of the camera
+	CameraModel   string // Model of the camera
+	DateCorrected bool   // The capture date has been corrected by a clock rule
+
 	// Information inferred from the original file name
 	NameInfo
 
@@ -69,9 +81,14 @@ type Asset struct {
 	// GPS location
 	Latitude  float64 // GPS latitude
 	Longitude float64 // GPS longitude
+	GeoTagged bool    // The position has been given by a track log
+
+	// MotionPhoto is the motion photo this video is extracted from, the video has no file of its own
+	MotionPhoto *Asset
 
 	// buffer management
 	cacheReader *cachereader.CacheReader
+	content     []byte // replaces the file's content, see SetContent
 }
 
 // Kind is the probable type of the image
@@ -87,6 +104,43 @@ const (
 	KindLongExposure
 )
 
+func (k Kind) String() string {
+	switch k {
+	case KindBurst:
+		return "burst"
+	case KindEdited:
+		return "edited"
+	case KindPortrait:
+		return "portrait"
+	case KindNight:
+		return "night"
+	case KindMotion:
+		return "motion"
+	case KindLongExposure:
+		return "long exposure"
+	}
+	return ""
+}
+
+// ParseKind returns the kind named by s, KindNone when the name is unknown
+func ParseKind(s string) Kind {
+	switch strings.ToLower(s) {
+	case "burst":
+		return KindBurst
+	case "edited":
+		return KindEdited
+	case "portrait":
+		return KindPortrait
+	case "night":
+		return KindNight
+	case "motion", "mp":
+		return KindMotion
+	case "long_exposure", "longexposure", "long exposure":
+		return KindLongExposure
+	}
+	return KindNone
+}
+
 type NameInfo struct {
 	Base       string    // base name (with extension)
 	Ext        string    // extension
@@ -107,6 +161,10 @@ func (a *Asset) UseMetadata(md *Metadata) *Metadata {
 	if md == nil {
 		return nil
 	}
+	if md.NaiveDate {
+		// Use the time zone of the place of capture when it's known
+		md.DateTaken = LocalizeDate(md.DateTaken, md.Latitude, md.Longitude)
+	}
 	a.Description = md.Description
 	a.Latitude = md.Latitude
 	a.Longitude = md.Longitude
@@ -118,6 +176,10 @@ func (a *Asset) UseMetadata(md *Metadata) *Metadata {
 	a.Rating = int(md.Rating)
 	a.MergeAlbums(md.Albums)
 	a.MergeTags(md.Tags)
+	if md.CameraMake != "" || md.CameraModel != "" {
+		a.CameraMake = md.CameraMake
+		a.CameraModel = md.CameraModel
+	}
 	return md
 }
 
@@ -186,7 +248,7 @@ func (a *Asset) GetChecksum() (string, error) {
 		return "", errors.New("no file to compute checksum")
 	}
 
-	f, err := a.File.Open()
+	f, err := a.openSource()
 	if err != nil {
 		return "", err
 	}
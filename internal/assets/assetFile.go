@@ -1,8 +1,12 @@
 package assets
 
 import (
+	"bytes"
+	"io"
+
 	"github.com/simulot/immich-go/internal/fshelper/cachereader"
 	"github.com/simulot/immich-go/internal/fshelper/debugfiles"
+	"github.com/simulot/immich-go/internal/fshelper/hash"
 	"github.com/simulot/immich-go/internal/fshelper/osfs"
 )
 
@@ -16,11 +20,10 @@ import (
 func (a *Asset) OpenFile() (osfs.OSFS, error) {
 	if a.cacheReader == nil {
 		// get a FS.File from of the asset
-		f, err := a.File.Open()
+		f, err := a.openSource()
 		if err != nil {
 			return nil, err
 		}
-		debugfiles.TrackOpenFile(f, a.File.FullName())
 		// Create a cache reader from the FS.File
 		cr, sha1, err := cachereader.NewCacheReader(a.File.FullName(), f)
 		if err != nil {
@@ -34,12 +37,42 @@ func (a *Asset) OpenFile() (osfs.OSFS, error) {
 	return a.cacheReader.OpenFile()
 }
 
-// Close close the temporary file  and close the source
+// openSource opens the asset's file, or its content when given by SetContent
+func (a *Asset) openSource() (io.ReadCloser, error) {
+	if a.content != nil {
+		return io.NopCloser(bytes.NewReader(a.content)), nil
+	}
+	f, err := a.File.Open()
+	if err != nil {
+		return nil, err
+	}
+	debugfiles.TrackOpenFile(f, a.File.FullName())
+	return f, nil
+}
+
+// SetContent replaces the content of the asset's file, like the still image cut from a motion photo.
+// The file stays the source of the asset for the logs and the actions after the upload.
+func (a *Asset) SetContent(b []byte) error {
+	_ = a.Close()
+	checksum, err := hash.Base64Encode(hash.GetSHA1Hash(bytes.NewReader(b)))
+	if err != nil {
+		return err
+	}
+	a.content = b
+	a.FileSize = len(b)
+	a.Checksum = checksum
+	return nil
+}
+
+// Close close the temporary file  and close the source.
+// The next OpenFile reads the source again.
 func (a *Asset) Close() error {
 	if a.cacheReader == nil {
 		return nil
 	}
-	return a.cacheReader.Close()
+	err := a.cacheReader.Close()
+	a.cacheReader = nil
+	return err
 }
 
 /*
@@ -83,6 +83,20 @@ func (cc *CollectionCache[T]) GetCollection(key string) (T, []string, bool) {
 	return c.collection, c.Items(), true
 }
 
+// Flush saves the pending ids of all collections
+func (cc *CollectionCache[T]) Flush() {
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	cc.chanNewCollection <- func() {
+		defer wg.Done()
+		cc.collections.Range(func(key string, c *Collection[T]) bool {
+			c.flush()
+			return true
+		})
+	}
+	wg.Wait()
+}
+
 func (cc *CollectionCache[T]) Close() {
 	cc.collections.Range(func(key string, c *Collection[T]) bool {
 		c.close()
@@ -129,12 +143,19 @@ func (c *Collection[T]) addID(id string) bool {
 		added = true
 		c.newItems.Add(id)
 		if c.newItems.Len() >= c.maxCacheSize {
-			// err is ignored because it's logged in the saveFn
-			c.collection, _ = c.saveFn(c.collection, c.newItems.Items())
-
-			// a fresh set of assets, even if the save failed, to avoid retrying the same assets
-			c.newItems = syncset.New[string]()
+			c.flush()
 		}
 	}
 	return added
 }
+
+func (c *Collection[T]) flush() {
+	if c.newItems.Len() == 0 {
+		return
+	}
+	// err is ignored because it's logged in the saveFn
+	c.collection, _ = c.saveFn(c.collection, c.newItems.Items())
+
+	// a fresh set of assets, even if the save failed, to avoid retrying the same assets
+	c.newItems = syncset.New[string]()
+}
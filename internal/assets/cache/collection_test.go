@@ -146,3 +146,28 @@ func TestCollectionCacheConcurrentAccess(t *testing.T) {
 	}
 	cc.Close()
 }
+
+func TestFlush(t *testing.T) {
+	saved := map[string][]string{}
+	saveFn := func(coll string, ids []string) (string, error) {
+		saved[coll] = append(saved[coll], ids...)
+		return coll + "+", nil
+	}
+
+	cc := NewCollectionCache[string](10, saveFn)
+	cc.AddIDToCollection("key1", "coll1", "id1")
+	cc.AddIDToCollection("key2", "coll2", "id2")
+	cc.Flush()
+	if len(saved["coll1"]) != 1 || len(saved["coll2"]) != 1 {
+		t.Errorf("expected the pending ids to be saved, got %v", saved)
+	}
+
+	// the saved collection is used for the next saves, and the ids are saved once
+	cc.AddIDToCollection("key1", "coll1", "id3")
+	cc.Flush()
+	cc.Flush()
+	if len(saved["coll1+"]) != 1 || saved["coll1+"][0] != "id3" {
+		t.Errorf("expected id3 to be saved once in coll1+, got %v", saved)
+	}
+	cc.Close()
+}
@@ -25,6 +25,9 @@ type Metadata struct {
 	Archived    bool               `json:"archived,omitempty"`    // Flag to indicate if the image has been archived
 	Favorited   bool               `json:"favorited,omitempty"`   // Flag to indicate if the image has been favorited
 	FromPartner bool               `json:"fromPartner,omitempty"` // Flag to indicate if the image is from a partner
+	NaiveDate   bool               `json:"naiveDate,omitempty"`   // DateTaken has no time offset, it is expressed in the local time zone
+	CameraMake  string             `json:"-"`                     // Maker of the camera
+	CameraModel string             `json:"-"`                     // Model of the camera
 }
 
 func (m Metadata) LogValue() slog.Value {
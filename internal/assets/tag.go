@@ -20,3 +20,12 @@ func (a *Asset) AddTag(tag string) {
 	}
 	a.Tags = append(a.Tags, Tag{Name: path.Base(tag), Value: tag})
 }
+
+func (a *Asset) RemoveTag(tag string) {
+	for i, t := range a.Tags {
+		if t.Value == tag {
+			a.Tags = append(a.Tags[:i], a.Tags[i+1:]...)
+			return
+		}
+	}
+}
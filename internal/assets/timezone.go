@@ -0,0 +1,29 @@
+package assets
+
+import "time"
+
+// TimeZoneFinder gives the time zone of a GPS position
+type TimeZoneFinder interface {
+	Location(latitude, longitude float64) *time.Location
+}
+
+var tzFinder TimeZoneFinder
+
+// SetTimeZoneFinder enables the localization of the dates without time offset.
+// A nil finder disables it.
+func SetTimeZoneFinder(f TimeZoneFinder) {
+	tzFinder = f
+}
+
+// LocalizeDate interprets the wall clock of a date without time offset in the time zone
+// of the given position. The date is returned unchanged when the time zone can't be determined.
+func LocalizeDate(d time.Time, latitude, longitude float64) time.Time {
+	if tzFinder == nil || d.IsZero() || (latitude == 0 && longitude == 0) {
+		return d
+	}
+	loc := tzFinder.Location(latitude, longitude)
+	if loc == nil {
+		return d
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), d.Nanosecond(), loc)
+}
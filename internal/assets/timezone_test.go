@@ -0,0 +1,52 @@
+package assets
+
+import (
+	"testing"
+	"time"
+)
+
+type fixedFinder struct {
+	loc *time.Location
+}
+
+func (f fixedFinder) Location(latitude, longitude float64) *time.Location {
+	return f.loc
+}
+
+func TestUseMetadataNaiveDate(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	SetTimeZoneFinder(fixedFinder{loc: tokyo})
+	defer SetTimeZoneFinder(nil)
+
+	tcs := []struct {
+		name string
+		md   Metadata
+		want time.Time
+	}{
+		{
+			name: "naive date with GPS",
+			md:   Metadata{DateTaken: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Latitude: 35.6586, Longitude: 139.7454, NaiveDate: true},
+			want: time.Date(2024, 5, 1, 12, 0, 0, 0, tokyo),
+		},
+		{
+			name: "naive date without GPS",
+			md:   Metadata{DateTaken: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), NaiveDate: true},
+			want: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date with offset",
+			md:   Metadata{DateTaken: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Latitude: 35.6586, Longitude: 139.7454},
+			want: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &Asset{}
+			md := tc.md
+			a.UseMetadata(&md)
+			if !a.CaptureDate.Equal(tc.want) {
+				t.Errorf("CaptureDate = %v, want %v", a.CaptureDate, tc.want)
+			}
+		})
+	}
+}
@@ -59,12 +59,16 @@ func (at *AssetTracker) DiscoverAsset(file fshelper.FSAndName, fileSize int64, e
 	defer at.mu.Unlock()
 
 	key := file.FullName()
-	if _, exists := at.assets[key]; exists {
-		// Asset already tracked - this shouldn't happen
-		if at.log != nil {
-			at.log.Warn("Asset already tracked", "file", file, "code", eventCode)
+	if record, exists := at.assets[key]; exists {
+		if record.State == StatePending {
+			// Asset already tracked - this shouldn't happen
+			if at.log != nil {
+				at.log.Warn("Asset already tracked", "file", file, "code", eventCode)
+			}
+			return
 		}
-		return
+		// The asset comes back in the pipeline, like the files of a watched folder
+		at.untrack(record)
 	}
 
 	record := &AssetRecord{
@@ -97,12 +101,15 @@ func (at *AssetTracker) DiscoverAndDiscard(file fshelper.FSAndName, fileSize int
 	defer at.mu.Unlock()
 
 	key := file.FullName()
-	if _, exists := at.assets[key]; exists {
-		// Asset already tracked
-		if at.log != nil {
-			at.log.Warn("Asset already tracked", "file", file, "code", eventCode)
+	if record, exists := at.assets[key]; exists {
+		if record.State == StatePending {
+			// Asset already tracked
+			if at.log != nil {
+				at.log.Warn("Asset already tracked", "file", file, "code", eventCode)
+			}
+			return
 		}
-		return
+		at.untrack(record)
 	}
 
 	now := time.Now()
@@ -133,6 +140,23 @@ func (at *AssetTracker) DiscoverAndDiscard(file fshelper.FSAndName, fileSize int
 	at.discardedSize += fileSize
 }
 
+// untrack removes a finalized asset from the counters before it's discovered again
+func (at *AssetTracker) untrack(record *AssetRecord) {
+	switch record.State {
+	case StateProcessed:
+		at.processed--
+		at.processedSize -= record.FileSize
+	case StateDiscarded:
+		at.discarded--
+		at.discardedSize -= record.FileSize
+	case StateError:
+		at.errors--
+		at.errorSize -= record.FileSize
+	}
+	at.assetSize -= record.FileSize
+	delete(at.assets, record.File.FullName())
+}
+
 // SetProcessed transitions an asset to the PROCESSED state
 func (at *AssetTracker) SetProcessed(file fshelper.FSAndName, eventCode fileevent.Code) {
 	at.mu.Lock()
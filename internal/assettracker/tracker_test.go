@@ -80,6 +80,30 @@ func TestDiscoverAndDiscard(t *testing.T) {
 	}
 }
 
+func TestDiscoverAgain(t *testing.T) {
+	tracker := New()
+	file := fshelper.FSName(mockFS{}, "test.jpg")
+
+	tracker.DiscoverAsset(file, 1024, fileevent.DiscoveredImage)
+	tracker.SetProcessed(file, fileevent.ProcessedUploadSuccess)
+
+	// a processed asset coming back in the pipeline is pending again
+	tracker.DiscoverAsset(file, 2048, fileevent.DiscoveredImage)
+	counters := tracker.GetCounters()
+	if counters.Pending != 1 || counters.Processed != 0 {
+		t.Errorf("expected 1 pending and 0 processed assets, got %d and %d", counters.Pending, counters.Processed)
+	}
+	if counters.AssetSize != 2048 || counters.ProcessedSize != 0 {
+		t.Errorf("expected asset size 2048 and processed size 0, got %d and %d", counters.AssetSize, counters.ProcessedSize)
+	}
+
+	// a pending asset isn't discovered twice
+	tracker.DiscoverAsset(file, 4096, fileevent.DiscoveredImage)
+	if counters := tracker.GetCounters(); counters.Total() != 1 || counters.AssetSize != 2048 {
+		t.Errorf("expected 1 asset of 2048 bytes, got %d of %d bytes", counters.Total(), counters.AssetSize)
+	}
+}
+
 func TestSetProcessed(t *testing.T) {
 	tracker := New()
 	file := fshelper.FSName(mockFS{}, "photo.jpg")
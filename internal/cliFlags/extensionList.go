@@ -14,6 +14,7 @@ type InclusionFlags struct {
 	IncludedExtensions ExtensionList
 	IncludedType       IncludeType
 	DateRange          DateRange
+	Where              Where
 }
 
 func (flags *InclusionFlags) RegisterFlags(fs *pflag.FlagSet, prefix string) {
@@ -21,6 +22,7 @@ func (flags *InclusionFlags) RegisterFlags(fs *pflag.FlagSet, prefix string) {
 	fs.Var(&flags.ExcludedExtensions, prefix+"exclude-extensions", "Comma-separated list of extension to exclude. (e.g. .gif,.PM) (default: none)")
 	fs.Var(&flags.IncludedExtensions, prefix+"include-extensions", "Comma-separated list of extension to include. (e.g. .jpg,.heic) (default: all)")
 	fs.Var(&flags.IncludedType, prefix+"include-type", "Single file type to include. (VIDEO or IMAGE) (default: all)")
+	fs.Var(&flags.Where, prefix+"where", "Only import the assets selected by the expression (e.g. 'size > 5MB && make == \"Apple\" && year(date) < 2015')")
 }
 
 // An IncludeType is either of the constants below which
@@ -0,0 +1,56 @@
+package cliflags
+
+import (
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/expression"
+)
+
+// Where is the expression selecting the assets given by the --where flag
+type Where struct {
+	s    string
+	expr *expression.Expr
+}
+
+// IsSet returns whether an expression is given
+func (w Where) IsSet() bool { return w.expr != nil }
+
+func (w Where) String() string { return w.s }
+
+// Set checks the expression. The dates are read in the local time zone until SetTZ is called.
+func (w *Where) Set(s string) error {
+	if s == "" {
+		*w = Where{}
+		return nil
+	}
+	expr, err := expression.Parse(s, time.Local)
+	if err != nil {
+		return err
+	}
+	*w = Where{s: s, expr: expr}
+	return nil
+}
+
+func (w Where) Type() string { return "expression" }
+
+// SetTZ reads the dates of the expression in the time zone tz
+func (w *Where) SetTZ(tz *time.Location) error {
+	if w.expr == nil {
+		return nil
+	}
+	expr, err := expression.Parse(w.s, tz)
+	if err != nil {
+		return err
+	}
+	w.expr = expr
+	return nil
+}
+
+// Match tells if the asset is selected. All assets are selected when no expression is given.
+func (w Where) Match(a *assets.Asset) bool {
+	if w.expr == nil {
+		return true
+	}
+	return w.expr.Match(a)
+}
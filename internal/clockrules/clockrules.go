@@ -0,0 +1,254 @@
+// Package clockrules corrects the capture dates of cameras with a wrong clock.
+//
+// A rule selects the photos of a camera (make and model) taken in a period,
+// and gives the shift to apply to their dates or the time zone where they were taken.
+//
+// Rules are written as a list of key=value pairs:
+//
+//	make=Canon model="EOS 5D" from=2012-05-01 to=2012-05-20 shift=+7h
+//	make=NIKON from=2019-03-02 to=2019-03-18 tz=Asia/Tokyo
+//
+// or as tables of a configuration file with the same keys.
+package clockrules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/simulot/immich-go/internal/dates"
+	"github.com/simulot/immich-go/internal/stringhelper"
+)
+
+// Rule describes the correction of the dates of a camera during a period
+type Rule struct {
+	Make  string         // camera maker, empty for any camera
+	Model string         // camera model, empty for any model
+	From  time.Time      // start of the period (inclusive), as shown by the camera clock
+	To    time.Time      // end of the period (exclusive), as shown by the camera clock
+	Shift time.Duration  // duration to add to the camera clock
+	TZ    *time.Location // time zone of the place of capture, the camera clock stayed at home time
+}
+
+// Rules is a list of rules. The first matching rule is applied.
+type Rules []Rule
+
+// Parse reads a rule written as key=value pairs separated by spaces.
+// Values containing spaces are enclosed in double quotes.
+func Parse(s string) (Rule, error) {
+	var r Rule
+	tokens, err := splitTokens(s)
+	if err != nil {
+		return r, fmt.Errorf("clock rule %q: %w", s, err)
+	}
+	for _, t := range tokens {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			return r, fmt.Errorf("clock rule %q: %q is not a key=value pair", s, t)
+		}
+		if err := r.set(k, v); err != nil {
+			return r, fmt.Errorf("clock rule %q: %w", s, err)
+		}
+	}
+	return r, r.check()
+}
+
+// ParseConfig reads the rules given by a configuration file.
+// Each item of the list is either a rule string or a table of keys.
+func ParseConfig(v any) (Rules, error) {
+	var rs Rules
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		r, err := Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		return Rules{r}, nil
+	case []string:
+		for _, s := range v {
+			r, err := Parse(s)
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, r)
+		}
+	case []any:
+		for _, item := range v {
+			var r Rule
+			var err error
+			switch item := item.(type) {
+			case string:
+				r, err = Parse(item)
+			case map[string]any:
+				r, err = parseTable(item)
+			default:
+				err = fmt.Errorf("clock rule: unexpected value %v", item)
+			}
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, r)
+		}
+	default:
+		return nil, fmt.Errorf("clock rules: unexpected value %v", v)
+	}
+	return rs, nil
+}
+
+func parseTable(m map[string]any) (Rule, error) {
+	var r Rule
+	for k, v := range m {
+		var s string
+		switch v := v.(type) {
+		case time.Time:
+			s = v.Format("2006-01-02T15:04:05")
+		default:
+			s = fmt.Sprint(v)
+		}
+		if err := r.set(k, s); err != nil {
+			return r, fmt.Errorf("clock rule: %w", err)
+		}
+	}
+	return r, r.check()
+}
+
+func (r *Rule) set(key, value string) error {
+	var err error
+	switch strings.ToLower(key) {
+	case "make":
+		r.Make = value
+	case "model":
+		r.Model = value
+	case "from":
+		r.From, _, err = dates.Parse(value, time.UTC)
+	case "to":
+		var dateOnly bool
+		r.To, dateOnly, err = dates.Parse(value, time.UTC)
+		if dateOnly {
+			// the last day is included
+			r.To = r.To.AddDate(0, 0, 1)
+		}
+	case "shift":
+		r.Shift, err = time.ParseDuration(value)
+	case "tz":
+		r.TZ, err = time.LoadLocation(value)
+	default:
+		err = fmt.Errorf("unknown key %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func (r Rule) check() error {
+	if r.Shift == 0 && r.TZ == nil {
+		return fmt.Errorf("clock rule %s: a shift or a tz is needed", r)
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return fmt.Errorf("clock rule %s: the period is empty", r)
+	}
+	return nil
+}
+
+// splitTokens splits the string on spaces, keeping the double-quoted values together
+func splitTokens(s string) ([]string, error) {
+	var tokens []string
+	var b strings.Builder
+	inQuotes := false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if b.Len() > 0 {
+				tokens = append(tokens, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote")
+	}
+	if b.Len() > 0 {
+		tokens = append(tokens, b.String())
+	}
+	return tokens, nil
+}
+
+// Match tells if the rule applies to a photo taken by the camera at the date shown by its clock
+func (r Rule) Match(cameraMake, cameraModel string, date time.Time) bool {
+	if date.IsZero() {
+		return false
+	}
+	if r.Make != "" && !stringhelper.ContainsFold(cameraMake, r.Make) {
+		return false
+	}
+	if r.Model != "" && !stringhelper.ContainsFold(cameraModel, r.Model) {
+		return false
+	}
+	// Compare the wall clock of the camera
+	wall := time.Date(date.Year(), date.Month(), date.Day(), date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), time.UTC)
+	if !r.From.IsZero() && wall.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !wall.Before(r.To) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the corrected date
+func (r Rule) Apply(date time.Time) time.Time {
+	date = date.Add(r.Shift)
+	if r.TZ != nil {
+		date = date.In(r.TZ)
+	}
+	return date
+}
+
+func (r Rule) String() string {
+	var parts []string
+	if r.Make != "" {
+		parts = append(parts, "make="+quote(r.Make))
+	}
+	if r.Model != "" {
+		parts = append(parts, "model="+quote(r.Model))
+	}
+	if !r.From.IsZero() {
+		parts = append(parts, "from="+r.From.Format("2006-01-02T15:04:05"))
+	}
+	if !r.To.IsZero() {
+		parts = append(parts, "to="+r.To.Format("2006-01-02T15:04:05"))
+	}
+	if r.Shift != 0 {
+		parts = append(parts, "shift="+r.Shift.String())
+	}
+	if r.TZ != nil {
+		parts = append(parts, "tz="+r.TZ.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Fix applies the first rule matching the camera and the date.
+// It returns the corrected date and true when a rule has been applied.
+func (rs Rules) Fix(cameraMake, cameraModel string, date time.Time) (time.Time, bool) {
+	for _, r := range rs {
+		if r.Match(cameraMake, cameraModel, date) {
+			return r.Apply(date), true
+		}
+	}
+	return date, false
+}
+
+func quote(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package clockrules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{rule: `make=Canon model="EOS 5D" from=2012-05-01 to=2012-05-20 shift=+7h`},
+		{rule: `make=NIKON from=2019-03-02 to=2019-03-18 tz=Asia/Tokyo`},
+		{rule: `model=X100 shift=-1h30m`},
+		{rule: `make=Canon`, wantErr: true},
+		{rule: `make=Canon shift=7`, wantErr: true},
+		{rule: `model="EOS 5D shift=1h`, wantErr: true},
+		{rule: `from=2012-05-20 to=2012-05-01 shift=1h`, wantErr: true},
+		{rule: `lens=50mm shift=1h`, wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.rule, func(t *testing.T) {
+			_, err := Parse(tc.rule)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	rs, err := ParseConfig([]any{
+		`make=Canon model="EOS 5D" from=2012-05-01 to=2012-05-20 shift=+7h`,
+		map[string]any{"make": "NIKON", "from": time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC), "to": "2019-03-18", "tz": "Asia/Tokyo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rs))
+	}
+	if rs[0].Model != "EOS 5D" || rs[0].Shift != 7*time.Hour {
+		t.Errorf("unexpected rule %s", rs[0])
+	}
+	if rs[1].TZ == nil || rs[1].TZ.String() != "Asia/Tokyo" {
+		t.Errorf("unexpected rule %s", rs[1])
+	}
+}
+
+func TestFix(t *testing.T) {
+	rs, err := ParseConfig([]string{
+		`make=Canon model="EOS 5D" from=2012-05-01 to=2012-05-20 shift=+7h`,
+		`make=NIKON from=2019-03-02 to=2019-03-18 tz=Asia/Tokyo`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, _ := time.LoadLocation("Europe/Paris")
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	tcs := []struct {
+		name      string
+		make      string
+		model     string
+		date      time.Time
+		want      time.Time
+		wantFixed bool
+	}{
+		{
+			name: "shift", make: "Canon", model: "Canon EOS 5D",
+			date: time.Date(2012, 5, 3, 10, 0, 0, 0, paris), want: time.Date(2012, 5, 3, 17, 0, 0, 0, paris), wantFixed: true,
+		},
+		{
+			name: "last day included", make: "CANON", model: "canon eos 5d",
+			date: time.Date(2012, 5, 20, 23, 0, 0, 0, paris), want: time.Date(2012, 5, 21, 6, 0, 0, 0, paris), wantFixed: true,
+		},
+		{
+			name: "out of the period", make: "Canon", model: "Canon EOS 5D",
+			date: time.Date(2012, 5, 21, 10, 0, 0, 0, paris), want: time.Date(2012, 5, 21, 10, 0, 0, 0, paris),
+		},
+		{
+			name: "other model", make: "Canon", model: "Canon EOS 7D",
+			date: time.Date(2012, 5, 3, 10, 0, 0, 0, paris), want: time.Date(2012, 5, 3, 10, 0, 0, 0, paris),
+		},
+		{
+			name: "time zone", make: "NIKON CORPORATION", model: "NIKON D750",
+			date: time.Date(2019, 3, 5, 2, 0, 0, 0, paris), want: time.Date(2019, 3, 5, 10, 0, 0, 0, tokyo), wantFixed: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, fixed := rs.Fix(tc.make, tc.model, tc.date)
+			if fixed != tc.wantFixed {
+				t.Fatalf("fixed = %v, want %v", fixed, tc.wantFixed)
+			}
+			if !got.Equal(tc.want) || got.Format(time.RFC3339) != tc.want.Format(time.RFC3339) {
+				t.Errorf("date = %s, want %s", got.Format(time.RFC3339), tc.want.Format(time.RFC3339))
+			}
+		})
+	}
+}
@@ -190,3 +190,9 @@ func (cm *ConfigurationManager) GetConfigFile() string {
 func (cm *ConfigurationManager) Save(fileName string) error {
 	return cm.v.WriteConfigAs(fileName)
 }
+
+// Get returns the value of a configuration section that isn't bound to a flag.
+// Returns nil when the section is not set.
+func (cm *ConfigurationManager) Get(key string) any {
+	return cm.v.Get(key)
+}
@@ -0,0 +1,33 @@
+// Package dates reads the dates written by the users in the configuration file and on the command line.
+package dates
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// layouts are the accepted formats, the date only format is the last one
+var layouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006:01:02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Parse reads a date with or without time. The dates without time zone are read in the location tz.
+// dateOnly tells the date has no time part.
+func Parse(s string, tz *time.Location) (t time.Time, dateOnly bool, err error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	for i, l := range layouts {
+		if t, err := time.ParseInLocation(l, s, tz); err == nil {
+			return t, i == len(layouts)-1, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", s)
+}
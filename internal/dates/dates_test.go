@@ -0,0 +1,33 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	tests := []struct {
+		s            string
+		want         time.Time
+		wantDateOnly bool
+		wantErr      bool
+	}{
+		{s: "2023-06-01", want: time.Date(2023, 6, 1, 0, 0, 0, 0, paris), wantDateOnly: true},
+		{s: " 2023-06-01 10:20 ", want: time.Date(2023, 6, 1, 10, 20, 0, 0, paris)},
+		{s: "2023-06-01T10:20:30", want: time.Date(2023, 6, 1, 10, 20, 30, 0, paris)},
+		{s: "2023:06:01 10:20:30", want: time.Date(2023, 6, 1, 10, 20, 30, 0, paris)},
+		{s: "2023-06-01T10:20:30+09:00", want: time.Date(2023, 6, 1, 1, 20, 30, 0, time.UTC)},
+		{s: "01/06/2023", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, dateOnly, err := Parse(tt.s, paris)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v", tt.s, err)
+			continue
+		}
+		if !got.Equal(tt.want) || dateOnly != tt.wantDateOnly {
+			t.Errorf("Parse(%q) = %v, %v, want %v, %v", tt.s, got, dateOnly, tt.want, tt.wantDateOnly)
+		}
+	}
+}
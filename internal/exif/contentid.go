@@ -0,0 +1,166 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+const contentIDReadLimit = 16 * 1024 * 1024 // the live photo files are small, the movie's metadata can be at the end
+
+var (
+	appleMakerNoteMarker = []byte("Apple iOS\x00")
+	quickTimeIDMarker    = []byte("com.apple.quicktime.content.identifier")
+)
+
+// appleContentIDTag is the tag of the ContentIdentifier in the Apple maker note
+const appleContentIDTag = 0x0011
+
+// ErrNoContentIdentifier is returned when the file has no Apple content identifier
+var ErrNoContentIdentifier = errors.New("no content identifier")
+
+// contentIDReader returns the function reading the content identifier of the file format, nil when the format has none
+func contentIDReader(name string) func(b []byte) (string, bool) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".heic", ".heif", ".jpg", ".jpeg":
+		return makerNoteContentID
+	case ".mov", ".mp4":
+		return quickTimeContentID
+	}
+	return nil
+}
+
+// HasContentIdentifier tells if the file format can hold an Apple content identifier
+func HasContentIdentifier(name string) bool {
+	return contentIDReader(name) != nil
+}
+
+// ContentIdentifier reads the Apple ContentIdentifier shared by the image and the video of a live photo.
+// It is the tag 0x0011 of the maker note of the HEIC and JPEG images, and the value of the
+// com.apple.quicktime.content.identifier key in the QuickTime metadata of the movies.
+func ContentIdentifier(r io.Reader, name string) (string, error) {
+	read := contentIDReader(name)
+	if read == nil {
+		return "", ErrNoContentIdentifier
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, contentIDReadLimit))
+	if err != nil {
+		return "", err
+	}
+	id, ok := read(b)
+	if !ok || id == "" {
+		return "", ErrNoContentIdentifier
+	}
+	return strings.ToUpper(id), nil
+}
+
+// makerNoteContentID reads the ContentIdentifier tag of the Apple maker note.
+// The maker note starts with "Apple iOS\0", a version and the byte order, then the IFD.
+// The offsets of the values are counted from the start of the maker note.
+func makerNoteContentID(b []byte) (string, bool) {
+	i := bytes.Index(b, appleMakerNoteMarker)
+	if i < 0 {
+		return "", false
+	}
+	mn := b[i:]
+	if len(mn) < 16 {
+		return "", false
+	}
+	var order binary.ByteOrder
+	switch string(mn[12:14]) {
+	case "MM":
+		order = binary.BigEndian
+	case "II":
+		order = binary.LittleEndian
+	default:
+		return "", false
+	}
+	n := int(order.Uint16(mn[14:16]))
+	for k := range n {
+		e := 16 + 12*k
+		if e+12 > len(mn) {
+			return "", false
+		}
+		tag, typ, count := order.Uint16(mn[e:]), order.Uint16(mn[e+2:]), order.Uint32(mn[e+4:])
+		if tag != appleContentIDTag || typ != 2 { // ASCII
+			continue
+		}
+		start := uint32(e + 8)
+		if count > 4 {
+			start = order.Uint32(mn[e+8:])
+		}
+		if uint64(start)+uint64(count) > uint64(len(mn)) {
+			return "", false
+		}
+		return strings.TrimRight(string(mn[start:start+count]), "\x00"), true
+	}
+	return "", false
+}
+
+// quickTimeContentID reads the value of the content identifier key of the QuickTime metadata.
+// The keys atom lists the key names, the ilst atom holds the values: each item's type is the index of its key.
+func quickTimeContentID(b []byte) (string, bool) {
+	for off := 0; ; {
+		i := bytes.Index(b[off:], quickTimeIDMarker)
+		if i < 0 {
+			return "", false
+		}
+		i += off
+		off = i + len(quickTimeIDMarker)
+
+		k := bytes.LastIndex(b[:i], []byte("keys")) - 4
+		if k < 0 {
+			continue
+		}
+		keysEnd := k + int(binary.BigEndian.Uint32(b[k:]))
+		if keysEnd > len(b) || k+16 > keysEnd {
+			continue
+		}
+		// the key entries: size, namespace, name
+		index := uint32(0)
+		n := binary.BigEndian.Uint32(b[k+12:])
+		for e, idx := k+16, uint32(1); idx <= n && e+8 <= keysEnd; idx++ {
+			size := int(binary.BigEndian.Uint32(b[e:]))
+			if size < 8 || e+size > keysEnd {
+				break
+			}
+			if bytes.Equal(b[e+8:e+size], quickTimeIDMarker) {
+				index = idx
+				break
+			}
+			e += size
+		}
+		if index == 0 {
+			continue
+		}
+
+		l := bytes.Index(b[keysEnd:], []byte("ilst"))
+		if l < 4 {
+			continue
+		}
+		l += keysEnd - 4
+		ilstEnd := l + int(binary.BigEndian.Uint32(b[l:]))
+		if ilstEnd > len(b) {
+			continue
+		}
+		// the items: size, key index, then the data atom: size, "data", type, locale, value
+		for e := l + 8; e+8 <= ilstEnd; {
+			size := int(binary.BigEndian.Uint32(b[e:]))
+			if size < 8 || e+size > ilstEnd {
+				break
+			}
+			if binary.BigEndian.Uint32(b[e+4:]) == index && size >= 24 && string(b[e+12:e+16]) == "data" {
+				dataEnd := e + 8 + int(binary.BigEndian.Uint32(b[e+8:]))
+				if dataEnd > e+size || dataEnd < e+24 {
+					break
+				}
+				return string(b[e+24 : dataEnd]), true
+			}
+			e += size
+		}
+	}
+}
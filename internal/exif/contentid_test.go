@@ -0,0 +1,97 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// appleMakerNote returns an Apple maker note with a run time tag and the content identifier
+func appleMakerNote(id string) []byte {
+	b := []byte("Apple iOS\x00\x00\x01MM")
+	b = binary.BigEndian.AppendUint16(b, 2)
+	// run time: a dictionary, the identifier follows the IFD
+	b = binary.BigEndian.AppendUint16(b, 0x0008)
+	b = binary.BigEndian.AppendUint16(b, 7)
+	b = binary.BigEndian.AppendUint32(b, 4)
+	b = append(b, "\x00\x00\x00\x00"...)
+	b = binary.BigEndian.AppendUint16(b, appleContentIDTag)
+	b = binary.BigEndian.AppendUint16(b, 2)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(id)+1))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(b)+4+4))
+	b = append(b, "\x00\x00\x00\x00"...) // next IFD
+	return append(b, id+"\x00"...)
+}
+
+// atom returns a QuickTime atom
+func atom(typ string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	b = append(b, typ...)
+	return append(b, body...)
+}
+
+// quickTimeMeta returns the meta atom of a movie with the keys and their values
+func quickTimeMeta(kv ...string) []byte {
+	keys := [][]byte{{0, 0, 0, 0}, binary.BigEndian.AppendUint32(nil, uint32(len(kv)/2))}
+	var items [][]byte
+	for i := 0; i < len(kv); i += 2 {
+		keys = append(keys, atom("mdta", []byte(kv[i])))
+		data := atom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(kv[i+1]))
+		items = append(items, atom(string(binary.BigEndian.AppendUint32(nil, uint32(i/2+1))), data))
+	}
+	return atom("meta", []byte{0, 0, 0, 0}, atom("hdlr", []byte("....mdta")), atom("keys", keys...), atom("ilst", items...))
+}
+
+func TestContentIdentifier(t *testing.T) {
+	const id = "9A8C3D1E-5B2F-4C6A-8E7D-0F1A2B3C4D5E"
+	const other = "11111111-2222-3333-4444-555555555555"
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{
+			name: "IMG_1234.HEIC",
+			data: append([]byte("ftypheic....Exif\x00\x00MM...."), appleMakerNote(id)...),
+			want: id,
+		},
+		{
+			name: "IMG_1235.HEIC",
+			// another identifier follows the marker, outside of the tag
+			data:    append([]byte("ftypheic....Exif\x00\x00MM....Apple iOS\x00\x00\x01MM\x00\x00"+other), appleMakerNote(id)...),
+			wantErr: ErrNoContentIdentifier,
+		},
+		{
+			name: "IMG_1234.MOV",
+			data: append([]byte("ftypqt  ....moov...."), quickTimeMeta(
+				"com.apple.quicktime.location.accuracy.horizontal", other,
+				"com.apple.quicktime.content.identifier", "9a8c3d1e-5b2f-4c6a-8e7d-0f1a2b3c4d5e",
+			)...),
+			want: id,
+		},
+		{
+			name:    "IMG_1234.JPG",
+			data:    []byte("\xff\xd8....Exif\x00\x00MM....Canon...." + id),
+			wantErr: ErrNoContentIdentifier,
+		},
+		{
+			name:    "IMG_1234.PNG",
+			data:    appleMakerNote(id),
+			wantErr: ErrNoContentIdentifier,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ContentIdentifier(bytes.NewReader(tt.data), tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ContentIdentifier() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ContentIdentifier() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
 	"github.com/simulot/immich-go/internal/assets"
 )
 
@@ -144,6 +145,8 @@ func getExifMetadata(x *exif.Exif, local *time.Location) (*assets.Metadata, erro
 	// _ = x.Walk(exifDumper{})
 
 	md := &assets.Metadata{}
+	md.CameraMake, _ = getTagSting(x, exif.Make)
+	md.CameraModel, _ = getTagSting(x, exif.Model)
 	// md.DateTaken, err = readGPSTimeStamp(x, local)
 	// if err != nil || md.DateTaken.IsZero() {
 	// GPS Time Stamp is not reliable
@@ -153,6 +156,7 @@ func getExifMetadata(x *exif.Exif, local *time.Location) (*assets.Metadata, erro
 		md.DateTaken, err = readDateTime(x, exif.DateTime, exif.SubSecTime, local)
 	}
 	if err == nil {
+		md.NaiveDate = !hasTimeOffset(x)
 		lat, lon, err := x.LatLong()
 		if err == nil {
 			md.Latitude = lat
@@ -162,6 +166,53 @@ func getExifMetadata(x *exif.Exif, local *time.Location) (*assets.Metadata, erro
 	return md, err
 }
 
+// Field names of the time offsets, unknown to goexif
+const (
+	exifOffsetTime         exif.FieldName = "OffsetTime"
+	exifOffsetTimeOriginal exif.FieldName = "OffsetTimeOriginal"
+)
+
+// offsetTimeParser loads the time offsets of the Exif IFD when the EXIF is decoded
+type offsetTimeParser struct{}
+
+func (offsetTimeParser) Parse(x *exif.Exif) error {
+	ptr, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return nil
+	}
+	offset, err := ptr.Int64(0)
+	if err != nil {
+		return nil
+	}
+	r := bytes.NewReader(x.Raw)
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return nil
+	}
+	d, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+	if err != nil {
+		return nil
+	}
+	x.LoadTags(d, map[uint16]exif.FieldName{
+		tagOffsetTime:         exifOffsetTime,
+		tagOffsetTimeOriginal: exifOffsetTimeOriginal,
+	}, false)
+	return nil
+}
+
+func init() {
+	exif.RegisterParsers(offsetTimeParser{})
+}
+
+// hasTimeOffset tells if the EXIF gives the time offset of the dates (OffsetTimeOriginal or OffsetTime)
+func hasTimeOffset(x *exif.Exif) bool {
+	for _, name := range []exif.FieldName{exifOffsetTimeOriginal, exifOffsetTime} {
+		if s, err := getTagSting(x, name); err == nil && s != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // readDateTime with subsecond when possible
 func readDateTime(x *exif.Exif, dateTag exif.FieldName, subSecTag exif.FieldName, local *time.Location) (time.Time, error) {
 	date, err := getTagSting(x, dateTag)
@@ -1,6 +1,7 @@
 package exif
 
 import (
+	"bytes"
 	"os"
 	"testing"
 	"time"
@@ -170,3 +171,28 @@ func Test_parseExifTime(t *testing.T) {
 func floatEquals(a, b, epsilon float64) bool {
 	return (a-b) < epsilon && (b-a) < epsilon
 }
+
+func Test_NaiveDate(t *testing.T) {
+	d := time.Date(2024, 3, 15, 10, 20, 30, 0, time.UTC)
+
+	// EXIF without time offset
+	tb := newTIFFBlock()
+	tb.ifd0.subDir(tagExifIFD).set(asciiEntry(tagDateTimeOriginal, d.Format("2006:01:02 15:04:05")))
+	md, err := MetadataFromDirectRead(bytes.NewReader(tb.encode()), "photo.jpg", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !md.NaiveDate {
+		t.Error("the date should be naive")
+	}
+
+	// EXIF with time offset
+	tb.setDateTaken(d, false)
+	md, err = MetadataFromDirectRead(bytes.NewReader(tb.encode()), "photo.jpg", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.NaiveDate {
+		t.Error("the date should not be naive")
+	}
+}
@@ -11,46 +11,54 @@ import (
 	"github.com/simulot/immich-go/internal/assets"
 )
 
-func ReadXMP(r io.Reader, md *assets.Metadata) error {
+// ReadXMP reads the metadata of the XMP file.
+// The dates without time offset are expressed in the local time zone.
+func ReadXMP(r io.Reader, md *assets.Metadata, local *time.Location) error {
 	// Read the XMP data from the reader and return an Asset
 	m, err := mxj.NewMapXmlReader(r)
 	if err != nil {
 		return err
 	}
-	walk(m, md, "")
+	walk(m, md, "", local)
 	return nil
 }
 
-func walk(m mxj.Map, md *assets.Metadata, path string) {
+func walk(m mxj.Map, md *assets.Metadata, path string, local *time.Location) {
 	for key, value := range m {
 		switch v := value.(type) {
 		case map[string]interface{}:
-			walk(v, md, path+"/"+key)
+			walk(v, md, path+"/"+key, local)
 		case []interface{}:
-			path = path + "/" + key
 			for i, item := range v {
-				p := fmt.Sprintf("%s[%d]", path, i)
+				p := fmt.Sprintf("%s/%s[%d]", path, key, i)
 				if itemMap, ok := item.(map[string]interface{}); ok {
-					walk(itemMap, md, p)
+					walk(itemMap, md, p, local)
 				} else {
-					filter(md, p, item.(string))
+					filter(md, p, item.(string), local)
 				}
 			}
 		default:
-			filter(md, path+"/"+key, value.(string))
+			filter(md, path+"/"+key, value.(string), local)
 		}
 	}
 }
 
-var reDescription = regexp.MustCompile(`/xmpmeta/RDF/Description\[\d+\]/`)
+var (
+	reDescription = regexp.MustCompile(`/xmpmeta/RDF/Description(\[\d+\])?/`)
+	reItemIndex   = regexp.MustCompile(`\[\d+\]$`)
+)
 
-func filter(md *assets.Metadata, p string, value string) {
+func filter(md *assets.Metadata, p string, value string, local *time.Location) {
 	p = reDescription.ReplaceAllString(p, "")
+	p = reItemIndex.ReplaceAllString(p, "")
 	// debug 	fmt.Printf("%s: %s\n", p, value)
 	switch p {
 	case "DateTimeOriginal":
 		if d, err := TimeStringToTime(value, time.UTC); err == nil {
 			md.DateTaken = d
+		} else if d, err := NaiveTimeStringToTime(value, local); err == nil {
+			md.DateTaken = d
+			md.NaiveDate = true
 		}
 	case "ImageDescription/Alt/li/#text":
 		md.Description = value
@@ -62,11 +70,11 @@ func filter(md *assets.Metadata, p string, value string) {
 				Name:  path.Base(value),
 				Value: value,
 			})
-	case "/xmpmeta/RDF/Description/GPSLatitude":
+	case "GPSLatitude":
 		if f, err := GPTStringToFloat(value); err == nil {
 			md.Latitude = f
 		}
-	case "/xmpmeta/RDF/Description/GPSLongitude":
+	case "GPSLongitude":
 		if f, err := GPTStringToFloat(value); err == nil {
 			md.Longitude = f
 		}
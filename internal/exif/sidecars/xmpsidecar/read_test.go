@@ -2,6 +2,7 @@ package xmpsidecar
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,7 +42,7 @@ func TestRead(t *testing.T) {
 			}
 			defer r.Close()
 			md := &assets.Metadata{}
-			err = ReadXMP(r, md)
+			err = ReadXMP(r, md, time.UTC)
 			if err != nil {
 				t.Fatal(err.Error())
 			}
@@ -103,3 +104,26 @@ func exploreMap(m mxj.Map, padding string) {
 	}
 }
 */
+
+func TestReadNaiveDate(t *testing.T) {
+	local := time.FixedZone("UTC-5", -5*3600)
+	for value, naive := range map[string]bool{
+		"2018-08-11T17:38:25Z":      false,
+		"2018-08-11T17:38:25+02:00": false,
+		"2018-08-11T17:38:25.500":   true,
+	} {
+		xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/"><exif:DateTimeOriginal>` + value + `</exif:DateTimeOriginal></rdf:Description>
+</rdf:RDF></x:xmpmeta>`
+		md := &assets.Metadata{}
+		if err := ReadXMP(strings.NewReader(xmp), md, local); err != nil {
+			t.Fatal(err)
+		}
+		if md.DateTaken.IsZero() || md.NaiveDate != naive {
+			t.Errorf("%s: DateTaken = %v, NaiveDate = %v", value, md.DateTaken, md.NaiveDate)
+		}
+		if naive && md.DateTaken.Location() != local {
+			t.Errorf("%s: the naive date should be in the local time zone: %v", value, md.DateTaken)
+		}
+	}
+}
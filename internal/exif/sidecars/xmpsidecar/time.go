@@ -56,8 +56,23 @@ October 23 a timestamp of 2004-10-23T12:00:00-06:00 is more understandable than
 
 const xmpTimeLayout = "2006-01-02T15:04:05Z"
 
+// xmpNaiveTimeLayout is the layout of the dates without time zone designator, they are local times
+const xmpNaiveTimeLayout = "2006-01-02T15:04:05"
+
 func TimeStringToTime(t string, l *time.Location) (time.Time, error) {
-	return time.ParseInLocation(xmpTimeLayout, t, l)
+	d, err := time.ParseInLocation(xmpTimeLayout, t, l)
+	if err != nil {
+		// Date with a time zone designator
+		if d2, err2 := time.Parse(time.RFC3339Nano, t); err2 == nil {
+			return d2, nil
+		}
+	}
+	return d, err
+}
+
+// NaiveTimeStringToTime reads a date without time zone designator in the location l
+func NaiveTimeStringToTime(t string, l *time.Location) (time.Time, error) {
+	return time.ParseInLocation(xmpNaiveTimeLayout, t, l)
 }
 
 func TimeToString(t time.Time) string {
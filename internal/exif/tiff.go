@@ -0,0 +1,422 @@
+package exif
+
+/*
+	Minimal TIFF / EXIF structure handling, used to rewrite the EXIF block of a file.
+
+	The original block is kept byte for byte: the IFDs, the values, the thumbnail and the
+	maker notes stay at their offsets. The changed values are appended at the end of the
+	block, and the IFD tables are patched in place. When entries are added or removed, the
+	new table is appended and its parent pointer is updated.
+	The maker notes holding offsets from the start of the block (Canon, Sony...) remain valid.
+
+	The blocks with IFDs that are not followed (SubIFDs...) are not rewritten.
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TIFF field types
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffSByte     = 6
+	tiffUndefined = 7
+	tiffSShort    = 8
+	tiffSLong     = 9
+	tiffSRational = 10
+	tiffFloat     = 11
+	tiffDouble    = 12
+	tiffIFD       = 13
+)
+
+// Tags handled by the writer
+const (
+	tagImageDescription   = 0x010e
+	tagSubIFDs            = 0x014a
+	tagGlobalParamsIFD    = 0x0190
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagInteropIFD         = 0xa005
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTime         = 0x9010
+	tagOffsetTimeOriginal = 0x9011
+	tagSubSecTimeOriginal = 0x9291
+
+	tagGPSVersionID    = 0x0000
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+var tiffTypeSize = map[uint16]uint32{
+	tiffByte:      1,
+	tiffASCII:     1,
+	tiffShort:     2,
+	tiffLong:      4,
+	tiffRational:  8,
+	tiffSByte:     1,
+	tiffUndefined: 1,
+	tiffSShort:    2,
+	tiffSLong:     4,
+	tiffSRational: 8,
+	tiffFloat:     4,
+	tiffDouble:    8,
+	tiffIFD:       4,
+}
+
+var (
+	errBadTIFF        = errors.New("invalid TIFF structure")
+	errUnfollowedIFDs = errors.New("TIFF structure with unhandled IFDs")
+)
+
+// unfollowedIFDs are the pointer tags of the IFDs that are not decoded
+var unfollowedIFDs = map[uint16]bool{
+	tagSubIFDs:         true,
+	tagGlobalParamsIFD: true,
+}
+
+type tiffEntry struct {
+	tag     uint16
+	typ     uint16
+	count   uint32
+	raw     [4]byte // value or offset field, as found in the block
+	value   []byte  // value, in the byte order of the block. nil when the type is unknown or the offset is out of the block
+	changed bool
+}
+
+type tiffDir struct {
+	offset  uint32              // offset of the IFD in the original block, 0 for a new IFD
+	count   int                 // number of entries in the original block
+	entries []tiffEntry         // entries, the pointers to the sub IFDs included
+	subDirs map[uint16]*tiffDir // sub IFDs (Exif, GPS, Interop) by pointer tag
+	next    uint32              // offset of the next IFD (IFD1), copied as is
+}
+
+type tiffBlock struct {
+	order binary.ByteOrder
+	raw   []byte // original block, nil for a new one
+	ifd0  *tiffDir
+}
+
+// newTIFFBlock returns an empty TIFF block
+func newTIFFBlock() *tiffBlock {
+	return &tiffBlock{
+		order: binary.BigEndian,
+		ifd0:  &tiffDir{},
+	}
+}
+
+// decodeTIFF parses a TIFF block
+func decodeTIFF(b []byte) (*tiffBlock, error) {
+	if len(b) < 8 {
+		return nil, errBadTIFF
+	}
+	t := tiffBlock{raw: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errBadTIFF
+	}
+	if t.order.Uint16(b[2:]) != 42 {
+		return nil, errBadTIFF
+	}
+	visited := map[uint32]bool{}
+	ifd0, err := t.decodeDir(t.order.Uint32(b[4:]), visited)
+	if err != nil {
+		return nil, err
+	}
+	t.ifd0 = ifd0
+	return &t, nil
+}
+
+// decodeDir reads the IFD at offset and its Exif, GPS and Interop sub IFDs.
+// The entries with an unknown type or a value out of the block are kept as is.
+func (t *tiffBlock) decodeDir(offset uint32, visited map[uint32]bool) (*tiffDir, error) {
+	b := t.raw
+	if visited[offset] {
+		return nil, fmt.Errorf("%w: IFD loop", errBadTIFF)
+	}
+	visited[offset] = true
+	if uint64(offset)+2 > uint64(len(b)) {
+		return nil, fmt.Errorf("%w: IFD offset out of range", errBadTIFF)
+	}
+	n := uint32(t.order.Uint16(b[offset:]))
+	p := offset + 2
+	if uint64(p)+uint64(n)*12+4 > uint64(len(b)) {
+		return nil, fmt.Errorf("%w: IFD too short", errBadTIFF)
+	}
+	d := &tiffDir{offset: offset, count: int(n)}
+	for i := uint32(0); i < n; i++ {
+		e := b[p+i*12 : p+i*12+12]
+		entry := tiffEntry{
+			tag:   t.order.Uint16(e),
+			typ:   t.order.Uint16(e[2:]),
+			count: t.order.Uint32(e[4:]),
+		}
+		copy(entry.raw[:], e[8:])
+		if size, ok := tiffTypeSize[entry.typ]; ok {
+			l := uint64(size) * uint64(entry.count)
+			if l <= 4 {
+				entry.value = append([]byte(nil), e[8:8+l]...)
+			} else if o := uint64(t.order.Uint32(e[8:])); o+l <= uint64(len(b)) {
+				entry.value = append([]byte(nil), b[o:o+l]...)
+			}
+		}
+		switch {
+		case entry.tag == tagExifIFD || entry.tag == tagGPSIFD || entry.tag == tagInteropIFD:
+			if entry.count != 1 || len(entry.value) != 4 {
+				return nil, fmt.Errorf("%w: invalid pointer 0x%04x", errBadTIFF, entry.tag)
+			}
+			sub, err := t.decodeDir(t.order.Uint32(entry.value), visited)
+			if err != nil {
+				return nil, err
+			}
+			if d.subDirs == nil {
+				d.subDirs = map[uint16]*tiffDir{}
+			}
+			d.subDirs[entry.tag] = sub
+		case entry.typ == tiffIFD || unfollowedIFDs[entry.tag]:
+			return nil, fmt.Errorf("%w: pointer 0x%04x", errUnfollowedIFDs, entry.tag)
+		}
+		d.entries = append(d.entries, entry)
+	}
+	d.next = t.order.Uint32(b[p+n*12:])
+	return d, nil
+}
+
+// encode returns the TIFF block with the changes.
+// The original block is copied, the changed values and tables are appended.
+func (t *tiffBlock) encode() []byte {
+	var buf []byte
+	if t.raw != nil {
+		buf = append(buf, t.raw...)
+	} else {
+		if t.order == binary.LittleEndian {
+			buf = append(buf, "II"...)
+		} else {
+			buf = append(buf, "MM"...)
+		}
+		buf = append(buf, t.uint16Bytes(42)...)
+		buf = append(buf, t.uint32Bytes(0)...)
+	}
+	if offset := t.encodeDir(&buf, t.ifd0); offset != t.order.Uint32(buf[4:]) {
+		t.order.PutUint32(buf[4:], offset)
+	}
+	return buf
+}
+
+// encodeDir writes the changes of the IFD d and its sub IFDs, and returns the offset of the IFD.
+// The table is patched in place when the number of entries is unchanged, otherwise it's appended.
+func (t *tiffBlock) encodeDir(buf *[]byte, d *tiffDir) uint32 {
+	dirty := false
+	for i := range d.entries {
+		e := &d.entries[i]
+		if sub := d.subDirs[e.tag]; sub != nil {
+			v := t.uint32Bytes(t.encodeDir(buf, sub))
+			if !bytes.Equal(v, e.raw[:]) {
+				e.value = v
+				e.changed = true
+			}
+		}
+		if !e.changed {
+			continue
+		}
+		dirty = true
+		e.raw = [4]byte{}
+		if len(e.value) <= 4 {
+			copy(e.raw[:], e.value)
+			continue
+		}
+		pad(buf)
+		t.order.PutUint32(e.raw[:], uint32(len(*buf)))
+		*buf = append(*buf, e.value...)
+	}
+
+	if d.offset != 0 && len(d.entries) == d.count {
+		if dirty {
+			for i, e := range d.entries {
+				t.putEntry((*buf)[d.offset+2+12*uint32(i):], e)
+			}
+		}
+		return d.offset
+	}
+
+	sort.SliceStable(d.entries, func(i, j int) bool { return d.entries[i].tag < d.entries[j].tag })
+	pad(buf)
+	offset := uint32(len(*buf))
+	*buf = append(*buf, t.uint16Bytes(uint16(len(d.entries)))...)
+	for _, e := range d.entries {
+		*buf = append(*buf, make([]byte, 12)...)
+		t.putEntry((*buf)[len(*buf)-12:], e)
+	}
+	*buf = append(*buf, t.uint32Bytes(d.next)...)
+	return offset
+}
+
+// putEntry writes the entry e in the 12 bytes of b
+func (t *tiffBlock) putEntry(b []byte, e tiffEntry) {
+	t.order.PutUint16(b, e.tag)
+	t.order.PutUint16(b[2:], e.typ)
+	t.order.PutUint32(b[4:], e.count)
+	copy(b[8:12], e.raw[:])
+}
+
+// pad aligns the buffer on a word boundary
+func pad(buf *[]byte) {
+	if len(*buf)%2 != 0 {
+		*buf = append(*buf, 0)
+	}
+}
+
+// subDir returns the sub IFD for the pointer tag, creating it when needed
+func (d *tiffDir) subDir(tag uint16) *tiffDir {
+	if d.subDirs == nil {
+		d.subDirs = map[uint16]*tiffDir{}
+	}
+	sd := d.subDirs[tag]
+	if sd == nil {
+		sd = &tiffDir{}
+		d.subDirs[tag] = sd
+		d.set(tiffEntry{tag: tag, typ: tiffLong, count: 1, value: make([]byte, 4)})
+	}
+	return sd
+}
+
+func (d *tiffDir) get(tag uint16) *tiffEntry {
+	for i := range d.entries {
+		if d.entries[i].tag == tag {
+			return &d.entries[i]
+		}
+	}
+	return nil
+}
+
+// set replaces or adds the entry
+func (d *tiffDir) set(e tiffEntry) {
+	e.changed = true
+	if old := d.get(e.tag); old != nil {
+		*old = e
+		return
+	}
+	d.entries = append(d.entries, e)
+}
+
+func (d *tiffDir) remove(tag uint16) {
+	for i := range d.entries {
+		if d.entries[i].tag == tag {
+			d.entries = append(d.entries[:i], d.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// exifString returns the value of an ASCII tag of the Exif IFD
+func (t *tiffBlock) exifString(tag uint16) (string, bool) {
+	exifDir := t.ifd0.subDirs[tagExifIFD]
+	if exifDir == nil {
+		return "", false
+	}
+	e := exifDir.get(tag)
+	if e == nil || e.typ != tiffASCII {
+		return "", false
+	}
+	return strings.TrimRight(string(e.value), "\x00 "), true
+}
+
+// Value builders
+
+func (t *tiffBlock) uint16Bytes(v uint16) []byte {
+	b := make([]byte, 2)
+	t.order.PutUint16(b, v)
+	return b
+}
+
+func (t *tiffBlock) uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	t.order.PutUint32(b, v)
+	return b
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	v := append([]byte(s), 0)
+	return tiffEntry{tag: tag, typ: tiffASCII, count: uint32(len(v)), value: v}
+}
+
+func (t *tiffBlock) rationalsEntry(tag uint16, values ...[2]uint32) tiffEntry {
+	v := make([]byte, 0, 8*len(values))
+	for _, r := range values {
+		v = append(v, t.uint32Bytes(r[0])...)
+		v = append(v, t.uint32Bytes(r[1])...)
+	}
+	return tiffEntry{tag: tag, typ: tiffRational, count: uint32(len(values)), value: v}
+}
+
+// setDateTaken writes DateTimeOriginal, SubSecTimeOriginal and OffsetTimeOriginal.
+// A naive date has no OffsetTimeOriginal, its wall clock stays floating.
+func (t *tiffBlock) setDateTaken(d time.Time, naive bool) {
+	exifDir := t.ifd0.subDir(tagExifIFD)
+	exifDir.set(asciiEntry(tagDateTimeOriginal, d.Format("2006:01:02 15:04:05")))
+	if ms := d.Nanosecond() / int(time.Millisecond); ms > 0 {
+		exifDir.set(asciiEntry(tagSubSecTimeOriginal, fmt.Sprintf("%03d", ms)))
+	} else {
+		exifDir.remove(tagSubSecTimeOriginal)
+	}
+	if naive {
+		exifDir.remove(tagOffsetTimeOriginal)
+		return
+	}
+	exifDir.set(asciiEntry(tagOffsetTimeOriginal, d.Format("-07:00")))
+}
+
+// setGPS replaces the position in the GPS IFD
+func (t *tiffBlock) setGPS(latitude, longitude float64) {
+	gps := t.ifd0.subDir(tagGPSIFD)
+	if gps.get(tagGPSVersionID) == nil {
+		gps.set(tiffEntry{tag: tagGPSVersionID, typ: tiffByte, count: 4, value: []byte{2, 3, 0, 0}})
+	}
+	ref := "N"
+	if latitude < 0 {
+		ref = "S"
+	}
+	gps.set(asciiEntry(tagGPSLatitudeRef, ref))
+	gps.set(t.rationalsEntry(tagGPSLatitude, degreesToRationals(latitude)...))
+	ref = "E"
+	if longitude < 0 {
+		ref = "W"
+	}
+	gps.set(asciiEntry(tagGPSLongitudeRef, ref))
+	gps.set(t.rationalsEntry(tagGPSLongitude, degreesToRationals(longitude)...))
+}
+
+// setDescription writes the ImageDescription tag
+func (t *tiffBlock) setDescription(s string) {
+	t.ifd0.set(asciiEntry(tagImageDescription, s))
+}
+
+// degreesToRationals converts a decimal coordinate into degrees, minutes and seconds rationals
+func degreesToRationals(v float64) [][2]uint32 {
+	v = math.Abs(v)
+	deg := math.Floor(v)
+	minutes := math.Floor((v - deg) * 60)
+	seconds := ((v-deg)*60 - minutes) * 60
+	return [][2]uint32{
+		{uint32(deg), 1},
+		{uint32(minutes), 1},
+		{uint32(math.Round(seconds * 10000)), 10000},
+	}
+}
@@ -0,0 +1,341 @@
+package exif
+
+/*
+	Write metadata into media files without using exiftool.
+
+	The image data is copied verbatim, only the metadata blocks are replaced.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/simulot/immich-go/internal/assets"
+)
+
+// ErrUnsupportedFormat is returned when the file format can't receive metadata
+var ErrUnsupportedFormat = errors.New("can't write metadata for this format")
+
+// CanWriteMetadata tells if the metadata can be embedded into a file of this name.
+//
+// HEIC and MP4 files are not supported: their metadata boxes are referenced by absolute
+// offsets, and rewriting them would require to relocate the media data.
+func CanWriteMetadata(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// WriteMetadata copies the file read from r into w, replacing its EXIF and XMP blocks by
+// blocks holding the given metadata.
+// The existing tags that are not concerned by the metadata are kept.
+func WriteMetadata(w io.Writer, r io.Reader, name string, md *assets.Metadata) error {
+	ext := strings.ToLower(path.Ext(name))
+	switch ext {
+	case ".jpg", ".jpeg":
+		return writeJPEGMetadata(w, r, md)
+	case ".png":
+		return writePNGMetadata(w, r, md)
+	}
+	return fmt.Errorf("%w: '%s'", ErrUnsupportedFormat, ext)
+}
+
+// updateTIFF returns the TIFF block updated with the metadata
+func updateTIFF(b []byte, md *assets.Metadata) ([]byte, error) {
+	t := newTIFFBlock()
+	if b != nil {
+		var err error
+		t, err = decodeTIFF(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !md.DateTaken.IsZero() {
+		t.setDateTaken(md.DateTaken, md.NaiveDate)
+	}
+	if md.Latitude != 0 || md.Longitude != 0 {
+		t.setGPS(md.Latitude, md.Longitude)
+	}
+	if md.Description != "" {
+		t.setDescription(md.Description)
+	}
+	return t.encode(), nil
+}
+
+// JPEG
+
+const (
+	jpegSOI  = 0xd8
+	jpegEOI  = 0xd9
+	jpegSOS  = 0xda
+	jpegAPP0 = 0xe0
+	jpegAPP1 = 0xe1
+)
+
+var (
+	jpegExifHeader = []byte("Exif\x00\x00")
+	jpegXMPHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+type jpegSegment struct {
+	marker byte
+	data   []byte // segment payload, without the length
+}
+
+func writeJPEGMetadata(w io.Writer, r io.Reader, md *assets.Metadata) error {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil {
+		return err
+	}
+	if soi[0] != 0xff || soi[1] != jpegSOI {
+		return errors.New("not a JPEG file")
+	}
+
+	// Read the segments up to the start of scan
+	var segments []jpegSegment
+	var exifData, xmpData []byte
+	var sos *jpegSegment
+	for sos == nil {
+		marker, err := readJPEGMarker(br)
+		if err != nil {
+			return err
+		}
+		if marker == jpegEOI || (marker >= 0xd0 && marker <= 0xd7) || marker == 0x01 {
+			// standalone markers
+			segments = append(segments, jpegSegment{marker: marker})
+			if marker == jpegEOI {
+				break
+			}
+			continue
+		}
+		var l [2]byte
+		if _, err = io.ReadFull(br, l[:]); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint16(l[:]))
+		if n < 2 {
+			return errors.New("invalid JPEG segment length")
+		}
+		data := make([]byte, n-2)
+		if _, err = io.ReadFull(br, data); err != nil {
+			return err
+		}
+		s := jpegSegment{marker: marker, data: data}
+		switch {
+		case marker == jpegSOS:
+			sos = &s
+		case marker == jpegAPP1 && bytes.HasPrefix(data, jpegExifHeader) && exifData == nil:
+			exifData = data[len(jpegExifHeader):]
+		case marker == jpegAPP1 && bytes.HasPrefix(data, jpegXMPHeader) && xmpData == nil:
+			xmpData = data[len(jpegXMPHeader):]
+		default:
+			segments = append(segments, s)
+		}
+	}
+
+	tiff, err := updateTIFF(exifData, md)
+	if err != nil {
+		return err
+	}
+	var xmp []byte
+	if xmpData != nil {
+		xmp = mergeXMPPacket(xmpData, md)
+	} else {
+		xmp = newXMPPacket(md)
+	}
+
+	// Keep the JFIF segment in the first place
+	head := []jpegSegment{}
+	if len(segments) > 0 && segments[0].marker == jpegAPP0 {
+		head = append(head, segments[0])
+		segments = segments[1:]
+	}
+	head = append(head,
+		jpegSegment{marker: jpegAPP1, data: append(append([]byte{}, jpegExifHeader...), tiff...)},
+		jpegSegment{marker: jpegAPP1, data: append(append([]byte{}, jpegXMPHeader...), xmp...)},
+	)
+	segments = append(head, segments...)
+	if sos != nil {
+		segments = append(segments, *sos)
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err = bw.Write([]byte{0xff, jpegSOI}); err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if err = writeJPEGSegment(bw, s); err != nil {
+			return err
+		}
+	}
+	if sos != nil {
+		// Copy the compressed image data and the trailer as is
+		if _, err = io.Copy(bw, br); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readJPEGMarker reads the next marker, skipping fill bytes
+func readJPEGMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xff {
+		return 0, errors.New("invalid JPEG marker")
+	}
+	for b == 0xff {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+func writeJPEGSegment(w io.Writer, s jpegSegment) error {
+	if s.data == nil {
+		// standalone marker
+		_, err := w.Write([]byte{0xff, s.marker})
+		return err
+	}
+	l := 2 + len(s.data)
+	if l > 0xffff {
+		return fmt.Errorf("JPEG segment too large (%d bytes)", l)
+	}
+	_, err := w.Write([]byte{0xff, s.marker, byte(l >> 8), byte(l)})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(s.data)
+	return err
+}
+
+// PNG
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+const pngXMPKeyword = "XML:com.adobe.xmp"
+
+func writePNGMetadata(w io.Writer, r io.Reader, md *assets.Metadata) error {
+	br := bufio.NewReader(r)
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(br, sig); err != nil {
+		return err
+	}
+	if !bytes.Equal(sig, pngSignature) {
+		return errors.New("not a PNG file")
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(pngSignature); err != nil {
+		return err
+	}
+
+	var exifData, xmpData []byte
+	metadataWritten := false
+	for {
+		var h [8]byte
+		_, err := io.ReadFull(br, h[:])
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		n := binary.BigEndian.Uint32(h[:4])
+		typ := string(h[4:8])
+		data := make([]byte, int(n)+4) // data and CRC
+		if _, err = io.ReadFull(br, data); err != nil {
+			return err
+		}
+		switch typ {
+		case "eXIf":
+			exifData = data[:n]
+			continue
+		case "iTXt":
+			if bytes.HasPrefix(data, []byte(pngXMPKeyword+"\x00")) {
+				xmpData = pngITXtText(data[:n])
+				continue
+			}
+		case "IDAT", "IEND":
+			if !metadataWritten {
+				// The metadata chunks must precede the image data
+				metadataWritten = true
+				if err = writePNGMetadataChunks(bw, exifData, xmpData, md); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err = bw.Write(h[:]); err != nil {
+			return err
+		}
+		if _, err = bw.Write(data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writePNGMetadataChunks(w io.Writer, exifData, xmpData []byte, md *assets.Metadata) error {
+	tiff, err := updateTIFF(exifData, md)
+	if err != nil {
+		return err
+	}
+	var xmp []byte
+	if xmpData != nil {
+		xmp = mergeXMPPacket(xmpData, md)
+	} else {
+		xmp = newXMPPacket(md)
+	}
+	if err = writePNGChunk(w, "eXIf", tiff); err != nil {
+		return err
+	}
+	// keyword, null separator, compression flag and method, empty language tag and translated keyword
+	itxt := append([]byte(pngXMPKeyword), 0, 0, 0, 0, 0)
+	return writePNGChunk(w, "iTXt", append(itxt, xmp...))
+}
+
+// pngITXtText returns the text of an uncompressed iTXt chunk
+func pngITXtText(data []byte) []byte {
+	p := bytes.IndexByte(data, 0) // end of keyword
+	if p < 0 || p+3 > len(data) || data[p+1] != 0 {
+		return nil
+	}
+	p += 3
+	// skip language tag and translated keyword
+	for range 2 {
+		q := bytes.IndexByte(data[p:], 0)
+		if q < 0 {
+			return nil
+		}
+		p += q + 1
+	}
+	return data[p:]
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	var h [8]byte
+	binary.BigEndian.PutUint32(h[:4], uint32(len(data)))
+	copy(h[4:], typ)
+	crc := crc32.Update(crc32.ChecksumIEEE(h[4:]), crc32.IEEETable, data)
+	var c [4]byte
+	binary.BigEndian.PutUint32(c[:], crc)
+	for _, b := range [][]byte{h[:], data, c[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/exif/sidecars/xmpsidecar"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := range 16 {
+		for y := range 16 {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+	return img
+}
+
+// jpegSegments walks the JPEG segments, and returns them with the data following the start of scan marker
+func jpegSegments(t *testing.T, b []byte) ([]jpegSegment, []byte) {
+	t.Helper()
+	var segments []jpegSegment
+	p := 2
+	for p+4 <= len(b) {
+		marker := b[p+1]
+		if marker == jpegSOS {
+			return segments, b[p:]
+		}
+		n := int(b[p+2])<<8 | int(b[p+3])
+		segments = append(segments, jpegSegment{marker: marker, data: b[p+4 : p+2+n]})
+		p += 2 + n
+	}
+	t.Fatal("SOS not found")
+	return nil, nil
+}
+
+// jpegXMP returns the XMP packet of the JPEG
+func jpegXMP(t *testing.T, b []byte) []byte {
+	t.Helper()
+	segments, _ := jpegSegments(t, b)
+	for _, s := range segments {
+		if s.marker == jpegAPP1 && bytes.HasPrefix(s.data, jpegXMPHeader) {
+			return s.data[len(jpegXMPHeader):]
+		}
+	}
+	t.Fatal("XMP packet not found")
+	return nil
+}
+
+// pngChunks returns the chunks of the given type
+func pngChunks(b []byte, typ string) [][]byte {
+	var r [][]byte
+	b = b[len(pngSignature):]
+	for len(b) >= 12 {
+		n := int(uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]))
+		if string(b[4:8]) == typ {
+			r = append(r, b[8:8+n])
+		}
+		b = b[12+n:]
+	}
+	return r
+}
+
+func checkXMP(t *testing.T, xmp []byte, want *assets.Metadata) {
+	t.Helper()
+	got := &assets.Metadata{}
+	if err := xmpsidecar.ReadXMP(bytes.NewReader(xmp), got, time.UTC); err != nil {
+		t.Fatalf("can't read the XMP packet: %v", err)
+	}
+	if !got.DateTaken.Equal(want.DateTaken) {
+		t.Errorf("XMP DateTaken = %v, want %v", got.DateTaken, want.DateTaken)
+	}
+	if !floatEquals(got.Latitude, want.Latitude, 1e-6) || !floatEquals(got.Longitude, want.Longitude, 1e-6) {
+		t.Errorf("XMP position = %v,%v, want %v,%v", got.Latitude, got.Longitude, want.Latitude, want.Longitude)
+	}
+	if got.Description != want.Description {
+		t.Errorf("XMP Description = %q, want %q", got.Description, want.Description)
+	}
+	if got.Rating != want.Rating {
+		t.Errorf("XMP Rating = %d, want %d", got.Rating, want.Rating)
+	}
+}
+
+func checkExif(t *testing.T, x *exif.Exif, want *assets.Metadata, tz *time.Location) {
+	t.Helper()
+	got, err := getExifMetadata(x, tz)
+	if err != nil {
+		t.Fatalf("can't read the EXIF: %v", err)
+	}
+	if !got.DateTaken.Equal(want.DateTaken) {
+		t.Errorf("EXIF DateTaken = %v, want %v", got.DateTaken, want.DateTaken)
+	}
+	if !floatEquals(got.Latitude, want.Latitude, 1e-6) || !floatEquals(got.Longitude, want.Longitude, 1e-6) {
+		t.Errorf("EXIF position = %v,%v, want %v,%v", got.Latitude, got.Longitude, want.Latitude, want.Longitude)
+	}
+	if tag, err := x.Get(exif.ImageDescription); err != nil {
+		t.Errorf("EXIF ImageDescription: %v", err)
+	} else if s, _ := tag.StringVal(); s != want.Description {
+		t.Errorf("EXIF ImageDescription = %q, want %q", s, want.Description)
+	}
+	tb, err := decodeTIFF(x.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := tb.exifString(tagOffsetTimeOriginal); s != want.DateTaken.Format("-07:00") {
+		t.Errorf("EXIF OffsetTimeOriginal = %q, want %q", s, want.DateTaken.Format("-07:00"))
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	tz := time.FixedZone("UTC+2", 2*3600)
+	md := &assets.Metadata{
+		DateTaken:   time.Date(2024, 3, 15, 10, 20, 30, int(250*time.Millisecond), tz),
+		Latitude:    -33.8567844,
+		Longitude:   151.2152967,
+		Description: "Sydney <opera> & harbour",
+		Rating:      4,
+	}
+
+	var newJPEG bytes.Buffer
+	if err := jpeg.Encode(&newJPEG, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	pixel, err := os.ReadFile("DATA/PXL_20231006_063000139.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		src  []byte
+	}{
+		{name: "jpeg without exif", src: newJPEG.Bytes()},
+		{name: "jpeg with exif and xmp", src: pixel},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := WriteMetadata(&out, bytes.NewReader(tc.src), "photo.jpg", md)
+			if err != nil {
+				t.Fatalf("WriteMetadata: %v", err)
+			}
+			_, gotScan := jpegSegments(t, out.Bytes())
+			_, wantScan := jpegSegments(t, tc.src)
+			if !bytes.Equal(gotScan, wantScan) {
+				t.Error("the image data has been modified")
+			}
+			if _, err = jpeg.Decode(bytes.NewReader(out.Bytes())); err != nil {
+				t.Errorf("can't decode the image: %v", err)
+			}
+
+			x, err := exif.Decode(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatalf("can't decode EXIF: %v", err)
+			}
+			checkExif(t, x, md, tz)
+
+			got, err := MetadataFromDirectRead(bytes.NewReader(out.Bytes()), "photo.jpg", tz)
+			if err != nil {
+				t.Fatalf("MetadataFromDirectRead: %v", err)
+			}
+			if !got.DateTaken.Equal(md.DateTaken) {
+				t.Errorf("DateTaken = %v, want %v", got.DateTaken, md.DateTaken)
+			}
+
+			checkXMP(t, jpegXMP(t, out.Bytes()), md)
+		})
+	}
+
+	t.Run("existing tags are kept", func(t *testing.T) {
+		var out bytes.Buffer
+		err := WriteMetadata(&out, bytes.NewReader(pixel), "photo.jpg", md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := exif.Decode(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []exif.FieldName{exif.Make, exif.Model, exif.FNumber} {
+			if _, err := x.Get(f); err != nil {
+				t.Errorf("tag %s is lost: %v", f, err)
+			}
+		}
+		if !bytes.Contains(jpegXMP(t, out.Bytes()), []byte("GCamera:shot_log_data")) {
+			t.Error("XMP properties are lost")
+		}
+	})
+
+	t.Run("png", func(t *testing.T) {
+		var src bytes.Buffer
+		if err := png.Encode(&src, testImage()); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		err := WriteMetadata(&out, bytes.NewReader(src.Bytes()), "image.PNG", md)
+		if err != nil {
+			t.Fatalf("WriteMetadata: %v", err)
+		}
+		if _, err = png.Decode(bytes.NewReader(out.Bytes())); err != nil {
+			t.Fatalf("can't decode the image: %v", err)
+		}
+		if !bytes.Equal(bytes.Join(pngChunks(out.Bytes(), "IDAT"), nil), bytes.Join(pngChunks(src.Bytes(), "IDAT"), nil)) {
+			t.Error("the image data has been modified")
+		}
+		exifs := pngChunks(out.Bytes(), "eXIf")
+		if len(exifs) != 1 {
+			t.Fatalf("expecting 1 eXIf chunk, got %d", len(exifs))
+		}
+		x, err := exif.Decode(bytes.NewReader(exifs[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkExif(t, x, md, tz)
+		itxt := pngChunks(out.Bytes(), "iTXt")
+		if len(itxt) != 1 {
+			t.Fatalf("expecting 1 iTXt chunk, got %d", len(itxt))
+		}
+		checkXMP(t, pngITXtText(itxt[0]), md)
+
+		// Writing twice replaces the chunks
+		var out2 bytes.Buffer
+		if err = WriteMetadata(&out2, bytes.NewReader(out.Bytes()), "image.png", md); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(pngChunks(out2.Bytes(), "eXIf")); n != 1 {
+			t.Errorf("expecting 1 eXIf chunk, got %d", n)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		err := WriteMetadata(&bytes.Buffer{}, bytes.NewReader(nil), "video.mp4", md)
+		if err == nil {
+			t.Error("expecting an error")
+		}
+	})
+}
+
+func TestMergeXMPPacket(t *testing.T) {
+	packet := []byte(`<x:xmpmeta><rdf:RDF><rdf:Description xmp:Rating="3" exif:DateTimeOriginal="2020-01-01T00:00:00">` +
+		`<dc:description><rdf:Alt><rdf:li xml:lang="x-default">kept</rdf:li></rdf:Alt></dc:description>` +
+		`<digiKam:TagsList><rdf:Seq><rdf:li>kept</rdf:li></rdf:Seq></digiKam:TagsList>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta>`)
+	md := &assets.Metadata{DateTaken: time.Date(2024, 3, 15, 10, 20, 30, 0, time.UTC)}
+
+	got := string(mergeXMPPacket(packet, md))
+	for _, kept := range []string{`xmp:Rating="3"`, `<rdf:li xml:lang="x-default">kept</rdf:li>`, `<rdf:li>kept</rdf:li>`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("%s is lost: %s", kept, got)
+		}
+	}
+	if strings.Contains(got, "2020-01-01") || strings.Count(got, "<exif:DateTimeOriginal>") != 1 {
+		t.Errorf("the date isn't replaced: %s", got)
+	}
+}
+
+// tiffEntryBytes returns an IFD entry of a big endian block
+func tiffEntryBytes(tag, typ uint16, count uint32, value []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, tag)
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint32(b, count)
+	return append(b, value...)
+}
+
+func TestUpdateTIFF(t *testing.T) {
+	be := binary.BigEndian
+	// IFD0 at 8, Exif IFD at 38, values at 68
+	block := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	block = be.AppendUint16(block, 2)
+	block = append(block, tiffEntryBytes(0x010f, tiffASCII, 6, be.AppendUint32(nil, 68))...)
+	block = append(block, tiffEntryBytes(tagExifIFD, tiffLong, 1, be.AppendUint32(nil, 38))...)
+	block = be.AppendUint32(block, 0)
+	block = be.AppendUint16(block, 2)
+	block = append(block, tiffEntryBytes(0x927c, tiffUndefined, 8, be.AppendUint32(nil, 74))...) // maker note
+	block = append(block, tiffEntryBytes(0xc000, 99, 1, []byte("ABCD"))...)                      // unknown type
+	block = be.AppendUint32(block, 0)
+	block = append(block, "Canon\x00"...)
+	block = append(block, "\x00\x00\x00\x10note"...)
+
+	md := &assets.Metadata{
+		DateTaken:   time.Date(2024, 3, 15, 10, 20, 30, 0, time.UTC),
+		Description: "harbour",
+	}
+	out, err := updateTIFF(block, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[68:len(block)], block[68:]) {
+		t.Error("the values have been moved")
+	}
+	tb, err := decodeTIFF(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := tb.ifd0.get(0x010f); e == nil || string(e.value) != "Canon\x00" {
+		t.Errorf("the make is lost: %+v", e)
+	}
+	if e := tb.ifd0.get(tagImageDescription); e == nil || string(e.value) != "harbour\x00" {
+		t.Errorf("ImageDescription = %+v", e)
+	}
+	exifDir := tb.ifd0.subDirs[tagExifIFD]
+	if exifDir == nil {
+		t.Fatal("the Exif IFD is lost")
+	}
+	if e := exifDir.get(0x927c); e == nil || be.Uint32(e.raw[:]) != 74 {
+		t.Errorf("the maker note has been moved: %+v", e)
+	}
+	if e := exifDir.get(0xc000); e == nil || e.typ != 99 || string(e.raw[:]) != "ABCD" {
+		t.Errorf("the entry of unknown type is lost: %+v", e)
+	}
+	if s, _ := tb.exifString(tagDateTimeOriginal); s != "2024:03:15 10:20:30" {
+		t.Errorf("DateTimeOriginal = %q", s)
+	}
+	if s, _ := tb.exifString(tagOffsetTimeOriginal); s != "+00:00" {
+		t.Errorf("OffsetTimeOriginal = %q", s)
+	}
+
+	// A naive date keeps a floating wall clock
+	md.NaiveDate = true
+	if out, err = updateTIFF(out, md); err != nil {
+		t.Fatal(err)
+	}
+	if tb, err = decodeTIFF(out); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := tb.exifString(tagDateTimeOriginal); s != "2024:03:15 10:20:30" {
+		t.Errorf("naive DateTimeOriginal = %q", s)
+	}
+	if tb.ifd0.subDirs[tagExifIFD].get(tagOffsetTimeOriginal) != nil {
+		t.Error("a naive date has no OffsetTimeOriginal")
+	}
+
+	// The sub IFDs are not followed, the block can't be rewritten
+	block = []byte("MM\x00\x2a\x00\x00\x00\x08")
+	block = be.AppendUint16(block, 1)
+	block = append(block, tiffEntryBytes(tagSubIFDs, tiffLong, 1, be.AppendUint32(nil, 8))...)
+	block = be.AppendUint32(block, 0)
+	if _, err = updateTIFF(block, md); !errors.Is(err, errUnfollowedIFDs) {
+		t.Errorf("updateTIFF() error = %v, want %v", err, errUnfollowedIFDs)
+	}
+}
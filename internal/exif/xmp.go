@@ -0,0 +1,149 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/exif/sidecars/xmpsidecar"
+)
+
+const (
+	xmpPacketBegin = "<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n"
+	xmpPacketEnd   = "<?xpacket end=\"w\"?>"
+)
+
+// xmpDescription returns the rdf:Description element holding the metadata
+func xmpDescription(md *assets.Metadata) string {
+	var b bytes.Buffer
+	b.WriteString(`<rdf:Description rdf:about=""` +
+		` xmlns:exif="http://ns.adobe.com/exif/1.0/"` +
+		` xmlns:tiff="http://ns.adobe.com/tiff/1.0/"` +
+		` xmlns:xmp="http://ns.adobe.com/xap/1.0/"` +
+		` xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:digiKam="http://www.digikam.org/ns/1.0/">` + "\n")
+	if !md.DateTaken.IsZero() {
+		layout := "2006-01-02T15:04:05.000-07:00"
+		if md.NaiveDate {
+			layout = "2006-01-02T15:04:05.000"
+		}
+		d := md.DateTaken.Format(layout)
+		fmt.Fprintf(&b, "<exif:DateTimeOriginal>%s</exif:DateTimeOriginal>\n", d)
+		fmt.Fprintf(&b, "<photoshop:DateCreated>%s</photoshop:DateCreated>\n", d)
+	}
+	if md.Latitude != 0 || md.Longitude != 0 {
+		fmt.Fprintf(&b, "<exif:GPSLatitude>%s</exif:GPSLatitude>\n", xmpsidecar.GPSFloatToString(md.Latitude, true))
+		fmt.Fprintf(&b, "<exif:GPSLongitude>%s</exif:GPSLongitude>\n", xmpsidecar.GPSFloatToString(md.Longitude, false))
+	}
+	if md.Description != "" {
+		alt := "<rdf:Alt><rdf:li xml:lang=\"x-default\">" + xmlEscape(md.Description) + "</rdf:li></rdf:Alt>"
+		fmt.Fprintf(&b, "<tiff:ImageDescription>%s</tiff:ImageDescription>\n", alt)
+		fmt.Fprintf(&b, "<dc:description>%s</dc:description>\n", alt)
+	}
+	if md.Rating > 0 {
+		fmt.Fprintf(&b, "<xmp:Rating>%s</xmp:Rating>\n", strconv.Itoa(int(md.Rating)))
+	}
+	if len(md.Tags) > 0 {
+		b.WriteString("<digiKam:TagsList><rdf:Seq>")
+		for _, t := range md.Tags {
+			b.WriteString("<rdf:li>" + xmlEscape(t.Value) + "</rdf:li>")
+		}
+		b.WriteString("</rdf:Seq></digiKam:TagsList>\n")
+	}
+	b.WriteString("</rdf:Description>\n")
+	return b.String()
+}
+
+// newXMPPacket returns a complete XMP packet holding the metadata
+func newXMPPacket(md *assets.Metadata) []byte {
+	var b bytes.Buffer
+	b.WriteString(xmpPacketBegin)
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="immich-go">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(xmpDescription(md))
+	b.WriteString("</rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(xmpPacketEnd)
+	return b.Bytes()
+}
+
+var reXMPRDFEnd = regexp.MustCompile(`</rdf:RDF>`)
+
+// xmpPropertyRE matches a property written as an element or as an attribute
+type xmpPropertyRE struct {
+	element   *regexp.Regexp
+	attribute *regexp.Regexp
+}
+
+// xmpPropertyREs are the expressions of the properties written by xmpDescription
+var xmpPropertyREs = compileXMPProperties(
+	"exif:DateTimeOriginal", "photoshop:DateCreated",
+	"exif:GPSLatitude", "exif:GPSLongitude",
+	"tiff:ImageDescription", "dc:description",
+	"xmp:Rating",
+	"digiKam:TagsList",
+)
+
+func compileXMPProperties(props ...string) map[string]xmpPropertyRE {
+	res := make(map[string]xmpPropertyRE, len(props))
+	for _, p := range props {
+		q := regexp.QuoteMeta(p)
+		res[p] = xmpPropertyRE{
+			element:   regexp.MustCompile(`(?s)<` + q + `>.*?</` + q + `>\s*`),
+			attribute: regexp.MustCompile(`\s` + q + `="[^"]*"`),
+		}
+	}
+	return res
+}
+
+// xmpProperties returns the XMP properties written by xmpDescription for the metadata
+func xmpProperties(md *assets.Metadata) []string {
+	var props []string
+	if !md.DateTaken.IsZero() {
+		props = append(props, "exif:DateTimeOriginal", "photoshop:DateCreated")
+	}
+	if md.Latitude != 0 || md.Longitude != 0 {
+		props = append(props, "exif:GPSLatitude", "exif:GPSLongitude")
+	}
+	if md.Description != "" {
+		props = append(props, "tiff:ImageDescription", "dc:description")
+	}
+	if md.Rating > 0 {
+		props = append(props, "xmp:Rating")
+	}
+	if len(md.Tags) > 0 {
+		props = append(props, "digiKam:TagsList")
+	}
+	return props
+}
+
+// mergeXMPPacket updates an existing XMP packet with the metadata.
+// The properties replaced by the metadata are removed from the packet, and
+// a new rdf:Description is added. Other properties are left untouched.
+func mergeXMPPacket(packet []byte, md *assets.Metadata) []byte {
+	loc := reXMPRDFEnd.FindIndex(packet)
+	if loc == nil {
+		return newXMPPacket(md)
+	}
+	head := packet[:loc[0]]
+	for _, p := range xmpProperties(md) {
+		re := xmpPropertyREs[p]
+		head = re.element.ReplaceAll(head, nil)
+		head = re.attribute.ReplaceAll(head, nil)
+	}
+
+	var b bytes.Buffer
+	b.Write(head)
+	b.WriteString(xmpDescription(md))
+	b.Write(packet[loc[0]:])
+	return b.Bytes()
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,523 @@
+// Package expression evaluates boolean expressions on the assets, to select them with the --where flag.
+//
+// The language has the fields of the asset, literals, comparisons and logical operators:
+//
+//	size > 5MB && make == "Apple" && !favorite && year(date) < 2015 && path =~ "Camera/"
+//
+// Expressions are type checked when parsed. They have no side effect.
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/dates"
+)
+
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeString
+	typeDate
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "boolean"
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeDate:
+		return "date"
+	}
+	return "unknown"
+}
+
+// node is a compiled part of the expression
+type node struct {
+	typ  valueType
+	eval func(a *assets.Asset) any
+	lit  *string // the value of a string literal, converted to a date or a regular expression when needed
+}
+
+type field struct {
+	typ valueType
+	get func(a *assets.Asset) any
+}
+
+var fields = map[string]field{
+	"path":        {typeString, func(a *assets.Asset) any { return a.File.Name() }},
+	"name":        {typeString, func(a *assets.Asset) any { return baseName(a) }},
+	"ext":         {typeString, func(a *assets.Asset) any { return strings.ToLower(path.Ext(baseName(a))) }},
+	"type":        {typeString, func(a *assets.Asset) any { return a.Type }},
+	"size":        {typeNumber, func(a *assets.Asset) any { return float64(a.FileSize) }},
+	"date":        {typeDate, func(a *assets.Asset) any { return a.CaptureDate }},
+	"filedate":    {typeDate, func(a *assets.Asset) any { return a.FileDate }},
+	"make":        {typeString, func(a *assets.Asset) any { return a.CameraMake }},
+	"model":       {typeString, func(a *assets.Asset) any { return a.CameraModel }},
+	"description": {typeString, func(a *assets.Asset) any { return a.Description }},
+	"favorite":    {typeBool, func(a *assets.Asset) any { return a.Favorite }},
+	"archived":    {typeBool, func(a *assets.Asset) any { return a.Archived || a.Visibility == assets.VisibilityArchive }},
+	"trashed":     {typeBool, func(a *assets.Asset) any { return a.Trashed }},
+	"partner":     {typeBool, func(a *assets.Asset) any { return a.FromPartner }},
+	"rating":      {typeNumber, func(a *assets.Asset) any { return float64(a.Rating) }},
+	"latitude":    {typeNumber, func(a *assets.Asset) any { return a.Latitude }},
+	"longitude":   {typeNumber, func(a *assets.Asset) any { return a.Longitude }},
+	"gps":         {typeBool, func(a *assets.Asset) any { return a.Latitude != 0 || a.Longitude != 0 }},
+	"kind":        {typeString, func(a *assets.Asset) any { return a.Kind.String() }},
+	"radical":     {typeString, func(a *assets.Asset) any { return a.Radical }},
+	"index":       {typeNumber, func(a *assets.Asset) any { return float64(a.Index) }},
+	"cover":       {typeBool, func(a *assets.Asset) any { return a.IsCover }},
+	"sidecar":     {typeBool, func(a *assets.Asset) any { return a.FromSideCar != nil }},
+}
+
+// baseName returns the original name of the file, the server's assets are read by ID
+func baseName(a *assets.Asset) string {
+	if a.OriginalFileName != "" {
+		return path.Base(a.OriginalFileName)
+	}
+	return path.Base(a.File.Name())
+}
+
+// functions of one argument
+type function struct {
+	arg, result valueType
+	call        func(a *assets.Asset, v any) any
+}
+
+var functions = map[string]function{
+	"year":  {typeDate, typeNumber, func(_ *assets.Asset, v any) any { return float64(v.(time.Time).Year()) }},
+	"month": {typeDate, typeNumber, func(_ *assets.Asset, v any) any { return float64(v.(time.Time).Month()) }},
+	"day":   {typeDate, typeNumber, func(_ *assets.Asset, v any) any { return float64(v.(time.Time).Day()) }},
+	"hour":  {typeDate, typeNumber, func(_ *assets.Asset, v any) any { return float64(v.(time.Time).Hour()) }},
+	"lower": {typeString, typeString, func(_ *assets.Asset, v any) any { return strings.ToLower(v.(string)) }},
+	"album": {typeString, typeBool, func(a *assets.Asset, v any) any {
+		for _, al := range a.Albums {
+			if strings.EqualFold(al.Title, v.(string)) {
+				return true
+			}
+		}
+		return false
+	}},
+	"tag": {typeString, typeBool, func(a *assets.Asset, v any) any {
+		for _, t := range a.Tags {
+			if strings.EqualFold(t.Value, v.(string)) {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+// Expr is a compiled expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse compiles the expression. The dates without time zone are read in the location tz.
+func Parse(s string, tz *time.Location) (*Expr, error) {
+	if tz == nil {
+		tz = time.Local
+	}
+	p := &parser{src: s, tz: tz}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	if n.typ != typeBool {
+		return nil, fmt.Errorf("expression %q: the result is a %s, not a boolean", s, n.typ)
+	}
+	return &Expr{src: s, root: n}, nil
+}
+
+// Match tells if the asset is selected by the expression
+func (e *Expr) Match(a *assets.Asset) bool {
+	return e.root.eval(a).(bool)
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Parser
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+type parser struct {
+	src    string
+	tz     *time.Location
+	tokens []token
+	pos    int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	pos := len(p.src)
+	if p.pos < len(p.tokens) {
+		pos = p.tokens[p.pos].pos
+	}
+	return fmt.Errorf("expression %q at position %d: %s", p.src, pos+1, fmt.Sprintf(format, args...))
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!"}
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+func (p *parser) tokenize() error {
+	s := p.src
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for i < len(s) && s[i] != c {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == c || s[i+1] == '\\') {
+					i++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return fmt.Errorf("expression %q at position %d: unterminated string", s, start+1)
+			}
+			i++
+			p.tokens = append(p.tokens, token{kind: tokString, text: b.String(), pos: start})
+		case c >= '0' && c <= '9' || c == '.' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			start := i
+			i++
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			numText := s[start:i]
+			for i < len(s) && isLetter(s[i]) {
+				i++
+			}
+			f, err := strconv.ParseFloat(numText, 64)
+			if err != nil {
+				return fmt.Errorf("expression %q at position %d: invalid number %q", s, start+1, s[start:i])
+			}
+			unit, ok := sizeUnits[strings.ToLower(s[start+len(numText):i])]
+			if !ok {
+				return fmt.Errorf("expression %q at position %d: invalid unit in %q", s, start+1, s[start:i])
+			}
+			p.tokens = append(p.tokens, token{kind: tokNumber, text: s[start:i], num: f * unit, pos: start})
+		case isLetter(c):
+			start := i
+			for i < len(s) && (isLetter(s[i]) || s[i] >= '0' && s[i] <= '9') {
+				i++
+			}
+			p.tokens = append(p.tokens, token{kind: tokIdent, text: strings.ToLower(s[start:i]), pos: start})
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(s[i:], op) {
+					p.tokens = append(p.tokens, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("expression %q at position %d: unexpected character %q", s, i+1, c)
+			}
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokEOF, pos: len(s)})
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == op
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return left, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return right, err
+		}
+		if left.typ != typeBool || right.typ != typeBool {
+			return node{}, p.errorf("|| needs booleans")
+		}
+		l, r := left.eval, right.eval
+		left = node{typ: typeBool, eval: func(a *assets.Asset) any { return l(a).(bool) || r(a).(bool) }}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return left, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return right, err
+		}
+		if left.typ != typeBool || right.typ != typeBool {
+			return node{}, p.errorf("&& needs booleans")
+		}
+		l, r := left.eval, right.eval
+		left = node{typ: typeBool, eval: func(a *assets.Asset) any { return l(a).(bool) && r(a).(bool) }}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return n, err
+		}
+		if n.typ != typeBool {
+			return node{}, p.errorf("! needs a boolean")
+		}
+		e := n.eval
+		return node{typ: typeBool, eval: func(a *assets.Asset) any { return !e(a).(bool) }}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return left, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return right, err
+	}
+
+	if t.text == "=~" || t.text == "!~" {
+		return p.regexMatch(t.text, left, right)
+	}
+
+	// a string literal compared with a date is a date
+	if left.typ == typeDate && right.lit != nil {
+		right, err = p.dateLiteral(*right.lit)
+	} else if right.typ == typeDate && left.lit != nil {
+		left, err = p.dateLiteral(*left.lit)
+	}
+	if err != nil {
+		return node{}, err
+	}
+	if left.typ != right.typ {
+		return node{}, p.errorf("can't compare a %s with a %s", left.typ, right.typ)
+	}
+	if left.typ == typeBool && t.text != "==" && t.text != "!=" {
+		return node{}, p.errorf("booleans can only be compared with == or !=")
+	}
+	l, r, op := left.eval, right.eval, t.text
+	return node{typ: typeBool, eval: func(a *assets.Asset) any { return compare(op, l(a), r(a)) }}, nil
+}
+
+func (p *parser) regexMatch(op string, left, right node) (node, error) {
+	if left.typ != typeString || right.lit == nil {
+		return node{}, p.errorf("%s needs a string on the left and a regular expression literal on the right", op)
+	}
+	re, err := regexp.Compile(*right.lit)
+	if err != nil {
+		return node{}, p.errorf("invalid regular expression: %s", err)
+	}
+	l := left.eval
+	not := op == "!~"
+	return node{typ: typeBool, eval: func(a *assets.Asset) any { return re.MatchString(l(a).(string)) != not }}, nil
+}
+
+func (p *parser) dateLiteral(s string) (node, error) {
+	if d, _, err := dates.Parse(s, p.tz); err == nil {
+		return node{typ: typeDate, eval: func(*assets.Asset) any { return d }}, nil
+	}
+	return node{}, p.errorf("invalid date %q", s)
+}
+
+func compare(op string, l, r any) bool {
+	var c int
+	switch l := l.(type) {
+	case bool:
+		eq := l == r.(bool)
+		if op == "==" {
+			return eq
+		}
+		return !eq
+	case float64:
+		r := r.(float64)
+		switch {
+		case l < r:
+			c = -1
+		case l > r:
+			c = 1
+		}
+	case string:
+		c = strings.Compare(l, r.(string))
+	case time.Time:
+		c = l.Compare(r.(time.Time))
+	}
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if math.IsInf(t.num, 0) || math.IsNaN(t.num) {
+			return node{}, p.errorf("invalid number %q", t.text)
+		}
+		v := t.num
+		return node{typ: typeNumber, eval: func(*assets.Asset) any { return v }}, nil
+	case tokString:
+		v := t.text
+		return node{typ: typeString, eval: func(*assets.Asset) any { return v }, lit: &v}, nil
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return n, err
+		}
+		if p.next().kind != tokRParen {
+			return node{}, p.errorf("missing )")
+		}
+		return node{typ: n.typ, eval: n.eval}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			v := t.text == "true"
+			return node{typ: typeBool, eval: func(*assets.Asset) any { return v }}, nil
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t)
+		}
+		f, ok := fields[t.text]
+		if !ok {
+			return node{}, fmt.Errorf("expression %q at position %d: unknown field %q", p.src, t.pos+1, t.text)
+		}
+		return node{typ: f.typ, eval: f.get}, nil
+	case tokEOF:
+		return node{}, errors.New("expression " + strconv.Quote(p.src) + ": unexpected end")
+	}
+	return node{}, fmt.Errorf("expression %q at position %d: unexpected %q", p.src, t.pos+1, t.text)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return node{}, fmt.Errorf("expression %q at position %d: unknown function %q", p.src, name.pos+1, name.text)
+	}
+	p.next() // (
+	arg, err := p.parseOr()
+	if err != nil {
+		return arg, err
+	}
+	if p.next().kind != tokRParen {
+		return node{}, p.errorf("missing ) after the argument of %s", name.text)
+	}
+	if arg.typ != fn.arg {
+		return node{}, fmt.Errorf("expression %q at position %d: %s needs a %s, not a %s", p.src, name.pos+1, name.text, fn.arg, arg.typ)
+	}
+	e, call := arg.eval, fn.call
+	return node{typ: fn.result, eval: func(a *assets.Asset) any { return call(a, e(a)) }}, nil
+}
@@ -0,0 +1,75 @@
+package expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		`size`,
+		`size > "big"`,
+		`unknown == 1`,
+		`size > 5XB`,
+		`make == "Apple`,
+		`(favorite`,
+		`favorite < true`,
+		`year(make) > 2000`,
+		`path =~ make`,
+		`path =~ "("`,
+		`date > "yesterday"`,
+		`favorite && size`,
+		`size > 1 1`,
+	} {
+		if _, err := Parse(s, time.UTC); err == nil {
+			t.Errorf("Parse(%q) should fail", s)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	a := &assets.Asset{
+		File:        fshelper.FSName(nil, "DCIM/Camera/IMG_0001.JPG"),
+		FileSize:    6_000_000,
+		CameraMake:  "Apple",
+		CameraModel: "iPhone 6",
+		CaptureDate: time.Date(2014, 7, 14, 10, 30, 0, 0, time.UTC),
+		Rating:      3,
+		Albums:      []assets.Album{{Title: "Holidays"}},
+		Tags:        []assets.Tag{{Name: "beach", Value: "places/beach"}},
+	}
+	a.Kind = assets.KindBurst
+
+	tcs := []struct {
+		expr string
+		want bool
+	}{
+		{`size > 5MB && make == "Apple" && !favorite && year(date) < 2015 && path =~ "Camera/"`, true},
+		{`size > 6MiB`, false},
+		{`ext == ".jpg" && name == "IMG_0001.JPG"`, true},
+		{`date >= "2014-07-14" && date < '2014-07-15'`, true},
+		{`date > "2014-07-14 11:00"`, false},
+		{`month(date) == 7 && day(date) == 14 && hour(date) == 10`, true},
+		{`rating >= 3 || favorite`, true},
+		{`!(rating >= 3)`, false},
+		{`lower(model) =~ '^iphone \d$'`, true},
+		{`path !~ "Screenshots"`, true},
+		{`album("holidays") && tag("places/beach")`, true},
+		{`kind == "burst" && !gps`, true},
+		{`favorite == false`, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.expr, func(t *testing.T) {
+			e, err := Parse(tc.expr, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Match(a); got != tc.want {
+				t.Errorf("Match() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
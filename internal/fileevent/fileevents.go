@@ -72,6 +72,15 @@ const (
 	ProcessedAlbumAdded         // Asset added to album
 	ProcessedTagged             // Asset tagged
 	ProcessedLivePhoto          // Live photo processed
+	ProcessedGeotagged          // Position interpolated from a track log
+	ProcessedDateCorrected      // Capture date corrected by a clock rule
+	ProcessedLocalDeleted       // Local file deleted after upload
+	ProcessedLocalMoved         // Local file moved after upload
+	ProcessedRulesApplied       // Rules of the configuration file applied
+
+	// ===== Lifecycle Events appended after their group to keep the values of the codes =====
+	ErrorChecksum          // To ERROR: checksum of the transferred file doesn't match
+	DiscardedNearDuplicate // To DISCARDED: server has a near-duplicate of the asset
 
 	MaxCode
 )
@@ -104,12 +113,14 @@ var _code = map[Code]string{
 	DiscardedLocalDuplicate:  "discarded local duplicate",
 	DiscardedNotSelected:     "discarded not selected",
 	DiscardedServerBetter:    "discarded server better",
+	DiscardedNearDuplicate:   "discarded near-duplicate",
 
 	// To ERROR
 	ErrorUploadFailed: "upload failed",
 	ErrorServerError:  "server error",
 	ErrorFileAccess:   "file access error",
 	ErrorIncomplete:   "incomplete processing",
+	ErrorChecksum:     "checksum mismatch",
 
 	// Processing Events
 	ProcessedAssociatedMetadata: "associated metadata",
@@ -118,6 +129,11 @@ var _code = map[Code]string{
 	ProcessedAlbumAdded:         "added to album",
 	ProcessedTagged:             "tagged",
 	ProcessedLivePhoto:          "live photo",
+	ProcessedGeotagged:          "geotagged from track log",
+	ProcessedDateCorrected:      "date corrected by clock rule",
+	ProcessedLocalDeleted:       "local file deleted",
+	ProcessedLocalMoved:         "local file moved",
+	ProcessedRulesApplied:       "rules applied",
 }
 
 var _logLevels = map[Code]slog.Level{
@@ -148,12 +164,14 @@ var _logLevels = map[Code]slog.Level{
 	DiscardedLocalDuplicate:  slog.LevelWarn,
 	DiscardedNotSelected:     slog.LevelWarn,
 	DiscardedServerBetter:    slog.LevelInfo,
+	DiscardedNearDuplicate:   slog.LevelInfo,
 
 	// To ERROR
 	ErrorUploadFailed: slog.LevelError,
 	ErrorServerError:  slog.LevelError,
 	ErrorFileAccess:   slog.LevelError,
 	ErrorIncomplete:   slog.LevelError,
+	ErrorChecksum:     slog.LevelError,
 
 	// Processing Events
 	ProcessedAssociatedMetadata: slog.LevelInfo,
@@ -162,6 +180,11 @@ var _logLevels = map[Code]slog.Level{
 	ProcessedAlbumAdded:         slog.LevelInfo,
 	ProcessedTagged:             slog.LevelInfo,
 	ProcessedLivePhoto:          slog.LevelInfo,
+	ProcessedGeotagged:          slog.LevelInfo,
+	ProcessedDateCorrected:      slog.LevelInfo,
+	ProcessedLocalDeleted:       slog.LevelInfo,
+	ProcessedLocalMoved:         slog.LevelInfo,
+	ProcessedRulesApplied:       slog.LevelInfo,
 }
 
 func (e Code) String() string {
@@ -329,6 +352,7 @@ func (r *Recorder) GenerateEventReport() string {
 		DiscardedLocalDuplicate,
 		DiscardedNotSelected,
 		DiscardedServerBetter,
+		DiscardedNearDuplicate,
 	} {
 		if eventCounts[c] > 0 {
 			hasDiscarded = true
@@ -345,6 +369,7 @@ func (r *Recorder) GenerateEventReport() string {
 			DiscardedLocalDuplicate,
 			DiscardedNotSelected,
 			DiscardedServerBetter,
+			DiscardedNearDuplicate,
 		} {
 			if count := eventCounts[c]; count > 0 {
 				if size := eventSizes[c]; size > 0 {
@@ -358,7 +383,7 @@ func (r *Recorder) GenerateEventReport() string {
 
 	// Asset Lifecycle - To ERROR
 	hasErrors := false
-	for _, c := range []Code{ErrorUploadFailed, ErrorServerError, ErrorFileAccess, ErrorIncomplete} {
+	for _, c := range []Code{ErrorUploadFailed, ErrorServerError, ErrorFileAccess, ErrorIncomplete, ErrorChecksum} {
 		if eventCounts[c] > 0 {
 			hasErrors = true
 			break
@@ -366,7 +391,7 @@ func (r *Recorder) GenerateEventReport() string {
 	}
 	if hasErrors {
 		sb.WriteString("\nAsset Lifecycle (ERROR):\n")
-		for _, c := range []Code{ErrorUploadFailed, ErrorServerError, ErrorFileAccess, ErrorIncomplete} {
+		for _, c := range []Code{ErrorUploadFailed, ErrorServerError, ErrorFileAccess, ErrorIncomplete, ErrorChecksum} {
 			if count := eventCounts[c]; count > 0 {
 				sb.WriteString(fmt.Sprintf("  %-35s: %7d\n", c.String(), count))
 			}
@@ -382,6 +407,11 @@ func (r *Recorder) GenerateEventReport() string {
 		ProcessedAlbumAdded,
 		ProcessedTagged,
 		ProcessedLivePhoto,
+		ProcessedGeotagged,
+		ProcessedDateCorrected,
+		ProcessedLocalDeleted,
+		ProcessedLocalMoved,
+		ProcessedRulesApplied,
 	} {
 		if eventCounts[c] > 0 {
 			hasProcessingEvents = true
@@ -397,6 +427,11 @@ func (r *Recorder) GenerateEventReport() string {
 			ProcessedAlbumAdded,
 			ProcessedTagged,
 			ProcessedLivePhoto,
+			ProcessedGeotagged,
+			ProcessedDateCorrected,
+			ProcessedLocalDeleted,
+			ProcessedLocalMoved,
+			ProcessedRulesApplied,
 		} {
 			if count := eventCounts[c]; count > 0 {
 				sb.WriteString(fmt.Sprintf("  %-35s: %7d\n", c.String(), count))
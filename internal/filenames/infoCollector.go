@@ -1,6 +1,7 @@
 package filenames
 
 import (
+	"fmt"
 	"path"
 	"strings"
 	"time"
@@ -10,15 +11,17 @@ import (
 )
 
 type InfoCollector struct {
-	TZ *time.Location
-	SM filetypes.SupportedMedia
+	TZ       *time.Location
+	SM       filetypes.SupportedMedia
+	Patterns []*NamePattern // user defined patterns, tried before the built-in matchers
 }
 
 // NewInfoCollector creates a new InfoCollector
-func NewInfoCollector(tz *time.Location, sm filetypes.SupportedMedia) *InfoCollector {
+func NewInfoCollector(tz *time.Location, sm filetypes.SupportedMedia, patterns ...*NamePattern) *InfoCollector {
 	return &InfoCollector{
-		TZ: tz,
-		SM: sm,
+		TZ:       tz,
+		SM:       sm,
+		Patterns: patterns,
 	}
 }
 
@@ -27,12 +30,39 @@ func NewInfoCollector(tz *time.Location, sm filetypes.SupportedMedia) *InfoColle
 // NameInfo -> the information extracted from the name
 type nameMatcher func(name string) (bool, assets.NameInfo)
 
+type namedMatcher struct {
+	name string
+	m    nameMatcher
+}
+
+// matchers returns the user defined matchers followed by the built-in ones
+func (ic InfoCollector) matchers() []namedMatcher {
+	ms := make([]namedMatcher, 0, len(ic.Patterns)+5)
+	for i, p := range ic.Patterns {
+		ms = append(ms, namedMatcher{name: fmt.Sprintf("pattern #%d %s", i+1, p), m: p.matcher(ic)})
+	}
+	return append(ms,
+		namedMatcher{name: "Pixel", m: ic.Pixel},
+		namedMatcher{name: "Samsung", m: ic.Samsung},
+		namedMatcher{name: "Nexus", m: ic.Nexus},
+		namedMatcher{name: "Huawei", m: ic.Huawei},
+		namedMatcher{name: "Sony Xperia", m: ic.SonyXperia},
+	)
+}
+
 // GetInfo analyze the name and return the information extracted from the name
 func (ic InfoCollector) GetInfo(name string) assets.NameInfo {
+	_, info := ic.Resolve(name)
+	return info
+}
+
+// Resolve analyze the name and return the name of the matcher that recognized it,
+// and the information extracted from the name
+func (ic InfoCollector) Resolve(name string) (string, assets.NameInfo) {
 	base := path.Base(name)
-	for _, m := range []nameMatcher{ic.Pixel, ic.Samsung, ic.Nexus, ic.Huawei, ic.SonyXperia} {
-		if ok, i := m(base); ok {
-			return i
+	for _, m := range ic.matchers() {
+		if ok, i := m.m(base); ok {
+			return m.name, i
 		}
 	}
 
@@ -40,7 +70,7 @@ func (ic InfoCollector) GetInfo(name string) assets.NameInfo {
 	t := TakeTimeFromPath(name, ic.TZ)
 	ext := path.Ext(base)
 
-	return assets.NameInfo{
+	return "generic", assets.NameInfo{
 		Base:    base,
 		Radical: strings.TrimSuffix(base, ext),
 		Ext:     strings.ToLower(ext),
@@ -0,0 +1,160 @@
+package filenames
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+)
+
+/*
+User defined file name patterns
+
+The patterns are regular expressions applied to the base name of the files.
+They use named groups to extract the information from the name:
+
+	year, month, day, hour, minute, second: date of capture
+	radical: part of the name shared by the files of a series
+	index: position of the file in the series
+	kind: burst, portrait, night, motion, long_exposure, edited
+	cover: when not empty, the file is the cover of the series
+
+Examples:
+
+	Scan_(?P<year>\d{4})-(?P<month>\d{2})_(?P<radical>Album\d+)_(?P<index>\d+)
+	^(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_PHOTO_(?P<index>\d{4})(?P<cover>_F)?
+*/
+
+var patternGroups = map[string]bool{
+	"year": true, "month": true, "day": true,
+	"hour": true, "minute": true, "second": true,
+	"radical": true, "index": true, "kind": true, "cover": true,
+}
+
+// NamePattern is a user defined file name pattern
+type NamePattern struct {
+	re *regexp.Regexp
+}
+
+// CompileNamePattern checks and compiles a file name pattern
+func CompileNamePattern(expr string) (*NamePattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("name pattern %q: %w", expr, err)
+	}
+	hasGroup := false
+	for _, g := range re.SubexpNames() {
+		if g == "" {
+			continue
+		}
+		if !patternGroups[g] {
+			return nil, fmt.Errorf("name pattern %q: unknown group %q", expr, g)
+		}
+		hasGroup = true
+	}
+	if !hasGroup {
+		return nil, fmt.Errorf("name pattern %q: the pattern has no named group", expr)
+	}
+	return &NamePattern{re: re}, nil
+}
+
+// ParseNamePatterns reads the list of patterns given by the configuration file
+func ParseNamePatterns(v any) ([]*NamePattern, error) {
+	var exprs []string
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		exprs = []string{v}
+	case []string:
+		exprs = v
+	case []any:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("name pattern: unexpected value %v", item)
+			}
+			exprs = append(exprs, s)
+		}
+	default:
+		return nil, fmt.Errorf("name patterns: unexpected value %v", v)
+	}
+
+	patterns := make([]*NamePattern, 0, len(exprs))
+	for _, expr := range exprs {
+		p, err := CompileNamePattern(expr)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func (p *NamePattern) String() string {
+	return p.re.String()
+}
+
+// matcher returns the nameMatcher of the pattern
+func (p *NamePattern) matcher(ic InfoCollector) nameMatcher {
+	return func(name string) (bool, assets.NameInfo) {
+		parts := p.re.FindStringSubmatch(name)
+		if len(parts) == 0 {
+			return false, assets.NameInfo{}
+		}
+		groups := map[string]string{}
+		for i, g := range p.re.SubexpNames() {
+			if g != "" && parts[i] != "" {
+				groups[g] = parts[i]
+			}
+		}
+
+		ext := path.Ext(name)
+		info := assets.NameInfo{
+			Base:    name,
+			Radical: strings.TrimSuffix(name, ext),
+			Ext:     strings.ToLower(ext),
+			Type:    ic.SM.TypeFromExt(ext),
+			IsCover: groups["cover"] != "",
+		}
+		if r, ok := groups["radical"]; ok {
+			info.Radical = r
+		}
+		if s, ok := groups["index"]; ok {
+			info.Index, _ = strconv.Atoi(s)
+		}
+		if s, ok := groups["kind"]; ok {
+			info.Kind = assets.ParseKind(s)
+		}
+		info.Taken = dateFromGroups(groups, ic.TZ)
+		return true, info
+	}
+}
+
+// dateFromGroups builds the date from the year, month, day, hour, minute, second groups.
+// The missing parts take their first value.
+func dateFromGroups(groups map[string]string, tz *time.Location) time.Time {
+	if _, ok := groups["year"]; !ok {
+		return time.Time{}
+	}
+	values := []int{0, 1, 1, 0, 0, 0}
+	for i, g := range []string{"year", "month", "day", "hour", "minute", "second"} {
+		if s, ok := groups[g]; ok {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return time.Time{}
+			}
+			values[i] = v
+		}
+	}
+	t := time.Date(values[0], time.Month(values[1]), values[2], values[3], values[4], values[5], 0, tz)
+	// Reject invalid dates like 2019-02-31
+	if t.Year() != values[0] || int(t.Month()) != values[1] || t.Day() != values[2] || t.Hour() != values[3] || t.Minute() != values[4] || t.Second() != values[5] {
+		return time.Time{}
+	}
+	return t
+}
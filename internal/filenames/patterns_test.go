@@ -0,0 +1,111 @@
+package filenames
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/filetypes"
+)
+
+func TestNamePatterns(t *testing.T) {
+	patterns, err := ParseNamePatterns([]any{
+		`^Scan_(?P<year>\d{4})-(?P<month>\d{2})_(?P<radical>Album\d+)_(?P<index>\d+)`,
+		`^(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_PHOTO_(?P<index>\d{4})(?P<cover>_F)?`,
+		`^(?P<radical>DASH_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})(?P<hour>\d{2})(?P<minute>\d{2})(?P<second>\d{2}))_(?P<kind>[A-Z]+)`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic := InfoCollector{
+		TZ:       time.UTC,
+		SM:       filetypes.DefaultSupportedMedia,
+		Patterns: patterns,
+	}
+
+	tests := []struct {
+		filename string
+		matcher  string
+		info     assets.NameInfo
+	}{
+		{
+			filename: "Scan_1987-07_Album3_012.tif",
+			matcher:  "pattern #1 " + patterns[0].String(),
+			info: assets.NameInfo{
+				Base:    "Scan_1987-07_Album3_012.tif",
+				Radical: "Album3",
+				Ext:     ".tif",
+				Type:    filetypes.TypeImage,
+				Index:   12,
+				Taken:   time.Date(1987, 7, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			filename: "archive/20190501_PHOTO_0003_F.jpg",
+			matcher:  "pattern #2 " + patterns[1].String(),
+			info: assets.NameInfo{
+				Base:    "20190501_PHOTO_0003_F.jpg",
+				Radical: "20190501_PHOTO_0003_F",
+				Ext:     ".jpg",
+				Type:    filetypes.TypeImage,
+				Index:   3,
+				IsCover: true,
+				Taken:   time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			filename: "DASH_20240612183005_BURST.mp4",
+			matcher:  "pattern #3 " + patterns[2].String(),
+			info: assets.NameInfo{
+				Base:    "DASH_20240612183005_BURST.mp4",
+				Radical: "DASH_20240612183005",
+				Ext:     ".mp4",
+				Type:    filetypes.TypeVideo,
+				Kind:    assets.KindBurst,
+				Taken:   time.Date(2024, 6, 12, 18, 30, 5, 0, time.UTC),
+			},
+		},
+		{
+			filename: "PXL_20231026_210642603.dng",
+			matcher:  "Pixel",
+			info: assets.NameInfo{
+				Base:    "PXL_20231026_210642603.dng",
+				Radical: "PXL_20231026_210642603",
+				Ext:     ".dng",
+				Type:    filetypes.TypeImage,
+				Taken:   time.Date(2023, 10, 26, 21, 6, 42, 0, time.UTC),
+			},
+		},
+		{
+			filename: "Scan_1987-13_Album3_012.tif",
+			matcher:  "pattern #1 " + patterns[0].String(),
+			info: assets.NameInfo{
+				Base:    "Scan_1987-13_Album3_012.tif",
+				Radical: "Album3",
+				Ext:     ".tif",
+				Type:    filetypes.TypeImage,
+				Index:   12,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			matcher, info := ic.Resolve(tt.filename)
+			if matcher != tt.matcher {
+				t.Errorf("matcher = %q, want %q", matcher, tt.matcher)
+			}
+			if !reflect.DeepEqual(info, tt.info) {
+				t.Errorf("expected \n%+v,\n  got \n%+v", tt.info, info)
+			}
+		})
+	}
+}
+
+func TestCompileNamePattern(t *testing.T) {
+	for _, expr := range []string{`Scan_(\d+)`, `Scan_(?P<album>\d+)`, `Scan_(?P<year>\d+`} {
+		if _, err := CompileNamePattern(expr); err == nil {
+			t.Errorf("CompileNamePattern(%q) should fail", expr)
+		}
+	}
+}
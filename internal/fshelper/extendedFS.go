@@ -23,6 +23,11 @@ type FSCanRemove interface {
 	Remove(name string) error
 }
 
+// FSCanLocate is implemented by the file systems backed by a folder of the OS
+type FSCanLocate interface {
+	OSPath(name string) (string, bool)
+}
+
 type FSCanStat interface {
 	Stat(name string) (fs.FileInfo, error)
 }
@@ -83,6 +88,16 @@ func Remove(fsys fs.FS, name string) error {
 	return errors.New("remove not supported")
 }
 
+// OSPath returns the path of the file in the OS file system
+func OSPath(fsys fs.FS, name string) (string, error) {
+	if fsys, ok := fsys.(FSCanLocate); ok {
+		if p, ok := fsys.OSPath(name); ok {
+			return p, nil
+		}
+	}
+	return "", errors.New("not a folder of the file system")
+}
+
 func Stat(fsys fs.FS, name string) (fs.FileInfo, error) {
 	if fsys, ok := fsys.(FSCanStat); ok {
 		return fsys.Stat(name)
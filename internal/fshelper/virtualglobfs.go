@@ -195,6 +195,14 @@ func (gw VirtualGlobFS) Name() string {
 	return gw.rootFS.Name()
 }
 
+// OSPath returns the path of the file in the OS file system
+func (gw VirtualGlobFS) OSPath(name string) (string, bool) {
+	if fsys, ok := gw.rootFS.(FSCanLocate); ok {
+		return fsys.OSPath(name)
+	}
+	return "", false
+}
+
 // FixedPathAndMagic split the path with the fixed part and the variable part
 func FixedPathAndMagic(name string) (string, string) {
 	if !HasMagic(name) {
@@ -224,6 +232,7 @@ type NameFS interface {
 type FSWithName struct {
 	fs.FS
 	name string
+	root string
 }
 
 var _ NameFS = FSWithName{}
@@ -234,9 +243,15 @@ func NewFSWithName(root string) NameFS {
 	return &FSWithName{
 		name: filepath.Base(root),
 		FS:   os.DirFS(root),
+		root: root,
 	}
 }
 
 func (f FSWithName) Name() string {
 	return f.name
 }
+
+// OSPath returns the path of the file in the OS file system
+func (f FSWithName) OSPath(name string) (string, bool) {
+	return filepath.Join(f.root, filepath.FromSlash(name)), true
+}
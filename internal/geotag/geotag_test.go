@@ -0,0 +1,182 @@
+package geotag
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+)
+
+const gpxSample = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><name>walk</name><trkseg>
+    <trkpt lat="48.8500" lon="2.2900"><ele>35</ele><time>2024-05-01T10:00:00Z</time></trkpt>
+    <trkpt lat="48.8600" lon="2.3000"><ele>35</ele><time>2024-05-01T10:10:00Z</time></trkpt>
+    <trkpt lat="48.8700" lon="2.3100"><time>2024-05-01T12:00:00Z</time></trkpt>
+    <trkpt lat="1" lon="1"></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+const kmlSample = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+<Document>
+  <Placemark>
+    <TimeStamp><when>2024-05-01T09:00:00Z</when></TimeStamp>
+    <Point><coordinates>2.2800,48.8400,0</coordinates></Point>
+  </Placemark>
+  <Placemark>
+    <gx:Track>
+      <when>2024-05-01T10:00:00Z</when>
+      <when>2024-05-01T10:10:00Z</when>
+      <gx:coord>2.2900 48.8500 35</gx:coord>
+      <gx:coord>2.3000 48.8600 35</gx:coord>
+    </gx:Track>
+  </Placemark>
+</Document>
+</kml>`
+
+const recordsSample = `{"locations": [
+  {"latitudeE7": 488500000, "longitudeE7": 22900000, "timestamp": "2024-05-01T10:00:00.000Z"},
+  {"latitudeE7": 488600000, "longitudeE7": 23000000, "timestampMs": "1714558200000"}
+]}`
+
+const timelineSample = `{"semanticSegments": [
+  {"startTime": "2024-05-01T12:00:00.000+02:00", "timelinePath": [
+    {"point": "48.8500000°, 2.2900000°", "time": "2024-05-01T12:00:00.000+02:00"},
+    {"point": "48.8600000°, 2.3000000°", "time": "2024-05-01T12:10:00.000+02:00"}
+  ]}
+]}`
+
+func TestReaders(t *testing.T) {
+	tcs := []struct {
+		name  string
+		read  func(s string) ([]Point, error)
+		input string
+		want  int
+	}{
+		{name: "gpx", read: func(s string) ([]Point, error) { return ReadGPX(strings.NewReader(s)) }, input: gpxSample, want: 3},
+		{name: "kml", read: func(s string) ([]Point, error) { return ReadKML(strings.NewReader(s)) }, input: kmlSample, want: 3},
+		{name: "records.json", read: func(s string) ([]Point, error) { return ReadLocationHistory(strings.NewReader(s)) }, input: recordsSample, want: 2},
+		{name: "timeline.json", read: func(s string) ([]Point, error) { return ReadLocationHistory(strings.NewReader(s)) }, input: timelineSample, want: 2},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			points, err := tc.read(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(points) != tc.want {
+				t.Fatalf("got %d points, want %d", len(points), tc.want)
+			}
+			// All samples have the point 48.85,2.29 at 10:00 UTC
+			var track Track
+			track.Add(points...)
+			lat, lon, ok := track.Locate(time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC), 15*time.Minute)
+			if !ok {
+				t.Fatal("position not found")
+			}
+			if math.Abs(lat-48.855) > 1e-6 || math.Abs(lon-2.295) > 1e-6 {
+				t.Errorf("position = %v,%v, want 48.855,2.295", lat, lon)
+			}
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	points, err := ReadGPX(strings.NewReader(gpxSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var track Track
+	track.Add(points...)
+
+	tcs := []struct {
+		name    string
+		at      time.Time
+		wantLat float64
+		wantOK  bool
+	}{
+		{name: "exact point", at: time.Date(2024, 5, 1, 10, 10, 0, 0, time.UTC), wantLat: 48.86, wantOK: true},
+		{name: "interpolated", at: time.Date(2024, 5, 1, 10, 2, 30, 0, time.UTC), wantLat: 48.8525, wantOK: true},
+		{name: "local time", at: time.Date(2024, 5, 1, 12, 2, 30, 0, time.FixedZone("CEST", 2*3600)), wantLat: 48.8525, wantOK: true},
+		{name: "gap, near the previous point", at: time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC), wantLat: 48.86, wantOK: true},
+		{name: "gap, near the next point", at: time.Date(2024, 5, 1, 11, 55, 0, 0, time.UTC), wantLat: 48.87, wantOK: true},
+		{name: "gap, far from the points", at: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), wantOK: false},
+		{name: "before the track", at: time.Date(2024, 5, 1, 9, 55, 0, 0, time.UTC), wantLat: 48.85, wantOK: true},
+		{name: "long before the track", at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), wantOK: false},
+		{name: "after the track", at: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), wantOK: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			lat, _, ok := track.Locate(tc.at, 10*time.Minute)
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantOK)
+			}
+			if ok && math.Abs(lat-tc.wantLat) > 1e-6 {
+				t.Errorf("latitude = %v, want %v", lat, tc.wantLat)
+			}
+		})
+	}
+}
+
+type fixedFinder struct {
+	loc *time.Location
+}
+
+func (f fixedFinder) Location(latitude, longitude float64) *time.Location {
+	return f.loc
+}
+
+func TestTagAsset(t *testing.T) {
+	points, err := ReadGPX(strings.NewReader(gpxSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Geotagger{maxGap: 10 * time.Minute, clockOffset: time.Hour}
+	g.track.Add(points...)
+
+	// The camera is one hour ahead
+	a := &assets.Asset{
+		CaptureDate:     time.Date(2024, 5, 1, 11, 5, 0, 0, time.UTC),
+		FromApplication: &assets.Metadata{},
+	}
+	if !g.TagAsset(a) {
+		t.Fatal("the asset is not tagged")
+	}
+	if !a.GeoTagged || math.Abs(a.Latitude-48.855) > 1e-6 || math.Abs(a.FromApplication.Longitude-2.295) > 1e-6 {
+		t.Errorf("unexpected position %v,%v", a.Latitude, a.Longitude)
+	}
+
+	// The date without time offset is localized at the position
+	paris := time.FixedZone("CEST", 2*3600)
+	assets.SetTimeZoneFinder(fixedFinder{loc: paris})
+	defer assets.SetTimeZoneFinder(nil)
+	a = &assets.Asset{
+		CaptureDate:     time.Date(2024, 5, 1, 11, 5, 0, 0, time.UTC),
+		FromApplication: &assets.Metadata{DateTaken: time.Date(2024, 5, 1, 11, 5, 0, 0, time.UTC), NaiveDate: true},
+	}
+	if !g.TagAsset(a) {
+		t.Fatal("the asset is not tagged")
+	}
+	if want := time.Date(2024, 5, 1, 11, 5, 0, 0, paris); !a.CaptureDate.Equal(want) || !a.FromApplication.DateTaken.Equal(want) {
+		t.Errorf("capture date = %v, want %v", a.CaptureDate, want)
+	}
+
+	// Assets with a position are left untouched
+	a = &assets.Asset{
+		CaptureDate:     time.Date(2024, 5, 1, 11, 5, 0, 0, time.UTC),
+		Latitude:        1,
+		Longitude:       1,
+		FromApplication: &assets.Metadata{},
+	}
+	if g.TagAsset(a) {
+		t.Error("an asset with a position must not be tagged")
+	}
+
+	var noTagger *Geotagger
+	if noTagger.TagAsset(a) {
+		t.Error("a nil Geotagger must not tag assets")
+	}
+}
@@ -0,0 +1,98 @@
+package geotag
+
+import (
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/exif"
+	"github.com/spf13/pflag"
+)
+
+// Options for the geotagging of assets
+type Options struct {
+	TrackFiles  []string      // GPX, KML or Google Location History JSON files
+	MaxGap      time.Duration // Maximum time between two points of the track to interpolate a position
+	ClockOffset time.Duration // Camera clock minus the actual time
+}
+
+func (o *Options) RegisterFlags(flags *pflag.FlagSet, prefix string) {
+	flags.StringSliceVar(&o.TrackFiles, prefix+"geotag-gpx", nil, "Give a position to assets without GPS data using a track log (GPX, KML or Google Location History JSON). Can be specified multiple times")
+	flags.DurationVar(&o.MaxGap, prefix+"geotag-max-gap", 10*time.Minute, "Maximum time between the capture and the track points to locate an asset")
+	flags.DurationVar(&o.ClockOffset, prefix+"geotag-clock-offset", 0, "Offset of the camera clock (camera time minus actual time, ex: 1m30s, -2h)")
+}
+
+// Geotagger locates assets on the tracks
+type Geotagger struct {
+	track       Track
+	maxGap      time.Duration
+	clockOffset time.Duration
+	tz          *time.Location
+}
+
+// New returns a Geotagger loaded with the track files, or nil when no track file is given
+func New(o Options, tz *time.Location) (*Geotagger, error) {
+	if len(o.TrackFiles) == 0 {
+		return nil, nil
+	}
+	g := &Geotagger{
+		maxGap:      o.MaxGap,
+		clockOffset: o.ClockOffset,
+		tz:          tz,
+	}
+	for _, name := range o.TrackFiles {
+		points, err := ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		g.track.Add(points...)
+	}
+	return g, nil
+}
+
+// Points returns the number of loaded points
+func (g *Geotagger) Points() int {
+	return g.track.Len()
+}
+
+// TagAsset gives a position to an asset without GPS data.
+// It returns true when the asset has been located.
+func (g *Geotagger) TagAsset(a *assets.Asset) bool {
+	if g == nil || a.Latitude != 0 || a.Longitude != 0 {
+		return false
+	}
+	captureDate := a.CaptureDate
+	if a.FromSourceFile == nil && a.FromApplication == nil {
+		// The embedded metadata haven't been read by the adapter
+		if f, err := a.OpenFile(); err == nil {
+			md, err := exif.GetMetaData(f, a.Ext, g.tz)
+			f.Close()
+			if err == nil && md != nil {
+				if md.Latitude != 0 || md.Longitude != 0 {
+					return false
+				}
+				if captureDate.IsZero() {
+					captureDate = md.DateTaken
+				}
+			}
+		}
+	}
+	if captureDate.IsZero() {
+		return false
+	}
+	lat, lon, ok := g.track.Locate(captureDate.Add(-g.clockOffset), g.maxGap)
+	if !ok {
+		return false
+	}
+	a.Latitude, a.Longitude = lat, lon
+	a.GeoTagged = true
+	if a.FromApplication != nil {
+		// The application metadata are applied again on the server
+		a.FromApplication.Latitude, a.FromApplication.Longitude = lat, lon
+		if a.FromApplication.NaiveDate {
+			// The date without time offset is expressed in the time zone of the position
+			a.FromApplication.DateTaken = assets.LocalizeDate(a.FromApplication.DateTaken, lat, lon)
+			a.CaptureDate = a.FromApplication.DateTaken
+		}
+	}
+	return true
+}
@@ -0,0 +1,208 @@
+package geotag
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadFile reads the points of a GPX, KML or Google Location History JSON file
+func ReadFile(name string) ([]Point, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []Point
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gpx":
+		points, err = ReadGPX(f)
+	case ".kml":
+		points, err = ReadKML(f)
+	case ".json":
+		points, err = ReadLocationHistory(f)
+	default:
+		return nil, fmt.Errorf("unsupported track log format: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read the track log %s: %w", name, err)
+	}
+	return points, nil
+}
+
+// ReadGPX reads the track, route and way points of a GPX file
+func ReadGPX(r io.Reader) ([]Point, error) {
+	type gpxPoint struct {
+		Lat  float64 `xml:"lat,attr"`
+		Lon  float64 `xml:"lon,attr"`
+		Time string  `xml:"time"`
+	}
+
+	var points []Point
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "trkpt", "rtept", "wpt":
+			var p gpxPoint
+			if err = d.DecodeElement(&p, &se); err != nil {
+				return nil, err
+			}
+			if p.Time == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(p.Time))
+			if err != nil {
+				continue
+			}
+			points = append(points, Point{Time: t, Latitude: p.Lat, Longitude: p.Lon})
+		}
+	}
+}
+
+// ReadKML reads the time stamped placemarks and the gx:Track elements of a KML file
+func ReadKML(r io.Reader) ([]Point, error) {
+	var points []Point
+	var whens, coords []string
+	var text strings.Builder
+
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			text.Reset()
+			switch t.Name.Local {
+			case "Placemark", "Track":
+				whens, coords = nil, nil
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "when":
+				whens = append(whens, strings.TrimSpace(text.String()))
+			case "coord", "coordinates":
+				coords = append(coords, strings.TrimSpace(text.String()))
+			case "Placemark", "Track":
+				for i := 0; i < len(whens) && i < len(coords); i++ {
+					if p, ok := kmlPoint(whens[i], coords[i]); ok {
+						points = append(points, p)
+					}
+				}
+				whens, coords = nil, nil
+			}
+		}
+	}
+}
+
+// kmlPoint decodes a point given as "lon,lat[,alt]" (Point) or "lon lat [alt]" (gx:coord)
+func kmlPoint(when, coord string) (Point, bool) {
+	t, err := time.Parse(time.RFC3339Nano, when)
+	if err != nil {
+		return Point{}, false
+	}
+	f := strings.FieldsFunc(coord, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' })
+	if len(f) < 2 {
+		return Point{}, false
+	}
+	lon, err1 := strconv.ParseFloat(f[0], 64)
+	lat, err2 := strconv.ParseFloat(f[1], 64)
+	if err1 != nil || err2 != nil {
+		return Point{}, false
+	}
+	return Point{Time: t, Latitude: lat, Longitude: lon}, true
+}
+
+// ReadLocationHistory reads a Google Location History export.
+// Both the Takeout Records.json and the on-device Timeline.json formats are supported.
+func ReadLocationHistory(r io.Reader) ([]Point, error) {
+	var lh struct {
+		Locations []struct {
+			LatitudeE7  int64  `json:"latitudeE7"`
+			LongitudeE7 int64  `json:"longitudeE7"`
+			Timestamp   string `json:"timestamp"`
+			TimestampMs string `json:"timestampMs"`
+		} `json:"locations"`
+		SemanticSegments []struct {
+			TimelinePath []struct {
+				Point string `json:"point"`
+				Time  string `json:"time"`
+			} `json:"timelinePath"`
+		} `json:"semanticSegments"`
+	}
+	if err := json.NewDecoder(r).Decode(&lh); err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(lh.Locations))
+	for _, l := range lh.Locations {
+		var t time.Time
+		switch {
+		case l.Timestamp != "":
+			var err error
+			t, err = time.Parse(time.RFC3339Nano, l.Timestamp)
+			if err != nil {
+				continue
+			}
+		case l.TimestampMs != "":
+			ms, err := strconv.ParseInt(l.TimestampMs, 10, 64)
+			if err != nil {
+				continue
+			}
+			t = time.UnixMilli(ms)
+		default:
+			continue
+		}
+		points = append(points, Point{
+			Time:      t,
+			Latitude:  float64(l.LatitudeE7) / 1e7,
+			Longitude: float64(l.LongitudeE7) / 1e7,
+		})
+	}
+
+	for _, s := range lh.SemanticSegments {
+		for _, p := range s.TimelinePath {
+			t, err := time.Parse(time.RFC3339Nano, p.Time)
+			if err != nil {
+				continue
+			}
+			// "48.8583736°, 2.2919010°"
+			f := strings.Split(strings.ReplaceAll(p.Point, "°", ""), ",")
+			if len(f) != 2 {
+				continue
+			}
+			lat, err1 := strconv.ParseFloat(strings.TrimSpace(f[0]), 64)
+			lon, err2 := strconv.ParseFloat(strings.TrimSpace(f[1]), 64)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			points = append(points, Point{Time: t, Latitude: lat, Longitude: lon})
+		}
+	}
+	return points, nil
+}
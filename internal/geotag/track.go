@@ -0,0 +1,75 @@
+// Package geotag gives a position to assets from track logs recorded by another device.
+package geotag
+
+import (
+	"sort"
+	"time"
+)
+
+// Point is a position recorded at a given time
+type Point struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+// Track is a list of points sorted by time
+type Track struct {
+	points []Point
+}
+
+// Add adds points to the track
+func (t *Track) Add(points ...Point) {
+	t.points = append(t.points, points...)
+	sort.SliceStable(t.points, func(i, j int) bool { return t.points[i].Time.Before(t.points[j].Time) })
+}
+
+// Len returns the number of points of the track
+func (t *Track) Len() int {
+	return len(t.points)
+}
+
+// Locate returns the position at the given time.
+//
+// The position is interpolated between the recorded points surrounding the time when they are
+// less than maxGap apart. Otherwise, the nearest point is used when it is recorded less than maxGap
+// before or after the given time.
+func (t *Track) Locate(at time.Time, maxGap time.Duration) (float64, float64, bool) {
+	if len(t.points) == 0 {
+		return 0, 0, false
+	}
+	// index of the first point after the time
+	i := sort.Search(len(t.points), func(i int) bool { return t.points[i].Time.After(at) })
+
+	var prev, next *Point
+	if i > 0 {
+		prev = &t.points[i-1]
+	}
+	if i < len(t.points) {
+		next = &t.points[i]
+	}
+
+	switch {
+	case prev != nil && prev.Time.Equal(at):
+		return prev.Latitude, prev.Longitude, true
+	case prev != nil && next != nil && next.Time.Sub(prev.Time) <= maxGap:
+		r := float64(at.Sub(prev.Time)) / float64(next.Time.Sub(prev.Time))
+		return prev.Latitude + (next.Latitude-prev.Latitude)*r,
+			prev.Longitude + (next.Longitude-prev.Longitude)*r,
+			true
+	}
+
+	// Use the nearest point
+	var nearest *Point
+	var d time.Duration
+	if prev != nil {
+		nearest, d = prev, at.Sub(prev.Time)
+	}
+	if next != nil && (nearest == nil || next.Time.Sub(at) < d) {
+		nearest, d = next, next.Time.Sub(at)
+	}
+	if d <= maxGap {
+		return nearest.Latitude, nearest.Longitude, true
+	}
+	return 0, 0, false
+}
@@ -2,15 +2,34 @@ package burst
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/simulot/immich-go/internal/assets"
 	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/phash"
 	"golang.org/x/exp/constraints"
 )
 
 const frameInterval = 500 * time.Millisecond
 
+// HashFn computes the perceptual hash of an image
+type HashFn func(ctx context.Context, a *assets.Asset) (phash.Hash, error)
+
+// FileHash computes the perceptual hash of the asset's file, the HEIC and raw files can't be hashed
+func FileHash(ctx context.Context, a *assets.Asset) (phash.Hash, error) {
+	if !phash.Decodable(strings.ToLower(a.Ext)) {
+		return 0, fmt.Errorf("can't decode %s files", a.Ext)
+	}
+	f, err := a.OpenFile()
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return phash.Read(f)
+}
+
 // Group groups photos taken within a period of less than 1 second with a digital camera.
 // This addresses photos taken with a digital camera when there isn't any burst indication in the file namee
 //
@@ -59,6 +78,90 @@ func Group(ctx context.Context, in <-chan *assets.Asset, out chan<- *assets.Asse
 	}
 }
 
+// Detector groups the images with less than Window between two frames.
+//
+// Ex: DSC_0101.JPG, DSC_0102.JPG, DSC_0103.JPG taken within a fraction of second
+//
+// With SameCamera, the frames must be taken by the same camera, the unknown camera matches the unknown camera.
+// When Hash is given, a frame joins the group only when its perceptual hash is within MaxDistance
+// of the previous frame's one. The frames that can't be hashed start a new group.
+//
+// Movies, edited images, images identified as burst already and images without a date taken are not considered.
+// The in channel receives assets sorted by date taken.
+type Detector struct {
+	Window      time.Duration
+	SameCamera  bool
+	MaxDistance int
+	Hash        HashFn
+}
+
+func (d Detector) Group(ctx context.Context, in <-chan *assets.Asset, out chan<- *assets.Asset, gOut chan<- *assets.Group) {
+	var currentGroup []*assets.Asset
+	var last *assets.Asset
+
+	// the hash of the last frame, computed only when the next frame is in the window
+	var lastHash phash.Hash
+	var lastErr error
+	lastHashed := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a, ok := <-in:
+			if !ok {
+				sendBurstGroup(ctx, out, gOut, currentGroup)
+				return
+			}
+
+			// exclude movies, edited or burst images
+			// exclude images without a date taken
+			ni := a.NameInfo
+			if ni.Type != filetypes.TypeImage ||
+				a.CaptureDate.IsZero() ||
+				ni.Kind == assets.KindBurst ||
+				ni.Kind == assets.KindEdited {
+				sendBurstGroup(ctx, out, gOut, currentGroup)
+				currentGroup, last = nil, nil
+				select {
+				case out <- a:
+				case <-ctx.Done():
+				}
+				continue
+			}
+
+			// exclude images taken more than Window apart, or by another camera
+			join := last != nil &&
+				abs(a.CaptureDate.Sub(last.CaptureDate)) <= d.Window &&
+				(!d.SameCamera || sameCamera(last, a))
+			hashed := false
+			var h phash.Hash
+			var err error
+			if join && d.Hash != nil {
+				if !lastHashed {
+					lastHash, lastErr = d.Hash(ctx, last)
+				}
+				h, err = d.Hash(ctx, a)
+				hashed = true
+				join = lastErr == nil && err == nil && phash.Distance(lastHash, h) <= d.MaxDistance
+			}
+
+			if !join {
+				sendBurstGroup(ctx, out, gOut, currentGroup)
+				currentGroup = nil
+			}
+			currentGroup = append(currentGroup, a)
+			last = a
+			lastHash, lastErr, lastHashed = h, err, hashed
+		}
+	}
+}
+
+// sameCamera tells if the images are taken by the same camera
+func sameCamera(a, b *assets.Asset) bool {
+	return a.CameraMake == b.CameraMake && a.CameraModel == b.CameraModel
+}
+
 // abs returns the absolute value of a given integer.
 func abs[T constraints.Integer](x T) T {
 	if x < 0 {
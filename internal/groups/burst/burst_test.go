@@ -2,7 +2,9 @@ package burst
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/simulot/immich-go/internal/filenames"
 	"github.com/simulot/immich-go/internal/filetypes"
 	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/simulot/immich-go/internal/phash"
 )
 
 func mockAsset(ic *filenames.InfoCollector, name string, dateTaken time.Time) *assets.Asset {
@@ -139,3 +142,115 @@ func TestGroup(t *testing.T) {
 		}
 	}
 }
+
+func cameraAsset(ic *filenames.InfoCollector, name string, camera string, dateTaken time.Time) *assets.Asset {
+	a := mockAsset(ic, name, dateTaken)
+	a.CameraModel = camera
+	return a
+}
+
+// run returns the groups and the single assets as lists of names
+func run(d Detector, list []*assets.Asset) ([]string, []string) {
+	ctx := context.Background()
+	in := make(chan *assets.Asset, len(list))
+	out := make(chan *assets.Asset)
+	gOut := make(chan *assets.Group)
+	go func() {
+		d.Group(ctx, in, out, gOut)
+		close(out)
+		close(gOut)
+	}()
+	for _, a := range list {
+		in <- a
+	}
+	close(in)
+
+	var gotGroups, gotAssets []string
+	for out != nil || gOut != nil {
+		select {
+		case g, ok := <-gOut:
+			if !ok {
+				gOut = nil
+				continue
+			}
+			names := []string{}
+			for _, a := range g.Assets {
+				names = append(names, a.File.Name())
+			}
+			gotGroups = append(gotGroups, strings.Join(names, ","))
+		case a, ok := <-out:
+			if !ok {
+				out = nil
+				continue
+			}
+			gotAssets = append(gotAssets, a.File.Name())
+		}
+	}
+	return gotGroups, gotAssets
+}
+
+func TestDetector(t *testing.T) {
+	ic := filenames.NewInfoCollector(time.Local, filetypes.DefaultSupportedMedia)
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)
+	ms := func(n int) time.Time { return base.Add(time.Duration(n) * time.Millisecond) }
+
+	list := []*assets.Asset{
+		cameraAsset(ic, "DSC_0101.jpg", "D750", ms(0)),
+		cameraAsset(ic, "DSC_0102.jpg", "D750", ms(150)),
+		cameraAsset(ic, "DSC_0103.jpg", "D750", ms(300)),
+		cameraAsset(ic, "IMG_0001.jpg", "iPhone", ms(350)), // other camera
+		cameraAsset(ic, "DSC_0104.jpg", "D750", ms(1000)),  // out of the window
+		cameraAsset(ic, "DSC_0105.mp4", "D750", ms(1100)),  // movie
+		cameraAsset(ic, "DSC_0106.jpg", "D750", ms(1200)),
+		cameraAsset(ic, "DSC_0107.jpg", "D750", ms(1400)),
+	}
+
+	t.Run("window", func(t *testing.T) {
+		gotGroups, gotAssets := run(Detector{Window: 200 * time.Millisecond, SameCamera: true}, list)
+		wantGroups := []string{"DSC_0101.jpg,DSC_0102.jpg,DSC_0103.jpg", "DSC_0106.jpg,DSC_0107.jpg"}
+		wantAssets := []string{"IMG_0001.jpg", "DSC_0104.jpg", "DSC_0105.mp4"}
+		if !reflect.DeepEqual(gotGroups, wantGroups) {
+			t.Errorf("groups: got %v, want %v", gotGroups, wantGroups)
+		}
+		if !reflect.DeepEqual(gotAssets, wantAssets) {
+			t.Errorf("assets: got %v, want %v", gotAssets, wantAssets)
+		}
+	})
+
+	t.Run("similarity", func(t *testing.T) {
+		hashes := map[string]phash.Hash{
+			"DSC_0101.jpg": 0x0000,
+			"DSC_0102.jpg": 0x0003, // distance 2
+			"DSC_0103.jpg": 0xff03, // distance 8, another subject
+			"DSC_0106.jpg": 0x0000,
+		}
+		hashed := map[string]int{}
+		d := Detector{
+			Window:      200 * time.Millisecond,
+			SameCamera:  true,
+			MaxDistance: 4,
+			Hash: func(ctx context.Context, a *assets.Asset) (phash.Hash, error) {
+				hashed[a.File.Name()]++
+				h, ok := hashes[a.File.Name()]
+				if !ok {
+					return 0, errors.New("can't hash")
+				}
+				return h, nil
+			},
+		}
+		gotGroups, gotAssets := run(d, list)
+		wantGroups := []string{"DSC_0101.jpg,DSC_0102.jpg"}
+		wantAssets := []string{"DSC_0103.jpg", "IMG_0001.jpg", "DSC_0104.jpg", "DSC_0105.mp4", "DSC_0106.jpg", "DSC_0107.jpg"}
+		if !reflect.DeepEqual(gotGroups, wantGroups) {
+			t.Errorf("groups: got %v, want %v", gotGroups, wantGroups)
+		}
+		if !reflect.DeepEqual(gotAssets, wantAssets) {
+			t.Errorf("assets: got %v, want %v", gotAssets, wantAssets)
+		}
+		// the lone frames are not hashed, the others once
+		wantHashed := map[string]int{"DSC_0101.jpg": 1, "DSC_0102.jpg": 1, "DSC_0103.jpg": 1, "DSC_0106.jpg": 1, "DSC_0107.jpg": 1}
+		if !reflect.DeepEqual(hashed, wantHashed) {
+			t.Errorf("hashed: got %v, want %v", hashed, wantHashed)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// Package journal records the changes made on the server by an upload session, to undo them later.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind of change
+type Kind string
+
+const (
+	KindUpload  Kind = "upload"  // an asset has been uploaded
+	KindReplace Kind = "replace" // an asset has replaced a smaller server's asset, deleted for good
+	KindAlbum   Kind = "album"   // assets have been added to an album
+	KindTag     Kind = "tag"     // assets have been tagged
+	KindStack   Kind = "stack"   // a stack has been created
+)
+
+// Entry is a change made on the server
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Kind     Kind      `json:"kind"`
+	ID       string    `json:"id"`                 // ID of the asset, the album, the tag or the stack
+	Name     string    `json:"name,omitempty"`     // file of the asset, title of the album or value of the tag
+	Replaced string    `json:"replaced,omitempty"` // ID of the server's asset deleted by a replacement
+	Created  bool      `json:"created,omitempty"`  // the album or the tag has been created by the session
+	Assets   []string  `json:"assets,omitempty"`   // the assets added to the album, tagged or stacked
+}
+
+// Journal writes the entries of a session. The entries are appended to the file
+// as soon as they are recorded, an interrupted session leaves a usable journal.
+// A nil journal records nothing.
+type Journal struct {
+	lock sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+}
+
+// Path returns the file of the session's journal in the user's cache folder
+func Path(session string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "immich-go", "sessions", FileName(session)), nil
+}
+
+// SessionPrefix starts the session tags
+const SessionPrefix = "{immich-go}/"
+
+// FileName returns the name of the session's journal file
+func FileName(session string) string {
+	session = strings.TrimPrefix(session, SessionPrefix)
+	session = strings.NewReplacer(":", "-", " ", "_", "/", "-", "\\", "-").Replace(session)
+	return session + ".jsonl"
+}
+
+// Open opens the journal file, the entries are appended to an existing journal
+func Open(name string) (*Journal, error) {
+	err := os.MkdirAll(filepath.Dir(name), 0o700)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record writes the entry to the journal
+func (j *Journal) Record(e Entry) error {
+	if j == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.enc.Encode(e)
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.f.Close()
+}
+
+// Read returns the entries of a journal file
+func Read(name string) ([]Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for s.Scan() {
+		line++
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(b, &e); err != nil {
+			// the last line of an interrupted session can be truncated
+			return entries, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
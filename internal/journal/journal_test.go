@@ -0,0 +1,54 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sessions", FileName("{immich-go}/2024-05-06 07:08:09"))
+	if filepath.Base(name) != "2024-05-06_07-08-09.jsonl" {
+		t.Errorf("unexpected file name %s", filepath.Base(name))
+	}
+
+	j, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Record(Entry{Kind: KindUpload, ID: "a1", Name: "IMG_1.JPG"})
+	_ = j.Record(Entry{Kind: KindAlbum, ID: "al1", Name: "Holidays", Created: true, Assets: []string{"a1"}})
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the entries are appended to the journal of an interrupted session
+	j, err = Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Record(Entry{Kind: KindReplace, ID: "a2", Replaced: "old"})
+	_ = j.Close()
+
+	var nilJournal *Journal
+	if err := nilJournal.Record(Entry{Kind: KindUpload}); err != nil {
+		t.Error("a nil journal records nothing")
+	}
+
+	entries, err := Read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].Name != "Holidays" || !entries[1].Created || entries[2].Replaced != "old" || entries[0].Time.IsZero() {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	// a truncated line
+	f, _ := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = f.WriteString(`{"kind": "upl`)
+	f.Close()
+	entries, err = Read(name)
+	if err == nil || len(entries) != 3 {
+		t.Errorf("the complete entries are returned with the error: %d, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package motionphoto splits the Samsung and Google motion photos: JPEG images followed by a short MP4 video.
+package motionphoto
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/fshelper"
+	"github.com/simulot/immich-go/internal/fshelper/osfs"
+)
+
+const xmpSearchRange = 256 * 1024 // the XMP packet is at the beginning of the JPEG
+
+var (
+	// Google Camera XMP: the video length, counted from the end of the file
+	reMicroVideoOffset = regexp.MustCompile(`GCamera:MicroVideoOffset(?:="|>)(\d+)`)
+
+	// Container directory XMP: the item with the MotionPhoto semantic gives the video length
+	reItemLength = regexp.MustCompile(`Item:Length(?:="|>)(\d+)`)
+
+	// flags and lengths of the XMP, reset in the still image
+	reMotionFlags = regexp.MustCompile(`(GCamera:(?:MicroVideo|MotionPhoto)(?:="|>))1`)
+
+	// the XMP items announcing a video, searched in the head of the file before reading it all
+	xmpVideoMarkers = [][]byte{[]byte("GCamera:MicroVideo"), []byte("GCamera:MotionPhoto"), []byte("Item:Semantic=\"MotionPhoto\"")}
+
+	samsungMarker  = []byte("MotionPhoto_Data")
+	samsungTrailer = []byte("SEFH")
+	motionSemantic = []byte(`Item:Semantic="MotionPhoto"`)
+)
+
+// VideoOffset returns the position of the MP4 video in the motion photo.
+// The XMP items GCamera:MicroVideoOffset and Container:Directory are used first, then the Samsung MotionPhoto_Data marker.
+// The position is checked with the MP4 ftyp box.
+func VideoOffset(b []byte) (int, bool) {
+	xmp := b[:min(len(b), xmpSearchRange)]
+	var offsets []int
+
+	if i := bytes.Index(xmp, motionSemantic); i >= 0 {
+		// the attributes of the item element
+		start := bytes.LastIndexByte(xmp[:i], '<')
+		end := bytes.IndexByte(xmp[i:], '>')
+		if start >= 0 && end >= 0 {
+			if m := reItemLength.FindSubmatch(xmp[start : i+end]); m != nil {
+				if l, err := strconv.Atoi(string(m[1])); err == nil {
+					offsets = append(offsets, len(b)-l)
+				}
+			}
+		}
+	}
+	if m := reMicroVideoOffset.FindSubmatch(xmp); m != nil {
+		if l, err := strconv.Atoi(string(m[1])); err == nil {
+			offsets = append(offsets, len(b)-l)
+		}
+	}
+	// the marker is repeated in the Samsung trailer, after the video
+	for i, s := 0, b; ; {
+		j := bytes.Index(s, samsungMarker)
+		if j < 0 {
+			break
+		}
+		i += j + len(samsungMarker)
+		s = b[i:]
+		offsets = append(offsets, i)
+	}
+
+	for _, o := range offsets {
+		if o > 0 && o+8 <= len(b) && string(b[o+4:o+8]) == "ftyp" {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// Split returns the still image and the video of the motion photo.
+// The still image's XMP doesn't announce a video anymore, the Samsung trailer is removed from the video.
+func Split(b []byte) (still []byte, video []byte, ok bool) {
+	o, ok := VideoOffset(b)
+	if !ok {
+		return nil, nil, false
+	}
+	video = b[o:]
+	if i := bytes.LastIndex(video, samsungTrailer); i > 0 && bytes.HasSuffix(video, []byte("SEFT")) {
+		video = video[:i]
+	}
+
+	still = bytes.Clone(bytes.TrimSuffix(b[:o], samsungMarker))
+	// the values keep their length, the JPEG segments are unchanged
+	head := still[:min(len(still), xmpSearchRange)]
+	head = reMotionFlags.ReplaceAll(head, []byte("${1}0"))
+	for _, re := range []*regexp.Regexp{reMicroVideoOffset, reItemLength} {
+		for _, m := range re.FindAllSubmatchIndex(head, -1) {
+			for i := m[2]; i < m[3]; i++ {
+				head[i] = '0'
+			}
+		}
+	}
+	copy(still, head)
+	return still, video, true
+}
+
+// mayBeMotionPhoto reads the head and the end of the file: the XMP announces the video, or the file ends with the Samsung trailer.
+// The file is positioned at its start.
+func mayBeMotionPhoto(f osfs.OSFS) (bool, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	head := make([]byte, min(size, xmpSearchRange))
+	if _, err := f.ReadAt(head, 0); err != nil && err != io.EOF {
+		return false, err
+	}
+	for _, m := range xmpVideoMarkers {
+		if bytes.Contains(head, m) {
+			return true, nil
+		}
+	}
+	if size < 4 {
+		return false, nil
+	}
+	tail := make([]byte, 4)
+	if _, err := f.ReadAt(tail, size-4); err != nil && err != io.EOF {
+		return false, err
+	}
+	return string(tail) == "SEFT", nil
+}
+
+// SplitAsset reads the JPEG file of the asset when its head or its end shows a video, and returns the still image and the video asset of a motion photo.
+// The video asset has no file, its content is set. The video is nil when the asset isn't a motion photo.
+func SplitAsset(a *assets.Asset) ([]byte, *assets.Asset, error) {
+	ext := strings.ToLower(path.Ext(a.File.Name()))
+	if a.Type != filetypes.TypeImage || (ext != ".jpg" && ext != ".jpeg") {
+		return nil, nil, nil
+	}
+	f, err := a.OpenFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	if maybe, err := mayBeMotionPhoto(f); err != nil || !maybe {
+		return nil, nil, err
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	still, video, ok := Split(b)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	name := strings.TrimSuffix(a.File.Name(), path.Ext(a.File.Name())) + ".mp4"
+	v := &assets.Asset{
+		File:             fshelper.FSName(a.File.FS(), name),
+		FileDate:         a.FileDate,
+		OriginalFileName: strings.TrimSuffix(a.OriginalFileName, path.Ext(a.OriginalFileName)) + ".mp4",
+		CaptureDate:      a.CaptureDate,
+		Archived:         a.Archived,
+		Visibility:       a.Visibility,
+		CameraMake:       a.CameraMake,
+		CameraModel:      a.CameraModel,
+		NameInfo:         a.NameInfo,
+		Latitude:         a.Latitude,
+		Longitude:        a.Longitude,
+		MotionPhoto:      a,
+	}
+	v.Base = strings.TrimSuffix(a.Base, path.Ext(a.Base)) + ".mp4"
+	v.Ext = ".mp4"
+	v.Type = filetypes.TypeVideo
+	if err := v.SetContent(video); err != nil {
+		return nil, nil, err
+	}
+	return still, v, nil
+}
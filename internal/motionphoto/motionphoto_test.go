@@ -0,0 +1,122 @@
+package motionphoto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/filetypes"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+var mp4 = []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom\x00\x00\x00\x08mdat")
+
+// jpeg returns a JPEG image with the XMP packet, the video length is written in place of %d
+func jpeg(xmp string, videoLength int) []byte {
+	if bytes.Contains([]byte(xmp), []byte("%d")) {
+		xmp = fmt.Sprintf(xmp, videoLength)
+	}
+	b := []byte("\xff\xd8\xff\xe1\x00\x00http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>")
+	b = append(b, xmp...)
+	b = append(b, "</x:xmpmeta>\xff\xda image data \xff\xd9"...)
+	return b
+}
+
+func TestSplit(t *testing.T) {
+	image := jpeg("", 0)
+	google := jpeg(`<rdf:Description GCamera:MicroVideo="1" GCamera:MicroVideoVersion="1" GCamera:MicroVideoOffset="%d"/>`, len(mp4))
+	container := jpeg(`<rdf:Description GCamera:MotionPhoto="1"><Container:Directory><rdf:Seq>`+
+		`<rdf:li rdf:parseType="Resource"><Container:Item Item:Mime="image/jpeg" Item:Semantic="Primary" Item:Length="0" Item:Padding="0"/></rdf:li>`+
+		`<rdf:li rdf:parseType="Resource"><Container:Item Item:Mime="video/mp4" Item:Semantic="MotionPhoto" Item:Length="%d" Item:Padding="0"/></rdf:li>`+
+		`</rdf:Seq></Container:Directory></rdf:Description>`, len(mp4))
+	samsung := jpeg("", 0)
+	length := []byte(fmt.Sprintf(`="%d"`, len(mp4)))
+
+	tests := []struct {
+		name      string
+		file      []byte
+		wantStill []byte
+		wantVideo []byte
+		wantOK    bool
+	}{
+		{name: "google", file: append(bytes.Clone(google), mp4...), wantStill: bytes.ReplaceAll(bytes.Replace(google, []byte(`MicroVideo="1"`), []byte(`MicroVideo="0"`), 1), length, []byte(`="00"`)), wantVideo: mp4, wantOK: true},
+		{name: "container", file: append(bytes.Clone(container), mp4...), wantStill: bytes.ReplaceAll(bytes.Replace(container, []byte(`MotionPhoto="1"`), []byte(`MotionPhoto="0"`), 1), length, []byte(`="00"`)), wantVideo: mp4, wantOK: true},
+		{name: "samsung", file: slicesConcat(samsung, []byte("MotionPhoto_Data"), mp4, []byte("SEFH\x01\x00\x00\x00MotionPhoto_Data\x10\x00\x00\x00SEFT")), wantStill: samsung, wantVideo: mp4, wantOK: true},
+		{name: "wrong offset", file: append(jpeg(`<rdf:Description GCamera:MicroVideoOffset="12"/>`, 0), mp4...)},
+		{name: "image", file: image},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			still, video, ok := Split(tt.file)
+			if ok != tt.wantOK {
+				t.Fatalf("Split() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !bytes.Equal(still, tt.wantStill) {
+				t.Errorf("Split() still = %q, want %q", still, tt.wantStill)
+			}
+			if !bytes.Equal(video, tt.wantVideo) {
+				t.Errorf("Split() video = %q, want %q", video, tt.wantVideo)
+			}
+		})
+	}
+}
+
+func slicesConcat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestSplitAsset(t *testing.T) {
+	file := append(jpeg(`<rdf:Description GCamera:MicroVideoOffset="%d"/>`, len(mp4)), mp4...)
+	fsys := fstest.MapFS{
+		"photos/PXL_20230330_184138390.MP.jpg": &fstest.MapFile{Data: file},
+		"photos/IMG_0001.jpg":                  &fstest.MapFile{Data: jpeg("", 0)},
+		"photos/20230330_184138.jpg":           &fstest.MapFile{Data: slicesConcat(jpeg("", 0), []byte("MotionPhoto_Data"), mp4, []byte("SEFH\x01\x00\x00\x00MotionPhoto_Data\x10\x00\x00\x00SEFT"))},
+	}
+
+	a := &assets.Asset{
+		File:             fshelper.FSName(fsys, "photos/PXL_20230330_184138390.MP.jpg"),
+		OriginalFileName: "PXL_20230330_184138390.MP.jpg",
+		NameInfo:         assets.NameInfo{Type: filetypes.TypeImage, Base: "PXL_20230330_184138390.MP.jpg", Ext: ".jpg"},
+	}
+	still, v, err := SplitAsset(a)
+	if err != nil || v == nil {
+		t.Fatalf("SplitAsset() = %v, %v", v, err)
+	}
+	if len(still) != len(file)-len(mp4) {
+		t.Errorf("still image size = %d, want %d", len(still), len(file)-len(mp4))
+	}
+	if v.File.Name() != "photos/PXL_20230330_184138390.MP.mp4" || v.OriginalFileName != "PXL_20230330_184138390.MP.mp4" || v.Type != filetypes.TypeVideo || v.MotionPhoto != a {
+		t.Errorf("unexpected video asset %+v", v)
+	}
+	f, err := v.OpenFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(f)
+	f.Close()
+	v.Close()
+	if !bytes.Equal(b, mp4) || v.FileSize != len(mp4) || v.Checksum == "" {
+		t.Errorf("video content = %q, size %d", b, v.FileSize)
+	}
+
+	// the Samsung motion photo is told by its trailer
+	a = &assets.Asset{
+		File:     fshelper.FSName(fsys, "photos/20230330_184138.jpg"),
+		NameInfo: assets.NameInfo{Type: filetypes.TypeImage},
+	}
+	if _, v, err := SplitAsset(a); err != nil || v == nil || v.FileSize != len(mp4) {
+		t.Errorf("SplitAsset() of a Samsung motion photo = %v, %v", v, err)
+	}
+	a.Close()
+
+	a = &assets.Asset{
+		File:     fshelper.FSName(fsys, "photos/IMG_0001.jpg"),
+		NameInfo: assets.NameInfo{Type: filetypes.TypeImage},
+	}
+	if _, v, err := SplitAsset(a); err != nil || v != nil {
+		t.Errorf("SplitAsset() of an image = %v, %v", v, err)
+	}
+}
@@ -50,6 +50,11 @@ func (l List) Match(name string) bool {
 	return false
 }
 
+// Len returns the number of patterns of the list
+func (l List) Len() int {
+	return len(l.entries)
+}
+
 func (l List) MatchFile(name string) bool {
 	for _, entry := range l.entries {
 		if entry.dirOnly {
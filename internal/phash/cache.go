@@ -0,0 +1,99 @@
+package phash
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache keeps the hashes of the server's assets by checksum, to avoid downloading their thumbnails again.
+// A nil cache keeps nothing.
+type Cache struct {
+	lock   sync.Mutex
+	name   string
+	hashes map[string]string // checksum to hash
+	dirty  bool
+}
+
+// CachePath returns the cache file in the user's cache folder
+func CachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "immich-go", "phash.json"), nil
+}
+
+// OpenCache reads the cache file, a missing file gives an empty cache
+func OpenCache(name string) (*Cache, error) {
+	c := &Cache{name: name, hashes: map[string]string{}}
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.hashes); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the hash of the checksum
+func (c *Cache) Get(checksum string) (Hash, bool) {
+	if c == nil || checksum == "" {
+		return 0, false
+	}
+	c.lock.Lock()
+	s, ok := c.hashes[checksum]
+	c.lock.Unlock()
+	if !ok {
+		return 0, false
+	}
+	h, err := Parse(s)
+	return h, err == nil
+}
+
+// Put keeps the hash of the checksum
+func (c *Cache) Put(checksum string, h Hash) {
+	if c == nil || checksum == "" {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.hashes[checksum] = h.String()
+	c.dirty = true
+}
+
+// Save writes the cache file when it has new hashes
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(c.name), 0o700)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(c.hashes)
+	if err != nil {
+		return err
+	}
+	tmp := c.name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.name); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,160 @@
+package phash
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// Match is an image found near a hash
+type Match struct {
+	ID       string
+	Hash     Hash
+	Distance int
+}
+
+// Index finds the images near a hash. It's a BK-tree: the children of a node are indexed by
+// their distance to the node, the triangle inequality prunes the branches too far from the searched hash.
+// The index is safe for concurrent use.
+type Index struct {
+	lock sync.RWMutex
+	root *node
+	size int
+}
+
+type node struct {
+	hash     Hash
+	ids      []string
+	children map[int]*node
+}
+
+// Add adds an image to the index
+func (ix *Index) Add(id string, h Hash) {
+	ix.lock.Lock()
+	defer ix.lock.Unlock()
+	ix.size++
+	if ix.root == nil {
+		ix.root = &node{hash: h, ids: []string{id}}
+		return
+	}
+	n := ix.root
+	for {
+		d := Distance(n.hash, h)
+		if d == 0 {
+			n.ids = append(n.ids, id)
+			return
+		}
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = map[int]*node{}
+			}
+			n.children[d] = &node{hash: h, ids: []string{id}}
+			return
+		}
+		n = child
+	}
+}
+
+// Len returns the number of images in the index
+func (ix *Index) Len() int {
+	ix.lock.RLock()
+	defer ix.lock.RUnlock()
+	return ix.size
+}
+
+// Near returns the images at a distance less or equal to maxDistance, the nearest first
+func (ix *Index) Near(h Hash, maxDistance int) []Match {
+	ix.lock.RLock()
+	defer ix.lock.RUnlock()
+	var matches []Match
+	if ix.root == nil {
+		return nil
+	}
+	stack := []*node{ix.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := Distance(n.hash, h)
+		if d <= maxDistance {
+			for _, id := range n.ids {
+				matches = append(matches, Match{ID: id, Hash: n.hash, Distance: d})
+			}
+		}
+		for cd, child := range n.children {
+			if cd >= d-maxDistance && cd <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		if a.Distance != b.Distance {
+			return a.Distance - b.Distance
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return matches
+}
+
+// Clusters groups the images of the index having near-duplicates. Two images are in the same cluster
+// when a chain of images at a distance less or equal to maxDistance links them.
+// The images of a cluster are sorted by ID, the clusters by their first image.
+func (ix *Index) Clusters(maxDistance int) [][]Match {
+	ix.lock.RLock()
+	var all []Match
+	stack := []*node{}
+	if ix.root != nil {
+		stack = append(stack, ix.root)
+	}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, id := range n.ids {
+			all = append(all, Match{ID: id, Hash: n.hash})
+		}
+		for _, child := range n.children {
+			stack = append(stack, child)
+		}
+	}
+	ix.lock.RUnlock()
+
+	// union-find of the images
+	parent := make(map[string]string, len(all))
+	var find func(id string) string
+	find = func(id string) string {
+		p := parent[id]
+		if p == id {
+			return id
+		}
+		r := find(p)
+		parent[id] = r
+		return r
+	}
+	for _, m := range all {
+		parent[m.ID] = m.ID
+	}
+	for _, m := range all {
+		for _, n := range ix.Near(m.Hash, maxDistance) {
+			a, b := find(m.ID), find(n.ID)
+			if a != b {
+				parent[b] = a
+			}
+		}
+	}
+
+	groups := map[string][]Match{}
+	for _, m := range all {
+		r := find(m.ID)
+		groups[r] = append(groups[r], m)
+	}
+	var clusters [][]Match
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		slices.SortFunc(g, func(a, b Match) int { return cmp.Compare(a.ID, b.ID) })
+		clusters = append(clusters, g)
+	}
+	slices.SortFunc(clusters, func(a, b []Match) int { return cmp.Compare(a[0].ID, b[0].ID) })
+	return clusters
+}
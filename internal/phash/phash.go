@@ -0,0 +1,137 @@
+// Package phash computes perceptual hashes of images to find the near-duplicates:
+// the same photo resized, recompressed or slightly edited.
+package phash
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"math/bits"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/rwcarlsen/goexif/exif"
+	_ "golang.org/x/image/webp" // the server's thumbnails
+)
+
+// Hash is a 64 bits difference hash (dHash)
+type Hash uint64
+
+// DHash computes the difference hash of the image: the image is reduced to 9x8 gray pixels,
+// each bit tells if a pixel is brighter than its right neighbour
+func DHash(img image.Image) Hash {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+	var h Hash
+	for y := range 8 {
+		for x := range 8 {
+			h <<= 1
+			if small.Pix[small.PixOffset(x, y)] > small.Pix[small.PixOffset(x+1, y)] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// Read decodes a JPEG, PNG, GIF, TIFF, BMP or WEBP image and computes its hash.
+// The EXIF orientation is applied, to compare with the server's thumbnails.
+func Read(r io.Reader) (Hash, error) {
+	img, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return 0, err
+	}
+	return DHash(img), nil
+}
+
+// ReadPreview computes the hash from the thumbnail of the image's EXIF, without decoding the whole image.
+// The image is decoded when it has no thumbnail with the same proportions.
+func ReadPreview(r io.ReadSeeker) (Hash, error) {
+	if img, ok := exifThumbnail(r); ok {
+		return DHash(img), nil
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return Read(r)
+}
+
+// exifThumbnail returns the EXIF thumbnail turned with the orientation of the image.
+// The thumbnails padded with black bars to another proportion than the image's one are not used.
+func exifThumbnail(r io.Reader) (image.Image, bool) {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return nil, false
+	}
+	start, okStart := tagInt(x, exif.ThumbJPEGInterchangeFormat)
+	length, okLength := tagInt(x, exif.ThumbJPEGInterchangeFormatLength)
+	if !okStart || !okLength || start <= 0 || length <= 0 || start+length > len(x.Raw) {
+		return nil, false
+	}
+	img, err := imaging.Decode(bytes.NewReader(x.Raw[start : start+length]))
+	if err != nil {
+		return nil, false
+	}
+	w, okW := tagInt(x, exif.PixelXDimension)
+	h, okH := tagInt(x, exif.PixelYDimension)
+	b := img.Bounds()
+	if !okW || !okH || w <= 0 || h <= 0 || b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, false
+	}
+	if math.Abs(float64(w)/float64(h)-float64(b.Dx())/float64(b.Dy())) > 0.02 {
+		return nil, false
+	}
+
+	o, _ := tagInt(x, exif.Orientation)
+	switch o {
+	case 2:
+		img = imaging.FlipH(img)
+	case 3:
+		img = imaging.Rotate180(img)
+	case 4:
+		img = imaging.FlipV(img)
+	case 5:
+		img = imaging.Transpose(img)
+	case 6:
+		img = imaging.Rotate270(img)
+	case 7:
+		img = imaging.Transverse(img)
+	case 8:
+		img = imaging.Rotate90(img)
+	}
+	return img, true
+}
+
+func tagInt(x *exif.Exif, name exif.FieldName) (int, bool) {
+	t, err := x.Get(name)
+	if err != nil {
+		return 0, false
+	}
+	v, err := t.Int(0)
+	return v, err == nil
+}
+
+// Distance is the number of different bits between two hashes, 0 for identical images
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Parse reads a hash written by String
+func Parse(s string) (Hash, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	return Hash(v), err
+}
+
+// Decodable tells if the extension is an image format decoded by Read
+func Decodable(ext string) bool {
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".tif", ".tiff", ".bmp", ".webp":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package phash
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// gradient draws a picture with a diagonal gradient and a bright square
+func gradient(w, h int, square image.Rectangle) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := uint8((x*255/w + y*255/h) / 2)
+			if image.Pt(x*100/w, y*100/h).In(square) {
+				v = 255 - v
+			}
+			img.Set(x, y, color.NRGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	original := gradient(800, 600, image.Rect(10, 10, 40, 40))
+
+	// the same photo resized and recompressed
+	var b bytes.Buffer
+	_ = jpeg.Encode(&b, imaging.Resize(original, 250, 0, imaging.Lanczos), &jpeg.Options{Quality: 40})
+	resized, err := Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := Distance(DHash(original), resized); d > 4 {
+		t.Errorf("the resized photo is at %d", d)
+	}
+
+	// another photo
+	other := DHash(gradient(800, 600, image.Rect(60, 50, 95, 95)))
+	if d := Distance(DHash(original), other); d < 10 {
+		t.Errorf("another photo is at %d", d)
+	}
+
+	h, err := Parse(other.String())
+	if err != nil || h != other {
+		t.Errorf("Parse(%s) = %s, %v", other, h, err)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	var ix Index
+	hashes := map[string]Hash{
+		"a": 0b0000,
+		"b": 0b0001,
+		"c": 0b0011,
+		"d": 0xFFFF0000,
+		"e": 0xFFFF0001,
+		"f": 0x0F0F0F0F0F0F0F0F,
+		"g": 0b0001,
+	}
+	for id, h := range hashes {
+		ix.Add(id, h)
+	}
+	if ix.Len() != len(hashes) {
+		t.Errorf("Len() = %d", ix.Len())
+	}
+
+	var ids []string
+	for _, m := range ix.Near(0, 1) {
+		ids = append(ids, m.ID)
+	}
+	if !slices.Equal(ids, []string{"a", "b", "g"}) {
+		t.Errorf("Near(0, 1) = %v", ids)
+	}
+
+	var clusters [][]string
+	for _, c := range ix.Clusters(1) {
+		var ids []string
+		for _, m := range c {
+			ids = append(ids, m.ID)
+		}
+		clusters = append(clusters, ids)
+	}
+	want := [][]string{{"a", "b", "c", "g"}, {"d", "e"}}
+	if !slices.EqualFunc(clusters, want, slices.Equal) {
+		t.Errorf("Clusters(1) = %v, want %v", clusters, want)
+	}
+}
+
+func TestIndexThumbnails(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "phash.json")
+	c, err := OpenCache(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("cached", 42)
+
+	var b bytes.Buffer
+	_ = jpeg.Encode(&b, gradient(250, 200, image.Rect(0, 0, 50, 50)), nil)
+	thumbnail := b.Bytes()
+	fetched := 0
+	fetch := func(ctx context.Context, id string) (io.ReadCloser, error) {
+		fetched++
+		if id == "missing" {
+			return nil, errors.New("not found")
+		}
+		return io.NopCloser(bytes.NewReader(thumbnail)), nil
+	}
+
+	var ix Index
+	failed, err := IndexThumbnails(context.Background(), &ix, c, []Thumbnail{{ID: "1", Checksum: "cached"}, {ID: "2", Checksum: "new"}, {ID: "missing"}}, 1, fetch)
+	if err != nil || failed != 1 || fetched != 2 || ix.Len() != 2 {
+		t.Errorf("IndexThumbnails() = %d, %v, fetched %d, indexed %d", failed, err, fetched, ix.Len())
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = OpenCache(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := c.Get("cached"); !ok || h != 42 {
+		t.Errorf("the cache has lost the hash: %v %v", h, ok)
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Error("the cache has not kept the new hash")
+	}
+}
+
+// withThumbnail inserts in the JPEG an EXIF segment with the image size, the orientation and the thumbnail
+func withThumbnail(img []byte, w, h, orientation int, thumbnail []byte) []byte {
+	le := binary.LittleEndian
+	entry := func(b []byte, tag, typ uint16, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, 1)
+		if typ == 3 {
+			return le.AppendUint32(b, value&0xffff)
+		}
+		return le.AppendUint32(b, value)
+	}
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00")
+	// IFD0 at 8, the EXIF IFD at 38, IFD1 at 68 and the thumbnail at 98
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = entry(tiff, 0x0112, 3, uint32(orientation))
+	tiff = entry(tiff, 0x8769, 4, 38)
+	tiff = le.AppendUint32(tiff, 68)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = entry(tiff, 0xa002, 4, uint32(w))
+	tiff = entry(tiff, 0xa003, 4, uint32(h))
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = entry(tiff, 0x0201, 4, 98)
+	tiff = entry(tiff, 0x0202, 4, uint32(len(thumbnail)))
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, thumbnail...)
+
+	b := append([]byte{}, img[:2]...)
+	b = append(b, 0xff, 0xe1)
+	b = binary.BigEndian.AppendUint16(b, uint16(2+6+len(tiff)))
+	b = append(b, "Exif\x00\x00"...)
+	b = append(b, tiff...)
+	return append(b, img[2:]...)
+}
+
+func TestReadPreview(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		var b bytes.Buffer
+		_ = jpeg.Encode(&b, img, &jpeg.Options{Quality: 90})
+		return b.Bytes()
+	}
+	original := gradient(800, 600, image.Rect(10, 10, 40, 40))
+	thumbnail := gradient(160, 120, image.Rect(60, 50, 95, 95))
+	img := encode(original)
+
+	tests := []struct {
+		name        string
+		thumbnail   image.Image
+		orientation int
+		expected    image.Image
+	}{
+		{"thumbnail", thumbnail, 1, thumbnail},
+		{"turned thumbnail", thumbnail, 6, imaging.Rotate270(thumbnail)},
+		{"padded thumbnail", gradient(160, 160, image.Rect(60, 50, 95, 95)), 1, original},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ReadPreview(bytes.NewReader(withThumbnail(img, 800, 600, tt.orientation, encode(tt.thumbnail))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := Distance(DHash(tt.expected), h); d > 4 {
+				t.Errorf("the hash is at %d of the expected image", d)
+			}
+		})
+	}
+
+	// no EXIF: the image is decoded
+	h, err := ReadPreview(bytes.NewReader(img))
+	if err != nil || Distance(DHash(original), h) > 4 {
+		t.Errorf("the image without thumbnail: %s, %v", h, err)
+	}
+}
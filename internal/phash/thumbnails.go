@@ -0,0 +1,53 @@
+package phash
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Thumbnail is a server's asset to hash with its thumbnail
+type Thumbnail struct {
+	ID       string
+	Checksum string // key of the cache
+}
+
+// FetchFn opens the thumbnail of an asset
+type FetchFn func(ctx context.Context, id string) (io.ReadCloser, error)
+
+// IndexThumbnails adds the hashes of the thumbnails to the index, the hashes of the cache are used
+// when available. The thumbnails that can't be read are counted in failed, they don't stop the indexing.
+func IndexThumbnails(ctx context.Context, ix *Index, c *Cache, thumbnails []Thumbnail, workers int, fetch FetchFn) (failed int, err error) {
+	var failures atomic.Int64
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(workers, 1))
+	for _, t := range thumbnails {
+		if h, ok := c.Get(t.Checksum); ok {
+			ix.Add(t.ID, h)
+			continue
+		}
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rc, err := fetch(ctx, t.ID)
+			if err != nil {
+				failures.Add(1)
+				return nil
+			}
+			h, err := Read(rc)
+			rc.Close()
+			if err != nil {
+				failures.Add(1)
+				return nil
+			}
+			c.Put(t.Checksum, h)
+			ix.Add(t.ID, h)
+			return nil
+		})
+	}
+	err = g.Wait()
+	return int(failures.Load()), err
+}
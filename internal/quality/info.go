@@ -0,0 +1,150 @@
+package quality
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/simulot/immich-go/internal/assets"
+)
+
+// Of returns the quality information of an asset.
+// With readHeader, the pixel dimensions and the bit depth are read from the file when they are unknown,
+// the dimensions are kept in the asset.
+func Of(a *assets.Asset, source string, readHeader bool) Info {
+	name := a.OriginalFileName
+	if name == "" {
+		name = a.File.Name()
+	}
+	format := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+	i := Info{
+		Width:  a.Width,
+		Height: a.Height,
+		Format: format,
+		Bits:   nominalBits[format],
+		Source: source,
+		Size:   int64(a.FileSize),
+	}
+	for _, known := range []bool{a.CameraMake != "", a.CameraModel != "", !a.CaptureDate.IsZero(), a.Latitude != 0 || a.Longitude != 0} {
+		if known {
+			i.ExifScore++
+		}
+	}
+
+	if readHeader && a.File.FS() != nil {
+		// the asset's cache is kept for the upload
+		if f, err := a.OpenFile(); err == nil {
+			w, h, bits, err := ReadHeader(f, format)
+			f.Close()
+			if err == nil {
+				if i.Width == 0 || i.Height == 0 {
+					i.Width, i.Height = w, h
+					a.Width, a.Height = w, h
+				}
+				if bits > 0 {
+					i.Bits = bits
+				}
+			}
+		}
+	}
+	return i
+}
+
+// headerSize is the part of the file searched for the image header
+const headerSize = 1024 * 1024
+
+var errNoHeader = errors.New("image header not found")
+
+// ReadHeader reads the pixel dimensions and the bit depth per channel of JPEG, PNG, HEIF and AVIF files
+func ReadHeader(r io.Reader, format string) (width, height, bits int, err error) {
+	b, err := io.ReadAll(io.LimitReader(r, headerSize))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	switch format {
+	case "jpg", "jpeg":
+		return jpegHeader(b)
+	case "png":
+		return pngHeader(b)
+	case "heic", "heif", "avif":
+		return heifHeader(b)
+	}
+	return 0, 0, 0, errNoHeader
+}
+
+// jpegHeader reads the start of frame segment, the segments before are skipped with their length
+func jpegHeader(b []byte) (int, int, int, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return 0, 0, 0, errNoHeader
+	}
+	i := 2
+	for i+4 <= len(b) {
+		if b[i] != 0xFF {
+			return 0, 0, 0, errNoHeader
+		}
+		marker := b[i+1]
+		if marker == 0xFF {
+			// padding
+			i++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(b[i+2:]))
+		isSOF := marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+		if isSOF {
+			if i+9 > len(b) {
+				break
+			}
+			bits := int(b[i+4])
+			height := int(binary.BigEndian.Uint16(b[i+5:]))
+			width := int(binary.BigEndian.Uint16(b[i+7:]))
+			return width, height, bits, nil
+		}
+		i += 2 + length
+	}
+	return 0, 0, 0, errNoHeader
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func pngHeader(b []byte) (int, int, int, error) {
+	if len(b) < 25 || !bytes.Equal(b[:8], pngSignature) || string(b[12:16]) != "IHDR" {
+		return 0, 0, 0, errNoHeader
+	}
+	width := int(binary.BigEndian.Uint32(b[16:]))
+	height := int(binary.BigEndian.Uint32(b[20:]))
+	return width, height, int(b[24]), nil
+}
+
+// heifHeader reads the image spatial extent (ispe) and the pixel information (pixi) properties.
+// The grid images have an ispe for the tiles and one for the image: the biggest is kept.
+func heifHeader(b []byte) (int, int, int, error) {
+	width, height, bits := 0, 0, 0
+	for i := 4; i+8 <= len(b); {
+		j := bytes.Index(b[i:], []byte("ispe"))
+		if j < 0 {
+			break
+		}
+		p := i + j + 4 + 4 // skip the type, the version and the flags
+		if p+8 <= len(b) {
+			w := int(binary.BigEndian.Uint32(b[p:]))
+			h := int(binary.BigEndian.Uint32(b[p+4:]))
+			if w*h > width*height {
+				width, height = w, h
+			}
+		}
+		i = i + j + 4
+	}
+	if j := bytes.Index(b, []byte("pixi")); j >= 0 && j+10 <= len(b) {
+		// version and flags, the number of channels, then the bits of each channel
+		if b[j+8] > 0 {
+			bits = int(b[j+9])
+		}
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, 0, errNoHeader
+	}
+	return width, height, bits, nil
+}
@@ -0,0 +1,235 @@
+// Package quality compares two versions of the same photo to keep the best one on the server.
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Info describes the quality of a version of a photo
+type Info struct {
+	Width     int    // pixel width, 0 when unknown
+	Height    int    // pixel height, 0 when unknown
+	Format    string // extension of the file, in lower case without the dot
+	Bits      int    // bit depth per channel, 0 when unknown
+	ExifScore int    // number of known metadata among make, model, date of capture and GPS position
+	Source    string // command having read the photo, or ServerSource
+	Size      int64  // file size in bytes
+}
+
+// ServerSource is the source of the assets already on the server
+const ServerSource = "server"
+
+// Criteria of a policy
+const (
+	Resolution = "resolution" // more pixels is better
+	Format     = "format"     // raw > tiff > heic, avif, jxl, png > jpeg > webp > gif
+	BitDepth   = "bitdepth"   // more bits per channel is better
+	Exif       = "exif"       // more metadata is better
+	Source     = "source"     // the first source of the priority list is better
+	Size       = "size"       // bigger is better
+)
+
+var criteria = []string{Resolution, Format, BitDepth, Exif, Source, Size}
+
+// presets are the named lists of criteria
+var presets = map[string][]string{
+	"size":    {Size},
+	"quality": {Resolution, Format, BitDepth, Exif, Size},
+}
+
+// DefaultSourcePriority ranks the sources from the best to the worst: the original files beat the server's assets,
+// that beat the Google Photos recompressed files
+var DefaultSourcePriority = []string{"from-folder", "from-icloud", "from-picasa", "from-manifest", ServerSource, "from-immich", "from-google-photos"}
+
+// Policy compares the versions with a list of criteria, the first criterion making a difference decides.
+// The zero policy compares the sizes.
+type Policy struct {
+	spec     string
+	criteria []string
+	priority []string
+}
+
+// Set parses a preset name or a comma separated list of criteria
+func (p *Policy) Set(s string) error {
+	var list []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if preset, ok := presets[c]; ok {
+			list = append(list, preset...)
+			continue
+		}
+		if !slices.Contains(criteria, c) {
+			return fmt.Errorf("unknown criterion %q, expected a preset (size, quality) or a list of %s", c, strings.Join(criteria, ", "))
+		}
+		list = append(list, c)
+	}
+	if len(list) == 0 {
+		return errors.New("the policy has no criterion")
+	}
+	p.spec = s
+	p.criteria = slices.Compact(list)
+	return nil
+}
+
+func (p *Policy) String() string {
+	if p.spec == "" {
+		return "size"
+	}
+	return p.spec
+}
+
+func (p *Policy) Type() string {
+	return "policy"
+}
+
+// SetSourcePriority sets the sources from the best to the worst
+func (p *Policy) SetSourcePriority(sources []string) {
+	p.priority = sources
+}
+
+// Has tells if the policy uses the criterion
+func (p *Policy) Has(criterion string) bool {
+	return slices.Contains(p.list(), criterion)
+}
+
+func (p *Policy) list() []string {
+	if len(p.criteria) == 0 {
+		return presets["size"]
+	}
+	return p.criteria
+}
+
+// Compare tells if the local version is better (> 0), worse (< 0) or equivalent (0) than the server's one,
+// with the reason of the decision
+func (p *Policy) Compare(local, server Info) (int, string) {
+	for _, c := range p.list() {
+		var r int
+		var reason string
+		switch c {
+		case Resolution:
+			lp, sp := local.Width*local.Height, server.Width*server.Height
+			if lp > 0 && sp > 0 {
+				r = compare(lp, sp)
+				reason = fmt.Sprintf("resolution %dx%d vs %dx%d", local.Width, local.Height, server.Width, server.Height)
+			}
+		case Format:
+			lr, lok := formatRank[local.Format]
+			sr, sok := formatRank[server.Format]
+			if lok && sok {
+				r = compare(lr, sr)
+				reason = fmt.Sprintf("format %s vs %s", local.Format, server.Format)
+			}
+		case BitDepth:
+			if local.Bits > 0 && server.Bits > 0 {
+				r = compare(local.Bits, server.Bits)
+				reason = fmt.Sprintf("bit depth %d vs %d", local.Bits, server.Bits)
+			}
+		case Exif:
+			r = compare(local.ExifScore, server.ExifScore)
+			reason = fmt.Sprintf("metadata %d/4 vs %d/4", local.ExifScore, server.ExifScore)
+		case Source:
+			lr, sr := p.sourceRank(local.Source), p.sourceRank(server.Source)
+			if lr >= 0 && sr >= 0 {
+				// the first source of the list is the best
+				r = compare(sr, lr)
+				reason = fmt.Sprintf("source %s vs %s", local.Source, server.Source)
+			}
+		case Size:
+			r = compare(local.Size, server.Size)
+			reason = fmt.Sprintf("size %d vs %d bytes", local.Size, server.Size)
+		}
+		if r != 0 {
+			return r, reason
+		}
+	}
+	return 0, "equivalent " + strings.Join(p.list(), ", ")
+}
+
+func (p *Policy) sourceRank(source string) int {
+	priority := p.priority
+	if priority == nil {
+		priority = DefaultSourcePriority
+	}
+	return slices.Index(priority, source)
+}
+
+func compare[T int | int64](a, b T) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
+
+// formatRank ranks the image formats, the videos are not ranked
+var formatRank = map[string]int{
+	"gif":  0,
+	"webp": 1,
+	"jpg":  2,
+	"jpeg": 2,
+	"png":  3,
+	"heic": 3,
+	"heif": 3,
+	"avif": 3,
+	"jxl":  3,
+	"tif":  4,
+	"tiff": 4,
+	"dng":  5,
+	"arw":  5,
+	"cr2":  5,
+	"cr3":  5,
+	"crw":  5,
+	"nef":  5,
+	"nrw":  5,
+	"orf":  5,
+	"raf":  5,
+	"rw2":  5,
+	"pef":  5,
+	"srw":  5,
+	"3fr":  5,
+	"erf":  5,
+	"iiq":  5,
+	"mrw":  5,
+	"x3f":  5,
+}
+
+// nominalBits is the usual bit depth of the formats, when the file header is not read
+var nominalBits = map[string]int{
+	"gif":  8,
+	"webp": 8,
+	"jpg":  8,
+	"jpeg": 8,
+	"png":  8,
+	"heic": 8,
+	"heif": 8,
+	"avif": 8,
+	"jxl":  8,
+	"tif":  8,
+	"tiff": 8,
+	"dng":  12,
+	"arw":  12,
+	"cr2":  12,
+	"cr3":  12,
+	"nef":  12,
+	"orf":  12,
+	"raf":  12,
+	"rw2":  12,
+}
+
+// Interchangeable tells if the format is used for the exports of a photo: a HEIC and a JPEG file with the same name
+// are two versions of the same photo. The raw files are not interchangeable, they are kept along their JPEG.
+func Interchangeable(format string) bool {
+	switch format {
+	case "jpg", "jpeg", "heic", "heif", "avif", "jxl", "webp", "png", "tif", "tiff":
+		return true
+	}
+	return false
+}
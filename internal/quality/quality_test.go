@@ -0,0 +1,164 @@
+package quality
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"testing/fstest"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+func TestPolicySet(t *testing.T) {
+	var p Policy
+	if !p.Has(Size) || p.Has(Resolution) || p.String() != "size" {
+		t.Errorf("the zero policy compares the sizes")
+	}
+	if err := p.Set("quality, source"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{Resolution, Format, BitDepth, Exif, Size, Source} {
+		if !p.Has(c) {
+			t.Errorf("the policy should have %s", c)
+		}
+	}
+	for _, bad := range []string{"", "pixels", "size,,colour"} {
+		if err := p.Set(bad); err == nil {
+			t.Errorf("Set(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	var q Policy
+	_ = q.Set("quality")
+
+	tcs := []struct {
+		name          string
+		policy        *Policy
+		local, server Info
+		want          int
+	}{
+		{
+			name:   "size policy prefers the bigger file",
+			policy: &Policy{},
+			local:  Info{Format: "png", Width: 1170, Height: 2532, Size: 4_000_000},
+			server: Info{Format: "jpg", Width: 4032, Height: 3024, Size: 2_000_000},
+			want:   1,
+		},
+		{
+			name:   "a bigger screenshot doesn't beat the original",
+			policy: &q,
+			local:  Info{Format: "png", Width: 1170, Height: 2532, Size: 4_000_000},
+			server: Info{Format: "jpg", Width: 4032, Height: 3024, Size: 2_000_000},
+			want:   -1,
+		},
+		{
+			name:   "the storage saver recompression is replaced",
+			policy: &q,
+			local:  Info{Format: "jpg", Width: 4032, Height: 3024, Size: 3_000_000},
+			server: Info{Format: "jpg", Width: 2048, Height: 1536, Size: 600_000},
+			want:   1,
+		},
+		{
+			name:   "the HEIC original beats the JPEG export of the same size",
+			policy: &q,
+			local:  Info{Format: "jpg", Width: 4032, Height: 3024, Bits: 8, Size: 3_500_000},
+			server: Info{Format: "heic", Width: 4032, Height: 3024, Bits: 10, Size: 1_800_000},
+			want:   -1,
+		},
+		{
+			name:   "more metadata",
+			policy: &q,
+			local:  Info{Format: "jpg", Bits: 8, ExifScore: 4},
+			server: Info{Format: "jpg", Bits: 8, ExifScore: 1},
+			want:   1,
+		},
+		{
+			name:   "equivalent",
+			policy: &q,
+			local:  Info{Format: "jpg", Width: 10, Height: 10, Size: 100},
+			server: Info{Format: "jpeg", Width: 10, Height: 10, Size: 100},
+			want:   0,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason := tc.policy.Compare(tc.local, tc.server)
+			if got != tc.want || reason == "" {
+				t.Errorf("Compare() = %d, %q, want %d", got, reason, tc.want)
+			}
+		})
+	}
+
+	var s Policy
+	_ = s.Set("source,size")
+	if r, _ := s.Compare(Info{Source: "from-folder", Size: 1}, Info{Source: ServerSource, Size: 2}); r != 1 {
+		t.Errorf("the folder beats the server")
+	}
+	if r, _ := s.Compare(Info{Source: "from-google-photos", Size: 3}, Info{Source: ServerSource, Size: 2}); r != -1 {
+		t.Errorf("the server beats the takeout")
+	}
+	s.SetSourcePriority([]string{"from-google-photos", ServerSource})
+	if r, _ := s.Compare(Info{Source: "from-google-photos", Size: 1}, Info{Source: ServerSource, Size: 2}); r != 1 {
+		t.Errorf("the takeout beats the server with the given priority")
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+
+	var b bytes.Buffer
+	_ = jpeg.Encode(&b, img, nil)
+	w, h, bits, err := ReadHeader(&b, "jpg")
+	if err != nil || w != 64 || h != 48 || bits != 8 {
+		t.Errorf("jpeg: %d %d %d %v", w, h, bits, err)
+	}
+
+	b.Reset()
+	_ = png.Encode(&b, img)
+	w, h, bits, err = ReadHeader(&b, "png")
+	if err != nil || w != 64 || h != 48 || bits != 8 {
+		t.Errorf("png: %d %d %d %v", w, h, bits, err)
+	}
+
+	// a grid image: the tile's ispe then the image's ispe, and a 10 bits pixi
+	heif := []byte("\x00\x00\x00\x18ftypheic")
+	for _, dim := range [][2]uint32{{512, 512}, {4032, 3024}} {
+		heif = append(heif, 0, 0, 0, 20)
+		heif = append(heif, "ispe\x00\x00\x00\x00"...)
+		heif = binary.BigEndian.AppendUint32(heif, dim[0])
+		heif = binary.BigEndian.AppendUint32(heif, dim[1])
+	}
+	heif = append(heif, "\x00\x00\x00\x10pixi\x00\x00\x00\x00\x03\x0a\x0a\x0a"...)
+	w, h, bits, err = ReadHeader(bytes.NewReader(heif), "heic")
+	if err != nil || w != 4032 || h != 3024 || bits != 10 {
+		t.Errorf("heic: %d %d %d %v", w, h, bits, err)
+	}
+
+	if _, _, _, err := ReadHeader(bytes.NewReader([]byte("not an image")), "jpg"); err == nil {
+		t.Error("a bad header should fail")
+	}
+}
+
+func TestOfReadsTheAssetContent(t *testing.T) {
+	var b bytes.Buffer
+	_ = png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 64, 48)))
+
+	// the content replaces the file, like the still image of a motion photo
+	fsys := fstest.MapFS{"PXL_0001.MP.png": &fstest.MapFile{Data: []byte("not an image")}}
+	a := &assets.Asset{File: fshelper.FSName(fsys, "PXL_0001.MP.png")}
+	if err := a.SetContent(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	i := Of(a, "from-folder", true)
+	if i.Width != 64 || i.Height != 48 || a.Width != 64 || a.Height != 48 {
+		t.Errorf("expected 64x48, got %dx%d", i.Width, i.Height)
+	}
+}
@@ -0,0 +1,442 @@
+// Package rules applies the rules of the configuration file to the assets before their upload.
+//
+// A rule is a table of conditions and actions. All conditions of a rule must be met for its actions to be applied:
+//
+//	name: holidays
+//	path-regex: '^(?P<year>\d{4})/(?P<event>[^/]+)/'
+//	make: Canon
+//	album: '${year} - ${event}'
+//	add-tag: holidays/${year}
+//	favorite: true
+//
+// Rules are applied in the order of the file. A rule with skip discards the asset,
+// a rule with stop ends the processing of the following rules.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/dates"
+	"github.com/simulot/immich-go/internal/namematcher"
+	"github.com/simulot/immich-go/internal/stringhelper"
+)
+
+// Rule describes the conditions to meet and the actions to apply to an asset
+type Rule struct {
+	Name string
+
+	// Conditions
+	Path   namematcher.List // patterns of the path, with the syntax of --ban-file
+	PathRE *regexp.Regexp   // regular expression of the path, its named groups are usable in the actions
+	Ext    []string         // extensions, with the dot, in lower case
+	Make   string           // part of the camera maker
+	Model  string           // part of the camera model
+	From   time.Time        // start of the capture period (inclusive)
+	To     time.Time        // end of the capture period (exclusive)
+	BBox   *BBox            // area of the capture
+	Kind   assets.Kind      // kind of the file given by its name
+	Source []string         // name of the source commands (from-folder, from-google-photos...)
+
+	// Actions
+	Albums      []string          // album names, with the groups of PathRE as $name or ${name}
+	AddTags     []string          // tags to add, with the groups of PathRE
+	RemoveTags  []string          // tags to remove
+	Visibility  assets.Visibility // archive, hidden, locked or timeline
+	Favorite    *bool
+	Rating      *int
+	Description *string // description, with the groups of PathRE
+	Skip        bool    // the asset is discarded
+	Stop        bool    // the next rules are ignored
+
+	hasKind bool
+}
+
+// BBox is a geographic area given by its south-west and north-east corners
+type BBox struct {
+	South, West, North, East float64
+}
+
+func (b BBox) contains(lat, lon float64) bool {
+	if lat < b.South || lat > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return lon >= b.West && lon <= b.East
+	}
+	// the box crosses the antimeridian
+	return lon >= b.West || lon <= b.East
+}
+
+// Rules is the ordered list of rules of the configuration file
+type Rules []*Rule
+
+// Result tells what the rules have done on an asset
+type Result struct {
+	Matched []string // names of the applied rules
+	Skip    bool     // the asset must be discarded
+	SkipBy  string   // name of the rule discarding the asset
+}
+
+// ParseConfig reads the rules section of the configuration file. It's a list of tables.
+func ParseConfig(v any) (Rules, error) {
+	var rs Rules
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		for i, item := range v {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("rule #%d: a table is expected, got %v", i+1, item)
+			}
+			r, err := parseTable(m)
+			if err != nil {
+				return nil, fmt.Errorf("rule #%d: %w", i+1, err)
+			}
+			if r.Name == "" {
+				r.Name = fmt.Sprintf("rule #%d", i+1)
+			}
+			rs = append(rs, r)
+		}
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i := range v {
+			items[i] = v[i]
+		}
+		return ParseConfig(items)
+	default:
+		return nil, fmt.Errorf("rules: a list of tables is expected, got %v", v)
+	}
+	return rs, nil
+}
+
+func parseTable(m map[string]any) (*Rule, error) {
+	r := &Rule{}
+
+	// sort the keys to get stable error messages
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := r.set(strings.ToLower(k), m[k]); err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return r, r.check()
+}
+
+func (r *Rule) set(key string, v any) error {
+	var err error
+	switch key {
+	case "name":
+		r.Name = fmt.Sprint(v)
+	case "path":
+		var ss []string
+		if ss, err = toStrings(v); err == nil {
+			r.Path, err = namematcher.New(ss...)
+		}
+	case "path-regex":
+		r.PathRE, err = regexp.Compile(fmt.Sprint(v))
+	case "ext":
+		var ss []string
+		ss, err = toStrings(v)
+		for _, s := range ss {
+			s = strings.ToLower(s)
+			if !strings.HasPrefix(s, ".") {
+				s = "." + s
+			}
+			r.Ext = append(r.Ext, s)
+		}
+	case "make":
+		r.Make = fmt.Sprint(v)
+	case "model":
+		r.Model = fmt.Sprint(v)
+	case "from":
+		r.From, _, err = toDate(v)
+	case "to":
+		var dateOnly bool
+		r.To, dateOnly, err = toDate(v)
+		if dateOnly {
+			// the last day is included
+			r.To = r.To.AddDate(0, 0, 1)
+		}
+	case "bbox":
+		r.BBox, err = toBBox(v)
+	case "kind":
+		r.Kind = assets.ParseKind(fmt.Sprint(v))
+		r.hasKind = true
+		if r.Kind == assets.KindNone && !strings.EqualFold(fmt.Sprint(v), "none") {
+			err = fmt.Errorf("unknown kind %q", v)
+		}
+	case "source":
+		r.Source, err = toStrings(v)
+	case "album", "albums":
+		r.Albums, err = toStrings(v)
+	case "add-tag", "add-tags":
+		r.AddTags, err = toStrings(v)
+	case "remove-tag", "remove-tags":
+		r.RemoveTags, err = toStrings(v)
+	case "visibility":
+		switch vis := assets.Visibility(strings.ToLower(fmt.Sprint(v))); vis {
+		case assets.VisibilityArchive, assets.VisibilityHidden, assets.VisibilityLocked, assets.VisibilityTimeline:
+			r.Visibility = vis
+		default:
+			err = fmt.Errorf("invalid visibility %q, expected archive, hidden, locked or timeline", v)
+		}
+	case "favorite":
+		var b bool
+		b, err = toBool(v)
+		r.Favorite = &b
+	case "rating":
+		var i int
+		i, err = strconv.Atoi(fmt.Sprint(v))
+		if err == nil && (i < 0 || i > 5) {
+			err = fmt.Errorf("the rating must be between 0 and 5")
+		}
+		r.Rating = &i
+	case "description":
+		s := fmt.Sprint(v)
+		r.Description = &s
+	case "skip":
+		r.Skip, err = toBool(v)
+	case "stop":
+		r.Stop, err = toBool(v)
+	default:
+		err = errors.New("unknown key")
+	}
+	return err
+}
+
+func (r *Rule) check() error {
+	if len(r.Albums) == 0 && len(r.AddTags) == 0 && len(r.RemoveTags) == 0 && r.Visibility == "" &&
+		r.Favorite == nil && r.Rating == nil && r.Description == nil && !r.Skip && !r.Stop {
+		return errors.New("the rule has no action")
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return errors.New("the period is empty")
+	}
+	return nil
+}
+
+func toStrings(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		ss := make([]string, len(v))
+		for i := range v {
+			ss[i] = fmt.Sprint(v[i])
+		}
+		return ss, nil
+	}
+	return nil, fmt.Errorf("unexpected value %v", v)
+}
+
+func toBool(v any) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return strconv.ParseBool(fmt.Sprint(v))
+}
+
+func toDate(v any) (time.Time, bool, error) {
+	if t, ok := v.(time.Time); ok {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		return t, t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0, nil
+	}
+	return dates.Parse(fmt.Sprint(v), time.UTC)
+}
+
+// toBBox reads the south, west, north and east limits of the area, as a list or a comma separated string
+func toBBox(v any) (*BBox, error) {
+	var ss []string
+	if s, ok := v.(string); ok {
+		ss = strings.Split(s, ",")
+	} else {
+		var err error
+		if ss, err = toStrings(v); err != nil {
+			return nil, err
+		}
+	}
+	if len(ss) != 4 {
+		return nil, fmt.Errorf("expected south,west,north,east, got %v", v)
+	}
+	var f [4]float64
+	for i, s := range ss {
+		var err error
+		f[i], err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q", s)
+		}
+	}
+	b := &BBox{South: f[0], West: f[1], North: f[2], East: f[3]}
+	if b.South > b.North {
+		return nil, errors.New("the south limit is above the north limit")
+	}
+	return b, nil
+}
+
+// Match tells if the asset read by the source command meets the conditions of the rule.
+// It returns the groups of the path regular expression.
+func (r *Rule) Match(a *assets.Asset, source string) (bool, []int) {
+	name := a.File.Name()
+	if len(r.Source) > 0 && !matchSource(r.Source, source) {
+		return false, nil
+	}
+	if len(r.Ext) > 0 {
+		ext := strings.ToLower(path.Ext(name))
+		found := false
+		for _, e := range r.Ext {
+			if e == ext {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if r.Path.Len() > 0 && !r.Path.Match(name) {
+		return false, nil
+	}
+	if r.hasKind && a.Kind != r.Kind {
+		return false, nil
+	}
+	if r.Make != "" && !stringhelper.ContainsFold(a.CameraMake, r.Make) {
+		return false, nil
+	}
+	if r.Model != "" && !stringhelper.ContainsFold(a.CameraModel, r.Model) {
+		return false, nil
+	}
+	if !r.From.IsZero() || !r.To.IsZero() {
+		d := a.CaptureDate
+		if d.IsZero() {
+			return false, nil
+		}
+		// Compare the wall clock of the capture
+		wall := time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), d.Nanosecond(), time.UTC)
+		if !r.From.IsZero() && wall.Before(r.From) {
+			return false, nil
+		}
+		if !r.To.IsZero() && !wall.Before(r.To) {
+			return false, nil
+		}
+	}
+	if r.BBox != nil {
+		if a.Latitude == 0 && a.Longitude == 0 {
+			return false, nil
+		}
+		if !r.BBox.contains(a.Latitude, a.Longitude) {
+			return false, nil
+		}
+	}
+	var groups []int
+	if r.PathRE != nil {
+		groups = r.PathRE.FindStringSubmatchIndex(name)
+		if groups == nil {
+			return false, nil
+		}
+	}
+	return true, groups
+}
+
+func matchSource(sources []string, source string) bool {
+	source = strings.TrimPrefix(strings.ToLower(source), "from-")
+	for _, s := range sources {
+		if strings.TrimPrefix(strings.ToLower(s), "from-") == source {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces the groups of the path regular expression in the template
+func (r *Rule) expand(template string, name string, groups []int) string {
+	if r.PathRE == nil || groups == nil {
+		return template
+	}
+	return string(r.PathRE.ExpandString(nil, template, name, groups))
+}
+
+// apply modifies the asset with the actions of the rule
+func (r *Rule) apply(a *assets.Asset, groups []int) {
+	name := a.File.Name()
+	for _, t := range r.Albums {
+		if title := strings.TrimSpace(r.expand(t, name, groups)); title != "" {
+			a.MergeAlbums([]assets.Album{{Title: title}})
+		}
+	}
+	for _, t := range r.AddTags {
+		if tag := strings.TrimSpace(r.expand(t, name, groups)); tag != "" {
+			a.AddTag(tag)
+		}
+	}
+	for _, t := range r.RemoveTags {
+		a.RemoveTag(t)
+	}
+
+	// The metadata given by the application are applied again at upload time
+	md := a.FromApplication
+	if r.Visibility != "" {
+		a.Visibility = r.Visibility
+		a.Archived = r.Visibility == assets.VisibilityArchive
+		if md != nil {
+			md.Archived = a.Archived
+		}
+	}
+	if r.Favorite != nil {
+		a.Favorite = *r.Favorite
+		if md != nil {
+			md.Favorited = a.Favorite
+		}
+	}
+	if r.Rating != nil {
+		a.Rating = *r.Rating
+		a.RuleRating = true
+		if md != nil {
+			md.Rating = byte(a.Rating)
+		}
+	}
+	if r.Description != nil {
+		a.Description = r.expand(*r.Description, name, groups)
+		a.RuleDescription = true
+		if md != nil {
+			md.Description = a.Description
+		}
+	}
+}
+
+// Apply applies the matching rules to the asset read by the source command
+func (rs Rules) Apply(a *assets.Asset, source string) Result {
+	var res Result
+	for _, r := range rs {
+		ok, groups := r.Match(a, source)
+		if !ok {
+			continue
+		}
+		res.Matched = append(res.Matched, r.Name)
+		if r.Skip {
+			res.Skip = true
+			res.SkipBy = r.Name
+			return res
+		}
+		r.apply(a, groups)
+		if r.Stop {
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simulot/immich-go/internal/assets"
+	"github.com/simulot/immich-go/internal/fshelper"
+)
+
+func TestParseConfig(t *testing.T) {
+	tcs := []struct {
+		name    string
+		rule    map[string]any
+		wantErr bool
+	}{
+		{name: "album", rule: map[string]any{"path-regex": `^(?P<year>\d{4})/`, "album": "Year ${year}"}},
+		{name: "toml date", rule: map[string]any{"from": time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC), "to": "2019-03-18", "favorite": true}},
+		{name: "bbox list", rule: map[string]any{"bbox": []any{43.1, 5.2, 44.0, 6.0}, "add-tag": []any{"provence", "holidays"}}},
+		{name: "no action", rule: map[string]any{"make": "Canon"}, wantErr: true},
+		{name: "unknown key", rule: map[string]any{"lens": "50mm", "skip": true}, wantErr: true},
+		{name: "bad visibility", rule: map[string]any{"visibility": "secret"}, wantErr: true},
+		{name: "bad rating", rule: map[string]any{"rating": 7}, wantErr: true},
+		{name: "bad kind", rule: map[string]any{"kind": "panorama", "skip": true}, wantErr: true},
+		{name: "bad bbox", rule: map[string]any{"bbox": "44,5,43,6", "skip": true}, wantErr: true},
+		{name: "empty period", rule: map[string]any{"from": "2020-05-01", "to": "2020-04-01", "skip": true}, wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseConfig([]any{tc.rule})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ParseConfig() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	rs, err := ParseConfig([]any{
+		map[string]any{"name": "screenshots", "path": "Screenshots/", "skip": true},
+		map[string]any{"name": "events", "path-regex": `^(?P<year>\d{4})/(?P<event>[^/]+)/`, "album": "${year} - ${event}", "add-tag": "events/${event}"},
+		map[string]any{"name": "canon", "make": "canon", "ext": []any{"CR2", ".jpg"}, "remove-tag": "events/Rome", "rating": 4},
+		map[string]any{"name": "provence", "bbox": "43,4,44.5,7", "visibility": "archive", "stop": true},
+		map[string]any{"name": "never", "source": "from-google-photos", "favorite": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newAsset := func(name string) *assets.Asset {
+		return &assets.Asset{File: fshelper.FSName(nil, name)}
+	}
+
+	a := newAsset("Pictures/Screenshots/shot.png")
+	res := rs.Apply(a, "from-folder")
+	if !res.Skip || res.SkipBy != "screenshots" {
+		t.Errorf("the screenshot should be skipped: %+v", res)
+	}
+
+	a = newAsset("2023/Rome/IMG_0001.JPG")
+	a.CameraMake = "Canon"
+	a.Latitude, a.Longitude = 43.5, 5.4
+	res = rs.Apply(a, "from-folder")
+	if res.Skip {
+		t.Fatal("the asset should not be skipped")
+	}
+	if len(res.Matched) != 3 {
+		t.Errorf("expected 3 matched rules, got %v", res.Matched)
+	}
+	if len(a.Albums) != 1 || a.Albums[0].Title != "2023 - Rome" {
+		t.Errorf("unexpected albums %+v", a.Albums)
+	}
+	if len(a.Tags) != 0 {
+		t.Errorf("the tag should be removed: %+v", a.Tags)
+	}
+	if a.Rating != 4 || !a.RuleRating {
+		t.Errorf("unexpected rating %d", a.Rating)
+	}
+	if a.Visibility != assets.VisibilityArchive || !a.Archived {
+		t.Errorf("unexpected visibility %q", a.Visibility)
+	}
+	if a.Favorite {
+		t.Error("the rule after stop should not be applied")
+	}
+
+	a = newAsset("misc/IMG_0002.JPG")
+	res = rs.Apply(a, "from-google-photos")
+	if !a.Favorite || len(res.Matched) != 1 {
+		t.Errorf("the source rule should be applied: %+v", res)
+	}
+}
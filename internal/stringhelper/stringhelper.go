@@ -0,0 +1,10 @@
+// Package stringhelper provides the string comparisons shared by the rules of the configuration file.
+package stringhelper
+
+import "strings"
+
+// ContainsFold tells if s contains substr, ignoring the case and the surrounding spaces.
+// It's used to match the camera makes and models.
+func ContainsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(strings.TrimSpace(s)), strings.ToLower(strings.TrimSpace(substr)))
+}
@@ -0,0 +1,41 @@
+// Package tzlookup finds the time zone of a GPS position without network access.
+//
+// The lookup uses github.com/bradfitz/latlong, which embeds the time zone boundaries into the binary
+// as a compact tile map of about 1 MB. Its boundaries date from 2017: the later changes of the borders
+// between time zones are not known. The offsets of each zone come from the Go time zone database.
+package tzlookup
+
+import (
+	"sync"
+	"time"
+	_ "time/tzdata" // Be independent of the system's zoneinfo database
+
+	"github.com/bradfitz/latlong"
+)
+
+// Finder gives the time zone of a position
+type Finder struct {
+	locations sync.Map // IANA name -> *time.Location
+}
+
+// New returns a Finder
+func New() *Finder {
+	return &Finder{}
+}
+
+// Location returns the time zone at the given position, or nil when it can't be determined
+func (f *Finder) Location(latitude, longitude float64) *time.Location {
+	name := latlong.LookupZoneName(latitude, longitude)
+	if name == "" {
+		return nil
+	}
+	if l, ok := f.locations.Load(name); ok {
+		return l.(*time.Location)
+	}
+	l, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	f.locations.Store(name, l)
+	return l
+}
@@ -0,0 +1,30 @@
+package tzlookup
+
+import (
+	"testing"
+)
+
+func TestLocation(t *testing.T) {
+	f := New()
+	tcs := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{name: "Paris", lat: 48.8583736, lon: 2.2919010, want: "Europe/Paris"},
+		{name: "Sydney", lat: -33.8567844, lon: 151.2152967, want: "Australia/Sydney"},
+		{name: "New York", lat: 40.6892, lon: -74.0445, want: "America/New_York"},
+		{name: "Tokyo", lat: 35.6586, lon: 139.7454, want: "Asia/Tokyo"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			l := f.Location(tc.lat, tc.lon)
+			if l == nil {
+				t.Fatal("no location found")
+			}
+			if l.String() != tc.want {
+				t.Errorf("Location = %s, want %s", l, tc.want)
+			}
+		})
+	}
+}